@@ -1,42 +1,612 @@
 package fees
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"time"
 )
 
 type Activities struct {
-	repo RepositoryInterface
+	repo     RepositoryInterface
+	notifier Notifier
+	biller   Biller
 }
 
-func NewActivities(repo RepositoryInterface) *Activities {
-	return &Activities{repo: repo}
+// ActivityOption configures optional Activities dependencies.
+type ActivityOption func(*Activities)
+
+// WithNotifier sets where dunning notifications go. Without one they are
+// only logged.
+func WithNotifier(notifier Notifier) ActivityOption {
+	return func(a *Activities) {
+		a.notifier = notifier
+	}
+}
+
+// WithBiller sets what subscriptions open, charge and close their bills
+// through. Without one, renewing a subscription fails.
+func WithBiller(biller Biller) ActivityOption {
+	return func(a *Activities) {
+		a.biller = biller
+	}
+}
+
+func NewActivities(repo RepositoryInterface, opts ...ActivityOption) *Activities {
+	a := &Activities{repo: repo, notifier: LogNotifier{}}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+type PriceUsageInput struct {
+	BillID   string
+	Currency Currency
 }
 
-func (a *Activities) CalculateTotalActivity(_ context.Context, items []LineItem) (int64, error) {
+// PricedUsage holds the USAGE items for a bill's metered usage. Claimed is
+// set when the events priced were claimed for the close; usage priced before
+// that only has Through, the sequence number of the last event priced.
+type PricedUsage struct {
+	Items   []LineItem
+	Through int64
+	Claimed bool
+}
+
+func (a *Activities) PriceUsageActivity(ctx context.Context, input PriceUsageInput) (PricedUsage, error) {
+	usage, err := a.repo.ClaimUsage(ctx, input.BillID)
+	if err != nil {
+		slog.Error("failed to claim usage", "bill_id", input.BillID, "error", err)
+		return PricedUsage{}, fmt.Errorf("failed to claim usage: %w", err)
+	}
+
+	items, err := usage.Price(input.Currency, time.Now())
+	if err != nil {
+		slog.Error("failed to price usage", "bill_id", input.BillID, "error", err)
+		return PricedUsage{}, fmt.Errorf("failed to price usage: %w", err)
+	}
+	for i := range items {
+		items[i].ID = newLineItemID()
+	}
+
+	slog.Debug("priced bill usage", "bill_id", input.BillID, "meters", len(usage.Meters), "items", len(items))
+	return PricedUsage{Items: items, Through: usage.LastEventSeq, Claimed: true}, nil
+}
+
+type CalculateTotalInput struct {
+	BillID        string
+	CustomerID    string
+	Currency      Currency
+	FeeScheduleID string
+	LineItems     []LineItem
+	// StoredItems adds the items stored for the bill to LineItems. Bill
+	// workflows always set it and pass only the priced usage, as the
+	// database holds every item and a workflow only the latest ones.
+	StoredItems bool
+	// Limits are the bill's own billing limits; unset bounds fall back to
+	// the customer's.
+	Limits BillingLimits
+	// Adjustment bills are charged exactly what their items say: no fee
+	// schedule or billing limits apply.
+	Adjustment bool
+}
+
+// BillTotals.Subtotal is charges net of credits, priced usage included; fees
+// are priced on it. Items are the active stored items it counted, which
+// the bill is invoiced for. Usage is filled in from PriceUsageActivity.
+// Adjustments hold the true-up or cap credit that keeps Subtotal + FeeTotal
+// within the bill's limits, and MinimumAmount is the minimum charge that
+// discounts can't go below either. The discount fields are filled in from
+// ApplyDiscountsActivity and TaxAmount and Taxes from CalculateTaxActivity,
+// each updating Total as it goes.
+type BillTotals struct {
+	Items              []LineItem
+	Usage              []LineItem
+	ChargeTotal        int64
+	CreditTotal        int64
+	Subtotal           int64
+	Fees               []LineItem
+	FeeTotal           int64
+	Total              int64
+	FeeScheduleID      string
+	FeeScheduleVersion *int
+	Adjustments        []LineItem
+	AdjustmentTotal    int64
+	MinimumAmount      *int64
+	Discounts          []LineItem
+	DiscountTotal      int64
+	AppliedDiscounts   []BillDiscount
+	TaxAmount          int64
+	Taxes              []TaxLine
+}
+
+// UnmarshalJSON also accepts the bare list of line items that workflows
+// started before fee schedules pass as the activity input.
+func (in *CalculateTotalInput) UnmarshalJSON(data []byte) error {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		*in = CalculateTotalInput{}
+		return json.Unmarshal(trimmed, &in.LineItems)
+	}
+	type plain CalculateTotalInput
+	return json.Unmarshal(data, (*plain)(in))
+}
+
+// UnmarshalJSON also accepts the bare total that the activity returned to
+// workflows started before fee schedules.
+func (t *BillTotals) UnmarshalJSON(data []byte) error {
 	var total int64
+	if err := json.Unmarshal(data, &total); err == nil {
+		*t = BillTotals{Subtotal: total, Total: total}
+		return nil
+	}
+	type plain BillTotals
+	return json.Unmarshal(data, (*plain)(t))
+}
+
+func (a *Activities) CalculateTotalActivity(ctx context.Context, input CalculateTotalInput) (BillTotals, error) {
+	var totals BillTotals
+	items := input.LineItems
+	if input.StoredItems {
+		stored, err := a.repo.GetLineItemsByBillID(ctx, input.BillID)
+		if err != nil {
+			slog.Error("failed to load line items", "bill_id", input.BillID, "error", err)
+			return BillTotals{}, fmt.Errorf("failed to load line items: %w", err)
+		}
+		for _, item := range stored {
+			if !item.IsVoided() {
+				totals.Items = append(totals.Items, item)
+			}
+		}
+		items = append(stored, items...)
+	}
+
 	for _, item := range items {
-		total += item.Amount
+		if item.IsVoided() {
+			continue
+		}
+		amount := item.ExtendedAmount()
+		if item.Kind == LineItemKindCredit {
+			totals.CreditTotal -= amount
+		} else {
+			totals.ChargeTotal += amount
+		}
+	}
+	totals.Subtotal = totals.ChargeTotal - totals.CreditTotal
+
+	var schedule *FeeSchedule
+	if !input.Adjustment {
+		var err error
+		schedule, err = a.resolveFeeSchedule(ctx, input)
+		if err != nil {
+			slog.Error("failed to resolve fee schedule", "bill_id", input.BillID, "error", err)
+			return BillTotals{}, fmt.Errorf("failed to resolve fee schedule: %w", err)
+		}
+	}
+
+	if schedule != nil {
+		totals.FeeScheduleID = schedule.ID
+		totals.FeeScheduleVersion = &schedule.Version
+		totals.Fees = schedule.Apply(totals.Subtotal, time.Now())
+		for i := range totals.Fees {
+			totals.Fees[i].ID = newLineItemID()
+			totals.FeeTotal += totals.Fees[i].Amount
+		}
+	}
+	totals.Total = totals.Subtotal + totals.FeeTotal
+
+	var limits BillingLimits
+	if !input.Adjustment {
+		var err error
+		limits, err = a.resolveBillingLimits(ctx, input)
+		if err != nil {
+			slog.Error("failed to resolve billing limits", "bill_id", input.BillID, "error", err)
+			return BillTotals{}, fmt.Errorf("failed to resolve billing limits: %w", err)
+		}
+	}
+	totals.MinimumAmount = limits.MinimumAmount
+	if adjustment := limits.Apply(totals.Total, input.Currency, time.Now()); adjustment != nil {
+		adjustment.ID = newLineItemID()
+		totals.Adjustments = []LineItem{*adjustment}
+		totals.AdjustmentTotal = adjustment.Amount
+		totals.Total += adjustment.Amount
+	}
+
+	slog.Debug("calculated total for bill",
+		"bill_id", input.BillID,
+		"line_items_count", len(items),
+		"charges", totals.ChargeTotal,
+		"credits", totals.CreditTotal,
+		"subtotal", totals.Subtotal,
+		"fees", totals.FeeTotal,
+		"adjustment", totals.AdjustmentTotal,
+		"total", totals.Total)
+	return totals, nil
+}
+
+// resolveBillingLimits layers the bill's own limits over the customer's.
+func (a *Activities) resolveBillingLimits(ctx context.Context, input CalculateTotalInput) (BillingLimits, error) {
+	customer, err := a.repo.GetCustomerBillingLimits(ctx, input.CustomerID, input.Currency)
+	if errors.Is(err, ErrBillingLimitsNotFound) {
+		return input.Limits, nil
+	}
+	if err != nil {
+		return BillingLimits{}, err
+	}
+	return customer.Limits().Override(input.Limits), nil
+}
+
+func (a *Activities) resolveFeeSchedule(ctx context.Context, input CalculateTotalInput) (*FeeSchedule, error) {
+	if input.FeeScheduleID != "" {
+		return a.repo.GetFeeSchedule(ctx, input.FeeScheduleID)
+	}
+
+	schedule, err := a.repo.GetCustomerFeeSchedule(ctx, input.CustomerID, input.Currency)
+	if errors.Is(err, ErrFeeScheduleNotFound) {
+		return nil, nil
+	}
+	return schedule, err
+}
+
+type AttachDiscountInput struct {
+	BillID   string
+	Discount BillDiscount
+}
+
+// AttachDiscountActivity stores a discount for the bill workflow, which only
+// runs it while the bill is open.
+func (a *Activities) AttachDiscountActivity(ctx context.Context, input AttachDiscountInput) error {
+	if err := a.repo.AttachDiscount(ctx, input.BillID, &input.Discount); err != nil {
+		slog.Error("failed to attach discount", "bill_id", input.BillID, "code", input.Discount.Code, "error", err)
+		return toWorkflowError(err)
+	}
+	slog.Info("attached discount", "bill_id", input.BillID, "code", input.Discount.Code)
+	return nil
+}
+
+type ApplyDiscountsInput struct {
+	BillID string
+	// Amount is the bill total after credits, fees and billing limits.
+	Amount int64
+	// Minimum is the bill's minimum charge, if it has one.
+	Minimum *int64
+}
+
+func (a *Activities) ApplyDiscountsActivity(ctx context.Context, input ApplyDiscountsInput) (BillDiscounts, error) {
+	discounts, err := a.repo.ListBillDiscounts(ctx, input.BillID)
+	if err != nil {
+		slog.Error("failed to list bill discounts", "bill_id", input.BillID, "error", err)
+		return BillDiscounts{}, fmt.Errorf("failed to list bill discounts: %w", err)
+	}
+
+	var floor int64
+	if input.Minimum != nil && *input.Minimum > 0 {
+		floor = *input.Minimum
+	}
+	result := ApplyDiscounts(discounts, input.Amount, floor, time.Now())
+	for i := range result.Items {
+		result.Items[i].ID = newLineItemID()
+	}
+
+	slog.Debug("applied discounts to bill",
+		"bill_id", input.BillID,
+		"discounts", len(discounts),
+		"amount", input.Amount,
+		"discount_total", result.DiscountTotal,
+		"total", result.Total)
+	return result, nil
+}
+
+type CalculateTaxInput struct {
+	BillID     string
+	CustomerID string
+	Currency   Currency
+	// Amount is the pre-tax total: charges net of credits, plus fees, less
+	// discounts.
+	Amount int64
+}
+
+func (a *Activities) CalculateTaxActivity(ctx context.Context, input CalculateTaxInput) (BillTax, error) {
+	rules, err := a.repo.ListTaxRules(ctx, input.Currency)
+	if err != nil {
+		slog.Error("failed to list tax rules", "bill_id", input.BillID, "error", err)
+		return BillTax{}, fmt.Errorf("failed to list tax rules: %w", err)
+	}
+
+	applicable := make([]TaxRule, 0, len(rules))
+	for _, rule := range rules {
+		applicable = append(applicable, *rule)
 	}
+	tax := ApplyTaxRules(applicable, input.CustomerID, input.Amount)
 
-	slog.Debug("calculated total for bill", "line_items_count", len(items), "total", total)
-	return total, nil
+	slog.Debug("calculated tax for bill",
+		"bill_id", input.BillID,
+		"rules", len(rules),
+		"taxable", input.Amount,
+		"subtotal", tax.Subtotal,
+		"tax", tax.TaxAmount,
+		"total", tax.Total)
+	return tax, nil
 }
 
+// FinalBill is what closing a bill stores: the totals, the items generated
+// at close and, for the invoice, everything that was billed.
 type FinalBill struct {
-	ID          string
-	TotalAmount int64
-	Status      BillStatus
+	ID                 string
+	CustomerID         string
+	Currency           Currency
+	LegalEntity        string
+	PeriodStart        *time.Time
+	PeriodEnd          *time.Time
+	SubtotalAmount     int64
+	TaxAmount          int64
+	Taxes              []TaxLine
+	TotalAmount        int64
+	Status             BillStatus
+	Fees               []LineItem
+	FeeScheduleID      string
+	FeeScheduleVersion *int
+	Adjustments        []LineItem
+	Discounts          []LineItem
+	AppliedDiscounts   []BillDiscount
+	// Usage prices the bill's metered usage: the events claimed for the
+	// close with UsageClaimed, otherwise those up to and including
+	// UsageThrough.
+	Usage        []LineItem
+	UsageThrough int64
+	UsageClaimed bool
+	// LineItems are all the active items on the bill, generated ones
+	// included.
+	LineItems []LineItem
+	// InvoiceNumber is set by the repository once the invoice is issued.
+	InvoiceNumber string
+	// DueAt is when payment is due under the bill's terms.
+	DueAt time.Time
 }
 
 func (a *Activities) SaveFinalBillActivity(ctx context.Context, bill FinalBill) error {
-	err := a.repo.UpdateBillStatus(ctx, bill.ID, bill.Status, bill.TotalAmount)
+	// Closes scheduled by the original workflow only send the ID, total and
+	// status. Their invoice lists the stored items, and with no fees or tax
+	// the subtotal is the total.
+	if bill.LineItems == nil && bill.SubtotalAmount == 0 {
+		stored, err := a.repo.GetLineItemsByBillID(ctx, bill.ID)
+		if err != nil {
+			slog.Error("failed to load line items", "bill_id", bill.ID, "error", err)
+			return fmt.Errorf("failed to load line items: %w", err)
+		}
+		for _, item := range stored {
+			if !item.IsVoided() {
+				bill.LineItems = append(bill.LineItems, item)
+			}
+		}
+		bill.SubtotalAmount = bill.TotalAmount
+	}
+
+	err := a.repo.FinalizeBill(ctx, &bill)
 	if err != nil {
 		slog.Error("failed to save final bill", "bill_id", bill.ID, "error", err)
 		return fmt.Errorf("failed to save final bill: %w", err)
 	}
 
-	slog.Info("bill finalized successfully", "bill_id", bill.ID, "total_amount", bill.TotalAmount)
+	slog.Info("bill finalized successfully", "bill_id", bill.ID, "total_amount", bill.TotalAmount, "invoice_number", bill.InvoiceNumber)
+	return nil
+}
+
+type VoidBillInput struct {
+	BillID   string
+	Reason   string
+	VoidedAt time.Time
+}
+
+// VoidBillActivity marks the bill voided in place of SaveFinalBillActivity.
+func (a *Activities) VoidBillActivity(ctx context.Context, input VoidBillInput) error {
+	if err := a.repo.VoidBill(ctx, input.BillID, input.Reason, input.VoidedAt); err != nil {
+		slog.Error("failed to void bill", "bill_id", input.BillID, "error", err)
+		return fmt.Errorf("failed to void bill: %w", err)
+	}
+
+	slog.Info("bill voided", "bill_id", input.BillID, "reason", input.Reason)
+	return nil
+}
+
+// SettleOutboxInput lists what a bill workflow run was delivered: ItemIDs
+// the items it added and VoidIDs the items it voided.
+type SettleOutboxInput struct {
+	BillID  string
+	ItemIDs []string
+	VoidIDs []string
+}
+
+// SettleOutboxActivity marks done the outbox events a bill workflow run has
+// already applied, before it continues as new and forgets their IDs. Left
+// pending, a redelivery would be counted a second time by a later run.
+func (a *Activities) SettleOutboxActivity(ctx context.Context, input SettleOutboxInput) error {
+	if err := a.repo.SettleOutboxEvents(ctx, input.BillID, input.ItemIDs, input.VoidIDs); err != nil {
+		slog.Error("failed to settle outbox events", "bill_id", input.BillID, "error", err)
+		return fmt.Errorf("failed to settle outbox events: %w", err)
+	}
+	return nil
+}
+
+type DunningStepInput struct {
+	BillID      string
+	DueAt       time.Time
+	DaysOverdue int
+	// Stage counts the reminders sent, this one included.
+	Stage int
+	Final bool
+}
+
+// DunningStepResult.Settled means the bill turned out to be paid and nothing
+// was sent.
+type DunningStepResult struct {
+	Settled bool
+}
+
+// SendDunningReminderActivity marks the bill overdue and sends the next
+// reminder, unless the bill has been paid in the meantime. Retries may send
+// the same reminder twice.
+func (a *Activities) SendDunningReminderActivity(ctx context.Context, input DunningStepInput) (DunningStepResult, error) {
+	bill, err := a.unsettledBill(ctx, input.BillID)
+	if bill == nil || err != nil {
+		return DunningStepResult{Settled: err == nil}, err
+	}
+
+	if err := a.repo.UpdateDunning(ctx, input.BillID, DunningStatusOverdue, input.Stage); err != nil {
+		slog.Error("failed to mark bill overdue", "bill_id", input.BillID, "error", err)
+		return DunningStepResult{}, fmt.Errorf("failed to mark bill overdue: %w", err)
+	}
+	if err := a.notify(ctx, NotificationPaymentReminder, bill, input); err != nil {
+		return DunningStepResult{}, err
+	}
+
+	slog.Info("payment reminder sent", "bill_id", input.BillID, "stage", input.Stage, "days_overdue", input.DaysOverdue)
+	return DunningStepResult{}, nil
+}
+
+// WriteOffBillActivity writes off a bill that is still unpaid once every
+// reminder has gone out.
+func (a *Activities) WriteOffBillActivity(ctx context.Context, input DunningStepInput) (DunningStepResult, error) {
+	bill, err := a.unsettledBill(ctx, input.BillID)
+	if bill == nil || err != nil {
+		return DunningStepResult{Settled: err == nil}, err
+	}
+
+	if err := a.repo.UpdateDunning(ctx, input.BillID, DunningStatusWrittenOff, input.Stage); err != nil {
+		slog.Error("failed to write off bill", "bill_id", input.BillID, "error", err)
+		return DunningStepResult{}, fmt.Errorf("failed to write off bill: %w", err)
+	}
+	if err := a.notify(ctx, NotificationBillWrittenOff, bill, input); err != nil {
+		return DunningStepResult{}, err
+	}
+
+	slog.Warn("bill written off", "bill_id", input.BillID, "balance", bill.Balance())
+	return DunningStepResult{}, nil
+}
+
+func (a *Activities) StopDunningActivity(ctx context.Context, billID string) error {
+	if err := a.repo.UpdateDunning(ctx, billID, DunningStatusStopped, 0); err != nil {
+		slog.Error("failed to stop dunning", "bill_id", billID, "error", err)
+		return fmt.Errorf("failed to stop dunning: %w", err)
+	}
+	slog.Info("dunning stopped, bill paid", "bill_id", billID)
+	return nil
+}
+
+// unsettledBill returns the bill if it still has something owing, or nil
+// once it has been paid.
+func (a *Activities) unsettledBill(ctx context.Context, billID string) (*Bill, error) {
+	bill, err := a.repo.GetBillByID(ctx, billID)
+	if err != nil {
+		slog.Error("failed to get bill for dunning", "bill_id", billID, "error", err)
+		return nil, fmt.Errorf("failed to get bill: %w", err)
+	}
+	if bill.PaymentStatus.IsSettled() {
+		return nil, nil
+	}
+	return bill, nil
+}
+
+func (a *Activities) notify(ctx context.Context, kind NotificationKind, bill *Bill, input DunningStepInput) error {
+	err := a.notifier.Notify(ctx, Notification{
+		Kind:        kind,
+		BillID:      bill.ID,
+		CustomerID:  bill.CustomerID,
+		Currency:    bill.Currency,
+		Balance:     bill.Balance(),
+		DueAt:       input.DueAt,
+		DaysOverdue: input.DaysOverdue,
+		Stage:       input.Stage,
+		Final:       input.Final,
+	})
+	if err != nil {
+		slog.Error("failed to send dunning notification", "bill_id", bill.ID, "kind", kind, "error", err)
+		return fmt.Errorf("failed to send %s notification: %w", kind, err)
+	}
 	return nil
 }
+
+type RenewSubscriptionInput struct {
+	SubscriptionID string
+	PeriodStart    time.Time
+	PeriodEnd      time.Time
+}
+
+// SubscriptionRenewal is the bill opened for the new period, empty if the
+// subscription is paused, and the status the subscription was found in.
+type SubscriptionRenewal struct {
+	BillID string
+	Status SubscriptionStatus
+}
+
+// RenewSubscriptionActivity moves a subscription on to a new period. An
+// active subscription gets a bill for the period with the plan charge on
+// it; either way the previous period's bill is closed. Retries return the
+// bill opened first.
+func (a *Activities) RenewSubscriptionActivity(ctx context.Context, input RenewSubscriptionInput) (SubscriptionRenewal, error) {
+	sub, err := a.repo.GetSubscription(ctx, input.SubscriptionID)
+	if err != nil {
+		slog.Error("failed to get subscription for renewal", "subscription_id", input.SubscriptionID, "error", err)
+		return SubscriptionRenewal{}, fmt.Errorf("failed to get subscription: %w", err)
+	}
+	if sub.Status == SubscriptionStatusCancelled {
+		slog.Info("subscription cancelled, not renewing", "subscription_id", sub.ID)
+		return SubscriptionRenewal{Status: sub.Status}, nil
+	}
+	if a.biller == nil {
+		return SubscriptionRenewal{}, errors.New("no biller configured for subscriptions")
+	}
+
+	var billID string
+	if sub.Status == SubscriptionStatusActive {
+		if billID, err = a.openSubscriptionBill(ctx, sub, input.PeriodStart, input.PeriodEnd); err != nil {
+			return SubscriptionRenewal{}, err
+		}
+	}
+
+	// A retry after the renewal was saved finds the new bill already
+	// current and must not close it. A voided previous bill stays voided.
+	if previous := sub.CurrentBillID; previous != "" && previous != billID {
+		_, err := a.biller.CloseBill(ctx, previous)
+		if err != nil && !errors.Is(err, ErrBillAlreadyClosed) && !errors.Is(err, ErrBillVoided) {
+			slog.Error("failed to close previous subscription bill", "subscription_id", sub.ID, "bill_id", previous, "error", err)
+			return SubscriptionRenewal{}, fmt.Errorf("failed to close bill %s: %w", previous, err)
+		}
+	}
+
+	if err := a.repo.RenewSubscription(ctx, sub.ID, billID, input.PeriodStart, input.PeriodEnd); err != nil {
+		slog.Error("failed to save subscription renewal", "subscription_id", sub.ID, "error", err)
+		return SubscriptionRenewal{}, fmt.Errorf("failed to save subscription renewal: %w", err)
+	}
+
+	slog.Info("subscription renewed", "subscription_id", sub.ID, "status", sub.Status, "bill_id", billID,
+		"period_start", input.PeriodStart, "period_end", input.PeriodEnd)
+	return SubscriptionRenewal{BillID: billID, Status: sub.Status}, nil
+}
+
+func (a *Activities) openSubscriptionBill(ctx context.Context, sub *Subscription, start, end time.Time) (string, error) {
+	bill, err := a.biller.CreateBill(ctx, sub.billRequest(start, end))
+	if err != nil {
+		slog.Error("failed to create subscription bill", "subscription_id", sub.ID, "error", err)
+		return "", fmt.Errorf("failed to create subscription bill: %w", err)
+	}
+	if sub.PlanAmount == 0 {
+		return bill.BillID, nil
+	}
+	charge, err := sub.planCharge(start, end)
+	if err != nil {
+		slog.Error("failed to price plan charge", "subscription_id", sub.ID, "error", err)
+		return "", err
+	}
+	if charge.Amount == 0 {
+		return bill.BillID, nil
+	}
+	if _, err := a.biller.AddLineItem(ctx, bill.BillID, charge); err != nil {
+		slog.Error("failed to add plan charge", "subscription_id", sub.ID, "bill_id", bill.BillID, "error", err)
+		return "", fmt.Errorf("failed to add plan charge: %w", err)
+	}
+	return bill.BillID, nil
+}
@@ -3,11 +3,14 @@ package fees
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/converter"
 )
 
 func TestActivities_SaveFinalBillActivity_Unit(t *testing.T) {
@@ -19,12 +22,14 @@ func TestActivities_SaveFinalBillActivity_Unit(t *testing.T) {
 	activities := NewActivities(mockRepo)
 
 	billToSave := FinalBill{
-		ID:          "bill-123",
-		TotalAmount: 1000,
-		Status:      BillStatusClosed,
+		ID:             "bill-123",
+		SubtotalAmount: 1000,
+		TotalAmount:    1000,
+		Status:         BillStatusClosed,
+		LineItems:      []LineItem{{ID: "item-1", Description: "Fee", Amount: 1000}},
 	}
 	mockRepo.EXPECT().
-		UpdateBillStatus(gomock.Any(), "bill-123", BillStatusClosed, int64(1000)).
+		FinalizeBill(gomock.Any(), &billToSave).
 		Return(nil).
 		Times(1)
 
@@ -33,6 +38,46 @@ func TestActivities_SaveFinalBillActivity_Unit(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestActivities_SaveFinalBillActivity_OriginalPayload(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := NewMockRepositoryInterface(ctrl)
+	activities := NewActivities(mockRepo)
+
+	// What the original workflow sent, as it comes out of a pending close.
+	var bill FinalBill
+	payload, err := converter.GetDefaultDataConverter().ToPayload(struct {
+		ID          string
+		TotalAmount int64
+		Status      BillStatus
+	}{ID: "bill-123", TotalAmount: 1500, Status: BillStatusClosed})
+	require.NoError(t, err)
+	require.NoError(t, converter.GetDefaultDataConverter().FromPayload(payload, &bill))
+
+	voidedAt := time.Now()
+	mockRepo.EXPECT().
+		GetLineItemsByBillID(gomock.Any(), "bill-123").
+		Return([]LineItem{
+			{ID: "item-1", Description: "Wire transfer fee", Amount: 1000},
+			{ID: "item-2", Description: "Duplicate", Amount: 700, VoidedAt: &voidedAt},
+			{ID: "item-3", Description: "Card processing fee", Amount: 500},
+		}, nil)
+
+	mockRepo.EXPECT().
+		FinalizeBill(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, saved *FinalBill) error {
+			assert.Equal(t, int64(1500), saved.SubtotalAmount)
+			assert.Equal(t, int64(1500), saved.TotalAmount)
+			require.Len(t, saved.LineItems, 2)
+			assert.Equal(t, "item-1", saved.LineItems[0].ID)
+			assert.Equal(t, "item-3", saved.LineItems[1].ID)
+			return nil
+		})
+
+	require.NoError(t, activities.SaveFinalBillActivity(context.Background(), bill))
+}
+
 func TestActivities_SaveFinalBillActivity_Unit_Error(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -41,11 +86,740 @@ func TestActivities_SaveFinalBillActivity_Unit_Error(t *testing.T) {
 	activities := NewActivities(mockRepo)
 
 	mockRepo.EXPECT().
-		UpdateBillStatus(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		FinalizeBill(gomock.Any(), gomock.Any()).
 		Return(errors.New("database is down")).
 		Times(1)
 
-	err := activities.SaveFinalBillActivity(context.Background(), FinalBill{})
+	err := activities.SaveFinalBillActivity(context.Background(), FinalBill{ID: "bill-123", LineItems: []LineItem{}})
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "database is down")
 }
+
+func TestActivities_VoidBillActivity(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := NewMockRepositoryInterface(ctrl)
+	activities := NewActivities(mockRepo)
+	voidedAt := time.Date(2026, 3, 2, 9, 3, 0, 0, time.UTC)
+
+	mockRepo.EXPECT().
+		VoidBill(gomock.Any(), "bill-123", "Created by mistake", voidedAt).
+		Return(nil)
+	require.NoError(t, activities.VoidBillActivity(context.Background(),
+		VoidBillInput{BillID: "bill-123", Reason: "Created by mistake", VoidedAt: voidedAt}))
+
+	mockRepo.EXPECT().
+		VoidBill(gomock.Any(), "bill-456", gomock.Any(), gomock.Any()).
+		Return(ErrBillAlreadyClosed)
+	err := activities.VoidBillActivity(context.Background(), VoidBillInput{BillID: "bill-456", Reason: "Too late"})
+	assert.ErrorIs(t, err, ErrBillAlreadyClosed)
+}
+
+func TestActivities_SettleOutboxActivity(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := NewMockRepositoryInterface(ctrl)
+	activities := NewActivities(mockRepo)
+	input := SettleOutboxInput{BillID: "bill-123", ItemIDs: []string{"item-1", "item-2"}, VoidIDs: []string{"item-2"}}
+
+	mockRepo.EXPECT().
+		SettleOutboxEvents(gomock.Any(), "bill-123", input.ItemIDs, input.VoidIDs).
+		Return(nil)
+	require.NoError(t, activities.SettleOutboxActivity(context.Background(), input))
+
+	mockRepo.EXPECT().
+		SettleOutboxEvents(gomock.Any(), "bill-123", gomock.Any(), gomock.Any()).
+		Return(errors.New("database unavailable"))
+	assert.Error(t, activities.SettleOutboxActivity(context.Background(), input))
+}
+
+func TestActivities_CalculateTotalActivity_Unit(t *testing.T) {
+	items := []LineItem{
+		{Description: "Item 1", Amount: 60000},
+		{Description: "Item 2", Amount: 90000},
+	}
+	upTo := int64(100000)
+	schedule := &FeeSchedule{
+		ID:       "standard",
+		Version:  3,
+		Name:     "Standard",
+		Currency: USD,
+		Tiers: []FeeTier{
+			{UpTo: &upTo, RateBps: 200},
+			{RateBps: 100},
+		},
+	}
+
+	t.Run("CustomerSchedule", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockRepo := NewMockRepositoryInterface(ctrl)
+		activities := NewActivities(mockRepo)
+
+		mockRepo.EXPECT().
+			GetCustomerFeeSchedule(gomock.Any(), "customer-1", USD).
+			Return(schedule, nil)
+		withoutBillingLimits(mockRepo)
+
+		totals, err := activities.CalculateTotalActivity(context.Background(), CalculateTotalInput{
+			BillID:     "bill-123",
+			CustomerID: "customer-1",
+			Currency:   USD,
+			LineItems:  items,
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, int64(150000), totals.Subtotal)
+		assert.Equal(t, int64(2500), totals.FeeTotal)
+		assert.Equal(t, int64(152500), totals.Total)
+		assert.Len(t, totals.Fees, 2)
+		assert.Equal(t, "standard", totals.FeeScheduleID)
+		require.NotNil(t, totals.FeeScheduleVersion)
+		assert.Equal(t, 3, *totals.FeeScheduleVersion)
+	})
+
+	t.Run("CreditsReduceFeeBase", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockRepo := NewMockRepositoryInterface(ctrl)
+		activities := NewActivities(mockRepo)
+
+		mockRepo.EXPECT().
+			GetCustomerFeeSchedule(gomock.Any(), "customer-1", USD).
+			Return(schedule, nil)
+		withoutBillingLimits(mockRepo)
+
+		withCredit := append(append([]LineItem{}, items...), LineItem{
+			Description: "Outage",
+			Amount:      -50000,
+			Kind:        LineItemKindCredit,
+			ReasonCode:  CreditReasonOutage,
+		})
+
+		totals, err := activities.CalculateTotalActivity(context.Background(), CalculateTotalInput{
+			BillID:     "bill-123",
+			CustomerID: "customer-1",
+			Currency:   USD,
+			LineItems:  withCredit,
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, int64(150000), totals.ChargeTotal)
+		assert.Equal(t, int64(50000), totals.CreditTotal)
+		assert.Equal(t, int64(100000), totals.Subtotal)
+		assert.Equal(t, int64(2000), totals.FeeTotal)
+		assert.Equal(t, int64(102000), totals.Total)
+	})
+
+	t.Run("PinnedSchedule", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockRepo := NewMockRepositoryInterface(ctrl)
+		activities := NewActivities(mockRepo)
+
+		mockRepo.EXPECT().
+			GetFeeSchedule(gomock.Any(), "standard").
+			Return(schedule, nil)
+		withoutBillingLimits(mockRepo)
+
+		totals, err := activities.CalculateTotalActivity(context.Background(), CalculateTotalInput{
+			BillID:        "bill-123",
+			CustomerID:    "customer-1",
+			Currency:      USD,
+			FeeScheduleID: "standard",
+			LineItems:     items,
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, int64(152500), totals.Total)
+	})
+
+	t.Run("NoSchedule", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockRepo := NewMockRepositoryInterface(ctrl)
+		activities := NewActivities(mockRepo)
+
+		mockRepo.EXPECT().
+			GetCustomerFeeSchedule(gomock.Any(), "customer-1", USD).
+			Return(nil, ErrFeeScheduleNotFound)
+		withoutBillingLimits(mockRepo)
+
+		totals, err := activities.CalculateTotalActivity(context.Background(), CalculateTotalInput{
+			BillID:     "bill-123",
+			CustomerID: "customer-1",
+			Currency:   USD,
+			LineItems:  items,
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, int64(150000), totals.Total)
+		assert.Empty(t, totals.Fees)
+		assert.Nil(t, totals.FeeScheduleVersion)
+	})
+
+	t.Run("PinnedScheduleMissing", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockRepo := NewMockRepositoryInterface(ctrl)
+		activities := NewActivities(mockRepo)
+
+		mockRepo.EXPECT().
+			GetFeeSchedule(gomock.Any(), "gone").
+			Return(nil, ErrFeeScheduleNotFound)
+
+		_, err := activities.CalculateTotalActivity(context.Background(), CalculateTotalInput{
+			BillID:        "bill-123",
+			FeeScheduleID: "gone",
+			LineItems:     items,
+		})
+
+		require.Error(t, err)
+		assert.ErrorIs(t, err, ErrFeeScheduleNotFound)
+	})
+
+	t.Run("StoredItems", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockRepo := NewMockRepositoryInterface(ctrl)
+		activities := NewActivities(mockRepo)
+
+		voidedAt := time.Now()
+		stored := append(items, LineItem{Description: "Voided", Amount: 5000, VoidedAt: &voidedAt})
+		mockRepo.EXPECT().
+			GetLineItemsByBillID(gomock.Any(), "bill-123").
+			Return(stored, nil)
+		mockRepo.EXPECT().
+			GetCustomerFeeSchedule(gomock.Any(), "customer-1", USD).
+			Return(nil, ErrFeeScheduleNotFound)
+		withoutBillingLimits(mockRepo)
+
+		totals, err := activities.CalculateTotalActivity(context.Background(), CalculateTotalInput{
+			BillID:      "bill-123",
+			CustomerID:  "customer-1",
+			Currency:    USD,
+			LineItems:   []LineItem{{Description: "API calls", Amount: 700, Kind: LineItemKindUsage}},
+			StoredItems: true,
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, int64(150700), totals.Total)
+		assert.Equal(t, items, totals.Items)
+	})
+
+	t.Run("StoredItemsError", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockRepo := NewMockRepositoryInterface(ctrl)
+		activities := NewActivities(mockRepo)
+
+		mockRepo.EXPECT().
+			GetLineItemsByBillID(gomock.Any(), "bill-123").
+			Return(nil, errors.New("database unavailable"))
+
+		_, err := activities.CalculateTotalActivity(context.Background(), CalculateTotalInput{
+			BillID:      "bill-123",
+			StoredItems: true,
+		})
+
+		assert.ErrorContains(t, err, "failed to load line items")
+	})
+}
+
+func TestCalculateTotal_DecodesPreScheduleShapes(t *testing.T) {
+	dc := converter.GetDefaultDataConverter()
+
+	// Workflows started before fee schedules scheduled the activity with the
+	// line items alone and got the total back as a number.
+	payloads, err := dc.ToPayloads([]LineItem{{Description: "Item 1", Amount: 300}})
+	require.NoError(t, err)
+	var input CalculateTotalInput
+	require.NoError(t, dc.FromPayloads(payloads, &input))
+	assert.Equal(t, CalculateTotalInput{LineItems: []LineItem{{Description: "Item 1", Amount: 300}}}, input)
+
+	payloads, err = dc.ToPayloads(int64(300))
+	require.NoError(t, err)
+	var totals BillTotals
+	require.NoError(t, dc.FromPayloads(payloads, &totals))
+	assert.Equal(t, BillTotals{Subtotal: 300, Total: 300}, totals)
+
+	version := 2
+	payloads, err = dc.ToPayloads(BillTotals{Subtotal: 300, FeeTotal: 6, Total: 306, FeeScheduleID: "standard", FeeScheduleVersion: &version})
+	require.NoError(t, err)
+	totals = BillTotals{}
+	require.NoError(t, dc.FromPayloads(payloads, &totals))
+	assert.Equal(t, int64(306), totals.Total)
+	assert.Equal(t, "standard", totals.FeeScheduleID)
+}
+
+func TestActivities_CalculateTotalActivity_BillingLimits(t *testing.T) {
+	items := []LineItem{{Description: "Usage", Amount: 3000}}
+	minimum := int64(5000)
+	maximum := int64(2000)
+	billMinimum := int64(4000)
+
+	tests := []struct {
+		name           string
+		customer       *CustomerBillingLimits
+		bill           BillingLimits
+		wantKind       LineItemKind
+		wantAdjustment int64
+	}{
+		{"no limits", nil, BillingLimits{}, "", 0},
+		{"customer minimum", &CustomerBillingLimits{MinimumAmount: &minimum}, BillingLimits{}, LineItemKindTrueUp, 2000},
+		{"customer cap", &CustomerBillingLimits{MaximumAmount: &maximum}, BillingLimits{}, LineItemKindCapCredit, -1000},
+		{"bill overrides customer", &CustomerBillingLimits{MinimumAmount: &minimum}, BillingLimits{MinimumAmount: &billMinimum}, LineItemKindTrueUp, 1000},
+		{"bill only", nil, BillingLimits{MaximumAmount: &maximum}, LineItemKindCapCredit, -1000},
+		{"within limits", &CustomerBillingLimits{MinimumAmount: &maximum}, BillingLimits{}, "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := NewMockRepositoryInterface(ctrl)
+			activities := NewActivities(mockRepo)
+
+			mockRepo.EXPECT().
+				GetCustomerFeeSchedule(gomock.Any(), "customer-1", USD).
+				Return(nil, ErrFeeScheduleNotFound)
+			if tt.customer != nil {
+				mockRepo.EXPECT().
+					GetCustomerBillingLimits(gomock.Any(), "customer-1", USD).
+					Return(tt.customer, nil)
+			} else {
+				withoutBillingLimits(mockRepo)
+			}
+
+			totals, err := activities.CalculateTotalActivity(context.Background(), CalculateTotalInput{
+				BillID:     "bill-123",
+				CustomerID: "customer-1",
+				Currency:   USD,
+				LineItems:  items,
+				Limits:     tt.bill,
+			})
+
+			require.NoError(t, err)
+			assert.Equal(t, int64(3000), totals.Subtotal)
+			assert.Equal(t, tt.wantAdjustment, totals.AdjustmentTotal)
+			assert.Equal(t, 3000+tt.wantAdjustment, totals.Total)
+			if tt.bill.MinimumAmount != nil {
+				require.NotNil(t, totals.MinimumAmount)
+				assert.Equal(t, *tt.bill.MinimumAmount, *totals.MinimumAmount)
+			}
+			if tt.wantKind == "" {
+				assert.Empty(t, totals.Adjustments)
+				return
+			}
+			require.Len(t, totals.Adjustments, 1)
+			adjustment := totals.Adjustments[0]
+			assert.Equal(t, tt.wantKind, adjustment.Kind)
+			assert.True(t, adjustment.SystemGenerated)
+			assert.NotEmpty(t, adjustment.ID)
+			assert.NoError(t, adjustment.Validate())
+		})
+	}
+
+	t.Run("RepositoryError", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockRepo := NewMockRepositoryInterface(ctrl)
+		activities := NewActivities(mockRepo)
+
+		mockRepo.EXPECT().
+			GetCustomerFeeSchedule(gomock.Any(), "customer-1", USD).
+			Return(nil, ErrFeeScheduleNotFound)
+		mockRepo.EXPECT().
+			GetCustomerBillingLimits(gomock.Any(), "customer-1", USD).
+			Return(nil, errors.New("database is down"))
+
+		_, err := activities.CalculateTotalActivity(context.Background(), CalculateTotalInput{
+			BillID:     "bill-123",
+			CustomerID: "customer-1",
+			Currency:   USD,
+			LineItems:  items,
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("AdjustmentBill", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		// Adjustment bills are priced as written, without fees or limits.
+		mockRepo := NewMockRepositoryInterface(ctrl)
+		activities := NewActivities(mockRepo)
+
+		totals, err := activities.CalculateTotalActivity(context.Background(), CalculateTotalInput{
+			BillID:     "bill-123",
+			CustomerID: "customer-1",
+			Currency:   USD,
+			LineItems:  []LineItem{{Description: "Refund of overcharge", Amount: -3000}},
+			Limits:     BillingLimits{MinimumAmount: &minimum},
+			Adjustment: true,
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, int64(-3000), totals.Total)
+		assert.Empty(t, totals.Adjustments)
+	})
+}
+
+// withoutBillingLimits expects a lookup of the customer's billing limits and
+// finds none.
+func withoutBillingLimits(mockRepo *MockRepositoryInterface) {
+	mockRepo.EXPECT().
+		GetCustomerBillingLimits(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil, ErrBillingLimitsNotFound)
+}
+
+func TestActivities_CalculateTaxActivity_Unit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := NewMockRepositoryInterface(ctrl)
+	activities := NewActivities(mockRepo)
+
+	t.Run("GeorgianVAT", func(t *testing.T) {
+		mockRepo.EXPECT().
+			ListTaxRules(gomock.Any(), GEL).
+			Return([]*TaxRule{{ID: "ge-vat", Name: "VAT", Jurisdiction: "GE", Currency: GEL, RateBps: 1800}}, nil)
+
+		tax, err := activities.CalculateTaxActivity(context.Background(), CalculateTaxInput{
+			BillID:     "bill-gel",
+			CustomerID: "customer-1",
+			Currency:   GEL,
+			Amount:     50000,
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, int64(50000), tax.Subtotal)
+		assert.Equal(t, int64(9000), tax.TaxAmount)
+		assert.Equal(t, int64(59000), tax.Total)
+		require.Len(t, tax.Lines, 1)
+		assert.Equal(t, "ge-vat", tax.Lines[0].RuleID)
+	})
+
+	t.Run("NoRules", func(t *testing.T) {
+		mockRepo.EXPECT().
+			ListTaxRules(gomock.Any(), USD).
+			Return(nil, nil)
+
+		tax, err := activities.CalculateTaxActivity(context.Background(), CalculateTaxInput{Currency: USD, Amount: 1000})
+
+		require.NoError(t, err)
+		assert.Equal(t, BillTax{Subtotal: 1000, Total: 1000}, tax)
+	})
+
+	t.Run("RepositoryError", func(t *testing.T) {
+		mockRepo.EXPECT().
+			ListTaxRules(gomock.Any(), USD).
+			Return(nil, errors.New("database is down"))
+
+		_, err := activities.CalculateTaxActivity(context.Background(), CalculateTaxInput{Currency: USD, Amount: 1000})
+		assert.Error(t, err)
+	})
+}
+
+func TestActivities_ApplyDiscountsActivity_Unit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := NewMockRepositoryInterface(ctrl)
+	activities := NewActivities(mockRepo)
+
+	t.Run("AppliesStoredDiscounts", func(t *testing.T) {
+		mockRepo.EXPECT().
+			ListBillDiscounts(gomock.Any(), "bill-1").
+			Return([]BillDiscount{{Code: "SPRING10", Kind: DiscountKindPercentage, PercentBps: 1000}}, nil)
+
+		result, err := activities.ApplyDiscountsActivity(context.Background(), ApplyDiscountsInput{BillID: "bill-1", Amount: 5000})
+
+		require.NoError(t, err)
+		assert.Equal(t, int64(500), result.DiscountTotal)
+		assert.Equal(t, int64(4500), result.Total)
+		require.Len(t, result.Items, 1)
+		assert.NotEmpty(t, result.Items[0].ID)
+	})
+
+	t.Run("KeepsMinimumCharge", func(t *testing.T) {
+		mockRepo.EXPECT().
+			ListBillDiscounts(gomock.Any(), "bill-1").
+			Return([]BillDiscount{{Code: "SPRING10", Kind: DiscountKindPercentage, PercentBps: 1000}}, nil)
+
+		minimum := int64(5000)
+		result, err := activities.ApplyDiscountsActivity(context.Background(), ApplyDiscountsInput{BillID: "bill-1", Amount: 5000, Minimum: &minimum})
+
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), result.DiscountTotal)
+		assert.Equal(t, int64(5000), result.Total)
+		assert.Empty(t, result.Items)
+	})
+
+	t.Run("RepositoryError", func(t *testing.T) {
+		mockRepo.EXPECT().
+			ListBillDiscounts(gomock.Any(), "bill-1").
+			Return(nil, errors.New("database is down"))
+
+		_, err := activities.ApplyDiscountsActivity(context.Background(), ApplyDiscountsInput{BillID: "bill-1", Amount: 5000})
+		assert.Error(t, err)
+	})
+}
+
+func TestActivities_Dunning_Unit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := NewMockRepositoryInterface(ctrl)
+	mockNotifier := NewMockNotifier(ctrl)
+	activities := NewActivities(mockRepo, WithNotifier(mockNotifier))
+
+	dueAt := time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+	unpaid := func() *Bill {
+		bill := &Bill{ID: "bill-1", CustomerID: "customer-1", Currency: USD, Status: BillStatusClosed,
+			TotalAmount: 10000, PaidAmount: 2500}
+		bill.settle()
+		return bill
+	}
+	step := DunningStepInput{BillID: "bill-1", DueAt: dueAt, DaysOverdue: 7, Stage: 2}
+
+	t.Run("SendsReminder", func(t *testing.T) {
+		mockRepo.EXPECT().GetBillByID(gomock.Any(), "bill-1").Return(unpaid(), nil)
+		mockRepo.EXPECT().UpdateDunning(gomock.Any(), "bill-1", DunningStatusOverdue, 2).Return(nil)
+		mockNotifier.EXPECT().Notify(gomock.Any(), Notification{
+			Kind:        NotificationPaymentReminder,
+			BillID:      "bill-1",
+			CustomerID:  "customer-1",
+			Currency:    USD,
+			Balance:     7500,
+			DueAt:       dueAt,
+			DaysOverdue: 7,
+			Stage:       2,
+		}).Return(nil)
+
+		result, err := activities.SendDunningReminderActivity(context.Background(), step)
+
+		require.NoError(t, err)
+		assert.False(t, result.Settled)
+	})
+
+	t.Run("SkipsPaidBill", func(t *testing.T) {
+		paid := &Bill{ID: "bill-1", Status: BillStatusClosed, TotalAmount: 10000, PaidAmount: 10000}
+		paid.settle()
+		mockRepo.EXPECT().GetBillByID(gomock.Any(), "bill-1").Return(paid, nil)
+
+		result, err := activities.SendDunningReminderActivity(context.Background(), step)
+
+		require.NoError(t, err)
+		assert.True(t, result.Settled)
+	})
+
+	t.Run("NotifierError", func(t *testing.T) {
+		mockRepo.EXPECT().GetBillByID(gomock.Any(), "bill-1").Return(unpaid(), nil)
+		mockRepo.EXPECT().UpdateDunning(gomock.Any(), "bill-1", DunningStatusOverdue, 2).Return(nil)
+		mockNotifier.EXPECT().Notify(gomock.Any(), gomock.Any()).Return(errors.New("smtp is down"))
+
+		_, err := activities.SendDunningReminderActivity(context.Background(), step)
+		assert.Error(t, err)
+	})
+
+	t.Run("WritesOff", func(t *testing.T) {
+		mockRepo.EXPECT().GetBillByID(gomock.Any(), "bill-1").Return(unpaid(), nil)
+		mockRepo.EXPECT().UpdateDunning(gomock.Any(), "bill-1", DunningStatusWrittenOff, 4).Return(nil)
+		mockNotifier.EXPECT().Notify(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, n Notification) error {
+				assert.Equal(t, NotificationBillWrittenOff, n.Kind)
+				assert.True(t, n.Final)
+				return nil
+			})
+
+		result, err := activities.WriteOffBillActivity(context.Background(),
+			DunningStepInput{BillID: "bill-1", DueAt: dueAt, DaysOverdue: 60, Stage: 4, Final: true})
+
+		require.NoError(t, err)
+		assert.False(t, result.Settled)
+	})
+
+	t.Run("Stops", func(t *testing.T) {
+		mockRepo.EXPECT().UpdateDunning(gomock.Any(), "bill-1", DunningStatusStopped, 0).Return(nil)
+
+		require.NoError(t, activities.StopDunningActivity(context.Background(), "bill-1"))
+	})
+}
+
+func TestActivities_PriceUsageActivity_Unit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := NewMockRepositoryInterface(ctrl)
+	activities := NewActivities(mockRepo)
+
+	t.Run("PricesAggregatedUsage", func(t *testing.T) {
+		mockRepo.EXPECT().
+			ClaimUsage(gomock.Any(), "bill-1").
+			Return(&BillUsage{Meters: []MeterUsage{{Meter: *testMeter(), Quantity: 1500 * QuantityOne, Events: 3}}, LastEventSeq: 42}, nil)
+
+		result, err := activities.PriceUsageActivity(context.Background(), PriceUsageInput{BillID: "bill-1", Currency: GEL})
+
+		require.NoError(t, err)
+		assert.Equal(t, int64(42), result.Through)
+		assert.True(t, result.Claimed)
+		require.Len(t, result.Items, 1)
+		assert.NotEmpty(t, result.Items[0].ID)
+		assert.Equal(t, int64(7500), result.Items[0].Amount)
+		assert.Equal(t, LineItemKindUsage, result.Items[0].Kind)
+	})
+
+	t.Run("NoUsage", func(t *testing.T) {
+		mockRepo.EXPECT().ClaimUsage(gomock.Any(), "bill-1").Return(&BillUsage{}, nil)
+
+		result, err := activities.PriceUsageActivity(context.Background(), PriceUsageInput{BillID: "bill-1", Currency: USD})
+
+		require.NoError(t, err)
+		assert.Empty(t, result.Items)
+		assert.Zero(t, result.Through)
+	})
+
+	t.Run("RepositoryError", func(t *testing.T) {
+		mockRepo.EXPECT().ClaimUsage(gomock.Any(), "bill-1").Return(nil, errors.New("database is down"))
+
+		_, err := activities.PriceUsageActivity(context.Background(), PriceUsageInput{BillID: "bill-1", Currency: USD})
+		assert.Error(t, err)
+	})
+}
+
+func TestActivities_RenewSubscriptionActivity_Unit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := NewMockRepositoryInterface(ctrl)
+	mockBiller := NewMockBiller(ctrl)
+	activities := NewActivities(mockRepo, WithBiller(mockBiller))
+
+	start := time.Date(2026, 4, 15, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2026, 5, 15, 0, 0, 0, 0, time.UTC)
+	input := RenewSubscriptionInput{SubscriptionID: "sub-1", PeriodStart: start, PeriodEnd: end}
+	subscription := func(status SubscriptionStatus, currentBillID string) *Subscription {
+		return &Subscription{ID: "sub-1", CustomerID: "customer-1", Plan: "Pro", PlanAmount: 4900, Currency: USD,
+			AnchorDay: 15, Status: status, CurrentBillID: currentBillID}
+	}
+
+	t.Run("OpensBillAndClosesPrevious", func(t *testing.T) {
+		ctx := context.Background()
+		sub := subscription(SubscriptionStatusActive, "bill-march")
+
+		mockRepo.EXPECT().GetSubscription(ctx, "sub-1").Return(sub, nil)
+		gomock.InOrder(
+			mockBiller.EXPECT().CreateBill(ctx, sub.billRequest(start, end)).Return(&CreateBillResponse{BillID: "bill-april"}, nil),
+			mockBiller.EXPECT().AddLineItem(ctx, "bill-april", &AddLineItemRequest{
+				Description:    "Pro plan, 2026-04-15 to 2026-05-15",
+				Amount:         4900,
+				Kind:           LineItemKindCharge,
+				IdempotencyKey: "plan-2026-04-15T00:00:00Z",
+			}).Return(&AddLineItemResponse{}, nil),
+			mockBiller.EXPECT().CloseBill(ctx, "bill-march").Return(&CloseBillResponse{}, nil),
+			mockRepo.EXPECT().RenewSubscription(ctx, "sub-1", "bill-april", start, end).Return(nil),
+		)
+
+		renewal, err := activities.RenewSubscriptionActivity(ctx, input)
+
+		require.NoError(t, err)
+		assert.Equal(t, SubscriptionRenewal{BillID: "bill-april", Status: SubscriptionStatusActive}, renewal)
+	})
+
+	t.Run("PreviousAlreadyClosed", func(t *testing.T) {
+		ctx := context.Background()
+		sub := subscription(SubscriptionStatusActive, "bill-march")
+		sub.PlanAmount = 0
+
+		mockRepo.EXPECT().GetSubscription(ctx, "sub-1").Return(sub, nil)
+		mockBiller.EXPECT().CreateBill(ctx, gomock.Any()).Return(&CreateBillResponse{BillID: "bill-april"}, nil)
+		mockBiller.EXPECT().CloseBill(ctx, "bill-march").
+			Return(nil, fmt.Errorf("%w: %w", ErrUpdateRejected, ErrBillAlreadyClosed))
+		mockRepo.EXPECT().RenewSubscription(ctx, "sub-1", "bill-april", start, end).Return(nil)
+
+		_, err := activities.RenewSubscriptionActivity(ctx, input)
+
+		require.NoError(t, err)
+	})
+
+	t.Run("RetryKeepsNewBillOpen", func(t *testing.T) {
+		ctx := context.Background()
+		sub := subscription(SubscriptionStatusActive, "bill-april")
+
+		mockRepo.EXPECT().GetSubscription(ctx, "sub-1").Return(sub, nil)
+		mockBiller.EXPECT().CreateBill(ctx, gomock.Any()).Return(&CreateBillResponse{BillID: "bill-april"}, nil)
+		mockBiller.EXPECT().AddLineItem(ctx, "bill-april", gomock.Any()).Return(&AddLineItemResponse{}, nil)
+		mockRepo.EXPECT().RenewSubscription(ctx, "sub-1", "bill-april", start, end).Return(nil)
+
+		_, err := activities.RenewSubscriptionActivity(ctx, input)
+
+		require.NoError(t, err)
+	})
+
+	t.Run("FirstPeriodProrated", func(t *testing.T) {
+		ctx := context.Background()
+		created := time.Date(2026, 4, 30, 12, 0, 0, 0, time.UTC)
+		first := RenewSubscriptionInput{SubscriptionID: "sub-1", PeriodStart: created, PeriodEnd: end}
+
+		mockRepo.EXPECT().GetSubscription(ctx, "sub-1").Return(subscription(SubscriptionStatusActive, ""), nil)
+		mockBiller.EXPECT().CreateBill(ctx, gomock.Any()).Return(&CreateBillResponse{BillID: "bill-april"}, nil)
+		mockBiller.EXPECT().AddLineItem(ctx, "bill-april", gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, req *AddLineItemRequest) (*AddLineItemResponse, error) {
+				// 15 of the 30 days from April 15 to May 15.
+				assert.Equal(t, int64(2450), req.Amount)
+				return &AddLineItemResponse{}, nil
+			})
+		mockRepo.EXPECT().RenewSubscription(ctx, "sub-1", "bill-april", created, end).Return(nil)
+
+		_, err := activities.RenewSubscriptionActivity(ctx, first)
+
+		require.NoError(t, err)
+	})
+
+	t.Run("PausedOnlyCloses", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().GetSubscription(ctx, "sub-1").Return(subscription(SubscriptionStatusPaused, "bill-march"), nil)
+		mockBiller.EXPECT().CloseBill(ctx, "bill-march").Return(&CloseBillResponse{}, nil)
+		mockRepo.EXPECT().RenewSubscription(ctx, "sub-1", "", start, end).Return(nil)
+
+		renewal, err := activities.RenewSubscriptionActivity(ctx, input)
+
+		require.NoError(t, err)
+		assert.Equal(t, SubscriptionRenewal{Status: SubscriptionStatusPaused}, renewal)
+	})
+
+	t.Run("Cancelled", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().GetSubscription(ctx, "sub-1").Return(subscription(SubscriptionStatusCancelled, "bill-march"), nil)
+
+		renewal, err := activities.RenewSubscriptionActivity(ctx, input)
+
+		require.NoError(t, err)
+		assert.Equal(t, SubscriptionStatusCancelled, renewal.Status)
+	})
+
+	t.Run("CreateBillFails", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().GetSubscription(ctx, "sub-1").Return(subscription(SubscriptionStatusActive, "bill-march"), nil)
+		mockBiller.EXPECT().CreateBill(ctx, gomock.Any()).Return(nil, errors.New("database unavailable"))
+
+		_, err := activities.RenewSubscriptionActivity(ctx, input)
+
+		assert.Error(t, err)
+	})
+}
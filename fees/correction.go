@@ -0,0 +1,175 @@
+package fees
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidCorrectionReason = errors.New("invalid correction reason")
+	ErrReopenWindowPassed      = errors.New("bill can no longer be reopened")
+	ErrBillHasPayments         = errors.New("bill has payments recorded against it")
+	ErrInvalidReopenGrace      = errors.New("invalid reopen grace period")
+)
+
+// ReopenGracePeriodEnv sets how long after closing a bill may still be
+// reopened, as a Go duration such as "72h". Unset means
+// DefaultReopenGracePeriod; "0" turns reopening off.
+const ReopenGracePeriodEnv = "FEES_REOPEN_GRACE_PERIOD"
+
+// DefaultReopenGracePeriod gives finance a working day or two to spot a bill
+// that was closed too early.
+const DefaultReopenGracePeriod = 48 * time.Hour
+
+const maxCorrectionReasonLength = 500
+
+// ParseReopenGracePeriod reads a ReopenGracePeriodEnv value.
+func ParseReopenGracePeriod(value string) (time.Duration, error) {
+	if value == "" {
+		return DefaultReopenGracePeriod, nil
+	}
+	grace, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidReopenGrace, err)
+	}
+	if grace < 0 {
+		return 0, fmt.Errorf("%w: %s is negative", ErrInvalidReopenGrace, value)
+	}
+	return grace, nil
+}
+
+// CorrectionAction is what was done to a closed bill.
+type CorrectionAction string
+
+const (
+	// CorrectionReopened put the bill back to OPEN. It is priced and
+	// invoiced again when it next closes.
+	CorrectionReopened CorrectionAction = "REOPENED"
+	// CorrectionAdjustmentIssued opened an adjustment bill against it.
+	CorrectionAdjustmentIssued CorrectionAction = "ADJUSTMENT_ISSUED"
+)
+
+// BillCorrection is the audit record of one correction. PreviousInvoiceNumber
+// and PreviousTotalAmount are what the bill stood at just before it.
+type BillCorrection struct {
+	ID                    int64            `json:"id"`
+	BillID                string           `json:"billId"`
+	Action                CorrectionAction `json:"action"`
+	OperatorID            string           `json:"operatorId"`
+	Reason                string           `json:"reason"`
+	PreviousInvoiceNumber string           `json:"previousInvoiceNumber,omitempty"`
+	PreviousTotalAmount   int64            `json:"previousTotalAmount"`
+	AdjustmentBillID      string           `json:"adjustmentBillId,omitempty"`
+	CreatedAt             time.Time        `json:"createdAt"`
+}
+
+func newBillCorrection(bill *Bill, action CorrectionAction, operator *Operator, reason string, at time.Time) *BillCorrection {
+	return &BillCorrection{
+		BillID:                bill.ID,
+		Action:                action,
+		OperatorID:            operator.ID,
+		Reason:                reason,
+		PreviousInvoiceNumber: bill.InvoiceNumber,
+		PreviousTotalAmount:   bill.TotalAmount,
+		CreatedAt:             at,
+	}
+}
+
+func validateCorrectionReason(reason string) error {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return fmt.Errorf("%w: reason cannot be empty", ErrInvalidCorrectionReason)
+	}
+	if len(reason) > maxCorrectionReasonLength {
+		return fmt.Errorf("%w: reason cannot exceed %d characters", ErrInvalidCorrectionReason, maxCorrectionReasonLength)
+	}
+	return nil
+}
+
+// CheckReopen reports why the bill can't be reopened, if it can't. Only a
+// closed bill with nothing paid against it, closed no earlier than
+// closedAfter, can be; anything else is corrected with an adjustment bill.
+func (b *Bill) CheckReopen(closedAfter time.Time) error {
+	switch {
+	case b.Status == BillStatusVoided:
+		return ErrBillVoided
+	case b.Status != BillStatusClosed:
+		return ErrBillNotClosed
+	case b.PaidAmount != 0:
+		return fmt.Errorf("%w: %d paid", ErrBillHasPayments, b.PaidAmount)
+	case b.ClosedAt == nil || b.ClosedAt.Before(closedAfter):
+		return fmt.Errorf("%w: reopen window has passed, issue an adjustment bill instead", ErrReopenWindowPassed)
+	}
+	return nil
+}
+
+// IsAdjustment reports whether the bill corrects another one.
+func (b *Bill) IsAdjustment() bool {
+	return b.CorrectsBillID != ""
+}
+
+// BillReopen is the outbox payload that starts a reopened bill's new
+// workflow run.
+type BillReopen struct {
+	Bill     Bill
+	Progress BillProgress
+}
+
+// reopenVoidReason is recorded on the items a bill added itself at close
+// when it is reopened. They stay on the bill for its history, and the next
+// close prices the bill again.
+const reopenVoidReason = "superseded when the bill was reopened"
+
+// reopenProgress starts a reopened bill's new run from the items stored on
+// it, as if the run that closed it had continued as new.
+func reopenProgress(items []LineItem) BillProgress {
+	progress := BillProgress{Runs: 1, Reopened: true}
+	for _, item := range items {
+		if item.IsVoided() || item.SystemGenerated {
+			continue
+		}
+		progress.ItemCount++
+		progress.RunningTotal += item.ExtendedAmount()
+	}
+	return progress
+}
+
+type ReopenBillRequest struct {
+	Reason string `json:"reason"`
+}
+
+func (r *ReopenBillRequest) Validate() error {
+	return validateCorrectionReason(r.Reason)
+}
+
+type ReopenBillResponse struct {
+	BillID     string          `json:"billId"`
+	Status     BillStatus      `json:"status"`
+	Correction *BillCorrection `json:"correction"`
+}
+
+type CreateAdjustmentBillRequest struct {
+	Reason string `json:"reason"`
+	// Retries with the same Idempotency-Key return the adjustment bill
+	// created first.
+	IdempotencyKey string `header:"Idempotency-Key"`
+}
+
+func (r *CreateAdjustmentBillRequest) Validate() error {
+	if err := validateCorrectionReason(r.Reason); err != nil {
+		return err
+	}
+	return validateIdempotencyKey(r.IdempotencyKey)
+}
+
+type CreateAdjustmentBillResponse struct {
+	BillID         string          `json:"billId"`
+	CorrectsBillID string          `json:"correctsBillId"`
+	Correction     *BillCorrection `json:"correction"`
+}
+
+type ListBillCorrectionsResponse struct {
+	Corrections []*BillCorrection `json:"corrections"`
+}
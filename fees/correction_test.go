@@ -0,0 +1,89 @@
+package fees
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReopenGracePeriod(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"", DefaultReopenGracePeriod, false},
+		{"72h", 72 * time.Hour, false},
+		{"0", 0, false},
+		{"-1h", 0, true},
+		{"two days", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseReopenGracePeriod(tt.input)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidReopenGrace)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestBill_CheckReopen(t *testing.T) {
+	cutoff := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	after := cutoff.Add(time.Hour)
+	before := cutoff.Add(-time.Hour)
+
+	tests := []struct {
+		name    string
+		bill    Bill
+		wantErr error
+	}{
+		{"closed in window", Bill{Status: BillStatusClosed, ClosedAt: &after}, nil},
+		{"closed at cutoff", Bill{Status: BillStatusClosed, ClosedAt: &cutoff}, nil},
+		{"open", Bill{Status: BillStatusOpen}, ErrBillNotClosed},
+		{"voided", Bill{Status: BillStatusVoided, ClosedAt: &after}, ErrBillVoided},
+		{"paid", Bill{Status: BillStatusClosed, ClosedAt: &after, PaidAmount: 100}, ErrBillHasPayments},
+		{"closed before cutoff", Bill{Status: BillStatusClosed, ClosedAt: &before}, ErrReopenWindowPassed},
+		{"closed before closes were recorded", Bill{Status: BillStatusClosed}, ErrReopenWindowPassed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.bill.CheckReopen(cutoff)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestReopenProgress(t *testing.T) {
+	voidedAt := time.Now()
+	progress := reopenProgress([]LineItem{
+		{ID: "item-1", Amount: 1000, Quantity: 2 * QuantityScale, UnitPrice: 500},
+		{ID: "item-2", Amount: 300},
+		{ID: "item-3", Amount: 999, VoidedAt: &voidedAt},
+		{Description: "Discount", Amount: -100, SystemGenerated: true, Kind: LineItemKindDiscount},
+		{Description: "Standard v1 tier 1", Amount: 250, SystemGenerated: true, Kind: LineItemKindFee,
+			VoidedAt: &voidedAt, VoidReason: reopenVoidReason},
+	})
+
+	assert.Equal(t, BillProgress{Runs: 1, ItemCount: 2, RunningTotal: 1300, Reopened: true}, progress)
+}
+
+func TestCorrectionRequests_Validate(t *testing.T) {
+	assert.NoError(t, (&ReopenBillRequest{Reason: "Usage was still arriving"}).Validate())
+	assert.ErrorIs(t, (&ReopenBillRequest{Reason: "  "}).Validate(), ErrInvalidCorrectionReason)
+	assert.NoError(t, (&CreateAdjustmentBillRequest{Reason: "Refund"}).Validate())
+	assert.ErrorIs(t, (&CreateAdjustmentBillRequest{Reason: strings.Repeat("x", maxCorrectionReasonLength+1)}).Validate(),
+		ErrInvalidCorrectionReason)
+}
@@ -0,0 +1,13 @@
+//go:build !test
+
+package fees
+
+import (
+	"encore.dev/cron"
+)
+
+var _ = cron.NewJob("outbox-relay", cron.JobConfig{
+	Title:    "Deliver pending outbox events to bill workflows",
+	Every:    1 * cron.Minute,
+	Endpoint: RelayOutbox,
+})
@@ -0,0 +1,120 @@
+package fees
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"pave-fees/fees/internal/proration"
+)
+
+// EnabledCurrenciesEnv lists the currencies a deployment accepts, comma
+// separated (e.g. "USD,GEL,EUR,JPY"). Unset means USD and GEL.
+const EnabledCurrenciesEnv = "FEES_ENABLED_CURRENCIES"
+
+// currencyExponents holds the active ISO 4217 codes and the number of digits
+// in their minor unit: JPY has no minor unit, KWD has fils (1/1000).
+var currencyExponents = map[Currency]int{
+	"AED": 2, "AFN": 2, "ALL": 2, "AMD": 2, "ANG": 2, "AOA": 2, "ARS": 2, "AUD": 2,
+	"AWG": 2, "AZN": 2, "BAM": 2, "BBD": 2, "BDT": 2, "BGN": 2, "BHD": 3, "BIF": 0,
+	"BMD": 2, "BND": 2, "BOB": 2, "BRL": 2, "BSD": 2, "BTN": 2, "BWP": 2, "BYN": 2,
+	"BZD": 2, "CAD": 2, "CDF": 2, "CHF": 2, "CLF": 4, "CLP": 0, "CNY": 2, "COP": 2,
+	"CRC": 2, "CUP": 2, "CVE": 2, "CZK": 2, "DJF": 0, "DKK": 2, "DOP": 2, "DZD": 2,
+	"EGP": 2, "ERN": 2, "ETB": 2, "EUR": 2, "FJD": 2, "FKP": 2, "GBP": 2, "GEL": 2,
+	"GHS": 2, "GIP": 2, "GMD": 2, "GNF": 0, "GTQ": 2, "GYD": 2, "HKD": 2, "HNL": 2,
+	"HTG": 2, "HUF": 2, "IDR": 2, "ILS": 2, "INR": 2, "IQD": 3, "IRR": 2, "ISK": 0,
+	"JMD": 2, "JOD": 3, "JPY": 0, "KES": 2, "KGS": 2, "KHR": 2, "KMF": 0, "KPW": 2,
+	"KRW": 0, "KWD": 3, "KYD": 2, "KZT": 2, "LAK": 2, "LBP": 2, "LKR": 2, "LRD": 2,
+	"LSL": 2, "LYD": 3, "MAD": 2, "MDL": 2, "MGA": 2, "MKD": 2, "MMK": 2, "MNT": 2,
+	"MOP": 2, "MRU": 2, "MUR": 2, "MVR": 2, "MWK": 2, "MXN": 2, "MYR": 2, "MZN": 2,
+	"NAD": 2, "NGN": 2, "NIO": 2, "NOK": 2, "NPR": 2, "NZD": 2, "OMR": 3, "PAB": 2,
+	"PEN": 2, "PGK": 2, "PHP": 2, "PKR": 2, "PLN": 2, "PYG": 0, "QAR": 2, "RON": 2,
+	"RSD": 2, "RUB": 2, "RWF": 0, "SAR": 2, "SBD": 2, "SCR": 2, "SDG": 2, "SEK": 2,
+	"SGD": 2, "SHP": 2, "SLE": 2, "SOS": 2, "SRD": 2, "SSP": 2, "STN": 2, "SVC": 2,
+	"SYP": 2, "SZL": 2, "THB": 2, "TJS": 2, "TMT": 2, "TND": 3, "TOP": 2, "TRY": 2,
+	"TTD": 2, "TWD": 2, "TZS": 2, "UAH": 2, "UGX": 0, "USD": 2, "UYI": 0, "UYU": 2,
+	"UYW": 4, "UZS": 2, "VES": 2, "VND": 0, "VUV": 0, "WST": 2, "XAF": 0, "XCD": 2,
+	"XOF": 0, "XPF": 0, "YER": 2, "ZAR": 2, "ZMW": 2, "ZWG": 2,
+}
+
+// currencyRounding lists currencies whose prorated amounts aren't rounded
+// half up to the minor unit.
+var currencyRounding = map[Currency]proration.Rounding{
+	// Swiss invoices are settled in steps of 5 centimes.
+	"CHF": {Mode: proration.HalfUp, Increment: 5},
+}
+
+// ProrationRounding is how prorated amounts in c are rounded.
+func (c Currency) ProrationRounding() proration.Rounding {
+	if rounding, ok := currencyRounding[c]; ok {
+		return rounding
+	}
+	return proration.DefaultRounding
+}
+
+var (
+	enabledMu         sync.RWMutex
+	enabledCurrencies = []Currency{USD, GEL}
+)
+
+// EnabledCurrencies returns the currencies this deployment accepts, in the
+// order they were configured.
+func EnabledCurrencies() []Currency {
+	enabledMu.RLock()
+	defer enabledMu.RUnlock()
+	return append([]Currency(nil), enabledCurrencies...)
+}
+
+// SetEnabledCurrencies replaces the enabled set. Every code must be in the
+// ISO 4217 registry.
+func SetEnabledCurrencies(currencies []Currency) error {
+	if len(currencies) == 0 {
+		return fmt.Errorf("%w: at least one currency must be enabled", ErrInvalidCurrency)
+	}
+	seen := make(map[Currency]bool, len(currencies))
+	for _, c := range currencies {
+		if _, ok := currencyExponents[c]; !ok {
+			return fmt.Errorf("%w: %q is not an ISO 4217 code", ErrInvalidCurrency, c)
+		}
+		if seen[c] {
+			return fmt.Errorf("%w: %s is listed twice", ErrInvalidCurrency, c)
+		}
+		seen[c] = true
+	}
+
+	enabledMu.Lock()
+	defer enabledMu.Unlock()
+	enabledCurrencies = append([]Currency(nil), currencies...)
+	return nil
+}
+
+// ParseCurrencyList parses a comma separated list of currency codes,
+// ignoring case and surrounding whitespace.
+func ParseCurrencyList(s string) ([]Currency, error) {
+	var currencies []Currency
+	for _, code := range strings.Split(s, ",") {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if code == "" {
+			continue
+		}
+		currencies = append(currencies, Currency(code))
+	}
+	if len(currencies) == 0 {
+		return nil, fmt.Errorf("%w: no currencies in %q", ErrInvalidCurrency, s)
+	}
+	return currencies, nil
+}
+
+// configureCurrenciesFromEnv applies EnabledCurrenciesEnv if it is set.
+func configureCurrenciesFromEnv() error {
+	value := os.Getenv(EnabledCurrenciesEnv)
+	if value == "" {
+		return nil
+	}
+	currencies, err := ParseCurrencyList(value)
+	if err != nil {
+		return err
+	}
+	return SetEnabledCurrencies(currencies)
+}
@@ -0,0 +1,145 @@
+package fees
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withEnabledCurrencies swaps the enabled set for the duration of a test.
+func withEnabledCurrencies(t *testing.T, currencies ...Currency) {
+	t.Helper()
+	previous := EnabledCurrencies()
+	require.NoError(t, SetEnabledCurrencies(currencies))
+	t.Cleanup(func() {
+		require.NoError(t, SetEnabledCurrencies(previous))
+	})
+}
+
+func TestCurrency_Exponent(t *testing.T) {
+	tests := []struct {
+		currency Currency
+		want     int
+	}{
+		{USD, 2},
+		{GEL, 2},
+		{"JPY", 0},
+		{"KRW", 0},
+		{"KWD", 3},
+		{"BHD", 3},
+		{"CLF", 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.currency), func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.currency.Exponent())
+		})
+	}
+}
+
+func TestCurrency_FormatAmount(t *testing.T) {
+	tests := []struct {
+		currency Currency
+		amount   int64
+		want     string
+	}{
+		{USD, 123456, "1234.56"},
+		{USD, 5, "0.05"},
+		{USD, 0, "0.00"},
+		{USD, -1500, "-15.00"},
+		{"JPY", 123456, "123456"},
+		{"JPY", -42, "-42"},
+		{"KWD", 123456, "123.456"},
+		{"KWD", 7, "0.007"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.currency.FormatAmount(tt.amount))
+		})
+	}
+}
+
+func TestCurrency_ParseAmount(t *testing.T) {
+	tests := []struct {
+		currency Currency
+		input    string
+		want     int64
+		wantErr  bool
+	}{
+		{USD, "12.50", 1250, false},
+		{USD, "12.5", 1250, false},
+		{USD, "12", 1200, false},
+		{USD, "-0.01", -1, false},
+		{USD, "12.505", 0, true},
+		{"JPY", "500", 500, false},
+		{"JPY", "500.5", 0, true},
+		{"KWD", "1.234", 1234, false},
+		{USD, "", 0, true},
+		{USD, "12.", 0, true},
+		{USD, "abc", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.currency)+" "+tt.input, func(t *testing.T) {
+			got, err := tt.currency.ParseAmount(tt.input)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidAmount)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSetEnabledCurrencies(t *testing.T) {
+	t.Run("enables configured currencies", func(t *testing.T) {
+		withEnabledCurrencies(t, USD, "EUR", "JPY")
+
+		assert.True(t, Currency("EUR").IsValid())
+		assert.True(t, Currency("JPY").IsValid())
+		assert.False(t, GEL.IsValid())
+	})
+
+	t.Run("validation error lists enabled set", func(t *testing.T) {
+		withEnabledCurrencies(t, "EUR", "JPY")
+
+		err := GEL.Validate()
+		assert.ErrorIs(t, err, ErrInvalidCurrency)
+		assert.Contains(t, err.Error(), "Supported currencies: EUR, JPY")
+	})
+
+	t.Run("rejects unknown codes", func(t *testing.T) {
+		assert.ErrorIs(t, SetEnabledCurrencies([]Currency{USD, "XYZ"}), ErrInvalidCurrency)
+	})
+
+	t.Run("rejects duplicates", func(t *testing.T) {
+		assert.ErrorIs(t, SetEnabledCurrencies([]Currency{USD, USD}), ErrInvalidCurrency)
+	})
+
+	t.Run("rejects empty set", func(t *testing.T) {
+		assert.ErrorIs(t, SetEnabledCurrencies(nil), ErrInvalidCurrency)
+	})
+}
+
+func TestParseCurrencyList(t *testing.T) {
+	currencies, err := ParseCurrencyList(" usd, GEL ,,jpy ")
+	require.NoError(t, err)
+	assert.Equal(t, []Currency{USD, GEL, "JPY"}, currencies)
+
+	_, err = ParseCurrencyList(" , ")
+	assert.ErrorIs(t, err, ErrInvalidCurrency)
+}
+
+func TestConfigureCurrenciesFromEnv(t *testing.T) {
+	withEnabledCurrencies(t, USD, GEL)
+
+	t.Setenv(EnabledCurrenciesEnv, "EUR,KWD")
+	require.NoError(t, configureCurrenciesFromEnv())
+	assert.Equal(t, []Currency{"EUR", "KWD"}, EnabledCurrencies())
+
+	t.Setenv(EnabledCurrenciesEnv, "EUR,NOPE")
+	assert.ErrorIs(t, configureCurrenciesFromEnv(), ErrInvalidCurrency)
+}
@@ -0,0 +1,238 @@
+package fees
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrPromoCodeNotFound      = errors.New("promo code not found")
+	ErrInvalidPromoCode       = errors.New("invalid promo code")
+	ErrPromoCodeExists        = errors.New("promo code already exists")
+	ErrPromoCodeInactive      = errors.New("promo code is not valid at this time")
+	ErrPromoCodeUsageExceeded = errors.New("promo code usage limit reached for customer")
+	ErrDiscountAlreadyApplied = errors.New("promo code is already on the bill")
+)
+
+type DiscountKind string
+
+const (
+	// Percentage discounts take PercentBps basis points off the bill.
+	DiscountKindPercentage DiscountKind = "PERCENTAGE"
+	// Fixed discounts take Amount minor units off a bill in Currency.
+	DiscountKindFixed DiscountKind = "FIXED"
+)
+
+func (k DiscountKind) IsValid() bool {
+	return k == DiscountKindPercentage || k == DiscountKindFixed
+}
+
+// NormalizePromoCode makes codes case-insensitive: " spring10 " is SPRING10.
+func NormalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// PromoCode defines a discount customers can attach to their bills. It can
+// only be attached between ValidFrom and ValidUntil, and at most
+// MaxUsesPerCustomer times per customer; zero means no limit.
+type PromoCode struct {
+	Code               string       `json:"code"`
+	Description        string       `json:"description"`
+	Kind               DiscountKind `json:"kind"`
+	PercentBps         int64        `json:"percentBps,omitempty"`
+	Amount             int64        `json:"amount,omitempty"`
+	Currency           Currency     `json:"currency,omitempty"`
+	ValidFrom          *time.Time   `json:"validFrom,omitempty"`
+	ValidUntil         *time.Time   `json:"validUntil,omitempty"`
+	MaxUsesPerCustomer int          `json:"maxUsesPerCustomer,omitempty"`
+	CreatedAt          time.Time    `json:"createdAt"`
+}
+
+func (p *PromoCode) Validate() error {
+	if p.Code == "" {
+		return fmt.Errorf("%w: code cannot be empty", ErrInvalidPromoCode)
+	}
+	if strings.TrimSpace(p.Description) == "" {
+		return ErrEmptyDescription
+	}
+	switch p.Kind {
+	case DiscountKindPercentage:
+		if p.PercentBps <= 0 || p.PercentBps > basisPointsPerUnit {
+			return fmt.Errorf("%w: percentBps must be between 1 and %d", ErrInvalidPromoCode, basisPointsPerUnit)
+		}
+		if p.Amount != 0 || p.Currency != "" {
+			return fmt.Errorf("%w: amount and currency only apply to fixed discounts", ErrInvalidPromoCode)
+		}
+	case DiscountKindFixed:
+		if p.Amount <= 0 {
+			return ErrInvalidAmount
+		}
+		if err := p.Currency.Validate(); err != nil {
+			return err
+		}
+		if p.PercentBps != 0 {
+			return fmt.Errorf("%w: percentBps only applies to percentage discounts", ErrInvalidPromoCode)
+		}
+	default:
+		return fmt.Errorf("%w: unknown kind %q. Supported kinds: PERCENTAGE, FIXED", ErrInvalidPromoCode, p.Kind)
+	}
+	if p.ValidFrom != nil && p.ValidUntil != nil && !p.ValidUntil.After(*p.ValidFrom) {
+		return fmt.Errorf("%w: validUntil must be after validFrom", ErrInvalidPromoCode)
+	}
+	if p.MaxUsesPerCustomer < 0 {
+		return fmt.Errorf("%w: maxUsesPerCustomer cannot be negative", ErrInvalidPromoCode)
+	}
+	return nil
+}
+
+// CheckApplicable reports why the code can't go on a bill in currency at
+// the given time, if it can't. Per-customer limits are enforced when the
+// discount is stored.
+func (p *PromoCode) CheckApplicable(currency Currency, at time.Time) error {
+	if p.ValidFrom != nil && at.Before(*p.ValidFrom) {
+		return fmt.Errorf("%w: %s starts at %s", ErrPromoCodeInactive, p.Code, p.ValidFrom.Format(time.RFC3339))
+	}
+	if p.ValidUntil != nil && !at.Before(*p.ValidUntil) {
+		return fmt.Errorf("%w: %s expired at %s", ErrPromoCodeInactive, p.Code, p.ValidUntil.Format(time.RFC3339))
+	}
+	if p.Kind == DiscountKindFixed && p.Currency != currency {
+		return fmt.Errorf("%w: %s is a discount in %s, bill is %s", ErrInvalidPromoCode, p.Code, p.Currency, currency)
+	}
+	return nil
+}
+
+// BillDiscount is a promo code attached to a bill. The code's terms are
+// copied when it is attached; AppliedAmount is what it took off the bill
+// and is only set once the bill has closed.
+type BillDiscount struct {
+	Code          string       `json:"code"`
+	Description   string       `json:"description"`
+	Kind          DiscountKind `json:"kind"`
+	PercentBps    int64        `json:"percentBps,omitempty"`
+	Amount        int64        `json:"amount,omitempty"`
+	AttachedAt    time.Time    `json:"attachedAt"`
+	AppliedAmount *int64       `json:"appliedAmount,omitempty"`
+}
+
+func newBillDiscount(promo *PromoCode, attachedAt time.Time) BillDiscount {
+	return BillDiscount{
+		Code:        promo.Code,
+		Description: promo.Description,
+		Kind:        promo.Kind,
+		PercentBps:  promo.PercentBps,
+		Amount:      promo.Amount,
+		AttachedAt:  attachedAt,
+	}
+}
+
+// BillDiscounts is the outcome of applying a bill's discounts to its
+// pre-tax total. Items are the DISCOUNT line items to store, one per
+// discount that took anything off; Total is Amount - DiscountTotal.
+type BillDiscounts struct {
+	Amount        int64
+	DiscountTotal int64
+	Total         int64
+	Items         []LineItem
+	Applied       []BillDiscount
+}
+
+// ApplyDiscounts takes discounts off amount, the bill total after credits
+// and fees, in the order they were attached (ties broken by code). Each
+// percentage applies to what is left after the discounts before it, and no
+// discount takes the total below floor: zero, or the bill's minimum charge.
+// Percentages round half up.
+func ApplyDiscounts(discounts []BillDiscount, amount, floor int64, at time.Time) BillDiscounts {
+	result := BillDiscounts{Amount: amount, Total: amount}
+
+	ordered := append([]BillDiscount(nil), discounts...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if !ordered[i].AttachedAt.Equal(ordered[j].AttachedAt) {
+			return ordered[i].AttachedAt.Before(ordered[j].AttachedAt)
+		}
+		return ordered[i].Code < ordered[j].Code
+	})
+
+	for _, discount := range ordered {
+		var off int64
+		if room := result.Total - floor; room > 0 {
+			switch discount.Kind {
+			case DiscountKindPercentage:
+				off = (result.Total*discount.PercentBps + basisPointsPerUnit/2) / basisPointsPerUnit
+			case DiscountKindFixed:
+				off = discount.Amount
+			}
+			if off > room {
+				off = room
+			}
+		}
+
+		applied := off
+		discount.AppliedAmount = &applied
+		result.Applied = append(result.Applied, discount)
+		if off == 0 {
+			continue
+		}
+
+		result.Items = append(result.Items, LineItem{
+			Description:     discount.lineItemDescription(),
+			Amount:          -off,
+			Quantity:        QuantityOne,
+			UnitPrice:       -off,
+			Timestamp:       at,
+			Kind:            LineItemKindDiscount,
+			SystemGenerated: true,
+		})
+		result.DiscountTotal += off
+		result.Total -= off
+	}
+	return result
+}
+
+func (d *BillDiscount) lineItemDescription() string {
+	if d.Kind == DiscountKindPercentage {
+		return fmt.Sprintf("Discount %s (%s%%)", d.Code, formatFixedPoint(d.PercentBps, 2, false))
+	}
+	return fmt.Sprintf("Discount %s", d.Code)
+}
+
+type CreatePromoCodeRequest struct {
+	Code               string       `json:"code"`
+	Description        string       `json:"description"`
+	Kind               DiscountKind `json:"kind"`
+	PercentBps         int64        `json:"percentBps,omitempty"`
+	Amount             int64        `json:"amount,omitempty"`
+	Currency           Currency     `json:"currency,omitempty"`
+	ValidFrom          *time.Time   `json:"validFrom,omitempty"`
+	ValidUntil         *time.Time   `json:"validUntil,omitempty"`
+	MaxUsesPerCustomer int          `json:"maxUsesPerCustomer,omitempty"`
+}
+
+func (r *CreatePromoCodeRequest) Validate() error {
+	return r.toPromoCode().Validate()
+}
+
+func (r *CreatePromoCodeRequest) toPromoCode() *PromoCode {
+	return &PromoCode{
+		Code:               NormalizePromoCode(r.Code),
+		Description:        r.Description,
+		Kind:               r.Kind,
+		PercentBps:         r.PercentBps,
+		Amount:             r.Amount,
+		Currency:           r.Currency,
+		ValidFrom:          r.ValidFrom,
+		ValidUntil:         r.ValidUntil,
+		MaxUsesPerCustomer: r.MaxUsesPerCustomer,
+	}
+}
+
+type ApplyDiscountRequest struct {
+	Code string `json:"code"`
+}
+
+type ApplyDiscountResponse struct {
+	BillID   string       `json:"billId"`
+	Discount BillDiscount `json:"discount"`
+}
@@ -0,0 +1,160 @@
+package fees
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromoCode_Validate(t *testing.T) {
+	percent := PromoCode{Code: "SPRING10", Description: "Spring sale", Kind: DiscountKindPercentage, PercentBps: 1000}
+	fixed := PromoCode{Code: "WELCOME", Description: "Welcome credit", Kind: DiscountKindFixed, Amount: 500, Currency: USD}
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	until := from.AddDate(0, 1, 0)
+
+	tests := []struct {
+		name    string
+		base    PromoCode
+		modify  func(*PromoCode)
+		wantErr error
+	}{
+		{"valid percentage", percent, func(p *PromoCode) {}, nil},
+		{"valid fixed", fixed, func(p *PromoCode) {}, nil},
+		{"valid window", percent, func(p *PromoCode) { p.ValidFrom, p.ValidUntil = &from, &until }, nil},
+		{"missing code", percent, func(p *PromoCode) { p.Code = "" }, ErrInvalidPromoCode},
+		{"missing description", percent, func(p *PromoCode) { p.Description = " " }, ErrEmptyDescription},
+		{"unknown kind", percent, func(p *PromoCode) { p.Kind = "BOGO" }, ErrInvalidPromoCode},
+		{"zero percent", percent, func(p *PromoCode) { p.PercentBps = 0 }, ErrInvalidPromoCode},
+		{"over 100 percent", percent, func(p *PromoCode) { p.PercentBps = 10001 }, ErrInvalidPromoCode},
+		{"percentage with amount", percent, func(p *PromoCode) { p.Amount = 100 }, ErrInvalidPromoCode},
+		{"fixed without amount", fixed, func(p *PromoCode) { p.Amount = 0 }, ErrInvalidAmount},
+		{"fixed without currency", fixed, func(p *PromoCode) { p.Currency = "" }, ErrInvalidCurrency},
+		{"fixed with percent", fixed, func(p *PromoCode) { p.PercentBps = 100 }, ErrInvalidPromoCode},
+		{"window ends before it starts", percent, func(p *PromoCode) { p.ValidFrom, p.ValidUntil = &until, &from }, ErrInvalidPromoCode},
+		{"negative usage limit", percent, func(p *PromoCode) { p.MaxUsesPerCustomer = -1 }, ErrInvalidPromoCode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			promo := tt.base
+			tt.modify(&promo)
+			err := promo.Validate()
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestPromoCode_CheckApplicable(t *testing.T) {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	until := from.AddDate(0, 1, 0)
+	promo := PromoCode{Code: "WELCOME", Kind: DiscountKindFixed, Amount: 500, Currency: USD, ValidFrom: &from, ValidUntil: &until}
+
+	assert.NoError(t, promo.CheckApplicable(USD, from))
+	assert.NoError(t, promo.CheckApplicable(USD, until.Add(-time.Second)))
+	assert.ErrorIs(t, promo.CheckApplicable(USD, from.Add(-time.Second)), ErrPromoCodeInactive)
+	assert.ErrorIs(t, promo.CheckApplicable(USD, until), ErrPromoCodeInactive)
+	assert.ErrorIs(t, promo.CheckApplicable(GEL, from), ErrInvalidPromoCode)
+
+	percent := PromoCode{Code: "SPRING10", Kind: DiscountKindPercentage, PercentBps: 1000}
+	assert.NoError(t, percent.CheckApplicable(GEL, from))
+}
+
+func TestApplyDiscounts(t *testing.T) {
+	at := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+	first := at.Add(-2 * time.Hour)
+	second := at.Add(-time.Hour)
+
+	t.Run("in attach order", func(t *testing.T) {
+		discounts := []BillDiscount{
+			{Code: "SPRING10", Kind: DiscountKindPercentage, PercentBps: 1000, AttachedAt: second},
+			{Code: "WELCOME", Kind: DiscountKindFixed, Amount: 2000, AttachedAt: first},
+		}
+
+		result := ApplyDiscounts(discounts, 10000, 0, at)
+
+		// 10000 - 2000 fixed, then 10% of the remaining 8000.
+		assert.Equal(t, int64(2800), result.DiscountTotal)
+		assert.Equal(t, int64(7200), result.Total)
+		require.Len(t, result.Applied, 2)
+		assert.Equal(t, "WELCOME", result.Applied[0].Code)
+		assert.Equal(t, int64(2000), *result.Applied[0].AppliedAmount)
+		assert.Equal(t, "SPRING10", result.Applied[1].Code)
+		assert.Equal(t, int64(800), *result.Applied[1].AppliedAmount)
+
+		require.Len(t, result.Items, 2)
+		assert.Equal(t, "Discount WELCOME", result.Items[0].Description)
+		assert.Equal(t, "Discount SPRING10 (10%)", result.Items[1].Description)
+		for _, item := range result.Items {
+			assert.Equal(t, LineItemKindDiscount, item.Kind)
+			assert.Equal(t, at, item.Timestamp)
+			assert.NoError(t, item.Validate())
+		}
+		assert.Equal(t, int64(-800), result.Items[1].Amount)
+	})
+
+	t.Run("ties broken by code", func(t *testing.T) {
+		discounts := []BillDiscount{
+			{Code: "B", Kind: DiscountKindPercentage, PercentBps: 5000, AttachedAt: first},
+			{Code: "A", Kind: DiscountKindFixed, Amount: 1000, AttachedAt: first},
+		}
+
+		result := ApplyDiscounts(discounts, 3000, 0, at)
+
+		assert.Equal(t, "A", result.Applied[0].Code)
+		assert.Equal(t, int64(1000), result.Total)
+	})
+
+	t.Run("never below zero", func(t *testing.T) {
+		discounts := []BillDiscount{
+			{Code: "BIG", Kind: DiscountKindFixed, Amount: 5000, AttachedAt: first},
+			{Code: "HALF", Kind: DiscountKindPercentage, PercentBps: 5000, AttachedAt: second},
+		}
+
+		result := ApplyDiscounts(discounts, 3000, 0, at)
+
+		assert.Equal(t, int64(0), result.Total)
+		assert.Equal(t, int64(3000), result.DiscountTotal)
+		require.Len(t, result.Items, 1)
+		require.Len(t, result.Applied, 2)
+		assert.Equal(t, int64(0), *result.Applied[1].AppliedAmount)
+	})
+
+	t.Run("never below the minimum charge", func(t *testing.T) {
+		discounts := []BillDiscount{
+			{Code: "SPRING10", Kind: DiscountKindPercentage, PercentBps: 1000, AttachedAt: first},
+			{Code: "WELCOME", Kind: DiscountKindFixed, Amount: 500, AttachedAt: second},
+		}
+
+		// A bill trued up to its 5000 minimum keeps it; one above it only
+		// comes down to it.
+		result := ApplyDiscounts(discounts, 5000, 5000, at)
+		assert.Equal(t, int64(5000), result.Total)
+		assert.Empty(t, result.Items)
+
+		result = ApplyDiscounts(discounts, 5400, 5000, at)
+		assert.Equal(t, int64(5000), result.Total)
+		assert.Equal(t, int64(400), *result.Applied[0].AppliedAmount)
+		assert.Equal(t, int64(0), *result.Applied[1].AppliedAmount)
+	})
+
+	t.Run("rounds half up", func(t *testing.T) {
+		discounts := []BillDiscount{{Code: "P", Kind: DiscountKindPercentage, PercentBps: 1250, AttachedAt: first}}
+
+		result := ApplyDiscounts(discounts, 1004, 0, at)
+
+		// 12.5% of 1004 is 125.5.
+		assert.Equal(t, int64(126), result.DiscountTotal)
+		assert.Equal(t, "Discount P (12.5%)", result.Items[0].Description)
+	})
+
+	t.Run("nothing to discount", func(t *testing.T) {
+		result := ApplyDiscounts(nil, 1000, 0, at)
+		assert.Equal(t, BillDiscounts{Amount: 1000, Total: 1000}, result)
+	})
+}
@@ -0,0 +1,182 @@
+package fees
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidPaymentTerms  = errors.New("invalid payment terms")
+	ErrInvalidDunningPolicy = errors.New("invalid dunning policy")
+)
+
+// DunningPolicyFileEnv names a JSON file with the DunningPolicy that bills
+// are chased with. Without it they get DefaultDunningPolicy.
+const DunningPolicyFileEnv = "FEES_DUNNING_POLICY_FILE"
+
+// DefaultPaymentTermsDays is how long customers have to pay a bill once it
+// closes, unless the bill sets its own terms.
+const DefaultPaymentTermsDays = 30
+
+const maxPaymentTermsDays = 365
+
+// DunningStatus tracks how far chasing a closed bill has gone.
+type DunningStatus string
+
+const (
+	// Scheduled bills are closed and not yet past their due date.
+	DunningStatusScheduled DunningStatus = "SCHEDULED"
+	// Overdue bills are past due and being sent reminders.
+	DunningStatusOverdue DunningStatus = "OVERDUE"
+	// Stopped bills were paid in full and are no longer chased.
+	DunningStatusStopped DunningStatus = "STOPPED"
+	// Written off bills went unpaid through every reminder and are no longer
+	// expected to be paid. Payments can still be recorded against them.
+	DunningStatusWrittenOff DunningStatus = "WRITTEN_OFF"
+)
+
+// DunningPolicy is when a closed bill is chased: one reminder for each entry
+// in ReminderDays, counted in days after the due date, and a write-off
+// WriteOffAfterDays after the due date. Zero WriteOffAfterDays never writes
+// the bill off.
+type DunningPolicy struct {
+	ReminderDays      []int `json:"reminderDays"`
+	WriteOffAfterDays int   `json:"writeOffAfterDays,omitempty"`
+}
+
+var DefaultDunningPolicy = DunningPolicy{
+	ReminderDays:      []int{1, 7, 14, 30},
+	WriteOffAfterDays: 60,
+}
+
+var (
+	dunningPolicyMu sync.RWMutex
+	dunningPolicy   = DefaultDunningPolicy
+)
+
+// CurrentDunningPolicy is the policy newly closed bills are chased with.
+// Bills already in dunning keep the one they started with.
+func CurrentDunningPolicy() DunningPolicy {
+	dunningPolicyMu.RLock()
+	defer dunningPolicyMu.RUnlock()
+	return dunningPolicy
+}
+
+// SetDunningPolicy replaces the policy newly closed bills are chased with.
+func SetDunningPolicy(policy DunningPolicy) error {
+	if err := policy.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidDunningPolicy, err)
+	}
+	dunningPolicyMu.Lock()
+	defer dunningPolicyMu.Unlock()
+	dunningPolicy = DunningPolicy{
+		ReminderDays:      append([]int(nil), policy.ReminderDays...),
+		WriteOffAfterDays: policy.WriteOffAfterDays,
+	}
+	return nil
+}
+
+// configureDunningPolicyFromEnv applies DunningPolicyFileEnv if it is set.
+func configureDunningPolicyFromEnv() error {
+	path := os.Getenv(DunningPolicyFileEnv)
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read dunning policy file: %w", err)
+	}
+	var policy DunningPolicy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidDunningPolicy, err)
+	}
+	return SetDunningPolicy(policy)
+}
+
+func (p DunningPolicy) Validate() error {
+	last := 0
+	for _, days := range p.ReminderDays {
+		if days <= last {
+			return fmt.Errorf("reminder days must be positive and increasing, got %v", p.ReminderDays)
+		}
+		last = days
+	}
+	if p.WriteOffAfterDays != 0 && p.WriteOffAfterDays <= last {
+		return fmt.Errorf("write-off after %d days comes before the last reminder on day %d", p.WriteOffAfterDays, last)
+	}
+	return nil
+}
+
+// ValidatePaymentTerms accepts net terms of 0 (due on close) to 365 days.
+func ValidatePaymentTerms(days int) error {
+	if days < 0 || days > maxPaymentTermsDays {
+		return fmt.Errorf("%w: paymentTermsDays must be between 0 and %d", ErrInvalidPaymentTerms, maxPaymentTermsDays)
+	}
+	return nil
+}
+
+// DueDate is when a bill closed at closedAt has to be paid by. Bills from
+// before payment terms existed get DefaultPaymentTermsDays.
+func (b *Bill) DueDate(closedAt time.Time) time.Time {
+	days := DefaultPaymentTermsDays
+	if b.PaymentTermsDays != nil {
+		days = *b.PaymentTermsDays
+	}
+	return closedAt.AddDate(0, 0, days)
+}
+
+type NotificationKind string
+
+const (
+	NotificationPaymentReminder NotificationKind = "PAYMENT_REMINDER"
+	NotificationBillWrittenOff  NotificationKind = "BILL_WRITTEN_OFF"
+)
+
+// Notification tells a customer about an unpaid bill. Stage counts the
+// reminders sent so far, this one included; later stages are more urgent.
+type Notification struct {
+	Kind        NotificationKind
+	BillID      string
+	CustomerID  string
+	Currency    Currency
+	Balance     int64
+	DueAt       time.Time
+	DaysOverdue int
+	Stage       int
+	Final       bool
+}
+
+// LogNotifier only logs notifications, for local development and tests.
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(ctx context.Context, n Notification) error {
+	slog.Info("dunning notification",
+		"kind", n.Kind,
+		"bill_id", n.BillID,
+		"customer_id", n.CustomerID,
+		"balance", fmt.Sprintf("%s %s", n.Currency, n.Currency.FormatAmount(n.Balance)),
+		"due_at", n.DueAt,
+		"days_overdue", n.DaysOverdue,
+		"stage", n.Stage,
+		"final", n.Final)
+	return nil
+}
+
+// PaymentRecorded is sent to a bill's dunning workflow whenever a payment
+// is recorded against it.
+type PaymentRecorded struct {
+	PaymentID     string        `json:"paymentId"`
+	PaidAmount    int64         `json:"paidAmount"`
+	Balance       int64         `json:"balance"`
+	PaymentStatus PaymentStatus `json:"paymentStatus"`
+}
+
+func DunningWorkflowID(billID string) string {
+	return "dunning-" + billID
+}
@@ -0,0 +1,89 @@
+package fees
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func TestBill_DueDate(t *testing.T) {
+	closedAt := time.Date(2026, 1, 31, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		terms *int
+		want  time.Time
+	}{
+		{"default terms", nil, time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)},
+		{"net 14", intPtr(14), time.Date(2026, 2, 14, 12, 0, 0, 0, time.UTC)},
+		{"due on close", intPtr(0), closedAt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bill := &Bill{PaymentTermsDays: tt.terms}
+			assert.Equal(t, tt.want, bill.DueDate(closedAt))
+		})
+	}
+}
+
+func TestValidatePaymentTerms(t *testing.T) {
+	assert.NoError(t, ValidatePaymentTerms(0))
+	assert.NoError(t, ValidatePaymentTerms(365))
+	assert.ErrorIs(t, ValidatePaymentTerms(-1), ErrInvalidPaymentTerms)
+	assert.ErrorIs(t, ValidatePaymentTerms(366), ErrInvalidPaymentTerms)
+}
+
+func TestDunningPolicy_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  DunningPolicy
+		wantErr bool
+	}{
+		{"default", DefaultDunningPolicy, false},
+		{"no reminders", DunningPolicy{WriteOffAfterDays: 30}, false},
+		{"never written off", DunningPolicy{ReminderDays: []int{1, 7}}, false},
+		{"reminder on due date", DunningPolicy{ReminderDays: []int{0, 7}}, true},
+		{"out of order", DunningPolicy{ReminderDays: []int{7, 1}}, true},
+		{"write-off before last reminder", DunningPolicy{ReminderDays: []int{1, 30}, WriteOffAfterDays: 14}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestConfigureDunningPolicyFromEnv(t *testing.T) {
+	t.Cleanup(func() { require.NoError(t, SetDunningPolicy(DefaultDunningPolicy)) })
+	path := filepath.Join(t.TempDir(), "dunning.json")
+	t.Setenv(DunningPolicyFileEnv, path)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"reminderDays": [3, 10], "writeOffAfterDays": 45}`), 0o600))
+	require.NoError(t, configureDunningPolicyFromEnv())
+	assert.Equal(t, DunningPolicy{ReminderDays: []int{3, 10}, WriteOffAfterDays: 45}, CurrentDunningPolicy())
+
+	// A bad file leaves the policy as it was.
+	require.NoError(t, os.WriteFile(path, []byte(`{"reminderDays": [10, 3]}`), 0o600))
+	assert.ErrorIs(t, configureDunningPolicyFromEnv(), ErrInvalidDunningPolicy)
+	require.NoError(t, os.WriteFile(path, []byte(`{"reminderDays": [`), 0o600))
+	assert.ErrorIs(t, configureDunningPolicyFromEnv(), ErrInvalidDunningPolicy)
+	assert.Equal(t, []int{3, 10}, CurrentDunningPolicy().ReminderDays)
+
+	t.Setenv(DunningPolicyFileEnv, "")
+	require.NoError(t, configureDunningPolicyFromEnv())
+}
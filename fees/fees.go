@@ -2,10 +2,17 @@ package fees
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
+	"os"
 	"sync"
 
+	"encore.dev"
+	"encore.dev/beta/auth"
+	"encore.dev/beta/errs"
+
 	"pave-fees/fees/internal/temporal"
 )
 
@@ -16,6 +23,13 @@ var (
 )
 
 func initService() (*BillService, error) {
+	if err := configureCurrenciesFromEnv(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", EnabledCurrenciesEnv, err)
+	}
+	if err := configureDunningPolicyFromEnv(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", DunningPolicyFileEnv, err)
+	}
+
 	tc, err := temporal.NewClient(temporal.ClientOptions{
 		Target:    "127.0.0.1:7233",
 		Namespace: "default",
@@ -24,18 +38,54 @@ func initService() (*BillService, error) {
 		return nil, fmt.Errorf("failed to create temporal client: %w", err)
 	}
 
+	var opts []ServiceOption
+	if path := os.Getenv(FXRatesFileEnv); path != "" {
+		opts = append(opts, WithRateProvider(NewFileRateProvider(path)))
+	}
+	if path := os.Getenv(InvoiceBrandingFileEnv); path != "" {
+		renderer, err := LoadInvoiceRenderer(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", InvoiceBrandingFileEnv, err)
+		}
+		opts = append(opts, WithInvoiceRenderer(renderer))
+	}
+	if path := os.Getenv(OperatorsFileEnv); path != "" {
+		operators, err := LoadOperators(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", OperatorsFileEnv, err)
+		}
+		opts = append(opts, WithOperators(operators))
+	}
+	grace, err := ParseReopenGracePeriod(os.Getenv(ReopenGracePeriodEnv))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", ReopenGracePeriodEnv, err)
+	}
+	opts = append(opts, WithReopenGracePeriod(grace))
+
 	repo := NewRepository(getDB())
-	activities := NewActivities(repo)
+	service := NewBillService(repo, tc, opts...)
+	activities := NewActivities(repo, WithBiller(service))
 
 	tc.RegisterWorkflow(BillWorkflow)
+	tc.RegisterWorkflow(DunningWorkflow)
+	tc.RegisterWorkflow(SubscriptionWorkflow)
+	tc.RegisterActivity(activities.PriceUsageActivity)
 	tc.RegisterActivity(activities.CalculateTotalActivity)
+	tc.RegisterActivity(activities.AttachDiscountActivity)
+	tc.RegisterActivity(activities.ApplyDiscountsActivity)
+	tc.RegisterActivity(activities.CalculateTaxActivity)
 	tc.RegisterActivity(activities.SaveFinalBillActivity)
+	tc.RegisterActivity(activities.VoidBillActivity)
+	tc.RegisterActivity(activities.SettleOutboxActivity)
+	tc.RegisterActivity(activities.SendDunningReminderActivity)
+	tc.RegisterActivity(activities.WriteOffBillActivity)
+	tc.RegisterActivity(activities.StopDunningActivity)
+	tc.RegisterActivity(activities.RenewSubscriptionActivity)
 
 	if err := tc.StartWorker(); err != nil {
 		return nil, fmt.Errorf("failed to start temporal worker: %w", err)
 	}
 
-	service := NewBillService(repo, tc)
 	slog.Info("Fees service initialized successfully")
 
 	return service, nil
@@ -62,23 +112,99 @@ func CreateBill(ctx context.Context, req *CreateBillRequest) (*CreateBillRespons
 }
 
 //encore:api public method=POST path=/bills/:billID/items
-func AddLineItem(ctx context.Context, billID string, req *AddLineItemRequest) error {
+func AddLineItem(ctx context.Context, billID string, req *AddLineItemRequest) (*AddLineItemResponse, error) {
 	service, err := getService()
 	if err != nil {
-		return fmt.Errorf("service initialization failed: %w", err)
+		return nil, fmt.Errorf("service initialization failed: %w", err)
 	}
 	return service.AddLineItem(ctx, billID, req)
 }
 
+type VoidLineItemParams struct {
+	Reason string `query:"reason"`
+}
+
+//encore:api public method=DELETE path=/bills/:billID/items/:itemID
+func VoidLineItem(ctx context.Context, billID string, itemID string, params VoidLineItemParams) (*VoidLineItemResponse, error) {
+	service, err := getService()
+	if err != nil {
+		return nil, fmt.Errorf("service initialization failed: %w", err)
+	}
+	return service.VoidLineItem(ctx, billID, itemID, params.Reason)
+}
+
 //encore:api public method=POST path=/bills/:billID/close
-func CloseBill(ctx context.Context, billID string) error {
+func CloseBill(ctx context.Context, billID string) (*CloseBillResponse, error) {
 	service, err := getService()
 	if err != nil {
-		return fmt.Errorf("service initialization failed: %w", err)
+		return nil, fmt.Errorf("service initialization failed: %w", err)
 	}
 	return service.CloseBill(ctx, billID)
 }
 
+//encore:api public method=POST path=/bills/:billID/void
+func VoidBill(ctx context.Context, billID string, req *VoidBillRequest) (*VoidBillResponse, error) {
+	service, err := getService()
+	if err != nil {
+		return nil, fmt.Errorf("service initialization failed: %w", err)
+	}
+	return service.VoidBill(ctx, billID, req)
+}
+
+// AuthHandler authenticates operators by the bearer token they send.
+//
+//encore:authhandler
+func AuthHandler(ctx context.Context, token string) (auth.UID, *Operator, error) {
+	service, err := getService()
+	if err != nil {
+		return "", nil, fmt.Errorf("service initialization failed: %w", err)
+	}
+	operator, err := service.Authenticate(token)
+	if err != nil {
+		return "", nil, &errs.Error{Code: errs.Unauthenticated, Message: err.Error()}
+	}
+	return auth.UID(operator.ID), operator, nil
+}
+
+// operatorError reports a missing role as 403 rather than as a server error.
+func operatorError(err error) error {
+	if errors.Is(err, ErrPermissionDenied) {
+		return &errs.Error{Code: errs.PermissionDenied, Message: err.Error()}
+	}
+	return err
+}
+
+//encore:api auth method=POST path=/bills/:billID/reopen
+func ReopenBill(ctx context.Context, billID string, req *ReopenBillRequest) (*ReopenBillResponse, error) {
+	service, err := getService()
+	if err != nil {
+		return nil, fmt.Errorf("service initialization failed: %w", err)
+	}
+	operator, _ := auth.Data().(*Operator)
+	resp, err := service.ReopenBill(ctx, operator, billID, req)
+	return resp, operatorError(err)
+}
+
+//encore:api auth method=POST path=/bills/:billID/adjustments
+func CreateAdjustmentBill(ctx context.Context, billID string, req *CreateAdjustmentBillRequest) (*CreateAdjustmentBillResponse, error) {
+	service, err := getService()
+	if err != nil {
+		return nil, fmt.Errorf("service initialization failed: %w", err)
+	}
+	operator, _ := auth.Data().(*Operator)
+	resp, err := service.CreateAdjustmentBill(ctx, operator, billID, req)
+	return resp, operatorError(err)
+}
+
+//encore:api auth method=GET path=/bills/:billID/corrections
+func ListBillCorrections(ctx context.Context, billID string) (*ListBillCorrectionsResponse, error) {
+	service, err := getService()
+	if err != nil {
+		return nil, fmt.Errorf("service initialization failed: %w", err)
+	}
+	return service.ListBillCorrections(ctx, billID)
+}
+
 //encore:api public method=GET path=/bills/:billID
 func GetBill(ctx context.Context, billID string) (*GetBillResponse, error) {
 	service, err := getService()
@@ -88,19 +214,291 @@ func GetBill(ctx context.Context, billID string) (*GetBillResponse, error) {
 	return service.GetBill(ctx, billID)
 }
 
+//encore:api public method=GET path=/bills/:billID/live
+func GetLiveBill(ctx context.Context, billID string) (*GetLiveBillResponse, error) {
+	service, err := getService()
+	if err != nil {
+		return nil, fmt.Errorf("service initialization failed: %w", err)
+	}
+	return service.GetLiveBill(ctx, billID)
+}
+
+//encore:api public method=POST path=/fee-schedules
+func CreateFeeSchedule(ctx context.Context, req *CreateFeeScheduleRequest) (*FeeSchedule, error) {
+	service, err := getService()
+	if err != nil {
+		return nil, fmt.Errorf("service initialization failed: %w", err)
+	}
+	return service.CreateFeeSchedule(ctx, req)
+}
+
+//encore:api public method=GET path=/fee-schedules/:scheduleID
+func GetFeeSchedule(ctx context.Context, scheduleID string) (*FeeSchedule, error) {
+	service, err := getService()
+	if err != nil {
+		return nil, fmt.Errorf("service initialization failed: %w", err)
+	}
+	return service.GetFeeSchedule(ctx, scheduleID)
+}
+
+//encore:api public method=POST path=/tax-rules
+func SaveTaxRule(ctx context.Context, req *SaveTaxRuleRequest) (*TaxRule, error) {
+	service, err := getService()
+	if err != nil {
+		return nil, fmt.Errorf("service initialization failed: %w", err)
+	}
+	return service.SaveTaxRule(ctx, req)
+}
+
+//encore:api public method=GET path=/tax-rules/:ruleID
+func GetTaxRule(ctx context.Context, ruleID string) (*TaxRule, error) {
+	service, err := getService()
+	if err != nil {
+		return nil, fmt.Errorf("service initialization failed: %w", err)
+	}
+	return service.GetTaxRule(ctx, ruleID)
+}
+
+//encore:api public method=GET path=/tax-rules
+func ListTaxRules(ctx context.Context, req *ListTaxRulesRequest) (*ListTaxRulesResponse, error) {
+	service, err := getService()
+	if err != nil {
+		return nil, fmt.Errorf("service initialization failed: %w", err)
+	}
+	return service.ListTaxRules(ctx, req)
+}
+
+//encore:api public method=POST path=/promo-codes
+func CreatePromoCode(ctx context.Context, req *CreatePromoCodeRequest) (*PromoCode, error) {
+	service, err := getService()
+	if err != nil {
+		return nil, fmt.Errorf("service initialization failed: %w", err)
+	}
+	return service.CreatePromoCode(ctx, req)
+}
+
+//encore:api public method=GET path=/promo-codes/:code
+func GetPromoCode(ctx context.Context, code string) (*PromoCode, error) {
+	service, err := getService()
+	if err != nil {
+		return nil, fmt.Errorf("service initialization failed: %w", err)
+	}
+	return service.GetPromoCode(ctx, code)
+}
+
+//encore:api public method=POST path=/meters
+func CreateMeter(ctx context.Context, req *CreateMeterRequest) (*Meter, error) {
+	service, err := getService()
+	if err != nil {
+		return nil, fmt.Errorf("service initialization failed: %w", err)
+	}
+	return service.CreateMeter(ctx, req)
+}
+
+//encore:api public method=GET path=/meters/:name
+func GetMeter(ctx context.Context, name string) (*Meter, error) {
+	service, err := getService()
+	if err != nil {
+		return nil, fmt.Errorf("service initialization failed: %w", err)
+	}
+	return service.GetMeter(ctx, name)
+}
+
+//encore:api public method=POST path=/usage
+func RecordUsage(ctx context.Context, req *RecordUsageRequest) (*RecordUsageResponse, error) {
+	service, err := getService()
+	if err != nil {
+		return nil, fmt.Errorf("service initialization failed: %w", err)
+	}
+	return service.RecordUsage(ctx, req)
+}
+
+//encore:api public method=GET path=/bills/:billID/usage
+func GetBillUsage(ctx context.Context, billID string) (*GetBillUsageResponse, error) {
+	service, err := getService()
+	if err != nil {
+		return nil, fmt.Errorf("service initialization failed: %w", err)
+	}
+	return service.GetBillUsage(ctx, billID)
+}
+
+//encore:api public method=GET path=/customers/:customerID/usage
+func ListUsageEvents(ctx context.Context, customerID string, params ListUsageEventsParams) (*ListUsageEventsResponse, error) {
+	service, err := getService()
+	if err != nil {
+		return nil, fmt.Errorf("service initialization failed: %w", err)
+	}
+	return service.ListUsageEvents(ctx, customerID, params)
+}
+
+//encore:api public method=POST path=/subscriptions
+func CreateSubscription(ctx context.Context, req *CreateSubscriptionRequest) (*Subscription, error) {
+	service, err := getService()
+	if err != nil {
+		return nil, fmt.Errorf("service initialization failed: %w", err)
+	}
+	return service.CreateSubscription(ctx, req)
+}
+
+//encore:api public method=GET path=/subscriptions/:subscriptionID
+func GetSubscription(ctx context.Context, subscriptionID string) (*Subscription, error) {
+	service, err := getService()
+	if err != nil {
+		return nil, fmt.Errorf("service initialization failed: %w", err)
+	}
+	return service.GetSubscription(ctx, subscriptionID)
+}
+
+//encore:api public method=POST path=/subscriptions/:subscriptionID/pause
+func PauseSubscription(ctx context.Context, subscriptionID string) (*Subscription, error) {
+	service, err := getService()
+	if err != nil {
+		return nil, fmt.Errorf("service initialization failed: %w", err)
+	}
+	return service.PauseSubscription(ctx, subscriptionID)
+}
+
+//encore:api public method=POST path=/subscriptions/:subscriptionID/resume
+func ResumeSubscription(ctx context.Context, subscriptionID string) (*Subscription, error) {
+	service, err := getService()
+	if err != nil {
+		return nil, fmt.Errorf("service initialization failed: %w", err)
+	}
+	return service.ResumeSubscription(ctx, subscriptionID)
+}
+
+//encore:api public method=POST path=/subscriptions/:subscriptionID/cancel
+func CancelSubscription(ctx context.Context, subscriptionID string) (*Subscription, error) {
+	service, err := getService()
+	if err != nil {
+		return nil, fmt.Errorf("service initialization failed: %w", err)
+	}
+	return service.CancelSubscription(ctx, subscriptionID)
+}
+
+//encore:api public method=POST path=/subscriptions/:subscriptionID/plan
+func ChangeSubscriptionPlan(ctx context.Context, subscriptionID string, req *ChangePlanRequest) (*ChangePlanResponse, error) {
+	service, err := getService()
+	if err != nil {
+		return nil, fmt.Errorf("service initialization failed: %w", err)
+	}
+	return service.ChangeSubscriptionPlan(ctx, subscriptionID, req)
+}
+
+//encore:api public method=GET path=/invoices/:number
+func GetInvoice(ctx context.Context, number string) (*Invoice, error) {
+	service, err := getService()
+	if err != nil {
+		return nil, fmt.Errorf("service initialization failed: %w", err)
+	}
+	return service.GetInvoice(ctx, number)
+}
+
+//encore:api public method=POST path=/bills/:billID/payments
+func RecordPayment(ctx context.Context, billID string, req *RecordPaymentRequest) (*RecordPaymentResponse, error) {
+	service, err := getService()
+	if err != nil {
+		return nil, fmt.Errorf("service initialization failed: %w", err)
+	}
+	return service.RecordPayment(ctx, billID, req)
+}
+
+//encore:api public method=GET path=/bills/:billID/payments
+func ListPayments(ctx context.Context, billID string) (*ListPaymentsResponse, error) {
+	service, err := getService()
+	if err != nil {
+		return nil, fmt.Errorf("service initialization failed: %w", err)
+	}
+	return service.ListPayments(ctx, billID)
+}
+
+//encore:api public raw method=GET path=/bills/:billID/invoice.pdf
+func GetBillInvoicePDF(w http.ResponseWriter, req *http.Request) {
+	serveBillInvoice(w, req, InvoiceFormatPDF)
+}
+
+//encore:api public raw method=GET path=/bills/:billID/invoice.html
+func GetBillInvoiceHTML(w http.ResponseWriter, req *http.Request) {
+	serveBillInvoice(w, req, InvoiceFormatHTML)
+}
+
+func serveBillInvoice(w http.ResponseWriter, req *http.Request, format InvoiceFormat) {
+	service, err := getService()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("service initialization failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	billID := encore.CurrentRequest().PathParams.Get("billID")
+	rendered, err := service.RenderInvoice(req.Context(), billID, format)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrBillNotFound) || errors.Is(err, ErrInvoiceNotFound) {
+			status = http.StatusNotFound
+		} else if errors.Is(err, ErrBillVoided) {
+			status = http.StatusGone
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", rendered.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", rendered.Filename))
+	w.Write(rendered.Body)
+}
+
+//encore:api public method=POST path=/bills/:billID/discounts
+func ApplyDiscount(ctx context.Context, billID string, req *ApplyDiscountRequest) (*ApplyDiscountResponse, error) {
+	service, err := getService()
+	if err != nil {
+		return nil, fmt.Errorf("service initialization failed: %w", err)
+	}
+	return service.ApplyDiscount(ctx, billID, req)
+}
+
+//encore:api private method=POST path=/internal/outbox/relay
+func RelayOutbox(ctx context.Context) (*RelayOutboxResponse, error) {
+	service, err := getService()
+	if err != nil {
+		return nil, fmt.Errorf("service initialization failed: %w", err)
+	}
+	return service.RelayOutbox(ctx)
+}
+
+//encore:api public method=PUT path=/customers/:customerID/billing-limits
+func SaveBillingLimits(ctx context.Context, customerID string, req *SaveBillingLimitsRequest) (*CustomerBillingLimits, error) {
+	service, err := getService()
+	if err != nil {
+		return nil, fmt.Errorf("service initialization failed: %w", err)
+	}
+	return service.SaveBillingLimits(ctx, customerID, req)
+}
+
+//encore:api public method=GET path=/customers/:customerID/billing-limits
+func GetBillingLimits(ctx context.Context, customerID string, params GetBillingLimitsParams) (*CustomerBillingLimits, error) {
+	service, err := getService()
+	if err != nil {
+		return nil, fmt.Errorf("service initialization failed: %w", err)
+	}
+	return service.GetBillingLimits(ctx, customerID, params.Currency)
+}
+
 type ListBillsParams struct {
 	Status string `query:"status"`
 	Limit  int    `query:"limit"`
 	Offset int    `query:"offset"`
+	// IncludeVoided adds voided bills to an unfiltered list.
+	IncludeVoided bool `query:"includeVoided"`
 }
 
 //encore:api public method=GET path=/customers/:customerID/bills
 func ListBills(ctx context.Context, customerID string, params ListBillsParams) (*ListBillsResponse, error) {
 	req := &ListBillsRequest{
-		CustomerID: customerID,
-		Status:     nil,
-		Limit:      10,
-		Offset:     0,
+		CustomerID:    customerID,
+		Status:        nil,
+		Limit:         10,
+		Offset:        0,
+		IncludeVoided: params.IncludeVoided,
 	}
 	
 	// Convert string status to BillStatus if provided and not empty
@@ -127,17 +525,19 @@ func ListBills(ctx context.Context, customerID string, params ListBillsParams) (
 }
 
 type ListAllBillsParams struct {
-	Status string `query:"status"`
-	Limit  int    `query:"limit"`
-	Offset int    `query:"offset"`
+	Status        string `query:"status"`
+	Limit         int    `query:"limit"`
+	Offset        int    `query:"offset"`
+	IncludeVoided bool   `query:"includeVoided"`
 }
 
 //encore:api public method=GET path=/bills
 func ListAllBills(ctx context.Context, params ListAllBillsParams) (*ListBillsResponse, error) {
 	req := &ListAllBillsRequest{
-		Status: nil,
-		Limit:  50,
-		Offset: 0,
+		Status:        nil,
+		Limit:         50,
+		Offset:        0,
+		IncludeVoided: params.IncludeVoided,
 	}
 	
 	// Convert string status to BillStatus if provided and not empty
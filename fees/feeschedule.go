@@ -0,0 +1,129 @@
+package fees
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrFeeScheduleNotFound = errors.New("fee schedule not found")
+	ErrInvalidFeeSchedule  = errors.New("invalid fee schedule")
+)
+
+const basisPointsPerUnit = 10000
+
+// FeeTier is one band of a progressive schedule. RateBps applies to the part
+// of the subtotal that falls between the previous tier's UpTo and this one's;
+// FlatAmount is charged once as soon as the subtotal reaches the band.
+type FeeTier struct {
+	UpTo       *int64 `json:"upTo,omitempty"`
+	RateBps    int64  `json:"rateBps"`
+	FlatAmount int64  `json:"flatAmount"`
+}
+
+// FeeSchedule versions are immutable; changing a schedule creates a new
+// version and bills record the version they were priced with at close.
+type FeeSchedule struct {
+	ID         string    `json:"id"`
+	Version    int       `json:"version"`
+	Name       string    `json:"name"`
+	Currency   Currency  `json:"currency"`
+	CustomerID string    `json:"customerId,omitempty"`
+	Tiers      []FeeTier `json:"tiers"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+func (fs *FeeSchedule) Validate() error {
+	if strings.TrimSpace(fs.ID) == "" {
+		return fmt.Errorf("%w: id cannot be empty", ErrInvalidFeeSchedule)
+	}
+	if strings.TrimSpace(fs.Name) == "" {
+		return fmt.Errorf("%w: name cannot be empty", ErrInvalidFeeSchedule)
+	}
+	if err := fs.Currency.Validate(); err != nil {
+		return err
+	}
+	if len(fs.Tiers) == 0 {
+		return fmt.Errorf("%w: at least one tier is required", ErrInvalidFeeSchedule)
+	}
+
+	var lower int64
+	for i, tier := range fs.Tiers {
+		if tier.RateBps < 0 || tier.RateBps > basisPointsPerUnit {
+			return fmt.Errorf("%w: tier %d rate must be between 0 and %d bps", ErrInvalidFeeSchedule, i+1, basisPointsPerUnit)
+		}
+		if tier.FlatAmount < 0 {
+			return fmt.Errorf("%w: tier %d flat amount cannot be negative", ErrInvalidFeeSchedule, i+1)
+		}
+		if tier.UpTo == nil {
+			if i != len(fs.Tiers)-1 {
+				return fmt.Errorf("%w: only the last tier can be unbounded", ErrInvalidFeeSchedule)
+			}
+			continue
+		}
+		if *tier.UpTo <= lower {
+			return fmt.Errorf("%w: tier %d upper bound must be above %d", ErrInvalidFeeSchedule, i+1, lower)
+		}
+		lower = *tier.UpTo
+	}
+	return nil
+}
+
+// Apply prices subtotal against the schedule and returns one fee line item per
+// tier that produced a non-zero fee. Rates round half up to the minor unit.
+func (fs *FeeSchedule) Apply(subtotal int64, timestamp time.Time) []LineItem {
+	var fees []LineItem
+	var lower int64
+	for i, tier := range fs.Tiers {
+		if subtotal <= lower {
+			break
+		}
+
+		upper := subtotal
+		if tier.UpTo != nil && *tier.UpTo < subtotal {
+			upper = *tier.UpTo
+		}
+
+		amount := ((upper-lower)*tier.RateBps+basisPointsPerUnit/2)/basisPointsPerUnit + tier.FlatAmount
+		if amount > 0 {
+			fees = append(fees, LineItem{
+				Description:     fmt.Sprintf("%s v%d tier %d", fs.Name, fs.Version, i+1),
+				Amount:          amount,
+				Timestamp:       timestamp,
+				Kind:            LineItemKindFee,
+				SystemGenerated: true,
+			})
+		}
+
+		if tier.UpTo == nil {
+			break
+		}
+		lower = *tier.UpTo
+	}
+	return fees
+}
+
+type CreateFeeScheduleRequest struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Currency   Currency  `json:"currency"`
+	CustomerID string    `json:"customerId,omitempty"`
+	Tiers      []FeeTier `json:"tiers"`
+}
+
+func (r *CreateFeeScheduleRequest) Validate() error {
+	schedule := r.toSchedule()
+	return schedule.Validate()
+}
+
+func (r *CreateFeeScheduleRequest) toSchedule() *FeeSchedule {
+	return &FeeSchedule{
+		ID:         r.ID,
+		Name:       r.Name,
+		Currency:   r.Currency,
+		CustomerID: r.CustomerID,
+		Tiers:      r.Tiers,
+	}
+}
@@ -0,0 +1,97 @@
+package fees
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func int64Ptr(v int64) *int64 {
+	return &v
+}
+
+func TestFeeSchedule_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		tiers   []FeeTier
+		wantErr bool
+	}{
+		{"single unbounded tier", []FeeTier{{RateBps: 250}}, false},
+		{"ascending bounds", []FeeTier{{UpTo: int64Ptr(1000), RateBps: 300}, {UpTo: int64Ptr(5000), RateBps: 200}, {RateBps: 100}}, false},
+		{"flat only", []FeeTier{{FlatAmount: 50}}, false},
+		{"no tiers", nil, true},
+		{"descending bounds", []FeeTier{{UpTo: int64Ptr(5000), RateBps: 300}, {UpTo: int64Ptr(1000), RateBps: 200}}, true},
+		{"unbounded tier not last", []FeeTier{{RateBps: 300}, {UpTo: int64Ptr(1000), RateBps: 200}}, true},
+		{"negative rate", []FeeTier{{RateBps: -1}}, true},
+		{"rate above 100%", []FeeTier{{RateBps: 10001}}, true},
+		{"negative flat amount", []FeeTier{{FlatAmount: -1}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule := &FeeSchedule{ID: "standard", Name: "Standard", Currency: USD, Tiers: tt.tiers}
+			err := schedule.Validate()
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidFeeSchedule)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	t.Run("invalid currency", func(t *testing.T) {
+		schedule := &FeeSchedule{ID: "standard", Name: "Standard", Currency: "EUR", Tiers: []FeeTier{{RateBps: 100}}}
+		assert.ErrorIs(t, schedule.Validate(), ErrInvalidCurrency)
+	})
+}
+
+func TestFeeSchedule_Apply(t *testing.T) {
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	schedule := &FeeSchedule{
+		ID:       "standard",
+		Version:  2,
+		Name:     "Standard",
+		Currency: USD,
+		Tiers: []FeeTier{
+			{UpTo: int64Ptr(10000), RateBps: 300, FlatAmount: 25},
+			{UpTo: int64Ptr(50000), RateBps: 200},
+			{RateBps: 100},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		subtotal int64
+		want     []int64
+	}{
+		{"zero subtotal", 0, nil},
+		{"within first tier", 5000, []int64{175}},
+		{"first tier boundary", 10000, []int64{325}},
+		{"spans two tiers", 30000, []int64{325, 400}},
+		{"spans all tiers", 80000, []int64{325, 800, 300}},
+		{"rounds half up", 50, []int64{27}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fees := schedule.Apply(tt.subtotal, now)
+
+			var amounts []int64
+			for _, fee := range fees {
+				amounts = append(amounts, fee.Amount)
+				assert.Equal(t, LineItemKindFee, fee.Kind)
+				assert.Equal(t, now, fee.Timestamp)
+			}
+			assert.Equal(t, tt.want, amounts)
+		})
+	}
+
+	t.Run("describes schedule version and tier", func(t *testing.T) {
+		fees := schedule.Apply(30000, now)
+		require.Len(t, fees, 2)
+		assert.Equal(t, "Standard v2 tier 1", fees[0].Description)
+		assert.Equal(t, "Standard v2 tier 2", fees[1].Description)
+	})
+}
@@ -0,0 +1,263 @@
+package fees
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidRate  = errors.New("invalid exchange rate")
+	ErrRateNotFound = errors.New("exchange rate not found")
+)
+
+// FXRatesFileEnv points at a JSON rates file (see FileRateProvider) used to
+// convert foreign-currency line items. Unset means no conversions.
+const FXRatesFileEnv = "FEES_FX_RATES_FILE"
+
+const (
+	// rateDecimals is the number of fractional digits a Rate keeps.
+	rateDecimals = 8
+	RateScale    = 100000000
+)
+
+// Rate is the price of one unit of a base currency in a quote currency,
+// stored as an integer number of 1e-8ths: USD/GEL at 2.7153 is 271530000.
+// Like Quantity it reads and writes JSON as a plain decimal.
+type Rate int64
+
+func ParseRate(s string) (Rate, error) {
+	value, err := parseFixedPoint(s, rateDecimals)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidRate, err)
+	}
+	if value <= 0 {
+		return 0, fmt.Errorf("%w: rate must be positive", ErrInvalidRate)
+	}
+	return Rate(value), nil
+}
+
+func (r Rate) String() string {
+	return formatFixedPoint(int64(r), rateDecimals, false)
+}
+
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// UnmarshalJSON accepts both JSON numbers and numeric strings.
+func (r *Rate) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	if strings.ContainsAny(s, "eE") {
+		return fmt.Errorf("%w: exponent notation is not supported", ErrInvalidRate)
+	}
+
+	parsed, err := ParseRate(s)
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
+
+// Inverse returns the rate for the opposite direction, rounded half up.
+func (r Rate) Inverse() (Rate, error) {
+	if r <= 0 {
+		return 0, fmt.Errorf("%w: cannot invert %s", ErrInvalidRate, r)
+	}
+	scale := big.NewInt(RateScale)
+	inverse := new(big.Int).Mul(scale, scale)
+	inverse.Add(inverse, big.NewInt(int64(r)/2))
+	inverse.Quo(inverse, big.NewInt(int64(r)))
+	if !inverse.IsInt64() || inverse.Sign() == 0 {
+		return 0, fmt.Errorf("%w: inverse of %s is out of range", ErrInvalidRate, r)
+	}
+	return Rate(inverse.Int64()), nil
+}
+
+// ConvertAmount converts an amount in from's minor units into to's minor
+// units at rate, rounding half away from zero. The currencies' exponents are
+// accounted for, so 100 JPY at 0.0067 USD/JPY is 67 cents.
+func ConvertAmount(amount int64, from, to Currency, rate Rate) (int64, error) {
+	if rate <= 0 {
+		return 0, fmt.Errorf("%w: %s", ErrInvalidRate, rate)
+	}
+	numerator := new(big.Int).Mul(big.NewInt(amount), big.NewInt(int64(rate)))
+	numerator.Mul(numerator, pow10(to.Exponent()))
+	denominator := new(big.Int).Mul(big.NewInt(RateScale), pow10(from.Exponent()))
+
+	half := new(big.Int).Quo(denominator, big.NewInt(2))
+	if numerator.Sign() < 0 {
+		numerator.Sub(numerator, half)
+	} else {
+		numerator.Add(numerator, half)
+	}
+	numerator.Quo(numerator, denominator)
+
+	if !numerator.IsInt64() {
+		return 0, fmt.Errorf("%w: %d %s at %s overflows", ErrInvalidAmount, amount, from, rate)
+	}
+	return numerator.Int64(), nil
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// ExchangeRate is a rate as served by a RateProvider.
+type ExchangeRate struct {
+	Base  Currency  `json:"base"`
+	Quote Currency  `json:"quote"`
+	Rate  Rate      `json:"rate"`
+	AsOf  time.Time `json:"asOf"`
+}
+
+// CurrencyPair keys rate tables, e.g. {USD, GEL} is the GEL price of 1 USD.
+type CurrencyPair struct {
+	Base  Currency
+	Quote Currency
+}
+
+func (p CurrencyPair) String() string {
+	return string(p.Base) + "/" + string(p.Quote)
+}
+
+// ParseCurrencyPair parses "USD/GEL".
+func ParseCurrencyPair(s string) (CurrencyPair, error) {
+	base, quote, ok := strings.Cut(strings.ToUpper(strings.TrimSpace(s)), "/")
+	if !ok || base == "" || quote == "" {
+		return CurrencyPair{}, fmt.Errorf("%w: %q is not a BASE/QUOTE pair", ErrInvalidRate, s)
+	}
+	return CurrencyPair{Base: Currency(base), Quote: Currency(quote)}, nil
+}
+
+// StaticRateProvider serves a fixed rate table. A pair missing from the table
+// is served from its inverse when that is present.
+type StaticRateProvider struct {
+	rates map[CurrencyPair]Rate
+	asOf  time.Time
+}
+
+// NewStaticRateProvider serves rates as of asOf; a zero asOf reports the time
+// each conversion was asked for.
+func NewStaticRateProvider(rates map[CurrencyPair]Rate, asOf time.Time) *StaticRateProvider {
+	table := make(map[CurrencyPair]Rate, len(rates))
+	for pair, rate := range rates {
+		table[pair] = rate
+	}
+	return &StaticRateProvider{rates: table, asOf: asOf}
+}
+
+func (p *StaticRateProvider) Rate(ctx context.Context, base, quote Currency, at time.Time) (*ExchangeRate, error) {
+	asOf := p.asOf
+	if asOf.IsZero() {
+		asOf = at
+	}
+	if base == quote {
+		return &ExchangeRate{Base: base, Quote: quote, Rate: RateScale, AsOf: asOf}, nil
+	}
+
+	if rate, ok := p.rates[CurrencyPair{Base: base, Quote: quote}]; ok {
+		return &ExchangeRate{Base: base, Quote: quote, Rate: rate, AsOf: asOf}, nil
+	}
+	if rate, ok := p.rates[CurrencyPair{Base: quote, Quote: base}]; ok {
+		inverse, err := rate.Inverse()
+		if err != nil {
+			return nil, err
+		}
+		return &ExchangeRate{Base: base, Quote: quote, Rate: inverse, AsOf: asOf}, nil
+	}
+	return nil, fmt.Errorf("%w: %s/%s", ErrRateNotFound, base, quote)
+}
+
+// rateFile is the on-disk format read by FileRateProvider:
+//
+//	{"asOf": "2024-03-01T00:00:00Z", "rates": {"USD/GEL": "2.7153", "EUR/GEL": 2.9412}}
+type rateFile struct {
+	AsOf  time.Time       `json:"asOf"`
+	Rates map[string]Rate `json:"rates"`
+}
+
+// FileRateProvider serves rates from a JSON file for local use. The file is
+// read on every lookup so rates can be edited without a restart.
+type FileRateProvider struct {
+	path string
+}
+
+func NewFileRateProvider(path string) *FileRateProvider {
+	return &FileRateProvider{path: path}
+}
+
+func (p *FileRateProvider) Rate(ctx context.Context, base, quote Currency, at time.Time) (*ExchangeRate, error) {
+	static, err := p.load()
+	if err != nil {
+		return nil, err
+	}
+	return static.Rate(ctx, base, quote, at)
+}
+
+func (p *FileRateProvider) load() (*StaticRateProvider, error) {
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rates file: %w", err)
+	}
+
+	var file rateFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse rates file %s: %w", p.path, err)
+	}
+
+	rates := make(map[CurrencyPair]Rate, len(file.Rates))
+	for key, rate := range file.Rates {
+		pair, err := ParseCurrencyPair(key)
+		if err != nil {
+			return nil, fmt.Errorf("rates file %s: %w", p.path, err)
+		}
+		rates[pair] = rate
+	}
+	return NewStaticRateProvider(rates, file.AsOf), nil
+}
+
+// FXConversion records how a line item priced in another currency was
+// converted into the bill currency.
+type FXConversion struct {
+	Currency  Currency  `json:"currency"`
+	Amount    int64     `json:"amount"`
+	Quantity  Quantity  `json:"quantity"`
+	UnitPrice int64     `json:"unitPrice"`
+	Rate      Rate      `json:"rate"`
+	RateAt    time.Time `json:"rateAt"`
+}
+
+// convertLineItem reprices item from its original currency into currency at
+// rate. The converted item is a single unit at the converted amount; the
+// original pricing moves to item.FX.
+func convertLineItem(item *LineItem, currency Currency, rate *ExchangeRate) error {
+	original := item.FX
+	amount, err := ConvertAmount(original.Amount, original.Currency, currency, rate.Rate)
+	if err != nil {
+		return err
+	}
+	if amount == 0 {
+		return fmt.Errorf("%w: %d %s is less than one minor unit of %s", ErrInvalidAmount, original.Amount, original.Currency, currency)
+	}
+
+	original.Rate = rate.Rate
+	original.RateAt = rate.AsOf
+	item.Amount = amount
+	item.Quantity = QuantityOne
+	item.UnitPrice = amount
+	return nil
+}
@@ -0,0 +1,216 @@
+package fees
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Rate
+		wantErr bool
+	}{
+		{"2.7153", 271530000, false},
+		{"1", RateScale, false},
+		{"0.00000001", 1, false},
+		{"0", 0, true},
+		{"-1.5", 0, true},
+		{"0.000000001", 0, true},
+		{"abc", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseRate(tt.input)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidRate)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRate_JSON(t *testing.T) {
+	data, err := json.Marshal(Rate(271530000))
+	require.NoError(t, err)
+	assert.Equal(t, "2.7153", string(data))
+
+	var r Rate
+	require.NoError(t, json.Unmarshal([]byte(`"0.3683"`), &r))
+	assert.Equal(t, Rate(36830000), r)
+	assert.ErrorIs(t, json.Unmarshal([]byte(`2e3`), &r), ErrInvalidRate)
+}
+
+func TestRate_Inverse(t *testing.T) {
+	inverse, err := Rate(2 * RateScale).Inverse()
+	require.NoError(t, err)
+	assert.Equal(t, Rate(RateScale/2), inverse)
+
+	inverse, err = Rate(271530000).Inverse()
+	require.NoError(t, err)
+	assert.Equal(t, Rate(36828343), inverse)
+}
+
+func TestConvertAmount(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount int64
+		from   Currency
+		to     Currency
+		rate   Rate
+		want   int64
+	}{
+		{"USD to GEL", 1000, USD, GEL, 271530000, 2715},
+		{"rounds half up", 10, USD, GEL, 275000000, 28},
+		{"credits round away from zero", -10, USD, GEL, 275000000, -28},
+		{"JPY has no minor unit", 100, "JPY", USD, 670000, 67},
+		{"into KWD", 1000, USD, "KWD", 30700000, 3070},
+		{"from KWD", 1000, "KWD", USD, 325000000, 325},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ConvertAmount(tt.amount, tt.from, tt.to, tt.rate)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("rejects non-positive rate", func(t *testing.T) {
+		_, err := ConvertAmount(1000, USD, GEL, 0)
+		assert.ErrorIs(t, err, ErrInvalidRate)
+	})
+}
+
+func TestStaticRateProvider(t *testing.T) {
+	ctx := context.Background()
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	provider := NewStaticRateProvider(map[CurrencyPair]Rate{
+		{Base: USD, Quote: GEL}: 2 * RateScale,
+	}, time.Time{})
+
+	t.Run("direct pair", func(t *testing.T) {
+		rate, err := provider.Rate(ctx, USD, GEL, at)
+		require.NoError(t, err)
+		assert.Equal(t, Rate(2*RateScale), rate.Rate)
+		assert.Equal(t, at, rate.AsOf)
+	})
+
+	t.Run("inverse pair", func(t *testing.T) {
+		rate, err := provider.Rate(ctx, GEL, USD, at)
+		require.NoError(t, err)
+		assert.Equal(t, Rate(RateScale/2), rate.Rate)
+	})
+
+	t.Run("same currency", func(t *testing.T) {
+		rate, err := provider.Rate(ctx, GEL, GEL, at)
+		require.NoError(t, err)
+		assert.Equal(t, Rate(RateScale), rate.Rate)
+	})
+
+	t.Run("missing pair", func(t *testing.T) {
+		_, err := provider.Rate(ctx, "EUR", GEL, at)
+		assert.ErrorIs(t, err, ErrRateNotFound)
+	})
+}
+
+func TestFileRateProvider(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rates.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"asOf": "2024-03-01T00:00:00Z",
+		"rates": {"USD/GEL": "2.7153", "eur/gel": 2.9412}
+	}`), 0o600))
+
+	provider := NewFileRateProvider(path)
+
+	rate, err := provider.Rate(ctx, USD, GEL, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, Rate(271530000), rate.Rate)
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), rate.AsOf)
+
+	rate, err = provider.Rate(ctx, "EUR", GEL, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, Rate(294120000), rate.Rate)
+
+	t.Run("picks up edits", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, []byte(`{"rates": {"USD/GEL": "2.8"}}`), 0o600))
+		rate, err := provider.Rate(ctx, USD, GEL, time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, Rate(280000000), rate.Rate)
+	})
+
+	t.Run("bad pair", func(t *testing.T) {
+		bad := filepath.Join(dir, "bad.json")
+		require.NoError(t, os.WriteFile(bad, []byte(`{"rates": {"USDGEL": "2.8"}}`), 0o600))
+		_, err := NewFileRateProvider(bad).Rate(ctx, USD, GEL, time.Now())
+		assert.ErrorIs(t, err, ErrInvalidRate)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := NewFileRateProvider(filepath.Join(dir, "missing.json")).Rate(ctx, USD, GEL, time.Now())
+		assert.Error(t, err)
+	})
+}
+
+func TestConvertLineItem(t *testing.T) {
+	rateAt := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	rate := &ExchangeRate{Base: USD, Quote: GEL, Rate: 271530000, AsOf: rateAt}
+
+	t.Run("charge priced per unit", func(t *testing.T) {
+		item := LineItem{
+			Description: "API calls",
+			Amount:      60,
+			Quantity:    12 * QuantityScale,
+			UnitPrice:   5,
+			Kind:        LineItemKindCharge,
+			FX:          &FXConversion{Currency: USD, Amount: 60, Quantity: 12 * QuantityScale, UnitPrice: 5},
+		}
+
+		require.NoError(t, convertLineItem(&item, GEL, rate))
+		assert.Equal(t, int64(163), item.Amount)
+		assert.Equal(t, QuantityOne, item.Quantity)
+		assert.Equal(t, int64(163), item.UnitPrice)
+		assert.Equal(t, Rate(271530000), item.FX.Rate)
+		assert.Equal(t, rateAt, item.FX.RateAt)
+		assert.Equal(t, int64(60), item.FX.Amount)
+		assert.NoError(t, item.Validate())
+	})
+
+	t.Run("credit", func(t *testing.T) {
+		item := LineItem{
+			Description: "Refund",
+			Amount:      -1000,
+			Quantity:    QuantityOne,
+			UnitPrice:   -1000,
+			Kind:        LineItemKindCredit,
+			ReasonCode:  CreditReasonRefund,
+			FX:          &FXConversion{Currency: USD, Amount: -1000, Quantity: QuantityOne, UnitPrice: -1000},
+		}
+
+		require.NoError(t, convertLineItem(&item, GEL, rate))
+		assert.Equal(t, int64(-2715), item.Amount)
+		assert.NoError(t, item.Validate())
+	})
+
+	t.Run("rounds to nothing", func(t *testing.T) {
+		item := LineItem{
+			Description: "Tiny",
+			Amount:      1,
+			FX:          &FXConversion{Currency: "JPY", Amount: 1, Quantity: QuantityOne, UnitPrice: 1},
+		}
+		err := convertLineItem(&item, USD, &ExchangeRate{Rate: 400000})
+		assert.ErrorIs(t, err, ErrInvalidAmount)
+	})
+}
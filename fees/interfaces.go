@@ -2,25 +2,86 @@ package fees
 
 import (
 	"context"
+	"time"
 
 	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
 )
 
 //go:generate mockgen -source=interfaces.go -destination=mock_interfaces_test.go -package=fees
 
 type RepositoryInterface interface {
-	CreateBill(ctx context.Context, bill *Bill) error
+	CreateBill(ctx context.Context, bill *Bill) (*OutboxEvent, error)
 	GetBillByID(ctx context.Context, billID string) (*Bill, error)
+	GetBillByIdempotencyKey(ctx context.Context, customerID, key string) (*Bill, error)
 	GetBillStatus(ctx context.Context, billID string) (BillStatus, error)
-	AddLineItem(ctx context.Context, billID string, item *LineItem) error
+	AddLineItem(ctx context.Context, billID string, item *LineItem) (*OutboxEvent, error)
+	VoidLineItem(ctx context.Context, billID, itemID, reason string) (*OutboxEvent, error)
 	GetLineItemsByBillID(ctx context.Context, billID string) ([]LineItem, error)
-	UpdateBillStatus(ctx context.Context, billID string, status BillStatus, totalAmount int64) error
-	ListBillsByCustomer(ctx context.Context, customerID string, status *BillStatus, limit, offset int) ([]*Bill, error)
-	ListAllBills(ctx context.Context, status *BillStatus, limit, offset int) ([]*Bill, error)
+	GetLineItemByIdempotencyKey(ctx context.Context, billID, key string) (*LineItem, error)
+	FinalizeBill(ctx context.Context, bill *FinalBill) error
+	VoidBill(ctx context.Context, billID, reason string, voidedAt time.Time) error
+	ReopenBill(ctx context.Context, billID string, closedAfter time.Time, correction *BillCorrection) (*OutboxEvent, error)
+	CreateAdjustmentBill(ctx context.Context, bill *Bill, correction *BillCorrection) (*OutboxEvent, error)
+	ListBillCorrections(ctx context.Context, billID string) ([]*BillCorrection, error)
+	GetInvoice(ctx context.Context, number string) (*Invoice, error)
+	RecordPayment(ctx context.Context, payment *Payment) (*Payment, error)
+	ListPayments(ctx context.Context, billID string) ([]*Payment, error)
+	UpdateDunning(ctx context.Context, billID string, status DunningStatus, stage int) error
+	ListBillsByCustomer(ctx context.Context, customerID string, status *BillStatus, includeVoided bool, limit, offset int) ([]*Bill, error)
+	ListAllBills(ctx context.Context, status *BillStatus, includeVoided bool, limit, offset int) ([]*Bill, error)
+	ListPendingOutboxEvents(ctx context.Context, limit int) ([]*OutboxEvent, error)
+	MarkOutboxEventDone(ctx context.Context, eventID int64) error
+	MarkOutboxEventRetry(ctx context.Context, eventID int64, lastError string) error
+	MarkOutboxEventFailed(ctx context.Context, eventID int64, lastError string) error
+	SettleOutboxEvents(ctx context.Context, billID string, itemIDs, voidIDs []string) error
+	CreateFeeSchedule(ctx context.Context, schedule *FeeSchedule) error
+	GetFeeSchedule(ctx context.Context, scheduleID string) (*FeeSchedule, error)
+	GetCustomerFeeSchedule(ctx context.Context, customerID string, currency Currency) (*FeeSchedule, error)
+	SaveTaxRule(ctx context.Context, rule *TaxRule) error
+	GetTaxRule(ctx context.Context, ruleID string) (*TaxRule, error)
+	ListTaxRules(ctx context.Context, currency Currency) ([]*TaxRule, error)
+	CreatePromoCode(ctx context.Context, promo *PromoCode) error
+	GetPromoCode(ctx context.Context, code string) (*PromoCode, error)
+	AttachDiscount(ctx context.Context, billID string, discount *BillDiscount) error
+	ListBillDiscounts(ctx context.Context, billID string) ([]BillDiscount, error)
+	SaveCustomerBillingLimits(ctx context.Context, limits *CustomerBillingLimits) error
+	GetCustomerBillingLimits(ctx context.Context, customerID string, currency Currency) (*CustomerBillingLimits, error)
+	CreateMeter(ctx context.Context, meter *Meter) error
+	GetMeter(ctx context.Context, name string) (*Meter, error)
+	RecordUsageEvent(ctx context.Context, event *UsageEvent) (*UsageEvent, error)
+	ListUsageEvents(ctx context.Context, customerID string, status UsageEventStatus, limit int) ([]*UsageEvent, error)
+	AggregateUsage(ctx context.Context, billID string) (*BillUsage, error)
+	ClaimUsage(ctx context.Context, billID string) (*BillUsage, error)
+	CreateSubscription(ctx context.Context, sub *Subscription) error
+	GetSubscription(ctx context.Context, subscriptionID string) (*Subscription, error)
+	UpdateSubscriptionStatus(ctx context.Context, subscriptionID string, from, to SubscriptionStatus) error
+	RenewSubscription(ctx context.Context, subscriptionID, billID string, start, end time.Time) error
+	UpdateSubscriptionPlan(ctx context.Context, subscriptionID, plan string, planAmount int64, billID string, items []*LineItem) ([]*OutboxEvent, error)
 }
 
 type TemporalClientInterface interface {
 	ExecuteWorkflow(ctx context.Context, options client.StartWorkflowOptions, workflow interface{}, args ...interface{}) (client.WorkflowRun, error)
 	SignalWorkflow(ctx context.Context, workflowID, runID, signalName string, arg interface{}) error
+	UpdateWorkflow(ctx context.Context, options client.UpdateWorkflowOptions) (client.WorkflowUpdateHandle, error)
+	QueryWorkflow(ctx context.Context, workflowID, runID, queryType string, args ...interface{}) (converter.EncodedValue, error)
+	CancelWorkflow(ctx context.Context, workflowID, runID string) error
 }
 
+// Notifier delivers dunning notifications to customers.
+type Notifier interface {
+	Notify(ctx context.Context, notification Notification) error
+}
+
+// RateProvider looks up the rate to convert base into quote at a point in time.
+type RateProvider interface {
+	Rate(ctx context.Context, base, quote Currency, at time.Time) (*ExchangeRate, error)
+}
+
+// Biller opens, charges and closes bills the same way the API does, for
+// workflows that bill on their own schedule.
+type Biller interface {
+	CreateBill(ctx context.Context, req *CreateBillRequest) (*CreateBillResponse, error)
+	AddLineItem(ctx context.Context, billID string, req *AddLineItemRequest) (*AddLineItemResponse, error)
+	CloseBill(ctx context.Context, billID string) (*CloseBillResponse, error)
+}
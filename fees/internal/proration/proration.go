@@ -0,0 +1,189 @@
+// Package proration splits a recurring amount at an instant inside its
+// billing period. It works in integer minor units and knows nothing about
+// bills or currencies beyond how their amounts are rounded.
+package proration
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+var (
+	ErrInvalidPeriod      = errors.New("invalid proration period")
+	ErrOutsidePeriod      = errors.New("instant is outside the period")
+	ErrInvalidGranularity = errors.New("invalid proration granularity")
+	ErrInvalidRounding    = errors.New("invalid rounding")
+	ErrOverflow           = errors.New("prorated amount overflows")
+)
+
+// Granularity is the unit time is counted in.
+type Granularity string
+
+const (
+	// Day counts UTC calendar days from the start date up to the end date.
+	// The day of the change counts as remaining, so a change made any time
+	// on a day is worth the same.
+	Day Granularity = "DAY"
+	// Second counts whole seconds.
+	Second Granularity = "SECOND"
+)
+
+func (g Granularity) IsValid() bool {
+	return g == Day || g == Second
+}
+
+type RoundingMode string
+
+const (
+	// HalfUp rounds halves away from zero.
+	HalfUp RoundingMode = "HALF_UP"
+	// HalfEven rounds halves to the even multiple (banker's rounding).
+	HalfEven RoundingMode = "HALF_EVEN"
+	// Down truncates towards zero.
+	Down RoundingMode = "DOWN"
+)
+
+// Rounding rounds a prorated amount to a multiple of Increment minor units,
+// e.g. Increment 5 rounds Swiss francs to 5 centimes.
+type Rounding struct {
+	Mode      RoundingMode
+	Increment int64
+}
+
+// DefaultRounding rounds half up to the minor unit.
+var DefaultRounding = Rounding{Mode: HalfUp, Increment: 1}
+
+func (r Rounding) Validate() error {
+	switch r.Mode {
+	case HalfUp, HalfEven, Down:
+	default:
+		return fmt.Errorf("%w: unknown mode %q", ErrInvalidRounding, r.Mode)
+	}
+	if r.Increment <= 0 {
+		return fmt.Errorf("%w: increment must be positive", ErrInvalidRounding)
+	}
+	return nil
+}
+
+// Period is a billing period; End is exclusive.
+type Period struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Fraction is how much of a period is left, both counted in the same
+// granularity.
+type Fraction struct {
+	Remaining int64
+	Total     int64
+}
+
+// Remaining returns how much of the period is left at at. at has to be in
+// the period.
+func Remaining(period Period, at time.Time, granularity Granularity) (Fraction, error) {
+	if !period.End.After(period.Start) {
+		return Fraction{}, fmt.Errorf("%w: end %s is not after start %s", ErrInvalidPeriod, period.End, period.Start)
+	}
+	if at.Before(period.Start) || !at.Before(period.End) {
+		return Fraction{}, fmt.Errorf("%w: %s is not in %s to %s", ErrOutsidePeriod, at, period.Start, period.End)
+	}
+
+	var fraction Fraction
+	switch granularity {
+	case Day:
+		end := dayNumber(period.End)
+		fraction = Fraction{Remaining: end - dayNumber(at), Total: end - dayNumber(period.Start)}
+		if fraction.Total == 0 {
+			return Fraction{}, fmt.Errorf("%w: period is within one day, prorate it by %s", ErrInvalidPeriod, Second)
+		}
+	case Second:
+		fraction = Fraction{
+			Remaining: int64(period.End.Sub(at) / time.Second),
+			Total:     int64(period.End.Sub(period.Start) / time.Second),
+		}
+		if fraction.Total == 0 {
+			return Fraction{}, fmt.Errorf("%w: period is shorter than a second", ErrInvalidPeriod)
+		}
+	default:
+		return Fraction{}, fmt.Errorf("%w: %q", ErrInvalidGranularity, granularity)
+	}
+	return fraction, nil
+}
+
+// dayNumber counts UTC days since the Unix epoch.
+func dayNumber(t time.Time) int64 {
+	seconds := t.Unix()
+	days := seconds / 86400
+	if seconds%86400 < 0 {
+		days--
+	}
+	return days
+}
+
+// Prorate returns amount x Remaining / Total, rounded.
+func Prorate(amount int64, fraction Fraction, rounding Rounding) (int64, error) {
+	if fraction.Total <= 0 || fraction.Remaining < 0 || fraction.Remaining > fraction.Total {
+		return 0, fmt.Errorf("%w: %d of %d remaining", ErrInvalidPeriod, fraction.Remaining, fraction.Total)
+	}
+	if err := rounding.Validate(); err != nil {
+		return 0, err
+	}
+
+	numerator := new(big.Int).Mul(big.NewInt(amount), big.NewInt(fraction.Remaining))
+	denominator := new(big.Int).Mul(big.NewInt(fraction.Total), big.NewInt(rounding.Increment))
+	quotient, remainder := new(big.Int).QuoRem(numerator, denominator, new(big.Int))
+
+	if remainder.Sign() != 0 {
+		// Compare twice the remainder with the divisor to tell below, at or
+		// above the half.
+		half := new(big.Int).Abs(remainder)
+		half.Mul(half, big.NewInt(2))
+		cmp := half.Cmp(denominator)
+
+		roundAway := false
+		switch rounding.Mode {
+		case HalfUp:
+			roundAway = cmp >= 0
+		case HalfEven:
+			roundAway = cmp > 0 || (cmp == 0 && quotient.Bit(0) == 1)
+		}
+		if roundAway {
+			quotient.Add(quotient, big.NewInt(int64(numerator.Sign())))
+		}
+	}
+
+	quotient.Mul(quotient, big.NewInt(rounding.Increment))
+	if !quotient.IsInt64() {
+		return 0, fmt.Errorf("%w: %d x %d/%d", ErrOverflow, amount, fraction.Remaining, fraction.Total)
+	}
+	return quotient.Int64(), nil
+}
+
+// Change is what switching from one recurring amount to another is worth
+// for the rest of the period: Credit gives back the unused part of the old
+// amount and Charge bills the new amount for the same time. Each is rounded
+// on its own.
+type Change struct {
+	Fraction
+	Credit int64
+	Charge int64
+}
+
+// Compute prorates a switch from oldAmount to newAmount at at.
+func Compute(period Period, at time.Time, oldAmount, newAmount int64, granularity Granularity, rounding Rounding) (Change, error) {
+	fraction, err := Remaining(period, at, granularity)
+	if err != nil {
+		return Change{}, err
+	}
+	credit, err := Prorate(oldAmount, fraction, rounding)
+	if err != nil {
+		return Change{}, err
+	}
+	charge, err := Prorate(newAmount, fraction, rounding)
+	if err != nil {
+		return Change{}, err
+	}
+	return Change{Fraction: fraction, Credit: credit, Charge: charge}, nil
+}
@@ -0,0 +1,113 @@
+package proration
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemaining(t *testing.T) {
+	at := func(day, hour int) time.Time { return time.Date(2026, 3, day, hour, 0, 0, 0, time.UTC) }
+	march := Period{Start: at(1, 0), End: time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)}
+
+	tests := []struct {
+		name        string
+		period      Period
+		at          time.Time
+		granularity Granularity
+		want        Fraction
+		wantErr     error
+	}{
+		{"first day", march, at(1, 0), Day, Fraction{Remaining: 31, Total: 31}, nil},
+		{"change day counts", march, at(11, 18), Day, Fraction{Remaining: 21, Total: 31}, nil},
+		{"last day", march, at(31, 23), Day, Fraction{Remaining: 1, Total: 31}, nil},
+		{"short first period", Period{Start: at(10, 12), End: at(15, 0)}, at(12, 18), Day, Fraction{Remaining: 3, Total: 5}, nil},
+		{"other zone", march, time.Date(2026, 3, 12, 1, 0, 0, 0, time.FixedZone("GET", 4*60*60)), Day,
+			Fraction{Remaining: 21, Total: 31}, nil},
+		{"seconds", Period{Start: at(1, 0), End: at(2, 0)}, at(1, 18), Second, Fraction{Remaining: 6 * 3600, Total: 24 * 3600}, nil},
+		{"seconds truncate", Period{Start: at(1, 0), End: at(1, 1)}, at(1, 0).Add(1500 * time.Millisecond), Second,
+			Fraction{Remaining: 3598, Total: 3600}, nil},
+		{"before the period", march, at(1, 0).Add(-time.Second), Day, Fraction{}, ErrOutsidePeriod},
+		{"at the end", march, march.End, Second, Fraction{}, ErrOutsidePeriod},
+		{"empty period", Period{Start: at(1, 0), End: at(1, 0)}, at(1, 0), Day, Fraction{}, ErrInvalidPeriod},
+		{"within a day", Period{Start: at(1, 0), End: at(1, 12)}, at(1, 6), Day, Fraction{}, ErrInvalidPeriod},
+		{"unknown granularity", march, at(5, 0), "HOUR", Fraction{}, ErrInvalidGranularity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fraction, err := Remaining(tt.period, tt.at, tt.granularity)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, fraction)
+		})
+	}
+}
+
+func TestProrate(t *testing.T) {
+	third := Fraction{Remaining: 1, Total: 3}
+	half := Fraction{Remaining: 1, Total: 2}
+
+	tests := []struct {
+		name     string
+		amount   int64
+		fraction Fraction
+		rounding Rounding
+		want     int64
+		wantErr  error
+	}{
+		{"whole period", 4900, Fraction{Remaining: 31, Total: 31}, DefaultRounding, 4900, nil},
+		{"nothing left", 4900, Fraction{Remaining: 0, Total: 31}, DefaultRounding, 0, nil},
+		{"rounds down below half", 100, third, DefaultRounding, 33, nil},
+		{"rounds up above half", 200, third, DefaultRounding, 67, nil},
+		{"half up", 5, half, DefaultRounding, 3, nil},
+		{"half up negative", -5, half, DefaultRounding, -3, nil},
+		{"half even to even", 5, half, Rounding{Mode: HalfEven, Increment: 1}, 2, nil},
+		{"half even from odd", 7, half, Rounding{Mode: HalfEven, Increment: 1}, 4, nil},
+		{"half even negative", -7, half, Rounding{Mode: HalfEven, Increment: 1}, -4, nil},
+		{"down", 200, third, Rounding{Mode: Down, Increment: 1}, 66, nil},
+		{"down negative", -200, third, Rounding{Mode: Down, Increment: 1}, -66, nil},
+		{"increment", 1000, Fraction{Remaining: 21, Total: 31}, Rounding{Mode: HalfUp, Increment: 5}, 675, nil},
+		{"increment half", 1010, half, Rounding{Mode: HalfUp, Increment: 5}, 505, nil},
+		{"large amount", math.MaxInt64, Fraction{Remaining: 30, Total: 31}, DefaultRounding, 8925843906633654007, nil},
+		{"overflow", math.MaxInt64, Fraction{Remaining: 1, Total: 1}, Rounding{Mode: HalfUp, Increment: 2}, 0, ErrOverflow},
+		{"more left than total", 100, Fraction{Remaining: 2, Total: 1}, DefaultRounding, 0, ErrInvalidPeriod},
+		{"zero increment", 100, half, Rounding{Mode: HalfUp}, 0, ErrInvalidRounding},
+		{"unknown mode", 100, half, Rounding{Mode: "CEILING", Increment: 1}, 0, ErrInvalidRounding},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amount, err := Prorate(tt.amount, tt.fraction, tt.rounding)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, amount)
+		})
+	}
+}
+
+func TestCompute(t *testing.T) {
+	april := Period{Start: time.Date(2026, 4, 15, 0, 0, 0, 0, time.UTC), End: time.Date(2026, 5, 15, 0, 0, 0, 0, time.UTC)}
+	at := time.Date(2026, 4, 25, 9, 30, 0, 0, time.UTC)
+
+	upgrade, err := Compute(april, at, 4900, 9900, Day, DefaultRounding)
+	require.NoError(t, err)
+	assert.Equal(t, Change{Fraction: Fraction{Remaining: 20, Total: 30}, Credit: 3267, Charge: 6600}, upgrade)
+
+	downgrade, err := Compute(april, at, 9900, 0, Day, DefaultRounding)
+	require.NoError(t, err)
+	assert.Equal(t, int64(6600), downgrade.Credit)
+	assert.Zero(t, downgrade.Charge)
+
+	_, err = Compute(april, april.End, 4900, 9900, Second, DefaultRounding)
+	assert.ErrorIs(t, err, ErrOutsidePeriod)
+}
@@ -0,0 +1,104 @@
+package fees
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrInvoiceNotFound    = errors.New("invoice not found")
+	ErrInvalidLegalEntity = errors.New("invalid legal entity")
+)
+
+// DefaultLegalEntity issues invoices for bills that don't name an entity.
+const DefaultLegalEntity = "PAVE"
+
+const maxLegalEntityLength = 16
+
+// ValidateLegalEntity accepts short upper-case codes such as "PAVE" or
+// "PAVEGE"; the code prefixes every invoice number the entity issues.
+func ValidateLegalEntity(entity string) error {
+	if entity == "" || len(entity) > maxLegalEntityLength {
+		return fmt.Errorf("%w: %q must be 1 to %d characters", ErrInvalidLegalEntity, entity, maxLegalEntityLength)
+	}
+	for _, r := range entity {
+		if (r < 'A' || r > 'Z') && (r < '0' || r > '9') {
+			return fmt.Errorf("%w: %q may only contain A-Z and 0-9", ErrInvalidLegalEntity, entity)
+		}
+	}
+	return nil
+}
+
+// FormatInvoiceNumber renders the sequence'th invoice of an entity, e.g.
+// PAVE-000042. Sequences start at 1 and have no gaps.
+func FormatInvoiceNumber(entity string, sequence int64) string {
+	return fmt.Sprintf("%s-%06d", entity, sequence)
+}
+
+// Invoice is the frozen record of a closed bill. It is written once, in the
+// same transaction that closes the bill, and never changes afterwards even
+// if the bill's items, fee schedules or tax rules do.
+type Invoice struct {
+	Number             string         `json:"number"`
+	LegalEntity        string         `json:"legalEntity"`
+	Sequence           int64          `json:"sequence"`
+	BillID             string         `json:"billId"`
+	CustomerID         string         `json:"customerId"`
+	Currency           Currency       `json:"currency"`
+	IssuedAt           time.Time      `json:"issuedAt"`
+	PeriodStart        *time.Time     `json:"periodStart,omitempty"`
+	PeriodEnd          *time.Time     `json:"periodEnd,omitempty"`
+	DueAt              *time.Time     `json:"dueAt,omitempty"`
+	LineItems          []LineItem     `json:"lineItems"`
+	SubtotalAmount     int64          `json:"subtotalAmount"`
+	TaxAmount          int64          `json:"taxAmount"`
+	TotalAmount        int64          `json:"totalAmount"`
+	Taxes              []TaxLine      `json:"taxes,omitempty"`
+	Discounts          []BillDiscount `json:"discounts,omitempty"`
+	FeeScheduleID      string         `json:"feeScheduleId,omitempty"`
+	FeeScheduleVersion *int           `json:"feeScheduleVersion,omitempty"`
+	// Supersedes is the bill's previous invoice when the bill was reopened
+	// and closed again; Corrects is the invoice of the bill an adjustment
+	// bill corrects. SupersededBy is not part of the issued document: it is
+	// filled in on read once a later invoice supersedes this one.
+	Supersedes   string `json:"supersedes,omitempty"`
+	Corrects     string `json:"corrects,omitempty"`
+	SupersededBy string `json:"supersededBy,omitempty"`
+}
+
+// newInvoice snapshots a bill being finalized. The number is filled in once
+// it has been allocated.
+func newInvoice(bill *FinalBill, issuedAt time.Time) *Invoice {
+	entity := bill.LegalEntity
+	if entity == "" {
+		entity = DefaultLegalEntity
+	}
+	items := bill.LineItems
+	if items == nil {
+		items = []LineItem{}
+	}
+	var dueAt *time.Time
+	if !bill.DueAt.IsZero() {
+		due := bill.DueAt
+		dueAt = &due
+	}
+	return &Invoice{
+		LegalEntity:        entity,
+		BillID:             bill.ID,
+		CustomerID:         bill.CustomerID,
+		Currency:           bill.Currency,
+		IssuedAt:           issuedAt,
+		PeriodStart:        bill.PeriodStart,
+		PeriodEnd:          bill.PeriodEnd,
+		DueAt:              dueAt,
+		LineItems:          items,
+		SubtotalAmount:     bill.SubtotalAmount,
+		TaxAmount:          bill.TaxAmount,
+		TotalAmount:        bill.TotalAmount,
+		Taxes:              bill.Taxes,
+		Discounts:          bill.AppliedDiscounts,
+		FeeScheduleID:      bill.FeeScheduleID,
+		FeeScheduleVersion: bill.FeeScheduleVersion,
+	}
+}
@@ -0,0 +1,77 @@
+package fees
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateLegalEntity(t *testing.T) {
+	tests := []struct {
+		entity  string
+		wantErr bool
+	}{
+		{"PAVE", false},
+		{"PAVEGE2", false},
+		{"", true},
+		{"pave", true},
+		{"PAVE-GE", true},
+		{"ABCDEFGHIJKLMNOPQ", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.entity, func(t *testing.T) {
+			err := ValidateLegalEntity(tt.entity)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidLegalEntity)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestFormatInvoiceNumber(t *testing.T) {
+	assert.Equal(t, "PAVE-000001", FormatInvoiceNumber("PAVE", 1))
+	assert.Equal(t, "PAVEGE-001234", FormatInvoiceNumber("PAVEGE", 1234))
+	assert.Equal(t, "PAVE-1234567", FormatInvoiceNumber("PAVE", 1234567))
+}
+
+func TestNewInvoice(t *testing.T) {
+	issuedAt := time.Date(2024, 3, 31, 23, 0, 0, 0, time.UTC)
+	version := 2
+	applied := int64(200)
+	bill := &FinalBill{
+		ID:                 "bill-1",
+		CustomerID:         "customer-1",
+		Currency:           GEL,
+		SubtotalAmount:     1800,
+		TaxAmount:          324,
+		TotalAmount:        2124,
+		Taxes:              []TaxLine{{RuleID: "ge-vat", RateBps: 1800, TaxableAmount: 1800, Amount: 324}},
+		FeeScheduleID:      "standard",
+		FeeScheduleVersion: &version,
+		LineItems:          []LineItem{{ID: "item-1", Description: "Usage", Amount: 2000}},
+		AppliedDiscounts:   []BillDiscount{{Code: "SPRING10", Kind: DiscountKindPercentage, PercentBps: 1000, AppliedAmount: &applied}},
+	}
+
+	invoice := newInvoice(bill, issuedAt)
+
+	assert.Equal(t, DefaultLegalEntity, invoice.LegalEntity)
+	assert.Empty(t, invoice.Number)
+	assert.Equal(t, "bill-1", invoice.BillID)
+	assert.Equal(t, "customer-1", invoice.CustomerID)
+	assert.Equal(t, issuedAt, invoice.IssuedAt)
+	assert.Equal(t, bill.LineItems, invoice.LineItems)
+	assert.Equal(t, int64(2124), invoice.TotalAmount)
+	assert.Equal(t, bill.Taxes, invoice.Taxes)
+	assert.Equal(t, bill.AppliedDiscounts, invoice.Discounts)
+	assert.Equal(t, &version, invoice.FeeScheduleVersion)
+
+	t.Run("keeps entity and never has nil items", func(t *testing.T) {
+		invoice := newInvoice(&FinalBill{ID: "bill-2", LegalEntity: "PAVEGE"}, issuedAt)
+		assert.Equal(t, "PAVEGE", invoice.LegalEntity)
+		assert.NotNil(t, invoice.LineItems)
+	})
+}
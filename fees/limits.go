@@ -0,0 +1,129 @@
+package fees
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrBillingLimitsNotFound = errors.New("billing limits not found")
+	ErrInvalidBillingLimits  = errors.New("invalid billing limits")
+)
+
+// BillingLimits bound what a bill can come to before discounts and tax. A
+// bill below MinimumAmount at close gets a TRUE_UP item for the difference;
+// one above MaximumAmount gets a CAP_CREDIT item bringing it down. Either
+// bound may be left unset.
+type BillingLimits struct {
+	MinimumAmount *int64 `json:"minimumAmount,omitempty"`
+	MaximumAmount *int64 `json:"maximumAmount,omitempty"`
+}
+
+func (l BillingLimits) Validate() error {
+	if l.MinimumAmount != nil && *l.MinimumAmount < 0 {
+		return fmt.Errorf("%w: minimumAmount cannot be negative", ErrInvalidBillingLimits)
+	}
+	if l.MaximumAmount != nil && *l.MaximumAmount < 0 {
+		return fmt.Errorf("%w: maximumAmount cannot be negative", ErrInvalidBillingLimits)
+	}
+	if l.MinimumAmount != nil && l.MaximumAmount != nil && *l.MinimumAmount > *l.MaximumAmount {
+		return fmt.Errorf("%w: minimumAmount %d is above maximumAmount %d", ErrInvalidBillingLimits, *l.MinimumAmount, *l.MaximumAmount)
+	}
+	return nil
+}
+
+func (l BillingLimits) IsZero() bool {
+	return l.MinimumAmount == nil && l.MaximumAmount == nil
+}
+
+// Override returns l with any bound set in override replacing its own, so a
+// bill's own limits win over its customer's.
+func (l BillingLimits) Override(override BillingLimits) BillingLimits {
+	if override.MinimumAmount != nil {
+		l.MinimumAmount = override.MinimumAmount
+	}
+	if override.MaximumAmount != nil {
+		l.MaximumAmount = override.MaximumAmount
+	}
+	return l
+}
+
+// Apply returns the system-generated item that brings total within the
+// limits, or nil if it already is.
+func (l BillingLimits) Apply(total int64, currency Currency, at time.Time) *LineItem {
+	var amount int64
+	var kind LineItemKind
+	var description string
+	switch {
+	case l.MinimumAmount != nil && total < *l.MinimumAmount:
+		amount = *l.MinimumAmount - total
+		kind = LineItemKindTrueUp
+		description = fmt.Sprintf("Minimum charge true-up to %s %s", currency, currency.FormatAmount(*l.MinimumAmount))
+	case l.MaximumAmount != nil && total > *l.MaximumAmount:
+		amount = *l.MaximumAmount - total
+		kind = LineItemKindCapCredit
+		description = fmt.Sprintf("Maximum charge cap at %s %s", currency, currency.FormatAmount(*l.MaximumAmount))
+	default:
+		return nil
+	}
+
+	return &LineItem{
+		Description:     description,
+		Amount:          amount,
+		Quantity:        QuantityOne,
+		UnitPrice:       amount,
+		Timestamp:       at,
+		Kind:            kind,
+		SystemGenerated: true,
+	}
+}
+
+// CustomerBillingLimits apply to every bill a customer has in Currency,
+// unless the bill sets its own.
+type CustomerBillingLimits struct {
+	CustomerID    string    `json:"customerId"`
+	Currency      Currency  `json:"currency"`
+	MinimumAmount *int64    `json:"minimumAmount,omitempty"`
+	MaximumAmount *int64    `json:"maximumAmount,omitempty"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+func (c *CustomerBillingLimits) Limits() BillingLimits {
+	return BillingLimits{MinimumAmount: c.MinimumAmount, MaximumAmount: c.MaximumAmount}
+}
+
+type SaveBillingLimitsRequest struct {
+	Currency      Currency `json:"currency"`
+	MinimumAmount *int64   `json:"minimumAmount,omitempty"`
+	MaximumAmount *int64   `json:"maximumAmount,omitempty"`
+}
+
+func (r *SaveBillingLimitsRequest) Validate() error {
+	if err := r.Currency.Validate(); err != nil {
+		return err
+	}
+	limits := r.limits()
+	if limits.IsZero() {
+		return fmt.Errorf("%w: set minimumAmount, maximumAmount or both", ErrInvalidBillingLimits)
+	}
+	return limits.Validate()
+}
+
+func (r *SaveBillingLimitsRequest) limits() BillingLimits {
+	return BillingLimits{MinimumAmount: r.MinimumAmount, MaximumAmount: r.MaximumAmount}
+}
+
+func (r *SaveBillingLimitsRequest) toCustomerLimits(customerID string) *CustomerBillingLimits {
+	return &CustomerBillingLimits{
+		CustomerID:    strings.TrimSpace(customerID),
+		Currency:      r.Currency,
+		MinimumAmount: r.MinimumAmount,
+		MaximumAmount: r.MaximumAmount,
+	}
+}
+
+type GetBillingLimitsParams struct {
+	Currency Currency `query:"currency"`
+}
@@ -0,0 +1,100 @@
+package fees
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBillingLimits_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		limits  BillingLimits
+		wantErr bool
+	}{
+		{"none", BillingLimits{}, false},
+		{"minimum only", BillingLimits{MinimumAmount: int64Ptr(5000)}, false},
+		{"both", BillingLimits{MinimumAmount: int64Ptr(5000), MaximumAmount: int64Ptr(1000000)}, false},
+		{"equal bounds", BillingLimits{MinimumAmount: int64Ptr(5000), MaximumAmount: int64Ptr(5000)}, false},
+		{"negative minimum", BillingLimits{MinimumAmount: int64Ptr(-1)}, true},
+		{"negative maximum", BillingLimits{MaximumAmount: int64Ptr(-1)}, true},
+		{"minimum above maximum", BillingLimits{MinimumAmount: int64Ptr(5000), MaximumAmount: int64Ptr(4999)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.limits.Validate()
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidBillingLimits)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestBillingLimits_Override(t *testing.T) {
+	customer := BillingLimits{MinimumAmount: int64Ptr(5000), MaximumAmount: int64Ptr(1000000)}
+
+	merged := customer.Override(BillingLimits{MaximumAmount: int64Ptr(20000)})
+
+	assert.Equal(t, int64(5000), *merged.MinimumAmount)
+	assert.Equal(t, int64(20000), *merged.MaximumAmount)
+	assert.Equal(t, customer, customer.Override(BillingLimits{}))
+}
+
+func TestBillingLimits_Apply(t *testing.T) {
+	at := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+	limits := BillingLimits{MinimumAmount: int64Ptr(5000), MaximumAmount: int64Ptr(1000000)}
+
+	t.Run("true-up below minimum", func(t *testing.T) {
+		item := limits.Apply(1250, USD, at)
+
+		require.NotNil(t, item)
+		assert.Equal(t, LineItemKindTrueUp, item.Kind)
+		assert.Equal(t, int64(3750), item.Amount)
+		assert.Equal(t, "Minimum charge true-up to USD 50.00", item.Description)
+		assert.True(t, item.SystemGenerated)
+		assert.Equal(t, at, item.Timestamp)
+		assert.NoError(t, item.Validate())
+	})
+
+	t.Run("true-up from a negative total", func(t *testing.T) {
+		item := limits.Apply(-500, USD, at)
+
+		require.NotNil(t, item)
+		assert.Equal(t, int64(5500), item.Amount)
+	})
+
+	t.Run("cap credit above maximum", func(t *testing.T) {
+		item := limits.Apply(1200000, USD, at)
+
+		require.NotNil(t, item)
+		assert.Equal(t, LineItemKindCapCredit, item.Kind)
+		assert.Equal(t, int64(-200000), item.Amount)
+		assert.Equal(t, "Maximum charge cap at USD 10000.00", item.Description)
+		assert.NoError(t, item.Validate())
+	})
+
+	t.Run("within limits", func(t *testing.T) {
+		assert.Nil(t, limits.Apply(5000, USD, at))
+		assert.Nil(t, limits.Apply(1000000, USD, at))
+		assert.Nil(t, BillingLimits{}.Apply(0, USD, at))
+	})
+
+	t.Run("formats in the bill currency", func(t *testing.T) {
+		item := BillingLimits{MinimumAmount: int64Ptr(5000)}.Apply(0, "JPY", at)
+
+		require.NotNil(t, item)
+		assert.Equal(t, "Minimum charge true-up to JPY 5000", item.Description)
+	})
+}
+
+func TestSaveBillingLimitsRequest_Validate(t *testing.T) {
+	assert.NoError(t, (&SaveBillingLimitsRequest{Currency: USD, MinimumAmount: int64Ptr(5000)}).Validate())
+	assert.ErrorIs(t, (&SaveBillingLimitsRequest{Currency: USD}).Validate(), ErrInvalidBillingLimits)
+	assert.ErrorIs(t, (&SaveBillingLimitsRequest{Currency: "EUR", MinimumAmount: int64Ptr(5000)}).Validate(), ErrInvalidCurrency)
+	assert.ErrorIs(t, (&SaveBillingLimitsRequest{Currency: USD, MaximumAmount: int64Ptr(-1)}).Validate(), ErrInvalidBillingLimits)
+}
@@ -0,0 +1,373 @@
+package fees
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMeterNotFound     = errors.New("meter not found")
+	ErrInvalidMeter      = errors.New("invalid meter")
+	ErrMeterExists       = errors.New("meter already exists")
+	ErrInvalidUsageEvent = errors.New("invalid usage event")
+)
+
+const (
+	maxMeterNameLength = 64
+	maxUsageBatchSize  = 500
+	// maxOpenBillsPerCustomer caps how many open bills usage is routed
+	// between.
+	maxOpenBillsPerCustomer = 100
+	defaultUsageEventsLimit = 50
+	// maxUsageClockSkew is how far in the future an event's timestamp may be.
+	maxUsageClockSkew = 5 * time.Minute
+)
+
+// MeterAggregation is how a meter's events on one bill combine into the
+// quantity that is billed.
+type MeterAggregation string
+
+const (
+	// Sum adds up every event, e.g. API calls.
+	MeterAggregationSum MeterAggregation = "SUM"
+	// Max bills the highest reading, e.g. peak seats.
+	MeterAggregationMax MeterAggregation = "MAX"
+	// Last bills the latest reading by event timestamp, e.g. storage used.
+	MeterAggregationLast MeterAggregation = "LAST"
+)
+
+func (a MeterAggregation) IsValid() bool {
+	switch a {
+	case MeterAggregationSum, MeterAggregationMax, MeterAggregationLast:
+		return true
+	}
+	return false
+}
+
+// NormalizeMeterName makes meter names case-insensitive: " API_Calls " is
+// api_calls.
+func NormalizeMeterName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// Meter defines a kind of usage and what it costs. UnitPrices holds the
+// price of one unit in each currency's minor unit; usage only goes on bills
+// in a currency the meter is priced in. Meters can't be changed once
+// created, so a bill's usage is always priced on the terms its events were
+// recorded under.
+type Meter struct {
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Unit        string             `json:"unit,omitempty"`
+	Aggregation MeterAggregation   `json:"aggregation"`
+	UnitPrices  map[Currency]int64 `json:"unitPrices"`
+	CreatedAt   time.Time          `json:"createdAt"`
+}
+
+func (m *Meter) Validate() error {
+	if m.Name == "" || len(m.Name) > maxMeterNameLength {
+		return fmt.Errorf("%w: name must be 1 to %d characters", ErrInvalidMeter, maxMeterNameLength)
+	}
+	for _, r := range m.Name {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '_' && r != '-' && r != '.' {
+			return fmt.Errorf("%w: name %q may only contain a-z, 0-9, '_', '-' and '.'", ErrInvalidMeter, m.Name)
+		}
+	}
+	if strings.TrimSpace(m.Description) == "" {
+		return ErrEmptyDescription
+	}
+	if !m.Aggregation.IsValid() {
+		return fmt.Errorf("%w: unknown aggregation %q. Supported aggregations: SUM, MAX, LAST", ErrInvalidMeter, m.Aggregation)
+	}
+	if len(m.UnitPrices) == 0 {
+		return fmt.Errorf("%w: at least one unit price is required", ErrInvalidMeter)
+	}
+	for currency, price := range m.UnitPrices {
+		if err := currency.Validate(); err != nil {
+			return err
+		}
+		if price < 0 {
+			return fmt.Errorf("%w: unit price in %s cannot be negative", ErrInvalidMeter, currency)
+		}
+	}
+	return nil
+}
+
+func (m *Meter) PricedIn(currency Currency) bool {
+	_, ok := m.UnitPrices[currency]
+	return ok
+}
+
+type UsageEventStatus string
+
+const (
+	// Pending events are on an open bill, waiting for it to close.
+	UsageEventStatusPending UsageEventStatus = "PENDING"
+	// Billed events were priced onto their bill when it closed.
+	UsageEventStatusBilled UsageEventStatus = "BILLED"
+	// Flagged events could not be billed and need someone to look at them;
+	// FlagReason says why.
+	UsageEventStatusFlagged UsageEventStatus = "FLAGGED"
+)
+
+// usageClosingFlagReason flags events that reached a bill after its usage
+// was priced for closing.
+const usageClosingFlagReason = "arrived while the bill was closing"
+
+// UsageEvent is one raw usage reading. Late events happened during a bill
+// that had already closed and were put on the customer's next open bill
+// instead.
+type UsageEvent struct {
+	ID             string           `json:"id"`
+	CustomerID     string           `json:"customerId"`
+	Meter          string           `json:"meter"`
+	Quantity       Quantity         `json:"quantity"`
+	Timestamp      time.Time        `json:"timestamp"`
+	IdempotencyKey string           `json:"idempotencyKey,omitempty"`
+	BillID         string           `json:"billId,omitempty"`
+	Status         UsageEventStatus `json:"status"`
+	Late           bool             `json:"late,omitempty"`
+	FlagReason     string           `json:"flagReason,omitempty"`
+	ReceivedAt     time.Time        `json:"receivedAt"`
+}
+
+func newUsageEventID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("failed to generate usage event ID: %v", err))
+	}
+	return "usage-" + hex.EncodeToString(b)
+}
+
+// SameUsage reports whether a retry describes the event already recorded
+// under its idempotency key.
+func (e *UsageEvent) SameUsage(other *UsageEvent) bool {
+	return e.CustomerID == other.CustomerID && e.Meter == other.Meter && e.Quantity == other.Quantity &&
+		e.Timestamp.Equal(other.Timestamp)
+}
+
+// billingStart is when a bill starts taking usage. Bills from before billing
+// periods existed start when they were created.
+func (b *Bill) billingStart() time.Time {
+	if b.PeriodStart != nil {
+		return *b.PeriodStart
+	}
+	return b.CreatedAt
+}
+
+// RouteUsageEvent picks which of a customer's open bills usage of meter at
+// the given time goes on. Only bills in a currency the meter is priced in
+// are considered, and never adjustment bills. The bill whose period covers
+// the event wins, the earliest one if several do. An event from before every
+// open bill's period belongs to a bill that has already closed, so it goes
+// on the earliest open bill and is late. It returns nil when no open bill can
+// take the event: there is none in a priced currency, or the event falls
+// after the end of every open period and its bill doesn't exist yet.
+func RouteUsageEvent(meter *Meter, openBills []*Bill, at time.Time) (bill *Bill, late bool) {
+	var candidates []*Bill
+	for _, b := range openBills {
+		if b.Status == BillStatusOpen && !b.IsAdjustment() && meter.PricedIn(b.Currency) {
+			candidates = append(candidates, b)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].billingStart().Before(candidates[j].billingStart())
+	})
+
+	for _, b := range candidates {
+		if !at.Before(b.billingStart()) && (b.PeriodEnd == nil || at.Before(*b.PeriodEnd)) {
+			return b, false
+		}
+	}
+	if len(candidates) > 0 && at.Before(candidates[0].billingStart()) {
+		return candidates[0], true
+	}
+	return nil, false
+}
+
+// assign puts the event on the open bill RouteUsageEvent picks for it, or
+// flags it if there is none.
+func (e *UsageEvent) assign(meter *Meter, openBills []*Bill) {
+	bill, late := RouteUsageEvent(meter, openBills, e.Timestamp)
+	if bill == nil {
+		e.BillID, e.Late = "", false
+		e.Status = UsageEventStatusFlagged
+		e.FlagReason = fmt.Sprintf("no open bill priced for %s covers %s", meter.Name, e.Timestamp.Format(time.RFC3339))
+		return
+	}
+	e.BillID, e.Late = bill.ID, late
+	e.Status = UsageEventStatusPending
+	e.FlagReason = ""
+}
+
+// MeterUsage is a meter's aggregated usage on one bill.
+type MeterUsage struct {
+	Meter    Meter    `json:"meter"`
+	Quantity Quantity `json:"quantity"`
+	Events   int      `json:"events"`
+}
+
+// BillUsage is everything metered on a bill so far. LastEventSeq is the
+// sequence number of the newest event included.
+type BillUsage struct {
+	Meters       []MeterUsage
+	LastEventSeq int64
+}
+
+// LineItem prices the usage in currency. Usage that comes to nothing
+// produces no item.
+func (u *MeterUsage) LineItem(currency Currency, at time.Time) (*LineItem, error) {
+	unitPrice, ok := u.Meter.UnitPrices[currency]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s has no price in %s", ErrInvalidMeter, u.Meter.Name, currency)
+	}
+	amount, err := ExtendedAmount(u.Quantity, unitPrice)
+	if err != nil {
+		return nil, err
+	}
+	if amount == 0 {
+		return nil, nil
+	}
+
+	description := u.Meter.Description
+	if u.Meter.Unit != "" {
+		description = fmt.Sprintf("%s (%s %s)", u.Meter.Description, u.Quantity, u.Meter.Unit)
+	}
+	return &LineItem{
+		Description:     description,
+		Amount:          amount,
+		Quantity:        u.Quantity,
+		UnitPrice:       unitPrice,
+		Timestamp:       at,
+		Kind:            LineItemKindUsage,
+		SystemGenerated: true,
+	}, nil
+}
+
+// Price prices every meter's usage in currency, in meter name order.
+func (u *BillUsage) Price(currency Currency, at time.Time) ([]LineItem, error) {
+	var items []LineItem
+	for i := range u.Meters {
+		item, err := u.Meters[i].LineItem(currency, at)
+		if err != nil {
+			return nil, err
+		}
+		if item != nil {
+			items = append(items, *item)
+		}
+	}
+	return items, nil
+}
+
+type CreateMeterRequest struct {
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Unit        string             `json:"unit,omitempty"`
+	Aggregation MeterAggregation   `json:"aggregation"`
+	UnitPrices  map[Currency]int64 `json:"unitPrices"`
+}
+
+func (r *CreateMeterRequest) Validate() error {
+	return r.toMeter().Validate()
+}
+
+func (r *CreateMeterRequest) toMeter() *Meter {
+	return &Meter{
+		Name:        NormalizeMeterName(r.Name),
+		Description: r.Description,
+		Unit:        strings.TrimSpace(r.Unit),
+		Aggregation: r.Aggregation,
+		UnitPrices:  r.UnitPrices,
+	}
+}
+
+type UsageEventInput struct {
+	CustomerID string   `json:"customerId"`
+	Meter      string   `json:"meter"`
+	Quantity   Quantity `json:"quantity"`
+	// Timestamp is when the usage happened and defaults to when it is
+	// received.
+	Timestamp      *time.Time `json:"timestamp,omitempty"`
+	IdempotencyKey string     `json:"idempotencyKey,omitempty"`
+}
+
+type RecordUsageRequest struct {
+	Events []UsageEventInput `json:"events"`
+}
+
+func (r *RecordUsageRequest) Validate(now time.Time) error {
+	if len(r.Events) == 0 || len(r.Events) > maxUsageBatchSize {
+		return fmt.Errorf("%w: send 1 to %d events at a time", ErrInvalidUsageEvent, maxUsageBatchSize)
+	}
+	for i := range r.Events {
+		if err := r.Events[i].validate(now); err != nil {
+			return fmt.Errorf("event %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (e *UsageEventInput) validate(now time.Time) error {
+	if strings.TrimSpace(e.CustomerID) == "" {
+		return ErrEmptyCustomerID
+	}
+	if NormalizeMeterName(e.Meter) == "" {
+		return fmt.Errorf("%w: meter cannot be empty", ErrInvalidUsageEvent)
+	}
+	if e.Quantity < 0 {
+		return fmt.Errorf("%w: quantity cannot be negative", ErrInvalidUsageEvent)
+	}
+	if e.Timestamp != nil && e.Timestamp.After(now.Add(maxUsageClockSkew)) {
+		return fmt.Errorf("%w: timestamp %s is in the future", ErrInvalidUsageEvent, e.Timestamp.Format(time.RFC3339))
+	}
+	return validateIdempotencyKey(e.IdempotencyKey)
+}
+
+func (e *UsageEventInput) toUsageEvent(now time.Time) *UsageEvent {
+	timestamp := now
+	if e.Timestamp != nil {
+		timestamp = *e.Timestamp
+	}
+	return &UsageEvent{
+		ID:             newUsageEventID(),
+		CustomerID:     strings.TrimSpace(e.CustomerID),
+		Meter:          NormalizeMeterName(e.Meter),
+		Quantity:       e.Quantity,
+		Timestamp:      timestamp,
+		IdempotencyKey: e.IdempotencyKey,
+		ReceivedAt:     now,
+	}
+}
+
+type RecordUsageResponse struct {
+	Events []*UsageEvent `json:"events"`
+}
+
+type GetBillUsageResponse struct {
+	BillID string       `json:"billId"`
+	Meters []MeterUsage `json:"meters"`
+	// LineItems are the usage charges the bill would get if it closed now.
+	LineItems []LineItem `json:"lineItems"`
+}
+
+func (s UsageEventStatus) IsValid() bool {
+	switch s {
+	case UsageEventStatusPending, UsageEventStatusBilled, UsageEventStatusFlagged:
+		return true
+	}
+	return false
+}
+
+type ListUsageEventsParams struct {
+	Status UsageEventStatus `query:"status"`
+	Limit  int              `query:"limit"`
+}
+
+type ListUsageEventsResponse struct {
+	Events []*UsageEvent `json:"events"`
+}
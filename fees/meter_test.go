@@ -0,0 +1,179 @@
+package fees
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testMeter() *Meter {
+	return &Meter{
+		Name:        "api_calls",
+		Description: "API calls",
+		Unit:        "calls",
+		Aggregation: MeterAggregationSum,
+		UnitPrices:  map[Currency]int64{USD: 2, GEL: 5},
+	}
+}
+
+func TestMeter_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(m *Meter)
+		wantErr error
+	}{
+		{"valid", func(m *Meter) {}, nil},
+		{"free meter", func(m *Meter) { m.UnitPrices = map[Currency]int64{USD: 0} }, nil},
+		{"empty name", func(m *Meter) { m.Name = "" }, ErrInvalidMeter},
+		{"upper-case name", func(m *Meter) { m.Name = "API_CALLS" }, ErrInvalidMeter},
+		{"empty description", func(m *Meter) { m.Description = " " }, ErrEmptyDescription},
+		{"unknown aggregation", func(m *Meter) { m.Aggregation = "AVG" }, ErrInvalidMeter},
+		{"no prices", func(m *Meter) { m.UnitPrices = nil }, ErrInvalidMeter},
+		{"negative price", func(m *Meter) { m.UnitPrices[USD] = -1 }, ErrInvalidMeter},
+		{"unknown currency", func(m *Meter) { m.UnitPrices = map[Currency]int64{"ABC": 1} }, ErrInvalidCurrency},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meter := testMeter()
+			tt.modify(meter)
+			err := meter.Validate()
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCreateMeterRequest_NormalizesName(t *testing.T) {
+	req := &CreateMeterRequest{Name: " API_Calls ", Description: "API calls", Aggregation: MeterAggregationSum,
+		UnitPrices: map[Currency]int64{USD: 2}}
+
+	require.NoError(t, req.Validate())
+	assert.Equal(t, "api_calls", req.toMeter().Name)
+}
+
+func TestRouteUsageEvent(t *testing.T) {
+	at := func(day int) time.Time { return time.Date(2026, 3, day, 12, 0, 0, 0, time.UTC) }
+	period := func(id string, currency Currency, start, end int) *Bill {
+		startAt, endAt := at(start), at(end)
+		return &Bill{ID: id, Currency: currency, Status: BillStatusOpen, PeriodStart: &startAt, PeriodEnd: &endAt}
+	}
+	march := period("bill-march", USD, 1, 31)
+	april := period("bill-april", USD, 31, 30)
+	april.PeriodEnd = nil
+	lari := period("bill-gel", GEL, 1, 31)
+	euro := period("bill-eur", "EUR", 1, 31)
+	adjustment := period("bill-adjustment", USD, 1, 31)
+	adjustment.CorrectsBillID = "bill-february"
+
+	tests := []struct {
+		name     string
+		bills    []*Bill
+		at       time.Time
+		wantBill string
+		wantLate bool
+	}{
+		{"inside period", []*Bill{march}, at(10), "bill-march", false},
+		{"earliest covering bill", []*Bill{lari, march}, at(10), "bill-gel", false},
+		{"open-ended period", []*Bill{april}, at(31).Add(48 * time.Hour), "bill-april", false},
+		{"after the last period", []*Bill{march}, at(31), "", false},
+		{"before every period is late", []*Bill{april}, at(10), "bill-april", true},
+		{"currency not priced", []*Bill{euro}, at(10), "", false},
+		{"adjustment bills skipped", []*Bill{adjustment, march}, at(10), "bill-march", false},
+		{"no open bills", nil, at(10), "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bill, late := RouteUsageEvent(testMeter(), tt.bills, tt.at)
+			if tt.wantBill == "" {
+				assert.Nil(t, bill)
+			} else {
+				require.NotNil(t, bill)
+				assert.Equal(t, tt.wantBill, bill.ID)
+			}
+			assert.Equal(t, tt.wantLate, late)
+		})
+	}
+}
+
+func TestUsageEvent_Assign(t *testing.T) {
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	bill := &Bill{ID: "bill-1", Currency: USD, Status: BillStatusOpen, PeriodStart: &start}
+
+	event := &UsageEvent{Timestamp: start.Add(-time.Hour)}
+	event.assign(testMeter(), []*Bill{bill})
+	assert.Equal(t, "bill-1", event.BillID)
+	assert.Equal(t, UsageEventStatusPending, event.Status)
+	assert.True(t, event.Late)
+
+	event.assign(testMeter(), nil)
+	assert.Empty(t, event.BillID)
+	assert.Equal(t, UsageEventStatusFlagged, event.Status)
+	assert.False(t, event.Late)
+	assert.Contains(t, event.FlagReason, "api_calls")
+}
+
+func TestBillUsage_Price(t *testing.T) {
+	now := time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+	storage := Meter{Name: "storage", Description: "Storage", Aggregation: MeterAggregationLast,
+		UnitPrices: map[Currency]int64{USD: 10}}
+	usage := &BillUsage{Meters: []MeterUsage{
+		{Meter: *testMeter(), Quantity: 1500 * QuantityOne, Events: 3},
+		{Meter: storage, Quantity: 2500000, Events: 1},
+		{Meter: *testMeter(), Quantity: 0, Events: 1},
+	}}
+
+	items, err := usage.Price(USD, now)
+
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	assert.Equal(t, LineItem{Description: "API calls (1500 calls)", Amount: 3000, Quantity: 1500 * QuantityOne, UnitPrice: 2,
+		Timestamp: now, Kind: LineItemKindUsage, SystemGenerated: true}, items[0])
+	assert.Equal(t, "Storage", items[1].Description)
+	assert.Equal(t, int64(25), items[1].Amount)
+
+	_, err = usage.Price("EUR", now)
+	assert.ErrorIs(t, err, ErrInvalidMeter)
+}
+
+func TestRecordUsageRequest_Validate(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	future := now.Add(time.Hour)
+	event := func(modify func(e *UsageEventInput)) RecordUsageRequest {
+		e := UsageEventInput{CustomerID: "customer-1", Meter: "api_calls", Quantity: QuantityOne}
+		modify(&e)
+		return RecordUsageRequest{Events: []UsageEventInput{e}}
+	}
+
+	tests := []struct {
+		name    string
+		req     RecordUsageRequest
+		wantErr error
+	}{
+		{"valid", event(func(e *UsageEventInput) {}), nil},
+		{"zero reading", event(func(e *UsageEventInput) { e.Quantity = 0 }), nil},
+		{"empty batch", RecordUsageRequest{}, ErrInvalidUsageEvent},
+		{"batch too large", RecordUsageRequest{Events: make([]UsageEventInput, maxUsageBatchSize+1)}, ErrInvalidUsageEvent},
+		{"no customer", event(func(e *UsageEventInput) { e.CustomerID = "" }), ErrEmptyCustomerID},
+		{"no meter", event(func(e *UsageEventInput) { e.Meter = " " }), ErrInvalidUsageEvent},
+		{"negative quantity", event(func(e *UsageEventInput) { e.Quantity = -1 }), ErrInvalidUsageEvent},
+		{"future timestamp", event(func(e *UsageEventInput) { e.Timestamp = &future }), ErrInvalidUsageEvent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate(now)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+		})
+	}
+}
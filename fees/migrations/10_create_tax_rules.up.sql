@@ -0,0 +1,24 @@
+-- Tax rules evaluated when a bill closes
+CREATE TABLE tax_rules (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    jurisdiction TEXT NOT NULL,
+    currency TEXT NOT NULL,
+    rate_bps BIGINT NOT NULL,
+    inclusive BOOLEAN NOT NULL DEFAULT FALSE,
+    exempt_customers JSONB NOT NULL DEFAULT '[]',
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_tax_rules_currency ON tax_rules(currency);
+
+-- Georgian VAT, charged on top of GEL bills
+INSERT INTO tax_rules (id, name, jurisdiction, currency, rate_bps, inclusive, updated_at)
+VALUES ('ge-vat', 'VAT', 'GE', 'GEL', 1800, FALSE, NOW());
+
+-- Closed bills keep subtotal, tax and grand total (total_amount) separately
+ALTER TABLE bills ADD COLUMN subtotal_amount BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE bills ADD COLUMN tax_amount BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE bills ADD COLUMN tax_lines JSONB;
+
+UPDATE bills SET subtotal_amount = total_amount WHERE status = 'CLOSED';
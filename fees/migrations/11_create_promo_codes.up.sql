@@ -0,0 +1,31 @@
+-- Promo codes customers can attach to open bills
+CREATE TABLE promo_codes (
+    code TEXT PRIMARY KEY,
+    description TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    percent_bps BIGINT NOT NULL DEFAULT 0,
+    amount BIGINT NOT NULL DEFAULT 0,
+    currency TEXT,
+    valid_from TIMESTAMPTZ,
+    valid_until TIMESTAMPTZ,
+    max_uses_per_customer INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+-- Codes attached to bills, with the terms copied at attach time; applied_amount
+-- is filled in when the bill closes
+CREATE TABLE bill_discounts (
+    bill_id TEXT NOT NULL REFERENCES bills(id),
+    code TEXT NOT NULL REFERENCES promo_codes(code),
+    customer_id TEXT NOT NULL,
+    description TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    percent_bps BIGINT NOT NULL DEFAULT 0,
+    amount BIGINT NOT NULL DEFAULT 0,
+    attached_at TIMESTAMPTZ NOT NULL,
+    applied_amount BIGINT,
+    PRIMARY KEY (bill_id, code)
+);
+
+-- Per-customer usage limits count rows here
+CREATE INDEX idx_bill_discounts_customer ON bill_discounts (customer_id, code);
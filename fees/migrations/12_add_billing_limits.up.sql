@@ -0,0 +1,18 @@
+-- Minimum charge and maximum cap enforced when a bill closes
+CREATE TABLE customer_billing_limits (
+    customer_id TEXT NOT NULL,
+    currency TEXT NOT NULL,
+    minimum_amount BIGINT,
+    maximum_amount BIGINT,
+    updated_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (customer_id, currency)
+);
+
+-- Per-bill overrides of the customer's limits
+ALTER TABLE bills ADD COLUMN minimum_amount BIGINT;
+ALTER TABLE bills ADD COLUMN maximum_amount BIGINT;
+
+-- Items added by the bill itself at close, as opposed to sent by clients
+ALTER TABLE line_items ADD COLUMN system_generated BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE line_items SET system_generated = TRUE WHERE kind IN ('FEE', 'DISCOUNT');
@@ -0,0 +1,35 @@
+-- One gap-free invoice sequence per legal entity
+CREATE TABLE invoice_sequences (
+    legal_entity TEXT PRIMARY KEY,
+    last_number BIGINT NOT NULL
+);
+
+-- Invoices issued when bills close. document is the full snapshot served by the API
+CREATE TABLE invoices (
+    number TEXT PRIMARY KEY,
+    legal_entity TEXT NOT NULL,
+    sequence BIGINT NOT NULL,
+    bill_id TEXT NOT NULL UNIQUE REFERENCES bills(id),
+    customer_id TEXT NOT NULL,
+    currency TEXT NOT NULL,
+    total_amount BIGINT NOT NULL,
+    issued_at TIMESTAMPTZ NOT NULL,
+    document JSONB NOT NULL,
+    UNIQUE (legal_entity, sequence)
+);
+
+CREATE INDEX idx_invoices_customer_id ON invoices(customer_id);
+
+-- Issued invoices are never edited or removed
+CREATE FUNCTION reject_invoice_change() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'invoice % is immutable', OLD.number;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER invoices_immutable
+    BEFORE UPDATE OR DELETE ON invoices
+    FOR EACH ROW EXECUTE FUNCTION reject_invoice_change();
+
+ALTER TABLE bills ADD COLUMN legal_entity TEXT NOT NULL DEFAULT 'PAVE';
+ALTER TABLE bills ADD COLUMN invoice_number TEXT;
@@ -0,0 +1,15 @@
+-- Money received against closed bills
+CREATE TABLE payments (
+    id TEXT PRIMARY KEY,
+    bill_id TEXT NOT NULL REFERENCES bills(id),
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    currency TEXT NOT NULL,
+    method TEXT NOT NULL,
+    external_reference TEXT,
+    received_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    UNIQUE (bill_id, external_reference)
+);
+
+-- Running sum of the bill's payments, kept in the same transaction as each insert
+ALTER TABLE bills ADD COLUMN paid_amount BIGINT NOT NULL DEFAULT 0;
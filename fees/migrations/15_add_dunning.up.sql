@@ -0,0 +1,5 @@
+-- Payment terms set at creation; the due date and dunning progress are set once the bill closes
+ALTER TABLE bills ADD COLUMN payment_terms_days INT;
+ALTER TABLE bills ADD COLUMN due_at TIMESTAMPTZ;
+ALTER TABLE bills ADD COLUMN dunning_status TEXT;
+ALTER TABLE bills ADD COLUMN dunning_stage INT NOT NULL DEFAULT 0;
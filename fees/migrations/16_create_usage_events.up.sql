@@ -0,0 +1,30 @@
+-- Meters define metered usage and its unit price per currency
+CREATE TABLE meters (
+    name TEXT PRIMARY KEY,
+    description TEXT NOT NULL,
+    unit TEXT NOT NULL DEFAULT '',
+    aggregation TEXT NOT NULL,
+    unit_prices JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+-- Raw usage events. seq orders events so closing a bill can tell which ones
+-- were priced; quantity is in millionths like line item quantities
+CREATE TABLE usage_events (
+    seq BIGSERIAL PRIMARY KEY,
+    id TEXT NOT NULL UNIQUE,
+    customer_id TEXT NOT NULL,
+    meter TEXT NOT NULL REFERENCES meters(name),
+    quantity BIGINT NOT NULL CHECK (quantity >= 0),
+    occurred_at TIMESTAMPTZ NOT NULL,
+    idempotency_key TEXT,
+    bill_id TEXT REFERENCES bills(id),
+    status TEXT NOT NULL,
+    late BOOLEAN NOT NULL DEFAULT FALSE,
+    flag_reason TEXT,
+    received_at TIMESTAMPTZ NOT NULL,
+    UNIQUE (customer_id, idempotency_key)
+);
+
+CREATE INDEX idx_usage_events_bill ON usage_events(bill_id, status);
+CREATE INDEX idx_usage_events_customer ON usage_events(customer_id, status, received_at);
@@ -0,0 +1,18 @@
+-- Recurring plans billed monthly on an anchor day. The current period and its
+-- bill move forward every time the subscription renews
+CREATE TABLE subscriptions (
+    id TEXT PRIMARY KEY,
+    customer_id TEXT NOT NULL,
+    plan TEXT NOT NULL,
+    plan_amount BIGINT NOT NULL CHECK (plan_amount >= 0),
+    currency TEXT NOT NULL,
+    anchor_day INT NOT NULL CHECK (anchor_day BETWEEN 1 AND 31),
+    status TEXT NOT NULL,
+    current_period_start TIMESTAMPTZ,
+    current_period_end TIMESTAMPTZ,
+    current_bill_id TEXT REFERENCES bills(id),
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_subscriptions_customer ON subscriptions(customer_id);
@@ -0,0 +1,3 @@
+-- Open bills can be voided instead of closed; the row is kept for audit
+ALTER TABLE bills ADD COLUMN voided_at TIMESTAMPTZ;
+ALTER TABLE bills ADD COLUMN void_reason TEXT;
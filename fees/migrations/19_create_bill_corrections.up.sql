@@ -0,0 +1,38 @@
+-- When a bill closed, so it can only be reopened for a short while after
+ALTER TABLE bills ADD COLUMN closed_at TIMESTAMPTZ;
+-- Adjustment bills point at the closed bill they correct
+ALTER TABLE bills ADD COLUMN corrects_bill_id TEXT REFERENCES bills(id);
+
+-- A reopened bill is invoiced again when it closes. The earlier invoice stays
+-- as it was; the new one names it, and bills.invoice_number points at the
+-- latest
+ALTER TABLE invoices DROP CONSTRAINT invoices_bill_id_key;
+CREATE INDEX idx_invoices_bill_id ON invoices(bill_id);
+ALTER TABLE invoices ADD COLUMN supersedes TEXT REFERENCES invoices(number);
+
+-- Every reopen or adjustment of a closed bill, who made it and why. Rows are
+-- only ever added
+CREATE TABLE bill_corrections (
+    id BIGSERIAL PRIMARY KEY,
+    bill_id TEXT NOT NULL REFERENCES bills(id),
+    action TEXT NOT NULL,
+    operator_id TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    previous_invoice_number TEXT,
+    previous_total_amount BIGINT NOT NULL,
+    adjustment_bill_id TEXT REFERENCES bills(id),
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_bill_corrections_bill_id ON bill_corrections(bill_id);
+CREATE INDEX idx_bill_corrections_adjustment_bill_id ON bill_corrections(adjustment_bill_id);
+
+CREATE FUNCTION reject_bill_correction_change() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'bill correction % is immutable', OLD.id;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER bill_corrections_append_only
+    BEFORE UPDATE OR DELETE ON bill_corrections
+    FOR EACH ROW EXECUTE FUNCTION reject_bill_correction_change();
@@ -0,0 +1,3 @@
+-- Closing a bill claims the usage events it prices, so exactly those are
+-- marked billed
+ALTER TABLE usage_events ADD COLUMN priced_at TIMESTAMPTZ;
@@ -0,0 +1,15 @@
+-- Workflow deliveries written in the same transaction as the bill/line item rows
+CREATE TABLE outbox (
+                        id BIGSERIAL PRIMARY KEY,
+                        bill_id TEXT NOT NULL REFERENCES bills(id),
+                        kind TEXT NOT NULL,
+                        payload JSONB NOT NULL,
+                        line_item_id INT,
+                        status TEXT NOT NULL DEFAULT 'PENDING',
+                        attempts INT NOT NULL DEFAULT 0,
+                        last_error TEXT,
+                        created_at TIMESTAMPTZ NOT NULL,
+                        processed_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_outbox_pending ON outbox (id) WHERE status = 'PENDING';
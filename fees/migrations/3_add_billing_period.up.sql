@@ -0,0 +1,3 @@
+-- Bills opened for a billing period close themselves when it ends
+ALTER TABLE bills ADD COLUMN period_start TIMESTAMPTZ;
+ALTER TABLE bills ADD COLUMN period_end TIMESTAMPTZ;
@@ -0,0 +1,18 @@
+-- Versioned progressive fee schedules applied when a bill closes
+CREATE TABLE fee_schedules (
+    id TEXT NOT NULL,
+    version INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    currency TEXT NOT NULL,
+    customer_id TEXT,
+    tiers JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (id, version)
+);
+
+CREATE INDEX idx_fee_schedules_customer ON fee_schedules(customer_id, currency, created_at DESC);
+
+ALTER TABLE bills ADD COLUMN fee_schedule_id TEXT;
+ALTER TABLE bills ADD COLUMN fee_schedule_version INTEGER;
+
+ALTER TABLE line_items ADD COLUMN kind TEXT NOT NULL DEFAULT 'CHARGE';
@@ -0,0 +1,5 @@
+-- Quantities are stored in millionths of a unit; existing rows become one unit of their amount
+ALTER TABLE line_items ADD COLUMN quantity BIGINT NOT NULL DEFAULT 1000000;
+ALTER TABLE line_items ADD COLUMN unit_price BIGINT;
+UPDATE line_items SET unit_price = amount;
+ALTER TABLE line_items ALTER COLUMN unit_price SET NOT NULL;
@@ -0,0 +1,3 @@
+-- Credit line items carry a negative amount and a reason code
+ALTER TABLE line_items ADD COLUMN reason_code TEXT;
+ALTER TABLE bills ADD COLUMN allow_negative_total BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,7 @@
+-- Line items get a stable public ID and are voided instead of deleted
+ALTER TABLE line_items ADD COLUMN public_id TEXT;
+UPDATE line_items SET public_id = 'item-' || id;
+CREATE UNIQUE INDEX idx_line_items_public_id ON line_items (public_id);
+
+ALTER TABLE line_items ADD COLUMN voided_at TIMESTAMPTZ;
+ALTER TABLE line_items ADD COLUMN void_reason TEXT;
@@ -0,0 +1,6 @@
+-- Client supplied Idempotency-Key headers; NULLs never conflict
+ALTER TABLE bills ADD COLUMN idempotency_key TEXT;
+CREATE UNIQUE INDEX idx_bills_idempotency_key ON bills (customer_id, idempotency_key);
+
+ALTER TABLE line_items ADD COLUMN idempotency_key TEXT;
+CREATE UNIQUE INDEX idx_line_items_idempotency_key ON line_items (bill_id, idempotency_key);
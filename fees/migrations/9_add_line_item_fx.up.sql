@@ -0,0 +1,8 @@
+-- Line items priced in another currency keep what they were converted from.
+-- fx_rate is the bill-currency price of one unit of original_currency, scaled by 1e8.
+ALTER TABLE line_items ADD COLUMN original_currency TEXT;
+ALTER TABLE line_items ADD COLUMN original_amount BIGINT;
+ALTER TABLE line_items ADD COLUMN original_quantity BIGINT;
+ALTER TABLE line_items ADD COLUMN original_unit_price BIGINT;
+ALTER TABLE line_items ADD COLUMN fx_rate BIGINT;
+ALTER TABLE line_items ADD COLUMN fx_rate_at TIMESTAMPTZ;
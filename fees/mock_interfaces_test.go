@@ -7,9 +7,11 @@ package fees
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	client "go.temporal.io/sdk/client"
+	converter "go.temporal.io/sdk/converter"
 )
 
 // MockRepositoryInterface is a mock of RepositoryInterface interface.
@@ -36,11 +38,12 @@ func (m *MockRepositoryInterface) EXPECT() *MockRepositoryInterfaceMockRecorder
 }
 
 // AddLineItem mocks base method.
-func (m *MockRepositoryInterface) AddLineItem(ctx context.Context, billID string, item *LineItem) error {
+func (m *MockRepositoryInterface) AddLineItem(ctx context.Context, billID string, item *LineItem) (*OutboxEvent, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "AddLineItem", ctx, billID, item)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret0, _ := ret[0].(*OutboxEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // AddLineItem indicates an expected call of AddLineItem.
@@ -49,20 +52,150 @@ func (mr *MockRepositoryInterfaceMockRecorder) AddLineItem(ctx, billID, item int
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddLineItem", reflect.TypeOf((*MockRepositoryInterface)(nil).AddLineItem), ctx, billID, item)
 }
 
-// CreateBill mocks base method.
-func (m *MockRepositoryInterface) CreateBill(ctx context.Context, bill *Bill) error {
+// AggregateUsage mocks base method.
+func (m *MockRepositoryInterface) AggregateUsage(ctx context.Context, billID string) (*BillUsage, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateBill", ctx, bill)
+	ret := m.ctrl.Call(m, "AggregateUsage", ctx, billID)
+	ret0, _ := ret[0].(*BillUsage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AggregateUsage indicates an expected call of AggregateUsage.
+func (mr *MockRepositoryInterfaceMockRecorder) AggregateUsage(ctx, billID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AggregateUsage", reflect.TypeOf((*MockRepositoryInterface)(nil).AggregateUsage), ctx, billID)
+}
+
+// AttachDiscount mocks base method.
+func (m *MockRepositoryInterface) AttachDiscount(ctx context.Context, billID string, discount *BillDiscount) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AttachDiscount", ctx, billID, discount)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
+// AttachDiscount indicates an expected call of AttachDiscount.
+func (mr *MockRepositoryInterfaceMockRecorder) AttachDiscount(ctx, billID, discount interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AttachDiscount", reflect.TypeOf((*MockRepositoryInterface)(nil).AttachDiscount), ctx, billID, discount)
+}
+
+// ClaimUsage mocks base method.
+func (m *MockRepositoryInterface) ClaimUsage(ctx context.Context, billID string) (*BillUsage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimUsage", ctx, billID)
+	ret0, _ := ret[0].(*BillUsage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimUsage indicates an expected call of ClaimUsage.
+func (mr *MockRepositoryInterfaceMockRecorder) ClaimUsage(ctx, billID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimUsage", reflect.TypeOf((*MockRepositoryInterface)(nil).ClaimUsage), ctx, billID)
+}
+
+// CreateAdjustmentBill mocks base method.
+func (m *MockRepositoryInterface) CreateAdjustmentBill(ctx context.Context, bill *Bill, correction *BillCorrection) (*OutboxEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAdjustmentBill", ctx, bill, correction)
+	ret0, _ := ret[0].(*OutboxEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAdjustmentBill indicates an expected call of CreateAdjustmentBill.
+func (mr *MockRepositoryInterfaceMockRecorder) CreateAdjustmentBill(ctx, bill, correction interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAdjustmentBill", reflect.TypeOf((*MockRepositoryInterface)(nil).CreateAdjustmentBill), ctx, bill, correction)
+}
+
+// CreateBill mocks base method.
+func (m *MockRepositoryInterface) CreateBill(ctx context.Context, bill *Bill) (*OutboxEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateBill", ctx, bill)
+	ret0, _ := ret[0].(*OutboxEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
 // CreateBill indicates an expected call of CreateBill.
 func (mr *MockRepositoryInterfaceMockRecorder) CreateBill(ctx, bill interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateBill", reflect.TypeOf((*MockRepositoryInterface)(nil).CreateBill), ctx, bill)
 }
 
+// CreateFeeSchedule mocks base method.
+func (m *MockRepositoryInterface) CreateFeeSchedule(ctx context.Context, schedule *FeeSchedule) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateFeeSchedule", ctx, schedule)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateFeeSchedule indicates an expected call of CreateFeeSchedule.
+func (mr *MockRepositoryInterfaceMockRecorder) CreateFeeSchedule(ctx, schedule interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateFeeSchedule", reflect.TypeOf((*MockRepositoryInterface)(nil).CreateFeeSchedule), ctx, schedule)
+}
+
+// CreateMeter mocks base method.
+func (m *MockRepositoryInterface) CreateMeter(ctx context.Context, meter *Meter) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateMeter", ctx, meter)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateMeter indicates an expected call of CreateMeter.
+func (mr *MockRepositoryInterfaceMockRecorder) CreateMeter(ctx, meter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateMeter", reflect.TypeOf((*MockRepositoryInterface)(nil).CreateMeter), ctx, meter)
+}
+
+// CreatePromoCode mocks base method.
+func (m *MockRepositoryInterface) CreatePromoCode(ctx context.Context, promo *PromoCode) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePromoCode", ctx, promo)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreatePromoCode indicates an expected call of CreatePromoCode.
+func (mr *MockRepositoryInterfaceMockRecorder) CreatePromoCode(ctx, promo interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePromoCode", reflect.TypeOf((*MockRepositoryInterface)(nil).CreatePromoCode), ctx, promo)
+}
+
+// CreateSubscription mocks base method.
+func (m *MockRepositoryInterface) CreateSubscription(ctx context.Context, sub *Subscription) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSubscription", ctx, sub)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateSubscription indicates an expected call of CreateSubscription.
+func (mr *MockRepositoryInterfaceMockRecorder) CreateSubscription(ctx, sub interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSubscription", reflect.TypeOf((*MockRepositoryInterface)(nil).CreateSubscription), ctx, sub)
+}
+
+// FinalizeBill mocks base method.
+func (m *MockRepositoryInterface) FinalizeBill(ctx context.Context, bill *FinalBill) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FinalizeBill", ctx, bill)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FinalizeBill indicates an expected call of FinalizeBill.
+func (mr *MockRepositoryInterfaceMockRecorder) FinalizeBill(ctx, bill interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FinalizeBill", reflect.TypeOf((*MockRepositoryInterface)(nil).FinalizeBill), ctx, bill)
+}
+
 // GetBillByID mocks base method.
 func (m *MockRepositoryInterface) GetBillByID(ctx context.Context, billID string) (*Bill, error) {
 	m.ctrl.T.Helper()
@@ -78,6 +211,21 @@ func (mr *MockRepositoryInterfaceMockRecorder) GetBillByID(ctx, billID interface
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBillByID", reflect.TypeOf((*MockRepositoryInterface)(nil).GetBillByID), ctx, billID)
 }
 
+// GetBillByIdempotencyKey mocks base method.
+func (m *MockRepositoryInterface) GetBillByIdempotencyKey(ctx context.Context, customerID, key string) (*Bill, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBillByIdempotencyKey", ctx, customerID, key)
+	ret0, _ := ret[0].(*Bill)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBillByIdempotencyKey indicates an expected call of GetBillByIdempotencyKey.
+func (mr *MockRepositoryInterfaceMockRecorder) GetBillByIdempotencyKey(ctx, customerID, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBillByIdempotencyKey", reflect.TypeOf((*MockRepositoryInterface)(nil).GetBillByIdempotencyKey), ctx, customerID, key)
+}
+
 // GetBillStatus mocks base method.
 func (m *MockRepositoryInterface) GetBillStatus(ctx context.Context, billID string) (BillStatus, error) {
 	m.ctrl.T.Helper()
@@ -93,6 +241,81 @@ func (mr *MockRepositoryInterfaceMockRecorder) GetBillStatus(ctx, billID interfa
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBillStatus", reflect.TypeOf((*MockRepositoryInterface)(nil).GetBillStatus), ctx, billID)
 }
 
+// GetCustomerBillingLimits mocks base method.
+func (m *MockRepositoryInterface) GetCustomerBillingLimits(ctx context.Context, customerID string, currency Currency) (*CustomerBillingLimits, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCustomerBillingLimits", ctx, customerID, currency)
+	ret0, _ := ret[0].(*CustomerBillingLimits)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCustomerBillingLimits indicates an expected call of GetCustomerBillingLimits.
+func (mr *MockRepositoryInterfaceMockRecorder) GetCustomerBillingLimits(ctx, customerID, currency interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCustomerBillingLimits", reflect.TypeOf((*MockRepositoryInterface)(nil).GetCustomerBillingLimits), ctx, customerID, currency)
+}
+
+// GetCustomerFeeSchedule mocks base method.
+func (m *MockRepositoryInterface) GetCustomerFeeSchedule(ctx context.Context, customerID string, currency Currency) (*FeeSchedule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCustomerFeeSchedule", ctx, customerID, currency)
+	ret0, _ := ret[0].(*FeeSchedule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCustomerFeeSchedule indicates an expected call of GetCustomerFeeSchedule.
+func (mr *MockRepositoryInterfaceMockRecorder) GetCustomerFeeSchedule(ctx, customerID, currency interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCustomerFeeSchedule", reflect.TypeOf((*MockRepositoryInterface)(nil).GetCustomerFeeSchedule), ctx, customerID, currency)
+}
+
+// GetFeeSchedule mocks base method.
+func (m *MockRepositoryInterface) GetFeeSchedule(ctx context.Context, scheduleID string) (*FeeSchedule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFeeSchedule", ctx, scheduleID)
+	ret0, _ := ret[0].(*FeeSchedule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFeeSchedule indicates an expected call of GetFeeSchedule.
+func (mr *MockRepositoryInterfaceMockRecorder) GetFeeSchedule(ctx, scheduleID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFeeSchedule", reflect.TypeOf((*MockRepositoryInterface)(nil).GetFeeSchedule), ctx, scheduleID)
+}
+
+// GetInvoice mocks base method.
+func (m *MockRepositoryInterface) GetInvoice(ctx context.Context, number string) (*Invoice, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetInvoice", ctx, number)
+	ret0, _ := ret[0].(*Invoice)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetInvoice indicates an expected call of GetInvoice.
+func (mr *MockRepositoryInterfaceMockRecorder) GetInvoice(ctx, number interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInvoice", reflect.TypeOf((*MockRepositoryInterface)(nil).GetInvoice), ctx, number)
+}
+
+// GetLineItemByIdempotencyKey mocks base method.
+func (m *MockRepositoryInterface) GetLineItemByIdempotencyKey(ctx context.Context, billID, key string) (*LineItem, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLineItemByIdempotencyKey", ctx, billID, key)
+	ret0, _ := ret[0].(*LineItem)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLineItemByIdempotencyKey indicates an expected call of GetLineItemByIdempotencyKey.
+func (mr *MockRepositoryInterfaceMockRecorder) GetLineItemByIdempotencyKey(ctx, billID, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLineItemByIdempotencyKey", reflect.TypeOf((*MockRepositoryInterface)(nil).GetLineItemByIdempotencyKey), ctx, billID, key)
+}
+
 // GetLineItemsByBillID mocks base method.
 func (m *MockRepositoryInterface) GetLineItemsByBillID(ctx context.Context, billID string) ([]LineItem, error) {
 	m.ctrl.T.Helper()
@@ -108,48 +331,399 @@ func (mr *MockRepositoryInterfaceMockRecorder) GetLineItemsByBillID(ctx, billID
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLineItemsByBillID", reflect.TypeOf((*MockRepositoryInterface)(nil).GetLineItemsByBillID), ctx, billID)
 }
 
+// GetMeter mocks base method.
+func (m *MockRepositoryInterface) GetMeter(ctx context.Context, name string) (*Meter, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMeter", ctx, name)
+	ret0, _ := ret[0].(*Meter)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMeter indicates an expected call of GetMeter.
+func (mr *MockRepositoryInterfaceMockRecorder) GetMeter(ctx, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMeter", reflect.TypeOf((*MockRepositoryInterface)(nil).GetMeter), ctx, name)
+}
+
+// GetPromoCode mocks base method.
+func (m *MockRepositoryInterface) GetPromoCode(ctx context.Context, code string) (*PromoCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPromoCode", ctx, code)
+	ret0, _ := ret[0].(*PromoCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPromoCode indicates an expected call of GetPromoCode.
+func (mr *MockRepositoryInterfaceMockRecorder) GetPromoCode(ctx, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPromoCode", reflect.TypeOf((*MockRepositoryInterface)(nil).GetPromoCode), ctx, code)
+}
+
+// GetSubscription mocks base method.
+func (m *MockRepositoryInterface) GetSubscription(ctx context.Context, subscriptionID string) (*Subscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSubscription", ctx, subscriptionID)
+	ret0, _ := ret[0].(*Subscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSubscription indicates an expected call of GetSubscription.
+func (mr *MockRepositoryInterfaceMockRecorder) GetSubscription(ctx, subscriptionID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSubscription", reflect.TypeOf((*MockRepositoryInterface)(nil).GetSubscription), ctx, subscriptionID)
+}
+
+// GetTaxRule mocks base method.
+func (m *MockRepositoryInterface) GetTaxRule(ctx context.Context, ruleID string) (*TaxRule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTaxRule", ctx, ruleID)
+	ret0, _ := ret[0].(*TaxRule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTaxRule indicates an expected call of GetTaxRule.
+func (mr *MockRepositoryInterfaceMockRecorder) GetTaxRule(ctx, ruleID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTaxRule", reflect.TypeOf((*MockRepositoryInterface)(nil).GetTaxRule), ctx, ruleID)
+}
+
 // ListAllBills mocks base method.
-func (m *MockRepositoryInterface) ListAllBills(ctx context.Context, status *BillStatus, limit, offset int) ([]*Bill, error) {
+func (m *MockRepositoryInterface) ListAllBills(ctx context.Context, status *BillStatus, includeVoided bool, limit, offset int) ([]*Bill, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ListAllBills", ctx, status, limit, offset)
+	ret := m.ctrl.Call(m, "ListAllBills", ctx, status, includeVoided, limit, offset)
 	ret0, _ := ret[0].([]*Bill)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ListAllBills indicates an expected call of ListAllBills.
-func (mr *MockRepositoryInterfaceMockRecorder) ListAllBills(ctx, status, limit, offset interface{}) *gomock.Call {
+func (mr *MockRepositoryInterfaceMockRecorder) ListAllBills(ctx, status, includeVoided, limit, offset interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAllBills", reflect.TypeOf((*MockRepositoryInterface)(nil).ListAllBills), ctx, status, limit, offset)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAllBills", reflect.TypeOf((*MockRepositoryInterface)(nil).ListAllBills), ctx, status, includeVoided, limit, offset)
+}
+
+// ListBillCorrections mocks base method.
+func (m *MockRepositoryInterface) ListBillCorrections(ctx context.Context, billID string) ([]*BillCorrection, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListBillCorrections", ctx, billID)
+	ret0, _ := ret[0].([]*BillCorrection)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListBillCorrections indicates an expected call of ListBillCorrections.
+func (mr *MockRepositoryInterfaceMockRecorder) ListBillCorrections(ctx, billID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListBillCorrections", reflect.TypeOf((*MockRepositoryInterface)(nil).ListBillCorrections), ctx, billID)
+}
+
+// ListBillDiscounts mocks base method.
+func (m *MockRepositoryInterface) ListBillDiscounts(ctx context.Context, billID string) ([]BillDiscount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListBillDiscounts", ctx, billID)
+	ret0, _ := ret[0].([]BillDiscount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListBillDiscounts indicates an expected call of ListBillDiscounts.
+func (mr *MockRepositoryInterfaceMockRecorder) ListBillDiscounts(ctx, billID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListBillDiscounts", reflect.TypeOf((*MockRepositoryInterface)(nil).ListBillDiscounts), ctx, billID)
 }
 
 // ListBillsByCustomer mocks base method.
-func (m *MockRepositoryInterface) ListBillsByCustomer(ctx context.Context, customerID string, status *BillStatus, limit, offset int) ([]*Bill, error) {
+func (m *MockRepositoryInterface) ListBillsByCustomer(ctx context.Context, customerID string, status *BillStatus, includeVoided bool, limit, offset int) ([]*Bill, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ListBillsByCustomer", ctx, customerID, status, limit, offset)
+	ret := m.ctrl.Call(m, "ListBillsByCustomer", ctx, customerID, status, includeVoided, limit, offset)
 	ret0, _ := ret[0].([]*Bill)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ListBillsByCustomer indicates an expected call of ListBillsByCustomer.
-func (mr *MockRepositoryInterfaceMockRecorder) ListBillsByCustomer(ctx, customerID, status, limit, offset interface{}) *gomock.Call {
+func (mr *MockRepositoryInterfaceMockRecorder) ListBillsByCustomer(ctx, customerID, status, includeVoided, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListBillsByCustomer", reflect.TypeOf((*MockRepositoryInterface)(nil).ListBillsByCustomer), ctx, customerID, status, includeVoided, limit, offset)
+}
+
+// ListPayments mocks base method.
+func (m *MockRepositoryInterface) ListPayments(ctx context.Context, billID string) ([]*Payment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPayments", ctx, billID)
+	ret0, _ := ret[0].([]*Payment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPayments indicates an expected call of ListPayments.
+func (mr *MockRepositoryInterfaceMockRecorder) ListPayments(ctx, billID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPayments", reflect.TypeOf((*MockRepositoryInterface)(nil).ListPayments), ctx, billID)
+}
+
+// ListPendingOutboxEvents mocks base method.
+func (m *MockRepositoryInterface) ListPendingOutboxEvents(ctx context.Context, limit int) ([]*OutboxEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPendingOutboxEvents", ctx, limit)
+	ret0, _ := ret[0].([]*OutboxEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPendingOutboxEvents indicates an expected call of ListPendingOutboxEvents.
+func (mr *MockRepositoryInterfaceMockRecorder) ListPendingOutboxEvents(ctx, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPendingOutboxEvents", reflect.TypeOf((*MockRepositoryInterface)(nil).ListPendingOutboxEvents), ctx, limit)
+}
+
+// ListTaxRules mocks base method.
+func (m *MockRepositoryInterface) ListTaxRules(ctx context.Context, currency Currency) ([]*TaxRule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTaxRules", ctx, currency)
+	ret0, _ := ret[0].([]*TaxRule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTaxRules indicates an expected call of ListTaxRules.
+func (mr *MockRepositoryInterfaceMockRecorder) ListTaxRules(ctx, currency interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTaxRules", reflect.TypeOf((*MockRepositoryInterface)(nil).ListTaxRules), ctx, currency)
+}
+
+// ListUsageEvents mocks base method.
+func (m *MockRepositoryInterface) ListUsageEvents(ctx context.Context, customerID string, status UsageEventStatus, limit int) ([]*UsageEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUsageEvents", ctx, customerID, status, limit)
+	ret0, _ := ret[0].([]*UsageEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUsageEvents indicates an expected call of ListUsageEvents.
+func (mr *MockRepositoryInterfaceMockRecorder) ListUsageEvents(ctx, customerID, status, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUsageEvents", reflect.TypeOf((*MockRepositoryInterface)(nil).ListUsageEvents), ctx, customerID, status, limit)
+}
+
+// MarkOutboxEventDone mocks base method.
+func (m *MockRepositoryInterface) MarkOutboxEventDone(ctx context.Context, eventID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkOutboxEventDone", ctx, eventID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkOutboxEventDone indicates an expected call of MarkOutboxEventDone.
+func (mr *MockRepositoryInterfaceMockRecorder) MarkOutboxEventDone(ctx, eventID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkOutboxEventDone", reflect.TypeOf((*MockRepositoryInterface)(nil).MarkOutboxEventDone), ctx, eventID)
+}
+
+// MarkOutboxEventFailed mocks base method.
+func (m *MockRepositoryInterface) MarkOutboxEventFailed(ctx context.Context, eventID int64, lastError string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkOutboxEventFailed", ctx, eventID, lastError)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkOutboxEventFailed indicates an expected call of MarkOutboxEventFailed.
+func (mr *MockRepositoryInterfaceMockRecorder) MarkOutboxEventFailed(ctx, eventID, lastError interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkOutboxEventFailed", reflect.TypeOf((*MockRepositoryInterface)(nil).MarkOutboxEventFailed), ctx, eventID, lastError)
+}
+
+// MarkOutboxEventRetry mocks base method.
+func (m *MockRepositoryInterface) MarkOutboxEventRetry(ctx context.Context, eventID int64, lastError string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkOutboxEventRetry", ctx, eventID, lastError)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkOutboxEventRetry indicates an expected call of MarkOutboxEventRetry.
+func (mr *MockRepositoryInterfaceMockRecorder) MarkOutboxEventRetry(ctx, eventID, lastError interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkOutboxEventRetry", reflect.TypeOf((*MockRepositoryInterface)(nil).MarkOutboxEventRetry), ctx, eventID, lastError)
+}
+
+// RecordPayment mocks base method.
+func (m *MockRepositoryInterface) RecordPayment(ctx context.Context, payment *Payment) (*Payment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordPayment", ctx, payment)
+	ret0, _ := ret[0].(*Payment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecordPayment indicates an expected call of RecordPayment.
+func (mr *MockRepositoryInterfaceMockRecorder) RecordPayment(ctx, payment interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListBillsByCustomer", reflect.TypeOf((*MockRepositoryInterface)(nil).ListBillsByCustomer), ctx, customerID, status, limit, offset)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordPayment", reflect.TypeOf((*MockRepositoryInterface)(nil).RecordPayment), ctx, payment)
 }
 
-// UpdateBillStatus mocks base method.
-func (m *MockRepositoryInterface) UpdateBillStatus(ctx context.Context, billID string, status BillStatus, totalAmount int64) error {
+// RecordUsageEvent mocks base method.
+func (m *MockRepositoryInterface) RecordUsageEvent(ctx context.Context, event *UsageEvent) (*UsageEvent, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateBillStatus", ctx, billID, status, totalAmount)
+	ret := m.ctrl.Call(m, "RecordUsageEvent", ctx, event)
+	ret0, _ := ret[0].(*UsageEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecordUsageEvent indicates an expected call of RecordUsageEvent.
+func (mr *MockRepositoryInterfaceMockRecorder) RecordUsageEvent(ctx, event interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordUsageEvent", reflect.TypeOf((*MockRepositoryInterface)(nil).RecordUsageEvent), ctx, event)
+}
+
+// RenewSubscription mocks base method.
+func (m *MockRepositoryInterface) RenewSubscription(ctx context.Context, subscriptionID, billID string, start, end time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RenewSubscription", ctx, subscriptionID, billID, start, end)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// UpdateBillStatus indicates an expected call of UpdateBillStatus.
-func (mr *MockRepositoryInterfaceMockRecorder) UpdateBillStatus(ctx, billID, status, totalAmount interface{}) *gomock.Call {
+// RenewSubscription indicates an expected call of RenewSubscription.
+func (mr *MockRepositoryInterfaceMockRecorder) RenewSubscription(ctx, subscriptionID, billID, start, end interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RenewSubscription", reflect.TypeOf((*MockRepositoryInterface)(nil).RenewSubscription), ctx, subscriptionID, billID, start, end)
+}
+
+// ReopenBill mocks base method.
+func (m *MockRepositoryInterface) ReopenBill(ctx context.Context, billID string, closedAfter time.Time, correction *BillCorrection) (*OutboxEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReopenBill", ctx, billID, closedAfter, correction)
+	ret0, _ := ret[0].(*OutboxEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReopenBill indicates an expected call of ReopenBill.
+func (mr *MockRepositoryInterfaceMockRecorder) ReopenBill(ctx, billID, closedAfter, correction interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReopenBill", reflect.TypeOf((*MockRepositoryInterface)(nil).ReopenBill), ctx, billID, closedAfter, correction)
+}
+
+// SaveCustomerBillingLimits mocks base method.
+func (m *MockRepositoryInterface) SaveCustomerBillingLimits(ctx context.Context, limits *CustomerBillingLimits) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveCustomerBillingLimits", ctx, limits)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveCustomerBillingLimits indicates an expected call of SaveCustomerBillingLimits.
+func (mr *MockRepositoryInterfaceMockRecorder) SaveCustomerBillingLimits(ctx, limits interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveCustomerBillingLimits", reflect.TypeOf((*MockRepositoryInterface)(nil).SaveCustomerBillingLimits), ctx, limits)
+}
+
+// SaveTaxRule mocks base method.
+func (m *MockRepositoryInterface) SaveTaxRule(ctx context.Context, rule *TaxRule) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveTaxRule", ctx, rule)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveTaxRule indicates an expected call of SaveTaxRule.
+func (mr *MockRepositoryInterfaceMockRecorder) SaveTaxRule(ctx, rule interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveTaxRule", reflect.TypeOf((*MockRepositoryInterface)(nil).SaveTaxRule), ctx, rule)
+}
+
+// SettleOutboxEvents mocks base method.
+func (m *MockRepositoryInterface) SettleOutboxEvents(ctx context.Context, billID string, itemIDs, voidIDs []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SettleOutboxEvents", ctx, billID, itemIDs, voidIDs)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SettleOutboxEvents indicates an expected call of SettleOutboxEvents.
+func (mr *MockRepositoryInterfaceMockRecorder) SettleOutboxEvents(ctx, billID, itemIDs, voidIDs interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SettleOutboxEvents", reflect.TypeOf((*MockRepositoryInterface)(nil).SettleOutboxEvents), ctx, billID, itemIDs, voidIDs)
+}
+
+// UpdateDunning mocks base method.
+func (m *MockRepositoryInterface) UpdateDunning(ctx context.Context, billID string, status DunningStatus, stage int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateDunning", ctx, billID, status, stage)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateDunning indicates an expected call of UpdateDunning.
+func (mr *MockRepositoryInterfaceMockRecorder) UpdateDunning(ctx, billID, status, stage interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateDunning", reflect.TypeOf((*MockRepositoryInterface)(nil).UpdateDunning), ctx, billID, status, stage)
+}
+
+// UpdateSubscriptionPlan mocks base method.
+func (m *MockRepositoryInterface) UpdateSubscriptionPlan(ctx context.Context, subscriptionID, plan string, planAmount int64, billID string, items []*LineItem) ([]*OutboxEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateSubscriptionPlan", ctx, subscriptionID, plan, planAmount, billID, items)
+	ret0, _ := ret[0].([]*OutboxEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateSubscriptionPlan indicates an expected call of UpdateSubscriptionPlan.
+func (mr *MockRepositoryInterfaceMockRecorder) UpdateSubscriptionPlan(ctx, subscriptionID, plan, planAmount, billID, items interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSubscriptionPlan", reflect.TypeOf((*MockRepositoryInterface)(nil).UpdateSubscriptionPlan), ctx, subscriptionID, plan, planAmount, billID, items)
+}
+
+// UpdateSubscriptionStatus mocks base method.
+func (m *MockRepositoryInterface) UpdateSubscriptionStatus(ctx context.Context, subscriptionID string, from, to SubscriptionStatus) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateSubscriptionStatus", ctx, subscriptionID, from, to)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateSubscriptionStatus indicates an expected call of UpdateSubscriptionStatus.
+func (mr *MockRepositoryInterfaceMockRecorder) UpdateSubscriptionStatus(ctx, subscriptionID, from, to interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSubscriptionStatus", reflect.TypeOf((*MockRepositoryInterface)(nil).UpdateSubscriptionStatus), ctx, subscriptionID, from, to)
+}
+
+// VoidBill mocks base method.
+func (m *MockRepositoryInterface) VoidBill(ctx context.Context, billID, reason string, voidedAt time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VoidBill", ctx, billID, reason, voidedAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// VoidBill indicates an expected call of VoidBill.
+func (mr *MockRepositoryInterfaceMockRecorder) VoidBill(ctx, billID, reason, voidedAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VoidBill", reflect.TypeOf((*MockRepositoryInterface)(nil).VoidBill), ctx, billID, reason, voidedAt)
+}
+
+// VoidLineItem mocks base method.
+func (m *MockRepositoryInterface) VoidLineItem(ctx context.Context, billID, itemID, reason string) (*OutboxEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VoidLineItem", ctx, billID, itemID, reason)
+	ret0, _ := ret[0].(*OutboxEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VoidLineItem indicates an expected call of VoidLineItem.
+func (mr *MockRepositoryInterfaceMockRecorder) VoidLineItem(ctx, billID, itemID, reason interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateBillStatus", reflect.TypeOf((*MockRepositoryInterface)(nil).UpdateBillStatus), ctx, billID, status, totalAmount)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VoidLineItem", reflect.TypeOf((*MockRepositoryInterface)(nil).VoidLineItem), ctx, billID, itemID, reason)
 }
 
 // MockTemporalClientInterface is a mock of TemporalClientInterface interface.
@@ -175,6 +749,20 @@ func (m *MockTemporalClientInterface) EXPECT() *MockTemporalClientInterfaceMockR
 	return m.recorder
 }
 
+// CancelWorkflow mocks base method.
+func (m *MockTemporalClientInterface) CancelWorkflow(ctx context.Context, workflowID, runID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelWorkflow", ctx, workflowID, runID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CancelWorkflow indicates an expected call of CancelWorkflow.
+func (mr *MockTemporalClientInterfaceMockRecorder) CancelWorkflow(ctx, workflowID, runID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelWorkflow", reflect.TypeOf((*MockTemporalClientInterface)(nil).CancelWorkflow), ctx, workflowID, runID)
+}
+
 // ExecuteWorkflow mocks base method.
 func (m *MockTemporalClientInterface) ExecuteWorkflow(ctx context.Context, options client.StartWorkflowOptions, workflow interface{}, args ...interface{}) (client.WorkflowRun, error) {
 	m.ctrl.T.Helper()
@@ -195,6 +783,26 @@ func (mr *MockTemporalClientInterfaceMockRecorder) ExecuteWorkflow(ctx, options,
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExecuteWorkflow", reflect.TypeOf((*MockTemporalClientInterface)(nil).ExecuteWorkflow), varargs...)
 }
 
+// QueryWorkflow mocks base method.
+func (m *MockTemporalClientInterface) QueryWorkflow(ctx context.Context, workflowID, runID, queryType string, args ...interface{}) (converter.EncodedValue, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, workflowID, runID, queryType}
+	for _, a := range args {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "QueryWorkflow", varargs...)
+	ret0, _ := ret[0].(converter.EncodedValue)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// QueryWorkflow indicates an expected call of QueryWorkflow.
+func (mr *MockTemporalClientInterfaceMockRecorder) QueryWorkflow(ctx, workflowID, runID, queryType interface{}, args ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, workflowID, runID, queryType}, args...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryWorkflow", reflect.TypeOf((*MockTemporalClientInterface)(nil).QueryWorkflow), varargs...)
+}
+
 // SignalWorkflow mocks base method.
 func (m *MockTemporalClientInterface) SignalWorkflow(ctx context.Context, workflowID, runID, signalName string, arg interface{}) error {
 	m.ctrl.T.Helper()
@@ -208,3 +816,161 @@ func (mr *MockTemporalClientInterfaceMockRecorder) SignalWorkflow(ctx, workflowI
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SignalWorkflow", reflect.TypeOf((*MockTemporalClientInterface)(nil).SignalWorkflow), ctx, workflowID, runID, signalName, arg)
 }
+
+// UpdateWorkflow mocks base method.
+func (m *MockTemporalClientInterface) UpdateWorkflow(ctx context.Context, options client.UpdateWorkflowOptions) (client.WorkflowUpdateHandle, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateWorkflow", ctx, options)
+	ret0, _ := ret[0].(client.WorkflowUpdateHandle)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateWorkflow indicates an expected call of UpdateWorkflow.
+func (mr *MockTemporalClientInterfaceMockRecorder) UpdateWorkflow(ctx, options interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateWorkflow", reflect.TypeOf((*MockTemporalClientInterface)(nil).UpdateWorkflow), ctx, options)
+}
+
+// MockNotifier is a mock of Notifier interface.
+type MockNotifier struct {
+	ctrl     *gomock.Controller
+	recorder *MockNotifierMockRecorder
+}
+
+// MockNotifierMockRecorder is the mock recorder for MockNotifier.
+type MockNotifierMockRecorder struct {
+	mock *MockNotifier
+}
+
+// NewMockNotifier creates a new mock instance.
+func NewMockNotifier(ctrl *gomock.Controller) *MockNotifier {
+	mock := &MockNotifier{ctrl: ctrl}
+	mock.recorder = &MockNotifierMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockNotifier) EXPECT() *MockNotifierMockRecorder {
+	return m.recorder
+}
+
+// Notify mocks base method.
+func (m *MockNotifier) Notify(ctx context.Context, notification Notification) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Notify", ctx, notification)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Notify indicates an expected call of Notify.
+func (mr *MockNotifierMockRecorder) Notify(ctx, notification interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Notify", reflect.TypeOf((*MockNotifier)(nil).Notify), ctx, notification)
+}
+
+// MockRateProvider is a mock of RateProvider interface.
+type MockRateProvider struct {
+	ctrl     *gomock.Controller
+	recorder *MockRateProviderMockRecorder
+}
+
+// MockRateProviderMockRecorder is the mock recorder for MockRateProvider.
+type MockRateProviderMockRecorder struct {
+	mock *MockRateProvider
+}
+
+// NewMockRateProvider creates a new mock instance.
+func NewMockRateProvider(ctrl *gomock.Controller) *MockRateProvider {
+	mock := &MockRateProvider{ctrl: ctrl}
+	mock.recorder = &MockRateProviderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRateProvider) EXPECT() *MockRateProviderMockRecorder {
+	return m.recorder
+}
+
+// Rate mocks base method.
+func (m *MockRateProvider) Rate(ctx context.Context, base, quote Currency, at time.Time) (*ExchangeRate, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Rate", ctx, base, quote, at)
+	ret0, _ := ret[0].(*ExchangeRate)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Rate indicates an expected call of Rate.
+func (mr *MockRateProviderMockRecorder) Rate(ctx, base, quote, at interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Rate", reflect.TypeOf((*MockRateProvider)(nil).Rate), ctx, base, quote, at)
+}
+
+// MockBiller is a mock of Biller interface.
+type MockBiller struct {
+	ctrl     *gomock.Controller
+	recorder *MockBillerMockRecorder
+}
+
+// MockBillerMockRecorder is the mock recorder for MockBiller.
+type MockBillerMockRecorder struct {
+	mock *MockBiller
+}
+
+// NewMockBiller creates a new mock instance.
+func NewMockBiller(ctrl *gomock.Controller) *MockBiller {
+	mock := &MockBiller{ctrl: ctrl}
+	mock.recorder = &MockBillerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockBiller) EXPECT() *MockBillerMockRecorder {
+	return m.recorder
+}
+
+// AddLineItem mocks base method.
+func (m *MockBiller) AddLineItem(ctx context.Context, billID string, req *AddLineItemRequest) (*AddLineItemResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddLineItem", ctx, billID, req)
+	ret0, _ := ret[0].(*AddLineItemResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddLineItem indicates an expected call of AddLineItem.
+func (mr *MockBillerMockRecorder) AddLineItem(ctx, billID, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddLineItem", reflect.TypeOf((*MockBiller)(nil).AddLineItem), ctx, billID, req)
+}
+
+// CloseBill mocks base method.
+func (m *MockBiller) CloseBill(ctx context.Context, billID string) (*CloseBillResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CloseBill", ctx, billID)
+	ret0, _ := ret[0].(*CloseBillResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CloseBill indicates an expected call of CloseBill.
+func (mr *MockBillerMockRecorder) CloseBill(ctx, billID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CloseBill", reflect.TypeOf((*MockBiller)(nil).CloseBill), ctx, billID)
+}
+
+// CreateBill mocks base method.
+func (m *MockBiller) CreateBill(ctx context.Context, req *CreateBillRequest) (*CreateBillResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateBill", ctx, req)
+	ret0, _ := ret[0].(*CreateBillResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateBill indicates an expected call of CreateBill.
+func (mr *MockBillerMockRecorder) CreateBill(ctx, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateBill", reflect.TypeOf((*MockBiller)(nil).CreateBill), ctx, req)
+}
@@ -0,0 +1,132 @@
+package fees
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var (
+	ErrInvalidOperators = errors.New("invalid operators")
+	ErrUnknownOperator  = errors.New("unknown operator token")
+	ErrPermissionDenied = errors.New("permission denied")
+)
+
+// OperatorsFileEnv points at a JSON file listing the staff who may call the
+// operator endpoints (see LoadOperators). Unset means nobody can.
+const OperatorsFileEnv = "FEES_OPERATORS_FILE"
+
+// OperatorRole grants access to a group of operator endpoints.
+type OperatorRole string
+
+const (
+	// OperatorRoleFinance may reopen closed bills and issue adjustment bills.
+	OperatorRoleFinance OperatorRole = "FINANCE"
+)
+
+func (r OperatorRole) IsValid() bool {
+	return r == OperatorRoleFinance
+}
+
+// Operator is the member of staff behind an authenticated request.
+type Operator struct {
+	ID    string         `json:"id"`
+	Roles []OperatorRole `json:"roles"`
+}
+
+// Require returns ErrPermissionDenied unless the operator has role. A nil
+// operator has no roles.
+func (o *Operator) Require(role OperatorRole) error {
+	if o != nil {
+		for _, r := range o.Roles {
+			if r == role {
+				return nil
+			}
+		}
+	}
+	id := "anonymous"
+	if o != nil {
+		id = o.ID
+	}
+	return fmt.Errorf("%w: %s does not have the %s role", ErrPermissionDenied, id, role)
+}
+
+// OperatorCredential is an operator as configured. Only the SHA-256 of the
+// bearer token is kept, hex encoded.
+type OperatorCredential struct {
+	ID          string         `json:"id"`
+	TokenSHA256 string         `json:"tokenSha256"`
+	Roles       []OperatorRole `json:"roles"`
+}
+
+// Operators looks up operators by their bearer token.
+type Operators struct {
+	byToken map[string]*Operator
+}
+
+// NewOperators checks the credentials; IDs and tokens must be unique.
+func NewOperators(credentials []OperatorCredential) (*Operators, error) {
+	ops := &Operators{byToken: make(map[string]*Operator, len(credentials))}
+	ids := make(map[string]bool, len(credentials))
+	for _, c := range credentials {
+		if strings.TrimSpace(c.ID) == "" {
+			return nil, fmt.Errorf("%w: operator id cannot be empty", ErrInvalidOperators)
+		}
+		if ids[c.ID] {
+			return nil, fmt.Errorf("%w: operator %s is listed twice", ErrInvalidOperators, c.ID)
+		}
+		ids[c.ID] = true
+
+		hash := strings.ToLower(c.TokenSHA256)
+		if decoded, err := hex.DecodeString(hash); err != nil || len(decoded) != sha256.Size {
+			return nil, fmt.Errorf("%w: operator %s needs a hex SHA-256 tokenSha256", ErrInvalidOperators, c.ID)
+		}
+		if _, ok := ops.byToken[hash]; ok {
+			return nil, fmt.Errorf("%w: operator %s shares a token with another operator", ErrInvalidOperators, c.ID)
+		}
+		for _, role := range c.Roles {
+			if !role.IsValid() {
+				return nil, fmt.Errorf("%w: operator %s has unknown role %q", ErrInvalidOperators, c.ID, role)
+			}
+		}
+		ops.byToken[hash] = &Operator{ID: c.ID, Roles: c.Roles}
+	}
+	return ops, nil
+}
+
+// operatorsFile is the on-disk format read by LoadOperators:
+//
+//	{"operators": [{"id": "nino", "tokenSha256": "9f86d0...", "roles": ["FINANCE"]}]}
+type operatorsFile struct {
+	Operators []OperatorCredential `json:"operators"`
+}
+
+// LoadOperators reads the operators from a JSON file.
+func LoadOperators(path string) (*Operators, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read operators file: %w", err)
+	}
+	var file operatorsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidOperators, err)
+	}
+	return NewOperators(file.Operators)
+}
+
+// Authenticate returns the operator a bearer token belongs to.
+func (o *Operators) Authenticate(token string) (*Operator, error) {
+	if o == nil || token == "" {
+		return nil, ErrUnknownOperator
+	}
+	sum := sha256.Sum256([]byte(token))
+	operator, ok := o.byToken[hex.EncodeToString(sum[:])]
+	if !ok {
+		return nil, ErrUnknownOperator
+	}
+	return operator, nil
+}
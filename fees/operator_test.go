@@ -0,0 +1,98 @@
+package fees
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func tokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestNewOperators_Validation(t *testing.T) {
+	finance := []OperatorRole{OperatorRoleFinance}
+	tests := []struct {
+		name        string
+		credentials []OperatorCredential
+	}{
+		{"empty id", []OperatorCredential{{ID: " ", TokenSHA256: tokenHash("a"), Roles: finance}}},
+		{"duplicate id", []OperatorCredential{
+			{ID: "nino", TokenSHA256: tokenHash("a"), Roles: finance},
+			{ID: "nino", TokenSHA256: tokenHash("b"), Roles: finance},
+		}},
+		{"plain token", []OperatorCredential{{ID: "nino", TokenSHA256: "secret", Roles: finance}}},
+		{"short hash", []OperatorCredential{{ID: "nino", TokenSHA256: tokenHash("a")[:32], Roles: finance}}},
+		{"shared token", []OperatorCredential{
+			{ID: "nino", TokenSHA256: tokenHash("a"), Roles: finance},
+			{ID: "giorgi", TokenSHA256: strings.ToUpper(tokenHash("a"))},
+		}},
+		{"unknown role", []OperatorCredential{{ID: "nino", TokenSHA256: tokenHash("a"), Roles: []OperatorRole{"ADMIN"}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewOperators(tt.credentials)
+			assert.ErrorIs(t, err, ErrInvalidOperators)
+		})
+	}
+}
+
+func TestOperators_Authenticate(t *testing.T) {
+	operators, err := NewOperators([]OperatorCredential{
+		{ID: "nino", TokenSHA256: strings.ToUpper(tokenHash("nino-token")), Roles: []OperatorRole{OperatorRoleFinance}},
+		{ID: "giorgi", TokenSHA256: tokenHash("giorgi-token")},
+	})
+	require.NoError(t, err)
+
+	operator, err := operators.Authenticate("nino-token")
+	require.NoError(t, err)
+	assert.Equal(t, "nino", operator.ID)
+	assert.NoError(t, operator.Require(OperatorRoleFinance))
+
+	operator, err = operators.Authenticate("giorgi-token")
+	require.NoError(t, err)
+	assert.ErrorIs(t, operator.Require(OperatorRoleFinance), ErrPermissionDenied)
+
+	_, err = operators.Authenticate("wrong")
+	assert.ErrorIs(t, err, ErrUnknownOperator)
+	_, err = operators.Authenticate("")
+	assert.ErrorIs(t, err, ErrUnknownOperator)
+
+	var nobody *Operators
+	_, err = nobody.Authenticate("nino-token")
+	assert.ErrorIs(t, err, ErrUnknownOperator)
+}
+
+func TestOperator_Require_Nil(t *testing.T) {
+	var operator *Operator
+	assert.ErrorIs(t, operator.Require(OperatorRoleFinance), ErrPermissionDenied)
+}
+
+func TestLoadOperators(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "operators.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"operators": [
+		{"id": "nino", "tokenSha256": "`+tokenHash("nino-token")+`", "roles": ["FINANCE"]}
+	]}`), 0o600))
+
+	operators, err := LoadOperators(path)
+	require.NoError(t, err)
+	operator, err := operators.Authenticate("nino-token")
+	require.NoError(t, err)
+	assert.Equal(t, []OperatorRole{OperatorRoleFinance}, operator.Roles)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"operators": [`), 0o600))
+	_, err = LoadOperators(path)
+	assert.ErrorIs(t, err, ErrInvalidOperators)
+
+	_, err = LoadOperators(filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,252 @@
+package fees
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"pave-fees/fees/internal/temporal"
+
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/client"
+)
+
+const (
+	outboxBatchSize   = 100
+	outboxMaxAttempts = 10
+)
+
+var errOutboxUndeliverable = errors.New("outbox event cannot be delivered")
+
+type OutboxEventKind string
+
+const (
+	OutboxStartBill    OutboxEventKind = "START_BILL"
+	OutboxAddLineItem  OutboxEventKind = "ADD_LINE_ITEM"
+	OutboxVoidLineItem OutboxEventKind = "VOID_LINE_ITEM"
+	OutboxReopenBill   OutboxEventKind = "REOPEN_BILL"
+)
+
+type OutboxStatus string
+
+const (
+	OutboxStatusPending OutboxStatus = "PENDING"
+	OutboxStatusDone    OutboxStatus = "DONE"
+	OutboxStatusFailed  OutboxStatus = "FAILED"
+)
+
+type OutboxEvent struct {
+	ID         int64           `json:"id"`
+	BillID     string          `json:"billId"`
+	Kind       OutboxEventKind `json:"kind"`
+	Payload    json.RawMessage `json:"payload"`
+	LineItemID *int64          `json:"lineItemId,omitempty"`
+	Status     OutboxStatus    `json:"status"`
+	Attempts   int             `json:"attempts"`
+	CreatedAt  time.Time       `json:"createdAt"`
+}
+
+type RelayOutboxResponse struct {
+	Delivered int `json:"delivered"`
+	Retrying  int `json:"retrying"`
+	Failed    int `json:"failed"`
+}
+
+// billWorkflowOptions rejects duplicate IDs so a late start can never
+// resurrect the workflow of a bill that has already been closed.
+func billWorkflowOptions(billID string) client.StartWorkflowOptions {
+	return client.StartWorkflowOptions{
+		ID:                    billID,
+		TaskQueue:             temporal.TaskQueue,
+		WorkflowIDReusePolicy: enumspb.WORKFLOW_ID_REUSE_POLICY_REJECT_DUPLICATE,
+	}
+}
+
+// reopenedBillWorkflowOptions start a reopened bill's new run under the same
+// workflow ID as the run that closed it. Starting while that run is still
+// finishing is an error rather than a handle to it.
+func reopenedBillWorkflowOptions(billID string) client.StartWorkflowOptions {
+	options := billWorkflowOptions(billID)
+	options.WorkflowIDReusePolicy = enumspb.WORKFLOW_ID_REUSE_POLICY_ALLOW_DUPLICATE
+	options.WorkflowExecutionErrorWhenAlreadyStarted = true
+	return options
+}
+
+func lineItemUpdateID(event *OutboxEvent) string {
+	return fmt.Sprintf("outbox-%d", event.ID)
+}
+
+func (s *BillService) RelayOutbox(ctx context.Context) (*RelayOutboxResponse, error) {
+	events, err := s.repo.ListPendingOutboxEvents(ctx, outboxBatchSize)
+	if err != nil {
+		slog.Error("failed to list pending outbox events", "error", err)
+		return nil, fmt.Errorf("failed to list pending outbox events: %w", err)
+	}
+
+	response := &RelayOutboxResponse{}
+	// Events for a bill are delivered in order, so one failure holds back the rest of that bill.
+	blocked := make(map[string]bool)
+	for _, event := range events {
+		if blocked[event.BillID] {
+			continue
+		}
+
+		if err := s.deliverOutboxEvent(ctx, event); err != nil {
+			blocked[event.BillID] = true
+			if s.recordOutboxFailure(ctx, event, err) {
+				response.Failed++
+			} else {
+				response.Retrying++
+			}
+			continue
+		}
+
+		if err := s.repo.MarkOutboxEventDone(ctx, event.ID); err != nil {
+			slog.Error("failed to mark outbox event done", "event_id", event.ID, "bill_id", event.BillID, "error", err)
+			return nil, fmt.Errorf("failed to mark outbox event done: %w", err)
+		}
+		response.Delivered++
+	}
+
+	slog.Info("outbox relay finished", "delivered", response.Delivered, "retrying", response.Retrying, "failed", response.Failed)
+	return response, nil
+}
+
+func (s *BillService) deliverOutboxEvent(ctx context.Context, event *OutboxEvent) error {
+	switch event.Kind {
+	case OutboxStartBill:
+		var bill Bill
+		if err := json.Unmarshal(event.Payload, &bill); err != nil {
+			return fmt.Errorf("%w: invalid bill payload: %v", errOutboxUndeliverable, err)
+		}
+		if _, err := s.temporal.ExecuteWorkflow(ctx, billWorkflowOptions(bill.ID), BillWorkflow, bill, BillProgress{}); err != nil {
+			return fmt.Errorf("failed to start bill workflow: %w", err)
+		}
+		return nil
+
+	case OutboxAddLineItem:
+		var item LineItem
+		if err := json.Unmarshal(event.Payload, &item); err != nil {
+			return fmt.Errorf("%w: invalid line item payload: %v", errOutboxUndeliverable, err)
+		}
+		return s.relayUpdate(ctx, event, AddLineItemUpdate, item)
+
+	case OutboxVoidLineItem:
+		void, err := s.decodeLineItemVoid(ctx, event)
+		if err != nil {
+			return err
+		}
+		return s.relayUpdate(ctx, event, VoidLineItemUpdate, void)
+
+	case OutboxReopenBill:
+		var reopen BillReopen
+		if err := json.Unmarshal(event.Payload, &reopen); err != nil {
+			return fmt.Errorf("%w: invalid reopen payload: %v", errOutboxUndeliverable, err)
+		}
+		return s.startReopenedBill(ctx, reopen)
+	}
+
+	return fmt.Errorf("%w: unknown kind %s", errOutboxUndeliverable, event.Kind)
+}
+
+// relayUpdate delivers an item or void event as an update. It uses the same
+// update ID as the API did, so a redelivery after a lost MarkOutboxEventDone
+// is deduplicated by the workflow. One the workflow rejects can never be
+// delivered, and failing it undoes its row change.
+func (s *BillService) relayUpdate(ctx context.Context, event *OutboxEvent, updateName string, arg interface{}) error {
+	var state BillState
+	err := s.updateWorkflow(ctx, event.BillID, updateName, lineItemUpdateID(event), &state, arg)
+	if err != nil {
+		if errors.Is(err, ErrUpdateRejected) {
+			return fmt.Errorf("%w: %v", errOutboxUndeliverable, err)
+		}
+		var notFound *serviceerror.NotFound
+		if errors.As(err, &notFound) {
+			return fmt.Errorf("%w: bill workflow is no longer running", errOutboxUndeliverable)
+		}
+		return fmt.Errorf("failed to deliver %s to bill workflow: %w", updateName, err)
+	}
+	return nil
+}
+
+// decodeLineItemVoid reads a void event. Events stored before voids carried
+// the item's amount have none, so it is looked up from the voided item; a
+// workflow that continued as new would otherwise take nothing off its total.
+func (s *BillService) decodeLineItemVoid(ctx context.Context, event *OutboxEvent) (LineItemVoid, error) {
+	var void LineItemVoid
+	var stored struct {
+		Amount *int64 `json:"amount"`
+	}
+	if err := json.Unmarshal(event.Payload, &void); err != nil {
+		return LineItemVoid{}, fmt.Errorf("%w: invalid void payload: %v", errOutboxUndeliverable, err)
+	}
+	if err := json.Unmarshal(event.Payload, &stored); err != nil || stored.Amount != nil {
+		return void, nil
+	}
+
+	items, err := s.repo.GetLineItemsByBillID(ctx, event.BillID)
+	if err != nil {
+		return LineItemVoid{}, fmt.Errorf("failed to look up voided line item: %w", err)
+	}
+	for _, item := range items {
+		if item.ID == void.ItemID {
+			void.Amount = item.ExtendedAmount()
+			return void, nil
+		}
+	}
+	return LineItemVoid{}, fmt.Errorf("%w: line item %s not found", errOutboxUndeliverable, void.ItemID)
+}
+
+// startReopenedBill stops the dunning of the bill's earlier close and starts
+// its new run. A run that is already going is this one, delivered before;
+// a closed one is the run that closed the bill, still finishing.
+func (s *BillService) startReopenedBill(ctx context.Context, reopen BillReopen) error {
+	billID := reopen.Bill.ID
+	var notFound *serviceerror.NotFound
+	if err := s.temporal.CancelWorkflow(ctx, DunningWorkflowID(billID), ""); err != nil && !errors.As(err, &notFound) {
+		return fmt.Errorf("failed to cancel dunning workflow: %w", err)
+	}
+
+	_, err := s.temporal.ExecuteWorkflow(ctx, reopenedBillWorkflowOptions(billID), BillWorkflow, reopen.Bill, reopen.Progress)
+	var alreadyStarted *serviceerror.WorkflowExecutionAlreadyStarted
+	if !errors.As(err, &alreadyStarted) {
+		if err != nil {
+			return fmt.Errorf("failed to start reopened bill workflow: %w", err)
+		}
+		return nil
+	}
+
+	value, err := s.temporal.QueryWorkflow(ctx, billID, "", GetBillStateQuery)
+	if err != nil {
+		return fmt.Errorf("failed to query running bill workflow: %w", err)
+	}
+	var state BillState
+	if err := value.Get(&state); err != nil {
+		return fmt.Errorf("failed to decode bill state: %w", err)
+	}
+	if state.IsClosed {
+		return fmt.Errorf("bill workflow that closed %s has not finished yet", billID)
+	}
+	return nil
+}
+
+// recordOutboxFailure reports whether the event was given up on.
+func (s *BillService) recordOutboxFailure(ctx context.Context, event *OutboxEvent, deliveryErr error) bool {
+	if errors.Is(deliveryErr, errOutboxUndeliverable) || event.Attempts+1 >= outboxMaxAttempts {
+		slog.Error("giving up on outbox event", "event_id", event.ID, "bill_id", event.BillID, "kind", event.Kind, "error", deliveryErr)
+		if err := s.repo.MarkOutboxEventFailed(ctx, event.ID, deliveryErr.Error()); err != nil {
+			slog.Error("failed to mark outbox event failed", "event_id", event.ID, "error", err)
+		}
+		return true
+	}
+
+	slog.Warn("outbox event delivery failed, will retry", "event_id", event.ID, "bill_id", event.BillID, "attempts", event.Attempts+1, "error", deliveryErr)
+	if err := s.repo.MarkOutboxEventRetry(ctx, event.ID, deliveryErr.Error()); err != nil {
+		slog.Error("failed to record outbox event attempt", "event_id", event.ID, "error", err)
+	}
+	return false
+}
@@ -0,0 +1,367 @@
+package fees
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/client"
+)
+
+func newTestOutboxEvent(t *testing.T, id int64, billID string, kind OutboxEventKind, payload interface{}) *OutboxEvent {
+	t.Helper()
+	data, err := json.Marshal(payload)
+	require.NoError(t, err)
+	return &OutboxEvent{
+		ID:      id,
+		BillID:  billID,
+		Kind:    kind,
+		Payload: data,
+		Status:  OutboxStatusPending,
+	}
+}
+
+func TestBillService_RelayOutbox(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := NewMockRepositoryInterface(ctrl)
+	mockTemporal := NewMockTemporalClientInterface(ctrl)
+	service := NewBillService(mockRepo, mockTemporal)
+
+	billA := &Bill{ID: "bill-a", CustomerID: "customer-a", Currency: USD, Status: BillStatusOpen}
+	billB := &Bill{ID: "bill-b", CustomerID: "customer-b", Currency: GEL, Status: BillStatusOpen}
+	item := LineItem{Description: "Item", Amount: 500}
+
+	t.Run("DeliversInOrderAndHoldsBackFailedBill", func(t *testing.T) {
+		ctx := context.Background()
+
+		events := []*OutboxEvent{
+			newTestOutboxEvent(t, 1, billA.ID, OutboxStartBill, billA),
+			newTestOutboxEvent(t, 2, billB.ID, OutboxStartBill, billB),
+			newTestOutboxEvent(t, 3, billA.ID, OutboxAddLineItem, item),
+			newTestOutboxEvent(t, 4, billB.ID, OutboxAddLineItem, item),
+		}
+
+		mockRepo.EXPECT().
+			ListPendingOutboxEvents(ctx, outboxBatchSize).
+			Return(events, nil)
+
+		mockTemporal.EXPECT().
+			ExecuteWorkflow(ctx, gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, options client.StartWorkflowOptions, workflow interface{}, args ...interface{}) (client.WorkflowRun, error) {
+				if options.ID == billB.ID {
+					return nil, errors.New("temporal unavailable")
+				}
+				return nil, nil
+			}).
+			Times(2)
+
+		mockRepo.EXPECT().MarkOutboxEventDone(ctx, int64(1)).Return(nil)
+		mockRepo.EXPECT().MarkOutboxEventRetry(ctx, int64(2), gomock.Any()).Return(nil)
+
+		mockTemporal.EXPECT().
+			UpdateWorkflow(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, options client.UpdateWorkflowOptions) (client.WorkflowUpdateHandle, error) {
+				assert.Equal(t, billA.ID, options.WorkflowID)
+				assert.Equal(t, AddLineItemUpdate, options.UpdateName)
+				assert.Equal(t, "outbox-3", options.UpdateID)
+				assert.Equal(t, []interface{}{item}, options.Args)
+				return fakeUpdateHandle{result: BillState{TotalAmount: 500}}, nil
+			})
+
+		mockRepo.EXPECT().MarkOutboxEventDone(ctx, int64(3)).Return(nil)
+
+		response, err := service.RelayOutbox(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, response.Delivered)
+		assert.Equal(t, 1, response.Retrying)
+		assert.Equal(t, 0, response.Failed)
+	})
+
+	t.Run("CompletedWorkflowFailsLineItemEvent", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().
+			ListPendingOutboxEvents(ctx, outboxBatchSize).
+			Return([]*OutboxEvent{newTestOutboxEvent(t, 5, billA.ID, OutboxAddLineItem, item)}, nil)
+
+		mockTemporal.EXPECT().
+			UpdateWorkflow(ctx, gomock.Any()).
+			Return(nil, serviceerror.NewNotFound("workflow execution already completed"))
+
+		mockRepo.EXPECT().
+			MarkOutboxEventFailed(ctx, int64(5), gomock.Any()).
+			Return(nil)
+
+		response, err := service.RelayOutbox(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, 0, response.Delivered)
+		assert.Equal(t, 1, response.Failed)
+	})
+
+	t.Run("RejectedLineItemEventFails", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().
+			ListPendingOutboxEvents(ctx, outboxBatchSize).
+			Return([]*OutboxEvent{newTestOutboxEvent(t, 9, billA.ID, OutboxAddLineItem, item)}, nil)
+
+		mockTemporal.EXPECT().
+			UpdateWorkflow(ctx, gomock.Any()).
+			Return(fakeUpdateHandle{err: toWorkflowError(ErrBillAlreadyClosed)}, nil)
+
+		mockRepo.EXPECT().
+			MarkOutboxEventFailed(ctx, int64(9), gomock.Any()).
+			Return(nil)
+
+		response, err := service.RelayOutbox(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, response.Failed)
+	})
+
+	t.Run("RejectedCreditEventFails", func(t *testing.T) {
+		ctx := context.Background()
+
+		credit := LineItem{
+			ID:          "item-credit",
+			Description: "Outage",
+			Amount:      -50000,
+			Kind:        LineItemKindCredit,
+			ReasonCode:  CreditReasonOutage,
+		}
+		mockRepo.EXPECT().
+			ListPendingOutboxEvents(ctx, outboxBatchSize).
+			Return([]*OutboxEvent{newTestOutboxEvent(t, 10, billA.ID, OutboxAddLineItem, credit)}, nil)
+
+		mockTemporal.EXPECT().
+			UpdateWorkflow(ctx, gomock.Any()).
+			Return(fakeUpdateHandle{err: toWorkflowError(ErrNegativeTotal)}, nil)
+
+		// The reason ends up on the voided row, not a DONE event.
+		mockRepo.EXPECT().
+			MarkOutboxEventFailed(ctx, int64(10), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ int64, lastError string) error {
+				assert.Contains(t, lastError, ErrNegativeTotal.Error())
+				return nil
+			})
+
+		response, err := service.RelayOutbox(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, 0, response.Delivered)
+		assert.Equal(t, 1, response.Failed)
+	})
+
+	t.Run("DeliversVoidAsUpdate", func(t *testing.T) {
+		ctx := context.Background()
+
+		void := LineItemVoid{ItemID: "item-1", Reason: "duplicate", Amount: 1000}
+		mockRepo.EXPECT().
+			ListPendingOutboxEvents(ctx, outboxBatchSize).
+			Return([]*OutboxEvent{
+				newTestOutboxEvent(t, 7, billA.ID, OutboxVoidLineItem, void),
+				newTestOutboxEvent(t, 8, billB.ID, OutboxVoidLineItem, void),
+			}, nil)
+
+		mockTemporal.EXPECT().
+			UpdateWorkflow(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, options client.UpdateWorkflowOptions) (client.WorkflowUpdateHandle, error) {
+				assert.Equal(t, billA.ID, options.WorkflowID)
+				assert.Equal(t, VoidLineItemUpdate, options.UpdateName)
+				assert.Equal(t, "outbox-7", options.UpdateID)
+				assert.Equal(t, []interface{}{void}, options.Args)
+				return fakeUpdateHandle{result: BillState{TotalAmount: 0}}, nil
+			})
+		mockRepo.EXPECT().MarkOutboxEventDone(ctx, int64(7)).Return(nil)
+
+		mockTemporal.EXPECT().
+			UpdateWorkflow(ctx, gomock.Any()).
+			Return(nil, serviceerror.NewNotFound("workflow execution already completed"))
+		mockRepo.EXPECT().MarkOutboxEventFailed(ctx, int64(8), gomock.Any()).Return(nil)
+
+		response, err := service.RelayOutbox(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, response.Delivered)
+		assert.Equal(t, 1, response.Failed)
+	})
+
+	t.Run("RejectedVoidEventFails", func(t *testing.T) {
+		ctx := context.Background()
+
+		void := LineItemVoid{ItemID: "item-1", Reason: "duplicate", Amount: 1000}
+		mockRepo.EXPECT().
+			ListPendingOutboxEvents(ctx, outboxBatchSize).
+			Return([]*OutboxEvent{newTestOutboxEvent(t, 12, billA.ID, OutboxVoidLineItem, void)}, nil)
+
+		mockTemporal.EXPECT().
+			UpdateWorkflow(ctx, gomock.Any()).
+			Return(fakeUpdateHandle{err: toWorkflowError(ErrLineItemVoided)}, nil)
+
+		// Failing the event restores the item the workflow still counts.
+		mockRepo.EXPECT().
+			MarkOutboxEventFailed(ctx, int64(12), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ int64, lastError string) error {
+				assert.Contains(t, lastError, ErrLineItemVoided.Error())
+				return nil
+			})
+
+		response, err := service.RelayOutbox(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, 0, response.Delivered)
+		assert.Equal(t, 1, response.Failed)
+	})
+
+	t.Run("LooksUpAmountOfOlderVoid", func(t *testing.T) {
+		ctx := context.Background()
+
+		// Stored before voids carried the item's amount.
+		older := map[string]string{"itemId": "item-2", "reason": "duplicate"}
+		mockRepo.EXPECT().
+			ListPendingOutboxEvents(ctx, outboxBatchSize).
+			Return([]*OutboxEvent{newTestOutboxEvent(t, 11, billA.ID, OutboxVoidLineItem, older)}, nil)
+		mockRepo.EXPECT().
+			GetLineItemsByBillID(ctx, billA.ID).
+			Return([]LineItem{
+				{ID: "item-1", Description: "Kept", Amount: 500},
+				{ID: "item-2", Description: "Calls", Quantity: 3 * QuantityOne, UnitPrice: 250},
+			}, nil)
+
+		mockTemporal.EXPECT().
+			UpdateWorkflow(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, options client.UpdateWorkflowOptions) (client.WorkflowUpdateHandle, error) {
+				assert.Equal(t, []interface{}{LineItemVoid{ItemID: "item-2", Reason: "duplicate", Amount: 750}}, options.Args)
+				return fakeUpdateHandle{result: BillState{}}, nil
+			})
+		mockRepo.EXPECT().MarkOutboxEventDone(ctx, int64(11)).Return(nil)
+
+		response, err := service.RelayOutbox(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, response.Delivered)
+	})
+
+	t.Run("ReopenCancelsDunningAndStartsNewRun", func(t *testing.T) {
+		ctx := context.Background()
+
+		reopen := BillReopen{
+			Bill:     Bill{ID: "bill-c", CustomerID: "customer-c", Currency: GEL, Status: BillStatusOpen},
+			Progress: BillProgress{Runs: 1, ItemCount: 2, RunningTotal: 1500, Reopened: true},
+		}
+		mockRepo.EXPECT().
+			ListPendingOutboxEvents(ctx, outboxBatchSize).
+			Return([]*OutboxEvent{newTestOutboxEvent(t, 9, "bill-c", OutboxReopenBill, reopen)}, nil)
+
+		gomock.InOrder(
+			mockTemporal.EXPECT().
+				CancelWorkflow(ctx, DunningWorkflowID("bill-c"), "").
+				Return(nil),
+			mockTemporal.EXPECT().
+				ExecuteWorkflow(ctx, gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, options client.StartWorkflowOptions, workflow interface{}, args ...interface{}) (client.WorkflowRun, error) {
+					assert.Equal(t, "bill-c", options.ID)
+					assert.Equal(t, enumspb.WORKFLOW_ID_REUSE_POLICY_ALLOW_DUPLICATE, options.WorkflowIDReusePolicy)
+					assert.True(t, options.WorkflowExecutionErrorWhenAlreadyStarted)
+					assert.Equal(t, []interface{}{reopen.Bill, reopen.Progress}, args)
+					return nil, nil
+				}),
+		)
+		mockRepo.EXPECT().MarkOutboxEventDone(ctx, int64(9)).Return(nil)
+
+		response, err := service.RelayOutbox(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, response.Delivered)
+	})
+
+	t.Run("ReopenWaitsForClosingRun", func(t *testing.T) {
+		ctx := context.Background()
+
+		reopen := BillReopen{Bill: Bill{ID: "bill-c"}, Progress: BillProgress{Runs: 1, Reopened: true}}
+		events := []*OutboxEvent{newTestOutboxEvent(t, 10, "bill-c", OutboxReopenBill, reopen)}
+		alreadyStarted := serviceerror.NewWorkflowExecutionAlreadyStarted("already started", "", "")
+
+		// The run that closed the bill is still finishing, so try again later.
+		mockRepo.EXPECT().ListPendingOutboxEvents(ctx, outboxBatchSize).Return(events, nil)
+		mockTemporal.EXPECT().
+			CancelWorkflow(ctx, DunningWorkflowID("bill-c"), "").
+			Return(serviceerror.NewNotFound("workflow not found"))
+		mockTemporal.EXPECT().
+			ExecuteWorkflow(ctx, gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(nil, alreadyStarted)
+		mockTemporal.EXPECT().
+			QueryWorkflow(ctx, "bill-c", "", GetBillStateQuery).
+			Return(fakeEncodedValue{value: BillState{BillID: "bill-c", IsClosed: true}}, nil)
+		mockRepo.EXPECT().MarkOutboxEventRetry(ctx, int64(10), gomock.Any()).Return(nil)
+
+		response, err := service.RelayOutbox(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, response.Retrying)
+
+		// An open run is the reopened one, started on the earlier attempt.
+		mockRepo.EXPECT().ListPendingOutboxEvents(ctx, outboxBatchSize).Return(events, nil)
+		mockTemporal.EXPECT().
+			CancelWorkflow(ctx, DunningWorkflowID("bill-c"), "").
+			Return(nil)
+		mockTemporal.EXPECT().
+			ExecuteWorkflow(ctx, gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(nil, alreadyStarted)
+		mockTemporal.EXPECT().
+			QueryWorkflow(ctx, "bill-c", "", GetBillStateQuery).
+			Return(fakeEncodedValue{value: BillState{BillID: "bill-c"}}, nil)
+		mockRepo.EXPECT().MarkOutboxEventDone(ctx, int64(10)).Return(nil)
+
+		response, err = service.RelayOutbox(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, response.Delivered)
+	})
+
+	t.Run("GivesUpAfterMaxAttempts", func(t *testing.T) {
+		ctx := context.Background()
+
+		event := newTestOutboxEvent(t, 6, billB.ID, OutboxStartBill, billB)
+		event.Attempts = outboxMaxAttempts - 1
+
+		mockRepo.EXPECT().
+			ListPendingOutboxEvents(ctx, outboxBatchSize).
+			Return([]*OutboxEvent{event}, nil)
+
+		mockTemporal.EXPECT().
+			ExecuteWorkflow(ctx, gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(nil, errors.New("temporal unavailable"))
+
+		mockRepo.EXPECT().
+			MarkOutboxEventFailed(ctx, int64(6), gomock.Any()).
+			Return(nil)
+
+		response, err := service.RelayOutbox(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, response.Failed)
+	})
+
+	t.Run("RepositoryError", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().
+			ListPendingOutboxEvents(ctx, outboxBatchSize).
+			Return(nil, errors.New("database error"))
+
+		response, err := service.RelayOutbox(ctx)
+
+		require.Error(t, err)
+		assert.Nil(t, response)
+		assert.Contains(t, err.Error(), "failed to list pending outbox events")
+	})
+}
@@ -0,0 +1,150 @@
+package fees
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidPayment           = errors.New("invalid payment")
+	ErrBillNotClosed            = errors.New("bill is not closed")
+	ErrPaymentReferenceConflict = errors.New("payment reference already used for a different payment")
+)
+
+type PaymentMethod string
+
+const (
+	PaymentMethodCard         PaymentMethod = "CARD"
+	PaymentMethodBankTransfer PaymentMethod = "BANK_TRANSFER"
+	PaymentMethodCash         PaymentMethod = "CASH"
+	PaymentMethodOther        PaymentMethod = "OTHER"
+)
+
+func (m PaymentMethod) IsValid() bool {
+	switch m {
+	case PaymentMethodCard, PaymentMethodBankTransfer, PaymentMethodCash, PaymentMethodOther:
+		return true
+	}
+	return false
+}
+
+// PaymentStatus is derived from what a closed bill came to and what has been
+// paid against it; it is never stored.
+type PaymentStatus string
+
+const (
+	PaymentStatusUnpaid        PaymentStatus = "UNPAID"
+	PaymentStatusPartiallyPaid PaymentStatus = "PARTIALLY_PAID"
+	PaymentStatusPaid          PaymentStatus = "PAID"
+	PaymentStatusOverpaid      PaymentStatus = "OVERPAID"
+)
+
+// IsSettled reports whether nothing is left owing.
+func (s PaymentStatus) IsSettled() bool {
+	return s == PaymentStatusPaid || s == PaymentStatusOverpaid
+}
+
+// SettlementStatus compares paid against total. A bill that came to zero or
+// less owes nothing, so it is PAID until something is paid against it.
+func SettlementStatus(total, paid int64) PaymentStatus {
+	switch {
+	case total <= 0 && paid == 0:
+		return PaymentStatusPaid
+	case paid > total:
+		return PaymentStatusOverpaid
+	case paid == total:
+		return PaymentStatusPaid
+	case paid == 0:
+		return PaymentStatusUnpaid
+	default:
+		return PaymentStatusPartiallyPaid
+	}
+}
+
+// Payment is money received against a closed bill, in the bill currency.
+// ExternalReference is the processor's or bank's ID for it; recording the
+// same reference twice on a bill returns the first payment.
+type Payment struct {
+	ID                string        `json:"id"`
+	BillID            string        `json:"billId"`
+	Amount            int64         `json:"amount"`
+	Currency          Currency      `json:"currency"`
+	Method            PaymentMethod `json:"method"`
+	ExternalReference string        `json:"externalReference,omitempty"`
+	ReceivedAt        time.Time     `json:"receivedAt"`
+	CreatedAt         time.Time     `json:"createdAt"`
+}
+
+func newPaymentID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("failed to generate payment ID: %v", err))
+	}
+	return "pay-" + hex.EncodeToString(b)
+}
+
+// SamePayment reports whether a retry describes the payment already recorded
+// under its reference.
+func (p *Payment) SamePayment(other *Payment) bool {
+	return p.Amount == other.Amount && p.Currency == other.Currency && p.Method == other.Method
+}
+
+type RecordPaymentRequest struct {
+	Amount int64         `json:"amount"`
+	Method PaymentMethod `json:"method"`
+	// Currency defaults to the bill currency and has to match it if set.
+	Currency          Currency `json:"currency,omitempty"`
+	ExternalReference string   `json:"externalReference,omitempty"`
+	// ReceivedAt defaults to now.
+	ReceivedAt *time.Time `json:"receivedAt,omitempty"`
+}
+
+func (r *RecordPaymentRequest) Validate() error {
+	if r.Amount <= 0 {
+		return ErrInvalidAmount
+	}
+	if !r.Method.IsValid() {
+		return fmt.Errorf("%w: unknown method %q. Supported methods: CARD, BANK_TRANSFER, CASH, OTHER", ErrInvalidPayment, r.Method)
+	}
+	if r.Currency != "" {
+		if err := r.Currency.Validate(); err != nil {
+			return err
+		}
+	}
+	if len(r.ExternalReference) > 255 {
+		return fmt.Errorf("%w: externalReference is longer than 255 characters", ErrInvalidPayment)
+	}
+	return nil
+}
+
+func (r *RecordPaymentRequest) toPayment(bill *Bill, now time.Time) *Payment {
+	receivedAt := now
+	if r.ReceivedAt != nil {
+		receivedAt = *r.ReceivedAt
+	}
+	return &Payment{
+		ID:                newPaymentID(),
+		BillID:            bill.ID,
+		Amount:            r.Amount,
+		Currency:          bill.Currency,
+		Method:            r.Method,
+		ExternalReference: strings.TrimSpace(r.ExternalReference),
+		ReceivedAt:        receivedAt,
+		CreatedAt:         now,
+	}
+}
+
+type RecordPaymentResponse struct {
+	Payment       *Payment      `json:"payment"`
+	PaidAmount    int64         `json:"paidAmount"`
+	Balance       int64         `json:"balance"`
+	PaymentStatus PaymentStatus `json:"paymentStatus"`
+}
+
+type ListPaymentsResponse struct {
+	Payments []*Payment `json:"payments"`
+}
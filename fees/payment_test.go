@@ -0,0 +1,96 @@
+package fees
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSettlementStatus(t *testing.T) {
+	tests := []struct {
+		name  string
+		total int64
+		paid  int64
+		want  PaymentStatus
+	}{
+		{"nothing paid", 10000, 0, PaymentStatusUnpaid},
+		{"part paid", 10000, 2500, PaymentStatusPartiallyPaid},
+		{"paid in full", 10000, 10000, PaymentStatusPaid},
+		{"paid too much", 10000, 10001, PaymentStatusOverpaid},
+		{"zero bill", 0, 0, PaymentStatusPaid},
+		{"credit bill", -500, 0, PaymentStatusPaid},
+		{"paid against zero bill", 0, 100, PaymentStatusOverpaid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, SettlementStatus(tt.total, tt.paid))
+		})
+	}
+}
+
+func TestBill_Settle(t *testing.T) {
+	bill := &Bill{Status: BillStatusOpen, TotalAmount: 10000, PaidAmount: 0}
+	bill.settle()
+	assert.Empty(t, bill.PaymentStatus)
+	assert.Equal(t, int64(10000), bill.Balance())
+
+	bill.Status = BillStatusClosed
+	bill.PaidAmount = 4000
+	bill.settle()
+	assert.Equal(t, PaymentStatusPartiallyPaid, bill.PaymentStatus)
+	assert.Equal(t, int64(6000), bill.Balance())
+
+	bill.PaidAmount = 12000
+	bill.settle()
+	assert.Equal(t, PaymentStatusOverpaid, bill.PaymentStatus)
+	assert.Equal(t, int64(-2000), bill.Balance())
+}
+
+func TestRecordPaymentRequest_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     RecordPaymentRequest
+		wantErr error
+	}{
+		{"valid", RecordPaymentRequest{Amount: 500, Method: PaymentMethodCard, ExternalReference: "ch_123"}, nil},
+		{"valid with currency", RecordPaymentRequest{Amount: 500, Method: PaymentMethodBankTransfer, Currency: GEL}, nil},
+		{"zero amount", RecordPaymentRequest{Amount: 0, Method: PaymentMethodCash}, ErrInvalidAmount},
+		{"negative amount", RecordPaymentRequest{Amount: -5, Method: PaymentMethodCash}, ErrInvalidAmount},
+		{"unknown method", RecordPaymentRequest{Amount: 500, Method: "BITCOIN"}, ErrInvalidPayment},
+		{"missing method", RecordPaymentRequest{Amount: 500}, ErrInvalidPayment},
+		{"bad currency", RecordPaymentRequest{Amount: 500, Method: PaymentMethodCard, Currency: "usd"}, ErrInvalidCurrency},
+		{"long reference", RecordPaymentRequest{Amount: 500, Method: PaymentMethodCard, ExternalReference: strings.Repeat("x", 256)}, ErrInvalidPayment},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate()
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestRecordPaymentRequest_ToPayment(t *testing.T) {
+	now := time.Date(2024, 4, 2, 10, 0, 0, 0, time.UTC)
+	bill := &Bill{ID: "bill-1", Currency: GEL}
+
+	payment := (&RecordPaymentRequest{Amount: 500, Method: PaymentMethodCard, ExternalReference: " ch_123 "}).toPayment(bill, now)
+
+	assert.Contains(t, payment.ID, "pay-")
+	assert.Equal(t, "bill-1", payment.BillID)
+	assert.Equal(t, GEL, payment.Currency)
+	assert.Equal(t, "ch_123", payment.ExternalReference)
+	assert.Equal(t, now, payment.ReceivedAt)
+	assert.Equal(t, now, payment.CreatedAt)
+
+	received := now.Add(-48 * time.Hour)
+	payment = (&RecordPaymentRequest{Amount: 500, Method: PaymentMethodBankTransfer, ReceivedAt: &received}).toPayment(bill, now)
+	assert.Equal(t, received, payment.ReceivedAt)
+}
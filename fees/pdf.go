@@ -0,0 +1,201 @@
+package fees
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// A minimal PDF 1.4 writer: text in the two standard Helvetica faces,
+// filled rectangles and lines, on A4 pages. The standard fonts ship with
+// every PDF reader, so nothing has to be embedded.
+
+const (
+	pdfPageWidth  = 595.0
+	pdfPageHeight = 842.0
+)
+
+type pdfFont string
+
+const (
+	pdfRegular pdfFont = "F1"
+	pdfBold    pdfFont = "F2"
+)
+
+// pdfColor is an RGB colour with components from 0 to 1.
+type pdfColor struct{ R, G, B float64 }
+
+var (
+	pdfBlack = pdfColor{0, 0, 0}
+	pdfWhite = pdfColor{1, 1, 1}
+	pdfGrey  = pdfColor{0.45, 0.45, 0.45}
+)
+
+// parseHexColor reads "#rrggbb".
+func parseHexColor(hex string) (pdfColor, error) {
+	var r, g, b uint8
+	if len(hex) != 7 || hex[0] != '#' {
+		return pdfColor{}, fmt.Errorf("colour %q is not #rrggbb", hex)
+	}
+	if _, err := fmt.Sscanf(hex[1:], "%02x%02x%02x", &r, &g, &b); err != nil {
+		return pdfColor{}, fmt.Errorf("colour %q is not #rrggbb", hex)
+	}
+	return pdfColor{float64(r) / 255, float64(g) / 255, float64(b) / 255}, nil
+}
+
+type pdfPage struct {
+	content bytes.Buffer
+}
+
+type pdfDocument struct {
+	title string
+	pages []*pdfPage
+}
+
+func newPDFDocument(title string) *pdfDocument {
+	return &pdfDocument{title: title}
+}
+
+func (d *pdfDocument) addPage() *pdfPage {
+	page := &pdfPage{}
+	d.pages = append(d.pages, page)
+	return page
+}
+
+// text draws s with its baseline starting at (x, y), measured in points
+// from the bottom left of the page.
+func (p *pdfPage) text(x, y float64, font pdfFont, size float64, color pdfColor, s string) {
+	fmt.Fprintf(&p.content, "BT %.3f %.3f %.3f rg /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n",
+		color.R, color.G, color.B, font, size, x, y, pdfEscape(s))
+}
+
+// textRight draws s so that it ends at x.
+func (p *pdfPage) textRight(x, y float64, font pdfFont, size float64, color pdfColor, s string) {
+	p.text(x-pdfTextWidth(s, font, size), y, font, size, color, s)
+}
+
+// rotatedText draws s turned anticlockwise by 45 degrees, for watermarks.
+func (p *pdfPage) rotatedText(x, y float64, font pdfFont, size float64, color pdfColor, s string) {
+	fmt.Fprintf(&p.content, "BT %.3f %.3f %.3f rg /%s %.1f Tf 0.7071 0.7071 -0.7071 0.7071 %.2f %.2f Tm (%s) Tj ET\n",
+		color.R, color.G, color.B, font, size, x, y, pdfEscape(s))
+}
+
+func (p *pdfPage) fillRect(x, y, width, height float64, color pdfColor) {
+	fmt.Fprintf(&p.content, "%.3f %.3f %.3f rg %.2f %.2f %.2f %.2f re f\n",
+		color.R, color.G, color.B, x, y, width, height)
+}
+
+func (p *pdfPage) line(x1, y1, x2, y2 float64, color pdfColor) {
+	fmt.Fprintf(&p.content, "%.3f %.3f %.3f RG 0.5 w %.2f %.2f m %.2f %.2f l S\n",
+		color.R, color.G, color.B, x1, y1, x2, y2)
+}
+
+// Bytes lays out the objects and cross-reference table.
+func (d *pdfDocument) Bytes() []byte {
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Objects 1-5 are fixed; each page then takes a page and a content object.
+	const firstPage = 6
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	object(fmt.Sprintf("<< /Title (%s) /Producer (pave-fees) >>", pdfEscape(d.title)))
+	for i, page := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] "+
+			"/Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, firstPage+2*i+1))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.content.Len(), page.content.String()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return buf.Bytes()
+}
+
+// pdfEscape encodes s for a literal string in WinAnsiEncoding. Latin-1
+// characters are kept; anything else the standard fonts can't show becomes
+// "?".
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 32 && r <= 126:
+			b.WriteRune(r)
+		case r >= 0xA0 && r <= 0xFF:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// Advance widths of ASCII 32-126 in thousandths of the font size, from the
+// Adobe metrics for the standard fonts.
+var (
+	helveticaWidths = [95]int{
+		278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+		1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+		333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+		556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+	}
+	helveticaBoldWidths = [95]int{
+		278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+		975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+		333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+		611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+	}
+)
+
+// pdfTextWidth is how wide s is in points. Characters outside ASCII are
+// counted as a digit.
+func pdfTextWidth(s string, font pdfFont, size float64) float64 {
+	widths := &helveticaWidths
+	if font == pdfBold {
+		widths = &helveticaBoldWidths
+	}
+	total := 0
+	for _, r := range s {
+		if r >= 32 && r <= 126 {
+			total += widths[r-32]
+		} else {
+			total += 556
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// pdfTruncate shortens s with "..." until it fits in width points.
+func pdfTruncate(s string, font pdfFont, size, width float64) string {
+	if pdfTextWidth(s, font, size) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && pdfTextWidth(string(runes)+"...", font, size) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
@@ -0,0 +1,68 @@
+package fees
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPDFDocument_Bytes(t *testing.T) {
+	doc := newPDFDocument("Invoice (test)")
+	doc.addPage().text(50, 800, pdfRegular, 12, pdfBlack, "Hello")
+	doc.addPage().text(50, 800, pdfBold, 12, pdfBlack, "World")
+
+	data := doc.Bytes()
+
+	assert.True(t, bytes.HasPrefix(data, []byte("%PDF-1.4\n")))
+	assert.True(t, bytes.HasSuffix(data, []byte("%%EOF\n")))
+	assert.Contains(t, string(data), "/Count 2")
+	assert.Contains(t, string(data), "/Title (Invoice \\(test\\))")
+
+	t.Run("cross-reference offsets point at their objects", func(t *testing.T) {
+		match := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(data)
+		require.NotNil(t, match)
+		xref, err := strconv.Atoi(string(match[1]))
+		require.NoError(t, err)
+		require.True(t, bytes.HasPrefix(data[xref:], []byte("xref\n")))
+
+		offsets := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(data[xref:], -1)
+		require.Len(t, offsets, 9)
+		for i, offset := range offsets {
+			at, err := strconv.Atoi(string(offset[1]))
+			require.NoError(t, err)
+			assert.True(t, bytes.HasPrefix(data[at:], []byte(fmt.Sprintf("%d 0 obj\n", i+1))), "object %d", i+1)
+		}
+	})
+}
+
+func TestPDFEscape(t *testing.T) {
+	assert.Equal(t, `Fee \(10%\) \\ total`, pdfEscape(`Fee (10%) \ total`))
+	assert.Equal(t, `Caf\351`, pdfEscape("Café"))
+	assert.Equal(t, "? 5", pdfEscape("₾ 5"))
+}
+
+func TestPDFTextWidth(t *testing.T) {
+	assert.InDelta(t, 5.56*3, pdfTextWidth("100", pdfRegular, 10), 0.001)
+	assert.Greater(t, pdfTextWidth("Invoice", pdfBold, 10), pdfTextWidth("Invoice", pdfRegular, 10))
+
+	truncated := pdfTruncate("A very long line item description that will not fit", pdfRegular, 9, 100)
+	assert.LessOrEqual(t, pdfTextWidth(truncated, pdfRegular, 9), 100.0)
+	assert.Contains(t, truncated, "...")
+	assert.Equal(t, "Short", pdfTruncate("Short", pdfRegular, 9, 100))
+}
+
+func TestParseHexColor(t *testing.T) {
+	color, err := parseHexColor("#ff8000")
+	require.NoError(t, err)
+	assert.Equal(t, pdfColor{1, 128.0 / 255, 0}, color)
+
+	_, err = parseHexColor("red")
+	assert.Error(t, err)
+	_, err = parseHexColor("#ggg000")
+	assert.Error(t, err)
+}
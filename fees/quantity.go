@@ -0,0 +1,157 @@
+package fees
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidQuantity = errors.New("quantity must be positive")
+	ErrAmountMismatch  = errors.New("amount does not match quantity times unit price")
+)
+
+const (
+	// quantityDecimals is the number of fractional digits a Quantity keeps.
+	quantityDecimals = 6
+	QuantityScale    = 1000000
+)
+
+// Quantity is a decimal count stored as an integer number of millionths, so
+// 12 API calls is 12000000 and 1.5 GB-hours is 1500000. In JSON it reads and
+// writes as a plain decimal number ("quantity": 1.5) without going through
+// float64.
+type Quantity int64
+
+const QuantityOne Quantity = QuantityScale
+
+func ParseQuantity(s string) (Quantity, error) {
+	value, err := parseFixedPoint(s, quantityDecimals)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidQuantity, err)
+	}
+	return Quantity(value), nil
+}
+
+func (q Quantity) String() string {
+	return formatFixedPoint(int64(q), quantityDecimals, false)
+}
+
+func (q Quantity) MarshalJSON() ([]byte, error) {
+	return []byte(q.String()), nil
+}
+
+// UnmarshalJSON accepts both JSON numbers and numeric strings.
+func (q *Quantity) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	if strings.ContainsAny(s, "eE") {
+		return fmt.Errorf("%w: exponent notation is not supported", ErrInvalidQuantity)
+	}
+
+	parsed, err := ParseQuantity(s)
+	if err != nil {
+		return err
+	}
+	*q = parsed
+	return nil
+}
+
+// parseFixedPoint parses a plain decimal ("-1.25", ".5") into an integer
+// scaled by 10^decimals without going through float64.
+func parseFixedPoint(s string, decimals int) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, errors.New("empty number")
+	}
+
+	negative := false
+	if s[0] == '-' || s[0] == '+' {
+		negative = s[0] == '-'
+		s = s[1:]
+	}
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return 0, fmt.Errorf("%q is not a number", s)
+	}
+	if len(frac) > decimals {
+		return 0, fmt.Errorf("at most %d decimal places are supported", decimals)
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	digits := whole + frac + strings.Repeat("0", decimals-len(frac))
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return 0, fmt.Errorf("%q is not a number", s)
+		}
+	}
+
+	value, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is out of range", s)
+	}
+	if negative {
+		value = -value
+	}
+	return value, nil
+}
+
+// formatFixedPoint is the inverse of parseFixedPoint. Trailing zeros are
+// trimmed unless padded is set.
+func formatFixedPoint(value int64, decimals int, padded bool) string {
+	sign := ""
+	if value < 0 {
+		sign = "-"
+	}
+	digits := strconv.FormatUint(absAmount(value), 10)
+	if decimals == 0 {
+		return sign + digits
+	}
+	if len(digits) <= decimals {
+		digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+	}
+	split := len(digits) - decimals
+	whole, frac := digits[:split], digits[split:]
+	if !padded {
+		frac = strings.TrimRight(frac, "0")
+		if frac == "" {
+			return sign + whole
+		}
+	}
+	return sign + whole + "." + frac
+}
+
+func absAmount(amount int64) uint64 {
+	if amount < 0 {
+		return uint64(-(amount + 1)) + 1
+	}
+	return uint64(amount)
+}
+
+// ExtendedAmount returns quantity x unitPrice in minor units, rounding half
+// away from zero. It fails instead of overflowing int64.
+func ExtendedAmount(quantity Quantity, unitPrice int64) (int64, error) {
+	product := new(big.Int).Mul(big.NewInt(int64(quantity)), big.NewInt(unitPrice))
+
+	half := big.NewInt(QuantityScale / 2)
+	if product.Sign() < 0 {
+		product.Sub(product, half)
+	} else {
+		product.Add(product, half)
+	}
+	product.Quo(product, big.NewInt(QuantityScale))
+
+	if !product.IsInt64() {
+		return 0, fmt.Errorf("%w: %s x %d overflows", ErrInvalidAmount, quantity, unitPrice)
+	}
+	return product.Int64(), nil
+}
@@ -0,0 +1,114 @@
+package fees
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func quantityPtr(q Quantity) *Quantity {
+	return &q
+}
+
+func TestParseQuantity(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Quantity
+		wantErr bool
+	}{
+		{"1", QuantityOne, false},
+		{"12", 12 * QuantityScale, false},
+		{"1.5", 1500000, false},
+		{"0.000001", 1, false},
+		{".25", 250000, false},
+		{"-2", -2 * QuantityScale, false},
+		{"0.0000001", 0, true},
+		{"", 0, true},
+		{"abc", 0, true},
+		{"1.2.3", 0, true},
+		{"99999999999999", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseQuantity(tt.input)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidQuantity)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestQuantity_JSON(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		for _, q := range []Quantity{QuantityOne, 1500000, 1, -2500000} {
+			data, err := json.Marshal(q)
+			require.NoError(t, err)
+
+			var decoded Quantity
+			require.NoError(t, json.Unmarshal(data, &decoded))
+			assert.Equal(t, q, decoded)
+		}
+	})
+
+	t.Run("encodes as decimal number", func(t *testing.T) {
+		data, err := json.Marshal(struct {
+			Quantity Quantity `json:"quantity"`
+		}{Quantity: 1500000})
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"quantity": 1.5}`, string(data))
+	})
+
+	t.Run("accepts numeric strings", func(t *testing.T) {
+		var q Quantity
+		require.NoError(t, json.Unmarshal([]byte(`"0.25"`), &q))
+		assert.Equal(t, Quantity(250000), q)
+	})
+
+	t.Run("rejects exponents", func(t *testing.T) {
+		var q Quantity
+		assert.ErrorIs(t, json.Unmarshal([]byte(`1e3`), &q), ErrInvalidQuantity)
+	})
+}
+
+func TestExtendedAmount(t *testing.T) {
+	tests := []struct {
+		name      string
+		quantity  Quantity
+		unitPrice int64
+		want      int64
+	}{
+		{"whole units", 12 * QuantityScale, 5, 60},
+		{"fractional quantity", 1500000, 25, 38},
+		{"rounds down below half", 1400000, 25, 35},
+		{"rounds half away from zero when negative", -1500000, 25, -38},
+		{"tiny quantity", 1, 100, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExtendedAmount(tt.quantity, tt.unitPrice)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("overflow", func(t *testing.T) {
+		_, err := ExtendedAmount(Quantity(math.MaxInt64), math.MaxInt64)
+		assert.ErrorIs(t, err, ErrInvalidAmount)
+	})
+}
+
+func TestLineItem_ExtendedAmount(t *testing.T) {
+	legacy := LineItem{Description: "Legacy", Amount: 700}
+	assert.Equal(t, int64(700), legacy.ExtendedAmount())
+
+	priced := LineItem{Description: "Storage", Amount: 38, Quantity: 1500000, UnitPrice: 25}
+	assert.Equal(t, int64(38), priced.ExtendedAmount())
+}
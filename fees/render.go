@@ -0,0 +1,364 @@
+package fees
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// InvoiceBrandingFileEnv points at a JSON file with the InvoiceBranding to
+// render invoices with. Unset means DefaultInvoiceBranding.
+const InvoiceBrandingFileEnv = "FEES_INVOICE_BRANDING_FILE"
+
+var (
+	ErrInvalidBranding      = errors.New("invalid invoice branding")
+	ErrInvalidInvoiceFormat = errors.New("invalid invoice format")
+)
+
+type InvoiceFormat string
+
+const (
+	InvoiceFormatHTML InvoiceFormat = "html"
+	InvoiceFormatPDF  InvoiceFormat = "pdf"
+)
+
+// InvoiceBranding is what rendered invoices show of the issuing company.
+// HTMLTemplate optionally replaces the built-in HTML layout with an
+// html/template file; a relative path is read from the branding file's
+// directory. Templates render an invoiceView.
+type InvoiceBranding struct {
+	CompanyName  string   `json:"companyName"`
+	AddressLines []string `json:"addressLines,omitempty"`
+	Email        string   `json:"email,omitempty"`
+	Footer       string   `json:"footer,omitempty"`
+	AccentColor  string   `json:"accentColor,omitempty"`
+	HTMLTemplate string   `json:"htmlTemplate,omitempty"`
+}
+
+var DefaultInvoiceBranding = InvoiceBranding{
+	CompanyName: "PAVE",
+	Footer:      "Thank you for your business.",
+	AccentColor: "#1f4e79",
+}
+
+//go:embed templates/invoice.html
+var defaultInvoiceHTML string
+
+var defaultInvoiceTemplate = template.Must(template.New("invoice").Parse(defaultInvoiceHTML))
+
+// InvoiceRenderer turns invoices into HTML pages and PDF documents.
+type InvoiceRenderer struct {
+	branding InvoiceBranding
+	accent   pdfColor
+	html     *template.Template
+}
+
+// NewInvoiceRenderer checks the branding and loads its template, if it has
+// one.
+func NewInvoiceRenderer(branding InvoiceBranding) (*InvoiceRenderer, error) {
+	if strings.TrimSpace(branding.CompanyName) == "" {
+		return nil, fmt.Errorf("%w: companyName cannot be empty", ErrInvalidBranding)
+	}
+	if branding.AccentColor == "" {
+		branding.AccentColor = DefaultInvoiceBranding.AccentColor
+	}
+	accent, err := parseHexColor(branding.AccentColor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBranding, err)
+	}
+
+	html := defaultInvoiceTemplate
+	if branding.HTMLTemplate != "" {
+		html, err = template.ParseFiles(branding.HTMLTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBranding, err)
+		}
+	}
+	return &InvoiceRenderer{branding: branding, accent: accent, html: html}, nil
+}
+
+// LoadInvoiceRenderer reads the branding from a JSON file.
+func LoadInvoiceRenderer(path string) (*InvoiceRenderer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read invoice branding: %w", err)
+	}
+	var branding InvoiceBranding
+	if err := json.Unmarshal(data, &branding); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBranding, err)
+	}
+	if branding.HTMLTemplate != "" && !filepath.IsAbs(branding.HTMLTemplate) {
+		branding.HTMLTemplate = filepath.Join(filepath.Dir(path), branding.HTMLTemplate)
+	}
+	return NewInvoiceRenderer(branding)
+}
+
+func defaultInvoiceRenderer() *InvoiceRenderer {
+	accent, _ := parseHexColor(DefaultInvoiceBranding.AccentColor)
+	return &InvoiceRenderer{branding: DefaultInvoiceBranding, accent: accent, html: defaultInvoiceTemplate}
+}
+
+// RenderedInvoice is a rendered invoice ready to be served.
+type RenderedInvoice struct {
+	ContentType string
+	Filename    string
+	Body        []byte
+}
+
+// Render renders invoice in format. Drafts are marked as such throughout and
+// carry no invoice number.
+func (r *InvoiceRenderer) Render(invoice *Invoice, draft bool, format InvoiceFormat) (*RenderedInvoice, error) {
+	view := newInvoiceView(invoice, r.branding, draft)
+
+	name := invoice.Number
+	if name == "" {
+		name = invoice.BillID
+	}
+	if draft {
+		name += "-draft"
+	}
+
+	switch format {
+	case InvoiceFormatHTML:
+		var buf bytes.Buffer
+		if err := r.html.Execute(&buf, view); err != nil {
+			return nil, fmt.Errorf("failed to render invoice: %w", err)
+		}
+		return &RenderedInvoice{ContentType: "text/html; charset=utf-8", Filename: name + ".html", Body: buf.Bytes()}, nil
+	case InvoiceFormatPDF:
+		return &RenderedInvoice{ContentType: "application/pdf", Filename: name + ".pdf", Body: r.renderPDF(view)}, nil
+	default:
+		return nil, fmt.Errorf("%w: %q. Supported formats: html, pdf", ErrInvalidInvoiceFormat, format)
+	}
+}
+
+// invoiceView is an invoice with every amount and date formatted for display.
+type invoiceView struct {
+	Branding InvoiceBranding
+	Draft    bool
+	Title    string
+	Note     string
+	Currency Currency
+	Details  []invoiceDetail
+	Lines    []invoiceLine
+	Totals   []invoiceTotal
+}
+
+type invoiceDetail struct {
+	Label string
+	Value string
+}
+
+type invoiceLine struct {
+	Description string
+	Quantity    string
+	UnitPrice   string
+	Amount      string
+}
+
+type invoiceTotal struct {
+	Label  string
+	Amount string
+	Grand  bool
+}
+
+const invoiceDateLayout = "2 Jan 2006"
+
+func newInvoiceView(invoice *Invoice, branding InvoiceBranding, draft bool) *invoiceView {
+	currency := invoice.Currency
+	view := &invoiceView{Branding: branding, Draft: draft, Currency: currency}
+
+	if draft {
+		view.Title = fmt.Sprintf("Draft invoice for %s", invoice.BillID)
+		view.Note = "Draft: this bill is still open. It is not an invoice and amounts may change when it closes."
+	} else if invoice.Number != "" {
+		view.Title = "Invoice " + invoice.Number
+		view.Details = append(view.Details, invoiceDetail{"Invoice number", invoice.Number})
+	} else {
+		view.Title = "Invoice for " + invoice.BillID
+	}
+	view.Details = append(view.Details,
+		invoiceDetail{"Date", invoice.IssuedAt.Format(invoiceDateLayout)},
+		invoiceDetail{"Bill", invoice.BillID},
+		invoiceDetail{"Customer", invoice.CustomerID},
+	)
+	if invoice.DueAt != nil {
+		view.Details = append(view.Details, invoiceDetail{"Due date", invoice.DueAt.Format(invoiceDateLayout)})
+	}
+	if invoice.PeriodStart != nil && invoice.PeriodEnd != nil {
+		view.Details = append(view.Details, invoiceDetail{"Period", fmt.Sprintf("%s - %s",
+			invoice.PeriodStart.Format(invoiceDateLayout), invoice.PeriodEnd.Format(invoiceDateLayout))})
+	}
+	if invoice.Supersedes != "" {
+		view.Details = append(view.Details, invoiceDetail{"Replaces invoice", invoice.Supersedes})
+	}
+	if invoice.Corrects != "" {
+		view.Details = append(view.Details, invoiceDetail{"Corrects invoice", invoice.Corrects})
+	}
+
+	for i := range invoice.LineItems {
+		item := &invoice.LineItems[i]
+		if item.IsVoided() {
+			continue
+		}
+		quantity, unitPrice := lineItemPricing(item)
+		view.Lines = append(view.Lines, invoiceLine{
+			Description: item.Description,
+			Quantity:    quantity.String(),
+			UnitPrice:   currency.FormatAmount(unitPrice),
+			Amount:      currency.FormatAmount(item.Amount),
+		})
+	}
+
+	format := func(amount int64) string {
+		return fmt.Sprintf("%s %s", currency, currency.FormatAmount(amount))
+	}
+	view.Totals = append(view.Totals, invoiceTotal{Label: "Subtotal", Amount: format(invoice.SubtotalAmount)})
+	for _, tax := range invoice.Taxes {
+		label := fmt.Sprintf("%s %s%%", tax.Name, formatFixedPoint(tax.RateBps, 2, false))
+		if tax.Inclusive {
+			label += " (included)"
+		}
+		view.Totals = append(view.Totals, invoiceTotal{Label: label, Amount: format(tax.Amount)})
+	}
+	view.Totals = append(view.Totals, invoiceTotal{Label: "Total", Amount: format(invoice.TotalAmount), Grand: true})
+	return view
+}
+
+// invoiceFromBill stands in for the invoice of a bill that has none: an open
+// bill, shown with what it holds so far before fees, limits, discounts and
+// tax, or one closed before invoices were issued.
+func invoiceFromBill(bill *Bill, at time.Time) *Invoice {
+	invoice := &Invoice{
+		LegalEntity:    bill.LegalEntity,
+		BillID:         bill.ID,
+		CustomerID:     bill.CustomerID,
+		Currency:       bill.Currency,
+		IssuedAt:       at,
+		PeriodStart:    bill.PeriodStart,
+		PeriodEnd:      bill.PeriodEnd,
+		DueAt:          bill.DueAt,
+		LineItems:      bill.LineItems,
+		SubtotalAmount: bill.SubtotalAmount,
+		TaxAmount:      bill.TaxAmount,
+		TotalAmount:    bill.TotalAmount,
+		Taxes:          bill.Taxes,
+		Discounts:      bill.Discounts,
+		FeeScheduleID:  bill.FeeScheduleID,
+	}
+	if bill.Status == BillStatusOpen {
+		invoice.SubtotalAmount = bill.CalculateTotal()
+		invoice.TaxAmount = 0
+		invoice.TotalAmount = invoice.SubtotalAmount
+		invoice.Taxes = nil
+	}
+	return invoice
+}
+
+// PDF layout in points. The quantity and unit price columns are aligned to
+// their right edge; pages break once the next row would go below pdfBottom.
+const (
+	pdfMargin       = 50.0
+	pdfRight        = pdfPageWidth - pdfMargin
+	pdfQuantityEdge = 370.0
+	pdfUnitEdge     = 460.0
+	pdfBottom       = 90.0
+)
+
+var pdfDraftRed = pdfColor{0.75, 0.16, 0.16}
+
+func (r *InvoiceRenderer) renderPDF(view *invoiceView) []byte {
+	doc := newPDFDocument(view.Title)
+	var page *pdfPage
+	var y float64
+
+	newPage := func() {
+		page = doc.addPage()
+		if view.Draft {
+			page.rotatedText(150, 250, pdfBold, 110, pdfColor{0.92, 0.92, 0.92}, "DRAFT")
+		}
+		y = pdfPageHeight - 60
+	}
+	tableHeader := func() {
+		page.fillRect(pdfMargin, y-6, pdfRight-pdfMargin, 20, r.accent)
+		page.text(pdfMargin+6, y, pdfBold, 9, pdfWhite, "Description")
+		page.textRight(pdfQuantityEdge, y, pdfBold, 9, pdfWhite, "Quantity")
+		page.textRight(pdfUnitEdge, y, pdfBold, 9, pdfWhite, "Unit price")
+		page.textRight(pdfRight-6, y, pdfBold, 9, pdfWhite, fmt.Sprintf("Amount (%s)", view.Currency))
+		y -= 22
+	}
+
+	newPage()
+	heading := "INVOICE"
+	if view.Draft {
+		heading = "DRAFT"
+	}
+	page.text(pdfMargin, y, pdfBold, 20, r.accent, view.Branding.CompanyName)
+	page.textRight(pdfRight, y, pdfBold, 20, pdfBlack, heading)
+	y -= 18
+	contact := append([]string(nil), view.Branding.AddressLines...)
+	if view.Branding.Email != "" {
+		contact = append(contact, view.Branding.Email)
+	}
+	for _, line := range contact {
+		page.text(pdfMargin, y, pdfRegular, 9, pdfGrey, line)
+		y -= 12
+	}
+	page.line(pdfMargin, y, pdfRight, y, r.accent)
+	y -= 20
+
+	if view.Note != "" {
+		page.text(pdfMargin, y, pdfBold, 9, pdfDraftRed, pdfTruncate(view.Note, pdfBold, 9, pdfRight-pdfMargin))
+		y -= 18
+	}
+	for _, detail := range view.Details {
+		page.text(pdfMargin, y, pdfBold, 9, pdfBlack, detail.Label)
+		page.text(pdfMargin+90, y, pdfRegular, 9, pdfBlack, detail.Value)
+		y -= 13
+	}
+	y -= 14
+
+	tableHeader()
+	for _, line := range view.Lines {
+		if y < pdfBottom {
+			newPage()
+			tableHeader()
+		}
+		page.text(pdfMargin+6, y, pdfRegular, 9, pdfBlack, pdfTruncate(line.Description, pdfRegular, 9, 230))
+		page.textRight(pdfQuantityEdge, y, pdfRegular, 9, pdfBlack, line.Quantity)
+		page.textRight(pdfUnitEdge, y, pdfRegular, 9, pdfBlack, line.UnitPrice)
+		page.textRight(pdfRight-6, y, pdfRegular, 9, pdfBlack, line.Amount)
+		y -= 16
+	}
+
+	if y-float64(len(view.Totals))*16 < pdfBottom {
+		newPage()
+	}
+	page.line(pdfMargin, y+8, pdfRight, y+8, pdfGrey)
+	y -= 6
+	for _, total := range view.Totals {
+		font, size := pdfRegular, 9.0
+		if total.Grand {
+			font, size = pdfBold, 11
+			y -= 4
+		}
+		page.textRight(pdfUnitEdge, y, font, size, pdfBlack, total.Label)
+		page.textRight(pdfRight-6, y, font, size, pdfBlack, total.Amount)
+		y -= 16
+	}
+
+	for i, p := range doc.pages {
+		if view.Branding.Footer != "" {
+			p.text(pdfMargin, 40, pdfRegular, 8, pdfGrey, view.Branding.Footer)
+		}
+		p.textRight(pdfRight, 40, pdfRegular, 8, pdfGrey, fmt.Sprintf("Page %d of %d", i+1, len(doc.pages)))
+	}
+	return doc.Bytes()
+}
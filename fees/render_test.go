@@ -0,0 +1,176 @@
+package fees
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testInvoice() *Invoice {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+	voidedAt := end
+	return &Invoice{
+		Number:      "PAVE-000042",
+		LegalEntity: "PAVE",
+		Sequence:    42,
+		BillID:      "bill-1",
+		CustomerID:  "customer-1",
+		Currency:    GEL,
+		IssuedAt:    end,
+		PeriodStart: &start,
+		PeriodEnd:   &end,
+		LineItems: []LineItem{
+			{ID: "item-1", Description: "API calls <v2>", Amount: 150000, Quantity: 3 * QuantityScale, UnitPrice: 50000},
+			{ID: "item-2", Description: "Mistake", Amount: 999, VoidedAt: &voidedAt},
+			{Description: "Discount SPRING10 (10%)", Amount: -15000, Quantity: QuantityOne, UnitPrice: -15000,
+				Kind: LineItemKindDiscount, SystemGenerated: true},
+		},
+		SubtotalAmount: 135000,
+		TaxAmount:      24300,
+		TotalAmount:    159300,
+		Taxes:          []TaxLine{{RuleID: "ge-vat", Name: "VAT", RateBps: 1800, TaxableAmount: 135000, Amount: 24300}},
+	}
+}
+
+func TestInvoiceRenderer_HTML(t *testing.T) {
+	renderer, err := NewInvoiceRenderer(InvoiceBranding{
+		CompanyName:  "Pave Georgia",
+		AddressLines: []string{"1 Rustaveli Ave", "Tbilisi"},
+		AccentColor:  "#aa0000",
+	})
+	require.NoError(t, err)
+
+	rendered, err := renderer.Render(testInvoice(), false, InvoiceFormatHTML)
+	require.NoError(t, err)
+
+	body := string(rendered.Body)
+	assert.Equal(t, "text/html; charset=utf-8", rendered.ContentType)
+	assert.Equal(t, "PAVE-000042.html", rendered.Filename)
+	assert.Contains(t, body, "Pave Georgia")
+	assert.Contains(t, body, "Tbilisi")
+	assert.Contains(t, body, "#aa0000")
+	assert.Contains(t, body, "PAVE-000042")
+	assert.Contains(t, body, "1 Mar 2024 - 31 Mar 2024")
+	assert.Contains(t, body, "API calls &lt;v2&gt;")
+	assert.Contains(t, body, "1500.00")
+	assert.Contains(t, body, "-150.00")
+	assert.Contains(t, body, "VAT 18%")
+	assert.Contains(t, body, "GEL 1593.00")
+	assert.NotContains(t, body, "Mistake")
+	assert.NotContains(t, body, "DRAFT")
+}
+
+func TestInvoiceRenderer_Draft(t *testing.T) {
+	renderer := defaultInvoiceRenderer()
+	bill := &Bill{
+		ID:         "bill-open",
+		CustomerID: "customer-1",
+		Currency:   "JPY",
+		Status:     BillStatusOpen,
+		LineItems:  []LineItem{{ID: "item-1", Description: "Usage", Amount: 500}},
+	}
+
+	invoice := invoiceFromBill(bill, time.Now())
+	assert.Equal(t, int64(500), invoice.TotalAmount)
+
+	rendered, err := renderer.Render(invoice, true, InvoiceFormatHTML)
+	require.NoError(t, err)
+	assert.Equal(t, "bill-open-draft.html", rendered.Filename)
+	assert.Contains(t, string(rendered.Body), "DRAFT")
+	assert.Contains(t, string(rendered.Body), "not an invoice")
+	assert.Contains(t, string(rendered.Body), "JPY 500")
+	assert.NotContains(t, string(rendered.Body), "Invoice number")
+
+	rendered, err = renderer.Render(invoice, true, InvoiceFormatPDF)
+	require.NoError(t, err)
+	assert.Equal(t, "bill-open-draft.pdf", rendered.Filename)
+	assert.Contains(t, string(rendered.Body), "(DRAFT)")
+}
+
+func TestInvoiceRenderer_Corrections(t *testing.T) {
+	renderer := defaultInvoiceRenderer()
+	invoice := testInvoice()
+	invoice.Supersedes = "PAVE-000041"
+	invoice.Corrects = "PAVE-000040"
+
+	rendered, err := renderer.Render(invoice, false, InvoiceFormatHTML)
+	require.NoError(t, err)
+	assert.Contains(t, string(rendered.Body), "Replaces invoice")
+	assert.Contains(t, string(rendered.Body), "PAVE-000041")
+	assert.Contains(t, string(rendered.Body), "Corrects invoice")
+	assert.Contains(t, string(rendered.Body), "PAVE-000040")
+
+	rendered, err = renderer.Render(testInvoice(), false, InvoiceFormatHTML)
+	require.NoError(t, err)
+	assert.NotContains(t, string(rendered.Body), "Replaces invoice")
+	assert.NotContains(t, string(rendered.Body), "Corrects invoice")
+}
+
+func TestInvoiceRenderer_PDF(t *testing.T) {
+	renderer := defaultInvoiceRenderer()
+
+	rendered, err := renderer.Render(testInvoice(), false, InvoiceFormatPDF)
+	require.NoError(t, err)
+
+	body := string(rendered.Body)
+	assert.Equal(t, "application/pdf", rendered.ContentType)
+	assert.Equal(t, "PAVE-000042.pdf", rendered.Filename)
+	assert.True(t, strings.HasPrefix(body, "%PDF-1.4"))
+	assert.Contains(t, body, "(PAVE-000042)")
+	assert.Contains(t, body, "(1500.00)")
+	assert.Contains(t, body, "(GEL 1593.00)")
+	assert.Contains(t, body, "(Page 1 of 1)")
+	assert.NotContains(t, body, "(DRAFT)")
+	assert.NotContains(t, body, "Mistake")
+
+	t.Run("long bills run onto more pages", func(t *testing.T) {
+		invoice := testInvoice()
+		for i := 0; i < 80; i++ {
+			invoice.LineItems = append(invoice.LineItems, LineItem{Description: "Usage", Amount: 100})
+		}
+
+		rendered, err := renderer.Render(invoice, false, InvoiceFormatPDF)
+		require.NoError(t, err)
+		assert.Contains(t, string(rendered.Body), "(Page 3 of 3)")
+	})
+}
+
+func TestInvoiceRenderer_UnknownFormat(t *testing.T) {
+	_, err := defaultInvoiceRenderer().Render(testInvoice(), false, "docx")
+	assert.ErrorIs(t, err, ErrInvalidInvoiceFormat)
+}
+
+func TestNewInvoiceRenderer_Validation(t *testing.T) {
+	_, err := NewInvoiceRenderer(InvoiceBranding{})
+	assert.ErrorIs(t, err, ErrInvalidBranding)
+
+	_, err = NewInvoiceRenderer(InvoiceBranding{CompanyName: "Pave", AccentColor: "blue"})
+	assert.ErrorIs(t, err, ErrInvalidBranding)
+}
+
+func TestLoadInvoiceRenderer(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "invoice.html"),
+		[]byte(`<h1>{{.Branding.CompanyName}}</h1>{{range .Totals}}{{.Label}}={{.Amount}};{{end}}`), 0o600))
+	path := filepath.Join(dir, "branding.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"companyName": "Acme", "htmlTemplate": "invoice.html"}`), 0o600))
+
+	renderer, err := LoadInvoiceRenderer(path)
+	require.NoError(t, err)
+
+	rendered, err := renderer.Render(testInvoice(), false, InvoiceFormatHTML)
+	require.NoError(t, err)
+	assert.Equal(t, "<h1>Acme</h1>Subtotal=GEL 1350.00;VAT 18%=GEL 243.00;Total=GEL 1593.00;", string(rendered.Body))
+
+	t.Run("missing template", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, []byte(`{"companyName": "Acme", "htmlTemplate": "missing.html"}`), 0o600))
+		_, err := LoadInvoiceRenderer(path)
+		assert.ErrorIs(t, err, ErrInvalidBranding)
+	})
+}
@@ -3,6 +3,7 @@ package fees
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
@@ -18,24 +19,89 @@ func NewRepository(db *sqldb.Database) *Repository {
 	return &Repository{db: db}
 }
 
-func (r *Repository) CreateBill(ctx context.Context, bill *Bill) error {
-	_, err := r.db.Exec(ctx, `
-		INSERT INTO bills (id, customer_id, currency, status, total_amount, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
-	`, bill.ID, bill.CustomerID, bill.Currency, bill.Status, bill.TotalAmount, time.Now())
-	
+const billColumns = `id, customer_id, currency, status, total_amount, created_at, period_start, period_end,
+	fee_schedule_id, fee_schedule_version, allow_negative_total, COALESCE(idempotency_key, ''),
+	subtotal_amount, tax_amount, tax_lines, minimum_amount, maximum_amount, legal_entity,
+	COALESCE(invoice_number, ''), paid_amount, payment_terms_days, due_at, COALESCE(dunning_status, ''), dunning_stage,
+	voided_at, COALESCE(void_reason, ''), closed_at, COALESCE(corrects_bill_id, '')`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanBill(row rowScanner, bill *Bill) error {
+	var feeScheduleID sql.NullString
+	var taxLines []byte
+	err := row.Scan(&bill.ID, &bill.CustomerID, &bill.Currency, &bill.Status, &bill.TotalAmount, &bill.CreatedAt,
+		&bill.PeriodStart, &bill.PeriodEnd, &feeScheduleID, &bill.FeeScheduleVersion, &bill.AllowNegativeTotal,
+		&bill.IdempotencyKey, &bill.SubtotalAmount, &bill.TaxAmount, &taxLines, &bill.MinimumAmount, &bill.MaximumAmount,
+		&bill.LegalEntity, &bill.InvoiceNumber, &bill.PaidAmount, &bill.PaymentTermsDays, &bill.DueAt,
+		&bill.DunningStatus, &bill.DunningStage, &bill.VoidedAt, &bill.VoidReason, &bill.ClosedAt, &bill.CorrectsBillID)
 	if err != nil {
-		return fmt.Errorf("failed to create bill: %w", err)
+		return err
+	}
+	bill.settle()
+	bill.FeeScheduleID = feeScheduleID.String
+	if len(taxLines) > 0 {
+		if err := json.Unmarshal(taxLines, &bill.Taxes); err != nil {
+			return fmt.Errorf("failed to decode tax lines: %w", err)
+		}
 	}
 	return nil
 }
 
+func (r *Repository) CreateBill(ctx context.Context, bill *Bill) (*OutboxEvent, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	event, err := insertBill(ctx, tx, bill)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit bill: %w", err)
+	}
+	return event, nil
+}
+
+// insertBill stores a new bill with its discounts and the event that starts
+// its workflow.
+func insertBill(ctx context.Context, tx *sqldb.Tx, bill *Bill) (*OutboxEvent, error) {
+	result, err := tx.Exec(ctx, `
+		INSERT INTO bills (id, customer_id, currency, status, total_amount, created_at, period_start, period_end,
+			fee_schedule_id, allow_negative_total, idempotency_key, minimum_amount, maximum_amount, legal_entity,
+			payment_terms_days, corrects_bill_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), $10, NULLIF($11, ''), $12, $13, $14, $15, NULLIF($16, ''))
+		ON CONFLICT (customer_id, idempotency_key) DO NOTHING
+	`, bill.ID, bill.CustomerID, bill.Currency, bill.Status, bill.TotalAmount, time.Now(), bill.PeriodStart, bill.PeriodEnd,
+		bill.FeeScheduleID, bill.AllowNegativeTotal, bill.IdempotencyKey, bill.MinimumAmount, bill.MaximumAmount,
+		bill.LegalEntity, bill.PaymentTermsDays, bill.CorrectsBillID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bill: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return nil, errDuplicateIdempotencyKey
+	}
+
+	for i := range bill.Discounts {
+		if err := attachDiscount(ctx, tx, bill.ID, bill.CustomerID, &bill.Discounts[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return insertOutboxEvent(ctx, tx, bill.ID, OutboxStartBill, bill, nil)
+}
+
 func (r *Repository) GetBillByID(ctx context.Context, billID string) (*Bill, error) {
 	var bill Bill
-	err := r.db.QueryRow(ctx, 
-		"SELECT id, customer_id, currency, status, total_amount FROM bills WHERE id = $1", 
+	err := scanBill(r.db.QueryRow(ctx,
+		"SELECT "+billColumns+" FROM bills WHERE id = $1",
 		billID,
-	).Scan(&bill.ID, &bill.CustomerID, &bill.Currency, &bill.Status, &bill.TotalAmount)
+	), &bill)
 	
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -49,10 +115,31 @@ func (r *Repository) GetBillByID(ctx context.Context, billID string) (*Bill, err
 		return nil, fmt.Errorf("failed to get line items for bill %s: %w", billID, err)
 	}
 	bill.LineItems = lineItems
+
+	discounts, err := r.ListBillDiscounts(ctx, billID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get discounts for bill %s: %w", billID, err)
+	}
+	bill.Discounts = discounts
 	
 	return &bill, nil
 }
 
+func (r *Repository) GetBillByIdempotencyKey(ctx context.Context, customerID, key string) (*Bill, error) {
+	var billID string
+	err := r.db.QueryRow(ctx,
+		"SELECT id FROM bills WHERE customer_id = $1 AND idempotency_key = $2",
+		customerID, key,
+	).Scan(&billID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrBillNotFound
+		}
+		return nil, fmt.Errorf("failed to get bill: %w", err)
+	}
+	return r.GetBillByID(ctx, billID)
+}
+
 func (r *Repository) GetBillStatus(ctx context.Context, billID string) (BillStatus, error) {
 	var status BillStatus
 	err := r.db.QueryRow(ctx, "SELECT status FROM bills WHERE id = $1", billID).Scan(&status)
@@ -65,70 +152,540 @@ func (r *Repository) GetBillStatus(ctx context.Context, billID string) (BillStat
 	return status, nil
 }
 
-func (r *Repository) AddLineItem(ctx context.Context, billID string, item *LineItem) error {
-	_, err := r.db.Exec(ctx, `
-		INSERT INTO line_items (bill_id, description, amount, timestamp)
-		VALUES ($1, $2, $3, $4)
-	`, billID, item.Description, item.Amount, item.Timestamp)
-	
+func (r *Repository) AddLineItem(ctx context.Context, billID string, item *LineItem) (*OutboxEvent, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	event, err := insertLineItem(ctx, tx, billID, item)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit line item: %w", err)
+	}
+	return event, nil
+}
+
+// insertLineItem adds item to the bill in tx along with the outbox event that
+// delivers it to the bill workflow.
+func insertLineItem(ctx context.Context, tx *sqldb.Tx, billID string, item *LineItem) (*OutboxEvent, error) {
+	quantity, unitPrice := lineItemPricing(item)
+	var lineItemID int64
+	args := []interface{}{item.ID, billID, item.Description, item.Amount, quantity, unitPrice, item.Timestamp,
+		lineItemKind(item), item.ReasonCode, item.IdempotencyKey}
+	args = append(args, lineItemFXValues(item)...)
+	err := tx.QueryRow(ctx, `
+		INSERT INTO line_items (public_id, bill_id, description, amount, quantity, unit_price, timestamp, kind, reason_code,
+			idempotency_key, original_currency, original_amount, original_quantity, original_unit_price, fx_rate, fx_rate_at)
+		VALUES (NULLIF($1, ''), $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), NULLIF($10, ''), $11, $12, $13, $14, $15, $16)
+		ON CONFLICT (bill_id, idempotency_key) DO NOTHING
+		RETURNING id
+	`, args...).Scan(&lineItemID)
 	if err != nil {
-		return fmt.Errorf("failed to add line item: %w", err)
+		if err == sql.ErrNoRows {
+			return nil, errDuplicateIdempotencyKey
+		}
+		return nil, fmt.Errorf("failed to add line item: %w", err)
+	}
+
+	return insertOutboxEvent(ctx, tx, billID, OutboxAddLineItem, item, &lineItemID)
+}
+
+const lineItemColumns = `COALESCE(public_id, ''), description, amount, quantity, unit_price, timestamp, kind,
+	COALESCE(reason_code, ''), voided_at, COALESCE(void_reason, ''), COALESCE(idempotency_key, ''),
+	original_currency, original_amount, original_quantity, original_unit_price, fx_rate, fx_rate_at, system_generated`
+
+func scanLineItem(row rowScanner, item *LineItem) error {
+	var originalCurrency sql.NullString
+	var originalAmount, originalQuantity, originalUnitPrice, rate sql.NullInt64
+	var rateAt sql.NullTime
+	err := row.Scan(&item.ID, &item.Description, &item.Amount, &item.Quantity, &item.UnitPrice, &item.Timestamp,
+		&item.Kind, &item.ReasonCode, &item.VoidedAt, &item.VoidReason, &item.IdempotencyKey,
+		&originalCurrency, &originalAmount, &originalQuantity, &originalUnitPrice, &rate, &rateAt, &item.SystemGenerated)
+	if err != nil {
+		return err
+	}
+	if originalCurrency.Valid {
+		item.FX = &FXConversion{
+			Currency:  Currency(originalCurrency.String),
+			Amount:    originalAmount.Int64,
+			Quantity:  Quantity(originalQuantity.Int64),
+			UnitPrice: originalUnitPrice.Int64,
+			Rate:      Rate(rate.Int64),
+			RateAt:    rateAt.Time,
+		}
 	}
 	return nil
 }
 
+// lineItemFXValues returns the original_* and fx_* column values, all NULL for
+// items in the bill currency.
+func lineItemFXValues(item *LineItem) []interface{} {
+	if item.FX == nil {
+		return []interface{}{nil, nil, nil, nil, nil, nil}
+	}
+	fx := item.FX
+	return []interface{}{string(fx.Currency), fx.Amount, int64(fx.Quantity), fx.UnitPrice, int64(fx.Rate), fx.RateAt}
+}
+
 func (r *Repository) GetLineItemsByBillID(ctx context.Context, billID string) ([]LineItem, error) {
-	rows, err := r.db.Query(ctx, 
-		"SELECT description, amount, timestamp FROM line_items WHERE bill_id = $1 ORDER BY timestamp ASC", 
-		billID,
-	)
+	return queryLineItems(ctx, r.db, billID, false)
+}
+
+type rowsQuerier interface {
+	Query(ctx context.Context, query string, args ...interface{}) (*sqldb.Rows, error)
+}
+
+func queryLineItems(ctx context.Context, q rowsQuerier, billID string, activeOnly bool) ([]LineItem, error) {
+	query := "SELECT " + lineItemColumns + " FROM line_items WHERE bill_id = $1"
+	if activeOnly {
+		query += " AND voided_at IS NULL"
+	}
+	rows, err := q.Query(ctx, query+" ORDER BY timestamp ASC, id ASC", billID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query line items: %w", err)
 	}
 	defer rows.Close()
-	
+
 	var lineItems []LineItem
 	for rows.Next() {
 		var item LineItem
-		if err := rows.Scan(&item.Description, &item.Amount, &item.Timestamp); err != nil {
+		if err := scanLineItem(rows, &item); err != nil {
 			return nil, fmt.Errorf("failed to scan line item: %w", err)
 		}
 		lineItems = append(lineItems, item)
 	}
-	
+
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating line items: %w", err)
 	}
-	
+
 	return lineItems, nil
 }
 
-func (r *Repository) UpdateBillStatus(ctx context.Context, billID string, status BillStatus, totalAmount int64) error {
-	_, err := r.db.Exec(ctx, `
-		UPDATE bills 
-		SET status = $1, total_amount = $2 
+func (r *Repository) GetLineItemByIdempotencyKey(ctx context.Context, billID, key string) (*LineItem, error) {
+	var item LineItem
+	err := scanLineItem(r.db.QueryRow(ctx,
+		"SELECT "+lineItemColumns+" FROM line_items WHERE bill_id = $1 AND idempotency_key = $2",
+		billID, key,
+	), &item)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrLineItemNotFound
+		}
+		return nil, fmt.Errorf("failed to get line item: %w", err)
+	}
+	return &item, nil
+}
+
+// VoidLineItem marks the item voided and records the matching outbox event in
+// the same transaction. The row is kept for audit.
+func (r *Repository) VoidLineItem(ctx context.Context, billID, itemID, reason string) (*OutboxEvent, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var lineItemID int64
+	var item LineItem
+	err = tx.QueryRow(ctx, `
+		SELECT id, amount, quantity, unit_price, voided_at FROM line_items
+		WHERE bill_id = $1 AND public_id = $2
+		FOR UPDATE
+	`, billID, itemID).Scan(&lineItemID, &item.Amount, &item.Quantity, &item.UnitPrice, &item.VoidedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrLineItemNotFound
+		}
+		return nil, fmt.Errorf("failed to get line item: %w", err)
+	}
+	if item.IsVoided() {
+		return nil, ErrLineItemVoided
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE line_items SET voided_at = $1, void_reason = NULLIF($2, '')
 		WHERE id = $3
-	`, status, totalAmount, billID)
-	
+	`, time.Now(), reason, lineItemID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to void line item: %w", err)
+	}
+
+	event, err := insertOutboxEvent(ctx, tx, billID, OutboxVoidLineItem,
+		LineItemVoid{ItemID: itemID, Reason: reason, Amount: item.ExtendedAmount()}, &lineItemID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit line item void: %w", err)
+	}
+	return event, nil
+}
+
+// lineItemPricing stores items without a quantity as one unit of their amount.
+func lineItemPricing(item *LineItem) (Quantity, int64) {
+	if item.Quantity == 0 {
+		return QuantityOne, item.Amount
+	}
+	return item.Quantity, item.UnitPrice
+}
+
+func lineItemKind(item *LineItem) LineItemKind {
+	if item.Kind == "" {
+		return LineItemKindCharge
+	}
+	return item.Kind
+}
+
+// FinalizeBill stores the system-generated usage, fee, true-up, cap credit and
+// discount items, the amount each discount took off, the tax breakdown and the
+// closed totals in one transaction. It also issues the bill's invoice. A bill
+// that is already closed is left alone, so a retried close can't issue a
+// second invoice. The usage events that were priced are marked billed; any
+// that reached the bill after its usage was priced are flagged. Item events
+// not yet delivered to the workflow are settled as the close priced them.
+func (r *Repository) FinalizeBill(ctx context.Context, bill *FinalBill) error {
+	taxLines, err := json.Marshal(bill.Taxes)
+	if err != nil {
+		return fmt.Errorf("failed to encode tax lines: %w", err)
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(ctx, `
+		UPDATE bills
+		SET status = $1, total_amount = $2, fee_schedule_id = COALESCE(NULLIF($3, ''), fee_schedule_id), fee_schedule_version = $4,
+			subtotal_amount = $5, tax_amount = $6, tax_lines = $7, due_at = $8, dunning_status = $9, closed_at = $10
+		WHERE id = $11 AND status = $12
+	`, bill.Status, bill.TotalAmount, bill.FeeScheduleID, bill.FeeScheduleVersion,
+		bill.SubtotalAmount, bill.TaxAmount, string(taxLines), bill.DueAt, DunningStatusScheduled, time.Now(), bill.ID, BillStatusOpen)
 	if err != nil {
 		return fmt.Errorf("failed to update bill status: %w", err)
 	}
-	
-	var exists bool
-	err = r.db.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM bills WHERE id = $1)", billID).Scan(&exists)
+	if result.RowsAffected() == 0 {
+		err := tx.QueryRow(ctx, "SELECT COALESCE(invoice_number, '') FROM bills WHERE id = $1", bill.ID).Scan(&bill.InvoiceNumber)
+		if err == sql.ErrNoRows {
+			return ErrBillNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get bill: %w", err)
+		}
+		return nil
+	}
+
+	var generated []LineItem
+	generated = append(generated, bill.Usage...)
+	generated = append(generated, bill.Fees...)
+	generated = append(generated, bill.Adjustments...)
+	generated = append(generated, bill.Discounts...)
+	for i := range generated {
+		item := &generated[i]
+		quantity, unitPrice := lineItemPricing(item)
+		_, err := tx.Exec(ctx, `
+			INSERT INTO line_items (public_id, bill_id, description, amount, quantity, unit_price, timestamp, kind, system_generated)
+			VALUES (NULLIF($1, ''), $2, $3, $4, $5, $6, $7, $8, TRUE)
+		`, item.ID, bill.ID, item.Description, item.Amount, quantity, unitPrice, item.Timestamp, lineItemKind(item))
+		if err != nil {
+			return fmt.Errorf("failed to save %s line item: %w", strings.ToLower(string(lineItemKind(item))), err)
+		}
+	}
+
+	for _, discount := range bill.AppliedDiscounts {
+		_, err := tx.Exec(ctx, `
+			UPDATE bill_discounts SET applied_amount = $1
+			WHERE bill_id = $2 AND code = $3
+		`, discount.AppliedAmount, bill.ID, discount.Code)
+		if err != nil {
+			return fmt.Errorf("failed to record applied discount %s: %w", discount.Code, err)
+		}
+	}
+
+	// Closes that priced usage before events were claimed go by sequence
+	// number instead.
+	priced := "priced_at IS NOT NULL"
+	if !bill.UsageClaimed {
+		priced = fmt.Sprintf("seq <= %d", bill.UsageThrough)
+	}
+	_, err = tx.Exec(ctx, `
+		UPDATE usage_events
+		SET status = CASE WHEN `+priced+` THEN $1 ELSE $2 END,
+			flag_reason = CASE WHEN `+priced+` THEN NULL ELSE $3 END
+		WHERE bill_id = $4 AND status = $5
+	`, UsageEventStatusBilled, UsageEventStatusFlagged, usageClosingFlagReason, bill.ID, UsageEventStatusPending)
 	if err != nil {
-		return fmt.Errorf("failed to verify bill exists: %w", err)
+		return fmt.Errorf("failed to mark usage events billed: %w", err)
 	}
-	
-	if !exists {
-		return ErrBillNotFound
+
+	if err := settleBilledOutboxEvents(ctx, tx, bill); err != nil {
+		return err
+	}
+
+	if err := issueInvoice(ctx, tx, bill); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit final bill: %w", err)
+	}
+	return nil
+}
+
+// settleBilledOutboxEvents marks done the item and void events still waiting
+// for delivery that the close already accounted for: an item it billed, or a
+// void of one it left out. The closed workflow would reject them, and the
+// relay would then undo what the bill was priced on. Events the close didn't
+// see are left for the relay to fail and undo.
+func settleBilledOutboxEvents(ctx context.Context, tx *sqldb.Tx, bill *FinalBill) error {
+	billed := []string{}
+	for _, item := range bill.LineItems {
+		if item.ID != "" {
+			billed = append(billed, item.ID)
+		}
+	}
+	billedJSON, err := json.Marshal(billed)
+	if err != nil {
+		return fmt.Errorf("failed to encode billed items: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE outbox o
+		SET status = $1, processed_at = $2
+		FROM line_items li
+		WHERE o.line_item_id = li.id AND o.bill_id = $3 AND o.status = $4
+			AND (o.kind = $5) = COALESCE(li.public_id IN (SELECT jsonb_array_elements_text($6::jsonb)), FALSE)
+			AND o.kind IN ($5, $7)
+	`, OutboxStatusDone, time.Now(), bill.ID, OutboxStatusPending, OutboxAddLineItem, string(billedJSON), OutboxVoidLineItem)
+	if err != nil {
+		return fmt.Errorf("failed to settle billed outbox events: %w", err)
+	}
+	return nil
+}
+
+// VoidBill marks an open bill voided. Usage events still waiting for the
+// bill are flagged, as they will never be billed on it. Voiding a bill that
+// is already voided does nothing, so the activity can be retried.
+func (r *Repository) VoidBill(ctx context.Context, billID, reason string, voidedAt time.Time) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(ctx, `
+		UPDATE bills SET status = $1, voided_at = $2, void_reason = $3
+		WHERE id = $4 AND status = $5
+	`, BillStatusVoided, voidedAt, reason, billID, BillStatusOpen)
+	if err != nil {
+		return fmt.Errorf("failed to void bill: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		var status BillStatus
+		err := tx.QueryRow(ctx, "SELECT status FROM bills WHERE id = $1", billID).Scan(&status)
+		if err == sql.ErrNoRows {
+			return ErrBillNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get bill: %w", err)
+		}
+		if status != BillStatusVoided {
+			return ErrBillAlreadyClosed
+		}
+		return nil
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE usage_events SET status = $1, flag_reason = $2
+		WHERE bill_id = $3 AND status = $4
+	`, UsageEventStatusFlagged, "bill was voided", billID, UsageEventStatusPending)
+	if err != nil {
+		return fmt.Errorf("failed to flag usage events: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit bill void: %w", err)
+	}
+	return nil
+}
+
+// ReopenBill puts a closed bill back to OPEN and records the correction. The
+// items the bill added itself at close are voided and its usage, billed or
+// flagged for arriving while it closed, goes back to pending, so it is all
+// priced when the bill next closes; the invoice it was issued stays, and is
+// superseded then. The returned event starts the bill's new workflow run. The
+// bill is checked again under lock against closedAfter, as a payment may
+// have landed since it was read.
+func (r *Repository) ReopenBill(ctx context.Context, billID string, closedAfter time.Time, correction *BillCorrection) (*OutboxEvent, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	bill, err := lockBill(ctx, tx, billID)
+	if err != nil {
+		return nil, err
+	}
+	if err := bill.CheckReopen(closedAfter); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE bills
+		SET status = $1, total_amount = 0, subtotal_amount = 0, tax_amount = 0, tax_lines = NULL,
+			fee_schedule_version = NULL, due_at = NULL, dunning_status = NULL, dunning_stage = 0,
+			invoice_number = NULL, closed_at = NULL
+		WHERE id = $2
+	`, BillStatusOpen, billID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reopen bill: %w", err)
+	}
+	_, err = tx.Exec(ctx, `
+		UPDATE line_items SET voided_at = $1, void_reason = $2
+		WHERE bill_id = $3 AND system_generated AND voided_at IS NULL
+	`, time.Now(), reopenVoidReason, billID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to void generated line items: %w", err)
+	}
+	if _, err := tx.Exec(ctx, "UPDATE bill_discounts SET applied_amount = NULL WHERE bill_id = $1", billID); err != nil {
+		return nil, fmt.Errorf("failed to reset applied discounts: %w", err)
+	}
+	_, err = tx.Exec(ctx, `
+		UPDATE usage_events SET status = $1, priced_at = NULL, flag_reason = NULL
+		WHERE bill_id = $2 AND (status = $3 OR (status = $4 AND flag_reason = $5))
+	`, UsageEventStatusPending, billID, UsageEventStatusBilled, UsageEventStatusFlagged, usageClosingFlagReason)
+	if err != nil {
+		return nil, fmt.Errorf("failed to return usage events to pending: %w", err)
+	}
+
+	if err := insertBillCorrection(ctx, tx, correction); err != nil {
+		return nil, err
+	}
+
+	items, err := queryLineItems(ctx, tx, billID, true)
+	if err != nil {
+		return nil, err
+	}
+	bill.Status = BillStatusOpen
+	bill.TotalAmount, bill.SubtotalAmount, bill.TaxAmount, bill.Taxes, bill.FeeScheduleVersion = 0, 0, 0, nil, nil
+	bill.InvoiceNumber, bill.DueAt, bill.ClosedAt, bill.DunningStatus, bill.DunningStage = "", nil, nil, "", 0
+	bill.settle()
+	reopen := BillReopen{Bill: *bill, Progress: reopenProgress(items)}
+	event, err := insertOutboxEvent(ctx, tx, billID, OutboxReopenBill, reopen, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit bill reopen: %w", err)
+	}
+	return event, nil
+}
+
+// CreateAdjustmentBill stores an adjustment bill and the correction it makes
+// to the closed bill it names, which must still be closed.
+func (r *Repository) CreateAdjustmentBill(ctx context.Context, bill *Bill, correction *BillCorrection) (*OutboxEvent, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	original, err := lockBill(ctx, tx, bill.CorrectsBillID)
+	if err != nil {
+		return nil, err
+	}
+	if original.Status == BillStatusVoided {
+		return nil, ErrBillVoided
+	}
+	if original.Status != BillStatusClosed {
+		return nil, ErrBillNotClosed
+	}
+
+	event, err := insertBill(ctx, tx, bill)
+	if err != nil {
+		return nil, err
+	}
+	if err := insertBillCorrection(ctx, tx, correction); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit adjustment bill: %w", err)
+	}
+	return event, nil
+}
+
+// lockBill reads a bill's own columns, holding its row until the
+// transaction ends.
+func lockBill(ctx context.Context, tx *sqldb.Tx, billID string) (*Bill, error) {
+	var bill Bill
+	err := scanBill(tx.QueryRow(ctx, "SELECT "+billColumns+" FROM bills WHERE id = $1 FOR UPDATE", billID), &bill)
+	if err == sql.ErrNoRows {
+		return nil, ErrBillNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bill: %w", err)
+	}
+	return &bill, nil
+}
+
+func insertBillCorrection(ctx context.Context, tx *sqldb.Tx, correction *BillCorrection) error {
+	err := tx.QueryRow(ctx, `
+		INSERT INTO bill_corrections (bill_id, action, operator_id, reason, previous_invoice_number,
+			previous_total_amount, adjustment_bill_id, created_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, NULLIF($7, ''), $8)
+		RETURNING id
+	`, correction.BillID, correction.Action, correction.OperatorID, correction.Reason, correction.PreviousInvoiceNumber,
+		correction.PreviousTotalAmount, correction.AdjustmentBillID, correction.CreatedAt).Scan(&correction.ID)
+	if err != nil {
+		return fmt.Errorf("failed to record bill correction: %w", err)
 	}
-	
 	return nil
 }
 
-func (r *Repository) listBills(ctx context.Context, customerID *string, status *BillStatus, limit, offset int, includeLineItems bool) ([]*Bill, error) {
-	query := `SELECT id, customer_id, currency, status, total_amount FROM bills`
+// ListBillCorrections returns the corrections made to a bill, and the one
+// that created it if it is an adjustment bill, oldest first.
+func (r *Repository) ListBillCorrections(ctx context.Context, billID string) ([]*BillCorrection, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, bill_id, action, operator_id, reason, COALESCE(previous_invoice_number, ''),
+			previous_total_amount, COALESCE(adjustment_bill_id, ''), created_at
+		FROM bill_corrections
+		WHERE bill_id = $1 OR adjustment_bill_id = $1
+		ORDER BY created_at ASC, id ASC
+	`, billID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list bill corrections: %w", err)
+	}
+	defer rows.Close()
+
+	var corrections []*BillCorrection
+	for rows.Next() {
+		var c BillCorrection
+		err := rows.Scan(&c.ID, &c.BillID, &c.Action, &c.OperatorID, &c.Reason, &c.PreviousInvoiceNumber,
+			&c.PreviousTotalAmount, &c.AdjustmentBillID, &c.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan bill correction: %w", err)
+		}
+		corrections = append(corrections, &c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating bill corrections: %w", err)
+	}
+	return corrections, nil
+}
+
+func (r *Repository) listBills(ctx context.Context, customerID *string, status *BillStatus, includeVoided bool, limit, offset int, includeLineItems bool) ([]*Bill, error) {
+	query := `SELECT ` + billColumns + ` FROM bills`
 	var args []interface{}
 	var conditions []string
 	
@@ -141,6 +698,10 @@ func (r *Repository) listBills(ctx context.Context, customerID *string, status *
 		placeholder := fmt.Sprintf("$%d", len(args)+1)
 		conditions = append(conditions, fmt.Sprintf("status = %s", placeholder))
 		args = append(args, *status)
+	} else if !includeVoided {
+		placeholder := fmt.Sprintf("$%d", len(args)+1)
+		conditions = append(conditions, fmt.Sprintf("status <> %s", placeholder))
+		args = append(args, BillStatusVoided)
 	}
 	
 	if len(conditions) > 0 {
@@ -159,7 +720,7 @@ func (r *Repository) listBills(ctx context.Context, customerID *string, status *
 	var bills []*Bill
 	for rows.Next() {
 		var bill Bill
-		if err := rows.Scan(&bill.ID, &bill.CustomerID, &bill.Currency, &bill.Status, &bill.TotalAmount); err != nil {
+		if err := scanBill(rows, &bill); err != nil {
 			return nil, fmt.Errorf("failed to scan bill: %w", err)
 		}
 		
@@ -183,10 +744,1001 @@ func (r *Repository) listBills(ctx context.Context, customerID *string, status *
 	return bills, nil
 }
 
-func (r *Repository) ListBillsByCustomer(ctx context.Context, customerID string, status *BillStatus, limit, offset int) ([]*Bill, error) {
-	return r.listBills(ctx, &customerID, status, limit, offset, false)
+// ListBillsByCustomer leaves voided bills out unless includeVoided is set or
+// status asks for them.
+func (r *Repository) ListBillsByCustomer(ctx context.Context, customerID string, status *BillStatus, includeVoided bool, limit, offset int) ([]*Bill, error) {
+	return r.listBills(ctx, &customerID, status, includeVoided, limit, offset, false)
+}
+
+func (r *Repository) ListAllBills(ctx context.Context, status *BillStatus, includeVoided bool, limit, offset int) ([]*Bill, error) {
+	return r.listBills(ctx, nil, status, includeVoided, limit, offset, false)
+}
+
+func insertOutboxEvent(ctx context.Context, tx *sqldb.Tx, billID string, kind OutboxEventKind, payload interface{}, lineItemID *int64) (*OutboxEvent, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode outbox payload: %w", err)
+	}
+
+	event := &OutboxEvent{
+		BillID:     billID,
+		Kind:       kind,
+		Payload:    data,
+		LineItemID: lineItemID,
+		Status:     OutboxStatusPending,
+		CreatedAt:  time.Now(),
+	}
+	err = tx.QueryRow(ctx, `
+		INSERT INTO outbox (bill_id, kind, payload, line_item_id, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, event.BillID, event.Kind, string(event.Payload), event.LineItemID, event.Status, event.CreatedAt).Scan(&event.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write outbox event: %w", err)
+	}
+	return event, nil
+}
+
+func (r *Repository) ListPendingOutboxEvents(ctx context.Context, limit int) ([]*OutboxEvent, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, bill_id, kind, payload, line_item_id, status, attempts, created_at
+		FROM outbox
+		WHERE status = $1
+		ORDER BY id ASC
+		LIMIT $2
+	`, OutboxStatusPending, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query outbox: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*OutboxEvent
+	for rows.Next() {
+		var event OutboxEvent
+		var payload []byte
+		if err := rows.Scan(&event.ID, &event.BillID, &event.Kind, &payload, &event.LineItemID, &event.Status, &event.Attempts, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox event: %w", err)
+		}
+		event.Payload = payload
+		events = append(events, &event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating outbox events: %w", err)
+	}
+
+	return events, nil
+}
+
+func (r *Repository) MarkOutboxEventDone(ctx context.Context, eventID int64) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE outbox
+		SET status = $1, attempts = attempts + 1, last_error = NULL, processed_at = $2
+		WHERE id = $3
+	`, OutboxStatusDone, time.Now(), eventID)
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox event done: %w", err)
+	}
+	return nil
 }
 
-func (r *Repository) ListAllBills(ctx context.Context, status *BillStatus, limit, offset int) ([]*Bill, error) {
-	return r.listBills(ctx, nil, status, limit, offset, false)
-}
\ No newline at end of file
+func (r *Repository) MarkOutboxEventRetry(ctx context.Context, eventID int64, lastError string) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE outbox
+		SET attempts = attempts + 1, last_error = $1
+		WHERE id = $2
+	`, lastError, eventID)
+	if err != nil {
+		return fmt.Errorf("failed to record outbox attempt: %w", err)
+	}
+	return nil
+}
+
+// MarkOutboxEventFailed gives up on an event. A line item that never reached
+// its workflow is voided with the error as its reason, so the table keeps
+// matching the workflow and a queued item shows why it was left off. Its
+// idempotency key is released so the caller can send it again. An event
+// the bill's close has settled in the meantime is left as it is.
+func (r *Repository) MarkOutboxEventFailed(ctx context.Context, eventID int64, lastError string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var kind OutboxEventKind
+	var lineItemID *int64
+	err = tx.QueryRow(ctx, `
+		UPDATE outbox
+		SET status = $1, attempts = attempts + 1, last_error = $2, processed_at = $3
+		WHERE id = $4 AND status = $5
+		RETURNING kind, line_item_id
+	`, OutboxStatusFailed, lastError, time.Now(), eventID, OutboxStatusPending).Scan(&kind, &lineItemID)
+	if err == sql.ErrNoRows {
+		// Settled by the bill's close in the meantime.
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox event failed: %w", err)
+	}
+
+	// Undo the row change the workflow never saw.
+	if lineItemID != nil {
+		switch kind {
+		case OutboxAddLineItem:
+			_, err := tx.Exec(ctx, `
+				UPDATE line_items SET voided_at = $1, void_reason = $2, idempotency_key = NULL
+				WHERE id = $3
+			`, time.Now(), lastError, *lineItemID)
+			if err != nil {
+				return fmt.Errorf("failed to void undelivered line item: %w", err)
+			}
+		case OutboxVoidLineItem:
+			if _, err := tx.Exec(ctx, "UPDATE line_items SET voided_at = NULL, void_reason = NULL WHERE id = $1", *lineItemID); err != nil {
+				return fmt.Errorf("failed to restore undelivered void: %w", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit outbox failure: %w", err)
+	}
+	return nil
+}
+
+// SettleOutboxEvents marks done the bill's pending item events for itemIDs and
+// void events for voidIDs, which its workflow has already applied.
+func (r *Repository) SettleOutboxEvents(ctx context.Context, billID string, itemIDs, voidIDs []string) error {
+	itemsJSON, err := json.Marshal(append([]string{}, itemIDs...))
+	if err != nil {
+		return fmt.Errorf("failed to encode item IDs: %w", err)
+	}
+	voidsJSON, err := json.Marshal(append([]string{}, voidIDs...))
+	if err != nil {
+		return fmt.Errorf("failed to encode void IDs: %w", err)
+	}
+
+	_, err = r.db.Exec(ctx, `
+		UPDATE outbox o
+		SET status = $1, processed_at = $2
+		FROM line_items li
+		WHERE o.line_item_id = li.id AND o.bill_id = $3 AND o.status = $4
+			AND ((o.kind = $5 AND li.public_id IN (SELECT jsonb_array_elements_text($6::jsonb)))
+				OR (o.kind = $7 AND li.public_id IN (SELECT jsonb_array_elements_text($8::jsonb))))
+	`, OutboxStatusDone, time.Now(), billID, OutboxStatusPending,
+		OutboxAddLineItem, string(itemsJSON), OutboxVoidLineItem, string(voidsJSON))
+	if err != nil {
+		return fmt.Errorf("failed to settle outbox events: %w", err)
+	}
+	return nil
+}
+
+// CreateFeeSchedule stores the schedule as the next version of its ID.
+func (r *Repository) CreateFeeSchedule(ctx context.Context, schedule *FeeSchedule) error {
+	tiers, err := json.Marshal(schedule.Tiers)
+	if err != nil {
+		return fmt.Errorf("failed to encode fee tiers: %w", err)
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", schedule.ID); err != nil {
+		return fmt.Errorf("failed to lock fee schedule: %w", err)
+	}
+
+	schedule.CreatedAt = time.Now()
+	err = tx.QueryRow(ctx, `
+		INSERT INTO fee_schedules (id, version, name, currency, customer_id, tiers, created_at)
+		SELECT $1, COALESCE(MAX(version), 0) + 1, $2, $3, NULLIF($4, ''), $5, $6
+		FROM fee_schedules WHERE id = $1
+		RETURNING version
+	`, schedule.ID, schedule.Name, schedule.Currency, schedule.CustomerID, string(tiers), schedule.CreatedAt).Scan(&schedule.Version)
+	if err != nil {
+		return fmt.Errorf("failed to create fee schedule: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit fee schedule: %w", err)
+	}
+	return nil
+}
+
+const feeScheduleColumns = `id, version, name, currency, customer_id, tiers, created_at`
+
+func scanFeeSchedule(row rowScanner) (*FeeSchedule, error) {
+	var schedule FeeSchedule
+	var customerID sql.NullString
+	var tiers []byte
+	err := row.Scan(&schedule.ID, &schedule.Version, &schedule.Name, &schedule.Currency, &customerID, &tiers, &schedule.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrFeeScheduleNotFound
+		}
+		return nil, fmt.Errorf("failed to get fee schedule: %w", err)
+	}
+	schedule.CustomerID = customerID.String
+	if err := json.Unmarshal(tiers, &schedule.Tiers); err != nil {
+		return nil, fmt.Errorf("failed to decode fee tiers: %w", err)
+	}
+	return &schedule, nil
+}
+
+func (r *Repository) GetFeeSchedule(ctx context.Context, scheduleID string) (*FeeSchedule, error) {
+	return scanFeeSchedule(r.db.QueryRow(ctx, `
+		SELECT `+feeScheduleColumns+`
+		FROM fee_schedules
+		WHERE id = $1
+		ORDER BY version DESC
+		LIMIT 1
+	`, scheduleID))
+}
+
+func (r *Repository) GetCustomerFeeSchedule(ctx context.Context, customerID string, currency Currency) (*FeeSchedule, error) {
+	return scanFeeSchedule(r.db.QueryRow(ctx, `
+		SELECT `+feeScheduleColumns+`
+		FROM fee_schedules
+		WHERE customer_id = $1 AND currency = $2
+		ORDER BY created_at DESC, version DESC
+		LIMIT 1
+	`, customerID, currency))
+}
+
+// SaveTaxRule inserts the rule or overwrites the one with the same ID.
+func (r *Repository) SaveTaxRule(ctx context.Context, rule *TaxRule) error {
+	exempt := rule.ExemptCustomers
+	if exempt == nil {
+		exempt = []string{}
+	}
+	exemptJSON, err := json.Marshal(exempt)
+	if err != nil {
+		return fmt.Errorf("failed to encode exempt customers: %w", err)
+	}
+
+	rule.UpdatedAt = time.Now()
+	_, err = r.db.Exec(ctx, `
+		INSERT INTO tax_rules (id, name, jurisdiction, currency, rate_bps, inclusive, exempt_customers, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (id) DO UPDATE
+		SET name = EXCLUDED.name, jurisdiction = EXCLUDED.jurisdiction, currency = EXCLUDED.currency,
+			rate_bps = EXCLUDED.rate_bps, inclusive = EXCLUDED.inclusive,
+			exempt_customers = EXCLUDED.exempt_customers, updated_at = EXCLUDED.updated_at
+	`, rule.ID, rule.Name, rule.Jurisdiction, rule.Currency, rule.RateBps, rule.Inclusive, string(exemptJSON), rule.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save tax rule: %w", err)
+	}
+	return nil
+}
+
+const taxRuleColumns = `id, name, jurisdiction, currency, rate_bps, inclusive, exempt_customers, updated_at`
+
+func scanTaxRule(row rowScanner) (*TaxRule, error) {
+	var rule TaxRule
+	var exempt []byte
+	err := row.Scan(&rule.ID, &rule.Name, &rule.Jurisdiction, &rule.Currency, &rule.RateBps, &rule.Inclusive, &exempt, &rule.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrTaxRuleNotFound
+		}
+		return nil, fmt.Errorf("failed to get tax rule: %w", err)
+	}
+	if err := json.Unmarshal(exempt, &rule.ExemptCustomers); err != nil {
+		return nil, fmt.Errorf("failed to decode exempt customers: %w", err)
+	}
+	return &rule, nil
+}
+
+func (r *Repository) GetTaxRule(ctx context.Context, ruleID string) (*TaxRule, error) {
+	return scanTaxRule(r.db.QueryRow(ctx, "SELECT "+taxRuleColumns+" FROM tax_rules WHERE id = $1", ruleID))
+}
+
+// ListTaxRules returns the rules for currency, or every rule if it is empty.
+func (r *Repository) ListTaxRules(ctx context.Context, currency Currency) ([]*TaxRule, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+taxRuleColumns+`
+		FROM tax_rules
+		WHERE $1 = '' OR currency = $1
+		ORDER BY id
+	`, currency)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tax rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []*TaxRule
+	for rows.Next() {
+		rule, err := scanTaxRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tax rules: %w", err)
+	}
+	return rules, nil
+}
+
+// CreatePromoCode stores a new code; codes can't be redefined once customers
+// may have used them.
+func (r *Repository) CreatePromoCode(ctx context.Context, promo *PromoCode) error {
+	promo.CreatedAt = time.Now()
+	result, err := r.db.Exec(ctx, `
+		INSERT INTO promo_codes (code, description, kind, percent_bps, amount, currency, valid_from, valid_until,
+			max_uses_per_customer, created_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, $9, $10)
+		ON CONFLICT (code) DO NOTHING
+	`, promo.Code, promo.Description, promo.Kind, promo.PercentBps, promo.Amount, promo.Currency,
+		promo.ValidFrom, promo.ValidUntil, promo.MaxUsesPerCustomer, promo.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create promo code: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("%w: %s", ErrPromoCodeExists, promo.Code)
+	}
+	return nil
+}
+
+func (r *Repository) GetPromoCode(ctx context.Context, code string) (*PromoCode, error) {
+	var promo PromoCode
+	var currency sql.NullString
+	err := r.db.QueryRow(ctx, `
+		SELECT code, description, kind, percent_bps, amount, currency, valid_from, valid_until,
+			max_uses_per_customer, created_at
+		FROM promo_codes
+		WHERE code = $1
+	`, code).Scan(&promo.Code, &promo.Description, &promo.Kind, &promo.PercentBps, &promo.Amount, &currency,
+		&promo.ValidFrom, &promo.ValidUntil, &promo.MaxUsesPerCustomer, &promo.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrPromoCodeNotFound
+		}
+		return nil, fmt.Errorf("failed to get promo code: %w", err)
+	}
+	promo.Currency = Currency(currency.String)
+	return &promo, nil
+}
+
+// AttachDiscount adds a discount to an open bill. The bill row is locked so
+// the check that the bill is open holds until the discount is stored; the
+// bill workflow makes sure that no discount is attached while it closes.
+func (r *Repository) AttachDiscount(ctx context.Context, billID string, discount *BillDiscount) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var customerID string
+	var status BillStatus
+	err = tx.QueryRow(ctx, "SELECT customer_id, status FROM bills WHERE id = $1 FOR UPDATE", billID).Scan(&customerID, &status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrBillNotFound
+		}
+		return fmt.Errorf("failed to get bill: %w", err)
+	}
+	if status != BillStatusOpen {
+		return ErrBillAlreadyClosed
+	}
+
+	if err := attachDiscount(ctx, tx, billID, customerID, discount); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit discount: %w", err)
+	}
+	return nil
+}
+
+// attachDiscount enforces the code's per-customer limit and records the
+// discount. Concurrent attaches of the same code for the same customer are
+// serialized so the limit can't be overrun.
+func attachDiscount(ctx context.Context, tx *sqldb.Tx, billID, customerID string, discount *BillDiscount) error {
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", "promo:"+customerID+":"+discount.Code); err != nil {
+		return fmt.Errorf("failed to lock promo code usage: %w", err)
+	}
+
+	var maxUses int
+	err := tx.QueryRow(ctx, "SELECT max_uses_per_customer FROM promo_codes WHERE code = $1", discount.Code).Scan(&maxUses)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: %s", ErrPromoCodeNotFound, discount.Code)
+		}
+		return fmt.Errorf("failed to get promo code: %w", err)
+	}
+	if maxUses > 0 {
+		var uses int
+		err := tx.QueryRow(ctx, `
+			SELECT COUNT(*) FROM bill_discounts d JOIN bills b ON b.id = d.bill_id
+			WHERE d.customer_id = $1 AND d.code = $2 AND d.bill_id <> $3 AND b.status <> $4
+		`, customerID, discount.Code, billID, BillStatusVoided).Scan(&uses)
+		if err != nil {
+			return fmt.Errorf("failed to count promo code uses: %w", err)
+		}
+		if uses >= maxUses {
+			return fmt.Errorf("%w: %s used %d of %d times", ErrPromoCodeUsageExceeded, discount.Code, uses, maxUses)
+		}
+	}
+
+	result, err := tx.Exec(ctx, `
+		INSERT INTO bill_discounts (bill_id, code, customer_id, description, kind, percent_bps, amount, attached_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (bill_id, code) DO NOTHING
+	`, billID, discount.Code, customerID, discount.Description, discount.Kind, discount.PercentBps, discount.Amount, discount.AttachedAt)
+	if err != nil {
+		return fmt.Errorf("failed to attach discount: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("%w: %s", ErrDiscountAlreadyApplied, discount.Code)
+	}
+	return nil
+}
+
+// ListBillDiscounts returns a bill's discounts in the order they apply.
+func (r *Repository) ListBillDiscounts(ctx context.Context, billID string) ([]BillDiscount, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT code, description, kind, percent_bps, amount, attached_at, applied_amount
+		FROM bill_discounts
+		WHERE bill_id = $1
+		ORDER BY attached_at ASC, code ASC
+	`, billID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query bill discounts: %w", err)
+	}
+	defer rows.Close()
+
+	var discounts []BillDiscount
+	for rows.Next() {
+		var d BillDiscount
+		if err := rows.Scan(&d.Code, &d.Description, &d.Kind, &d.PercentBps, &d.Amount, &d.AttachedAt, &d.AppliedAmount); err != nil {
+			return nil, fmt.Errorf("failed to scan bill discount: %w", err)
+		}
+		discounts = append(discounts, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating bill discounts: %w", err)
+	}
+	return discounts, nil
+}
+
+// SaveCustomerBillingLimits sets the customer's limits for a currency,
+// replacing any set before. Closed bills are not affected.
+func (r *Repository) SaveCustomerBillingLimits(ctx context.Context, limits *CustomerBillingLimits) error {
+	limits.UpdatedAt = time.Now()
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO customer_billing_limits (customer_id, currency, minimum_amount, maximum_amount, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (customer_id, currency) DO UPDATE
+		SET minimum_amount = EXCLUDED.minimum_amount, maximum_amount = EXCLUDED.maximum_amount,
+			updated_at = EXCLUDED.updated_at
+	`, limits.CustomerID, limits.Currency, limits.MinimumAmount, limits.MaximumAmount, limits.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save billing limits: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) GetCustomerBillingLimits(ctx context.Context, customerID string, currency Currency) (*CustomerBillingLimits, error) {
+	limits := CustomerBillingLimits{CustomerID: customerID, Currency: currency}
+	err := r.db.QueryRow(ctx, `
+		SELECT minimum_amount, maximum_amount, updated_at
+		FROM customer_billing_limits
+		WHERE customer_id = $1 AND currency = $2
+	`, customerID, currency).Scan(&limits.MinimumAmount, &limits.MaximumAmount, &limits.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrBillingLimitsNotFound
+		}
+		return nil, fmt.Errorf("failed to get billing limits: %w", err)
+	}
+	return &limits, nil
+}
+
+// issueInvoice allocates the next number for the bill's legal entity and
+// stores the invoice. The sequence row stays locked until the transaction
+// ends and rolls back with it, so numbers are handed out in order with no
+// gaps. A reopened bill's new invoice supersedes its previous one, and an
+// adjustment bill's invoice names the invoice of the bill it corrects.
+func issueInvoice(ctx context.Context, tx *sqldb.Tx, bill *FinalBill) error {
+	invoice := newInvoice(bill, time.Now())
+
+	var supersedes, corrects sql.NullString
+	err := tx.QueryRow(ctx, `
+		SELECT
+			(SELECT number FROM invoices WHERE bill_id = b.id ORDER BY issued_at DESC LIMIT 1),
+			(SELECT invoice_number FROM bills WHERE id = b.corrects_bill_id)
+		FROM bills b WHERE b.id = $1
+	`, bill.ID).Scan(&supersedes, &corrects)
+	if err != nil {
+		return fmt.Errorf("failed to look up earlier invoices: %w", err)
+	}
+	invoice.Supersedes = supersedes.String
+	invoice.Corrects = corrects.String
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO invoice_sequences (legal_entity, last_number)
+		VALUES ($1, 1)
+		ON CONFLICT (legal_entity) DO UPDATE SET last_number = invoice_sequences.last_number + 1
+		RETURNING last_number
+	`, invoice.LegalEntity).Scan(&invoice.Sequence)
+	if err != nil {
+		return fmt.Errorf("failed to allocate invoice number: %w", err)
+	}
+	invoice.Number = FormatInvoiceNumber(invoice.LegalEntity, invoice.Sequence)
+
+	document, err := json.Marshal(invoice)
+	if err != nil {
+		return fmt.Errorf("failed to encode invoice: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO invoices (number, legal_entity, sequence, bill_id, customer_id, currency, total_amount, issued_at, document, supersedes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''))
+	`, invoice.Number, invoice.LegalEntity, invoice.Sequence, invoice.BillID, invoice.CustomerID, invoice.Currency,
+		invoice.TotalAmount, invoice.IssuedAt, string(document), invoice.Supersedes)
+	if err != nil {
+		return fmt.Errorf("failed to save invoice: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, "UPDATE bills SET invoice_number = $1 WHERE id = $2", invoice.Number, bill.ID); err != nil {
+		return fmt.Errorf("failed to link invoice to bill: %w", err)
+	}
+	bill.InvoiceNumber = invoice.Number
+	return nil
+}
+
+// GetInvoice returns an invoice as it was issued, with SupersededBy set if
+// the bill has been invoiced again since.
+func (r *Repository) GetInvoice(ctx context.Context, number string) (*Invoice, error) {
+	var document []byte
+	var supersededBy sql.NullString
+	err := r.db.QueryRow(ctx, `
+		SELECT i.document, (SELECT number FROM invoices WHERE supersedes = i.number)
+		FROM invoices i WHERE i.number = $1
+	`, number).Scan(&document, &supersededBy)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInvoiceNotFound
+		}
+		return nil, fmt.Errorf("failed to get invoice: %w", err)
+	}
+
+	var invoice Invoice
+	if err := json.Unmarshal(document, &invoice); err != nil {
+		return nil, fmt.Errorf("failed to decode invoice %s: %w", number, err)
+	}
+	invoice.SupersededBy = supersededBy.String
+	return &invoice, nil
+}
+
+const paymentColumns = `id, bill_id, amount, currency, method, COALESCE(external_reference, ''), received_at, created_at`
+
+func scanPayment(row rowScanner, payment *Payment) error {
+	return row.Scan(&payment.ID, &payment.BillID, &payment.Amount, &payment.Currency, &payment.Method,
+		&payment.ExternalReference, &payment.ReceivedAt, &payment.CreatedAt)
+}
+
+// RecordPayment stores a payment against a closed bill and adds it to the
+// bill's paid amount. If the bill already has a payment with the same
+// external reference, that payment is returned and nothing is stored; the
+// bill row is locked so concurrent retries can't both get in.
+func (r *Repository) RecordPayment(ctx context.Context, payment *Payment) (*Payment, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var status BillStatus
+	err = tx.QueryRow(ctx, "SELECT status FROM bills WHERE id = $1 FOR UPDATE", payment.BillID).Scan(&status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrBillNotFound
+		}
+		return nil, fmt.Errorf("failed to get bill: %w", err)
+	}
+	if status != BillStatusClosed {
+		return nil, ErrBillNotClosed
+	}
+
+	if payment.ExternalReference != "" {
+		var existing Payment
+		err := scanPayment(tx.QueryRow(ctx, `SELECT `+paymentColumns+` FROM payments WHERE bill_id = $1 AND external_reference = $2`,
+			payment.BillID, payment.ExternalReference), &existing)
+		if err == nil {
+			return &existing, nil
+		}
+		if err != sql.ErrNoRows {
+			return nil, fmt.Errorf("failed to get payment: %w", err)
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO payments (id, bill_id, amount, currency, method, external_reference, received_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8)
+	`, payment.ID, payment.BillID, payment.Amount, payment.Currency, payment.Method, payment.ExternalReference,
+		payment.ReceivedAt, payment.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save payment: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, "UPDATE bills SET paid_amount = paid_amount + $1 WHERE id = $2", payment.Amount, payment.BillID); err != nil {
+		return nil, fmt.Errorf("failed to update paid amount: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit payment: %w", err)
+	}
+	return payment, nil
+}
+
+// ListPayments returns a bill's payments in the order they were received.
+func (r *Repository) ListPayments(ctx context.Context, billID string) ([]*Payment, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+paymentColumns+`
+		FROM payments
+		WHERE bill_id = $1
+		ORDER BY received_at ASC, created_at ASC
+	`, billID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list payments: %w", err)
+	}
+	defer rows.Close()
+
+	payments := []*Payment{}
+	for rows.Next() {
+		var payment Payment
+		if err := scanPayment(rows, &payment); err != nil {
+			return nil, fmt.Errorf("failed to scan payment: %w", err)
+		}
+		payments = append(payments, &payment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating payments: %w", err)
+	}
+	return payments, nil
+}
+
+// UpdateDunning records how far chasing a bill has got. The stage never goes
+// backwards, so a retried earlier step can't undo a later one.
+func (r *Repository) UpdateDunning(ctx context.Context, billID string, status DunningStatus, stage int) error {
+	result, err := r.db.Exec(ctx, `
+		UPDATE bills SET dunning_status = $1, dunning_stage = GREATEST(dunning_stage, $2)
+		WHERE id = $3
+	`, status, stage, billID)
+	if err != nil {
+		return fmt.Errorf("failed to update dunning status: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrBillNotFound
+	}
+	return nil
+}
+
+func (r *Repository) CreateMeter(ctx context.Context, meter *Meter) error {
+	unitPrices, err := json.Marshal(meter.UnitPrices)
+	if err != nil {
+		return fmt.Errorf("failed to encode unit prices: %w", err)
+	}
+
+	meter.CreatedAt = time.Now()
+	result, err := r.db.Exec(ctx, `
+		INSERT INTO meters (name, description, unit, aggregation, unit_prices, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (name) DO NOTHING
+	`, meter.Name, meter.Description, meter.Unit, meter.Aggregation, string(unitPrices), meter.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create meter: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("%w: %s", ErrMeterExists, meter.Name)
+	}
+	return nil
+}
+
+const meterColumns = `m.name, m.description, m.unit, m.aggregation, m.unit_prices, m.created_at`
+
+func scanMeter(unitPrices []byte, meter *Meter) error {
+	if err := json.Unmarshal(unitPrices, &meter.UnitPrices); err != nil {
+		return fmt.Errorf("failed to decode unit prices of meter %s: %w", meter.Name, err)
+	}
+	return nil
+}
+
+func (r *Repository) GetMeter(ctx context.Context, name string) (*Meter, error) {
+	var meter Meter
+	var unitPrices []byte
+	err := r.db.QueryRow(ctx, `SELECT `+meterColumns+` FROM meters m WHERE m.name = $1`, name).
+		Scan(&meter.Name, &meter.Description, &meter.Unit, &meter.Aggregation, &unitPrices, &meter.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrMeterNotFound
+		}
+		return nil, fmt.Errorf("failed to get meter: %w", err)
+	}
+	if err := scanMeter(unitPrices, &meter); err != nil {
+		return nil, err
+	}
+	return &meter, nil
+}
+
+const usageEventColumns = `id, customer_id, meter, quantity, occurred_at, COALESCE(idempotency_key, ''),
+	COALESCE(bill_id, ''), status, late, COALESCE(flag_reason, ''), received_at`
+
+func scanUsageEvent(row rowScanner, event *UsageEvent) error {
+	return row.Scan(&event.ID, &event.CustomerID, &event.Meter, &event.Quantity, &event.Timestamp, &event.IdempotencyKey,
+		&event.BillID, &event.Status, &event.Late, &event.FlagReason, &event.ReceivedAt)
+}
+
+// RecordUsageEvent stores a usage event. If the customer already sent an
+// event with the same idempotency key, that event is returned and nothing is
+// stored. An event routed to a bill that has closed in the meantime is
+// rejected with ErrBillAlreadyClosed so it can be routed again; the bill row
+// is share-locked so the event can't be stored while the bill is being
+// finalized.
+func (r *Repository) RecordUsageEvent(ctx context.Context, event *UsageEvent) (*UsageEvent, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if event.IdempotencyKey != "" {
+		var existing UsageEvent
+		err := scanUsageEvent(tx.QueryRow(ctx, `SELECT `+usageEventColumns+` FROM usage_events WHERE customer_id = $1 AND idempotency_key = $2`,
+			event.CustomerID, event.IdempotencyKey), &existing)
+		if err == nil {
+			return &existing, nil
+		}
+		if err != sql.ErrNoRows {
+			return nil, fmt.Errorf("failed to get usage event: %w", err)
+		}
+	}
+
+	if event.BillID != "" {
+		var status BillStatus
+		err := tx.QueryRow(ctx, "SELECT status FROM bills WHERE id = $1 FOR SHARE", event.BillID).Scan(&status)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return nil, ErrBillNotFound
+			}
+			return nil, fmt.Errorf("failed to get bill: %w", err)
+		}
+		if status != BillStatusOpen {
+			return nil, ErrBillAlreadyClosed
+		}
+	}
+
+	result, err := tx.Exec(ctx, `
+		INSERT INTO usage_events (id, customer_id, meter, quantity, occurred_at, idempotency_key, bill_id, status, late,
+			flag_reason, received_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), $8, $9, NULLIF($10, ''), $11)
+		ON CONFLICT (customer_id, idempotency_key) DO NOTHING
+	`, event.ID, event.CustomerID, event.Meter, event.Quantity, event.Timestamp, event.IdempotencyKey, event.BillID,
+		event.Status, event.Late, event.FlagReason, event.ReceivedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save usage event: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return nil, errDuplicateIdempotencyKey
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit usage event: %w", err)
+	}
+	return event, nil
+}
+
+// ListUsageEvents returns a customer's most recently received events,
+// optionally only those with the given status.
+func (r *Repository) ListUsageEvents(ctx context.Context, customerID string, status UsageEventStatus, limit int) ([]*UsageEvent, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+usageEventColumns+`
+		FROM usage_events
+		WHERE customer_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY received_at DESC, seq DESC
+		LIMIT $3
+	`, customerID, string(status), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list usage events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []*UsageEvent{}
+	for rows.Next() {
+		var event UsageEvent
+		if err := scanUsageEvent(rows, &event); err != nil {
+			return nil, fmt.Errorf("failed to scan usage event: %w", err)
+		}
+		events = append(events, &event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating usage events: %w", err)
+	}
+	return events, nil
+}
+
+// AggregateUsage combines a bill's pending usage events per meter according
+// to the meter's aggregation.
+func (r *Repository) AggregateUsage(ctx context.Context, billID string) (*BillUsage, error) {
+	return aggregateUsage(ctx, r.db, billID, false)
+}
+
+// ClaimUsage claims the bill's pending usage events for its close and
+// aggregates them like AggregateUsage. Closing the bill bills exactly the
+// claimed events; ones that commit after the claim are flagged instead.
+// Events an earlier attempt claimed are included again.
+func (r *Repository) ClaimUsage(ctx context.Context, billID string) (*BillUsage, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(ctx, `
+		UPDATE usage_events SET priced_at = $1
+		WHERE bill_id = $2 AND status = $3 AND priced_at IS NULL
+	`, time.Now(), billID, UsageEventStatusPending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim usage events: %w", err)
+	}
+	usage, err := aggregateUsage(ctx, tx, billID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit usage claim: %w", err)
+	}
+	return usage, nil
+}
+
+func aggregateUsage(ctx context.Context, q rowsQuerier, billID string, claimedOnly bool) (*BillUsage, error) {
+	rows, err := q.Query(ctx, `
+		SELECT `+meterColumns+`,
+			CASE m.aggregation
+				WHEN 'SUM' THEN SUM(e.quantity)::BIGINT
+				WHEN 'MAX' THEN MAX(e.quantity)
+				ELSE (ARRAY_AGG(e.quantity ORDER BY e.occurred_at DESC, e.seq DESC))[1]
+			END,
+			COUNT(*), MAX(e.seq)
+		FROM usage_events e
+		JOIN meters m ON m.name = e.meter
+		WHERE e.bill_id = $1 AND e.status = $2 AND (NOT $3 OR e.priced_at IS NOT NULL)
+		GROUP BY m.name
+		ORDER BY m.name
+	`, billID, UsageEventStatusPending, claimedOnly)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate usage: %w", err)
+	}
+	defer rows.Close()
+
+	usage := &BillUsage{}
+	for rows.Next() {
+		var meterUsage MeterUsage
+		var unitPrices []byte
+		var lastSeq int64
+		meter := &meterUsage.Meter
+		err := rows.Scan(&meter.Name, &meter.Description, &meter.Unit, &meter.Aggregation, &unitPrices, &meter.CreatedAt,
+			&meterUsage.Quantity, &meterUsage.Events, &lastSeq)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan usage: %w", err)
+		}
+		if err := scanMeter(unitPrices, meter); err != nil {
+			return nil, err
+		}
+		usage.Meters = append(usage.Meters, meterUsage)
+		if lastSeq > usage.LastEventSeq {
+			usage.LastEventSeq = lastSeq
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating usage: %w", err)
+	}
+	return usage, nil
+}
+
+func (r *Repository) CreateSubscription(ctx context.Context, sub *Subscription) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO subscriptions (id, customer_id, plan, plan_amount, currency, anchor_day, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, sub.ID, sub.CustomerID, sub.Plan, sub.PlanAmount, sub.Currency, sub.AnchorDay, sub.Status, sub.CreatedAt, sub.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create subscription: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) GetSubscription(ctx context.Context, subscriptionID string) (*Subscription, error) {
+	var sub Subscription
+	err := r.db.QueryRow(ctx, `
+		SELECT id, customer_id, plan, plan_amount, currency, anchor_day, status,
+			current_period_start, current_period_end, COALESCE(current_bill_id, ''), created_at, updated_at
+		FROM subscriptions
+		WHERE id = $1
+	`, subscriptionID).Scan(&sub.ID, &sub.CustomerID, &sub.Plan, &sub.PlanAmount, &sub.Currency, &sub.AnchorDay, &sub.Status,
+		&sub.CurrentPeriodStart, &sub.CurrentPeriodEnd, &sub.CurrentBillID, &sub.CreatedAt, &sub.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrSubscriptionNotFound
+		}
+		return nil, fmt.Errorf("failed to get subscription: %w", err)
+	}
+	return &sub, nil
+}
+
+// UpdateSubscriptionStatus moves the subscription from one status to
+// another. It fails with ErrSubscriptionStatusChanged if the subscription is
+// no longer in status from.
+func (r *Repository) UpdateSubscriptionStatus(ctx context.Context, subscriptionID string, from, to SubscriptionStatus) error {
+	result, err := r.db.Exec(ctx, `
+		UPDATE subscriptions SET status = $1, updated_at = $2
+		WHERE id = $3 AND status = $4
+	`, to, time.Now(), subscriptionID, from)
+	if err != nil {
+		return fmt.Errorf("failed to update subscription status: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("%w: %s is no longer %s", ErrSubscriptionStatusChanged, subscriptionID, from)
+	}
+	return nil
+}
+
+// RenewSubscription moves the subscription on to the period from start to
+// end, billed on billID or on nothing while paused.
+func (r *Repository) RenewSubscription(ctx context.Context, subscriptionID, billID string, start, end time.Time) error {
+	result, err := r.db.Exec(ctx, `
+		UPDATE subscriptions
+		SET current_period_start = $1, current_period_end = $2, current_bill_id = NULLIF($3, ''), updated_at = $4
+		WHERE id = $5
+	`, start, end, billID, time.Now(), subscriptionID)
+	if err != nil {
+		return fmt.Errorf("failed to renew subscription: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrSubscriptionNotFound
+	}
+	return nil
+}
+
+// UpdateSubscriptionPlan saves the new plan together with the items
+// prorating it on billID, so neither lands without the other. It returns the
+// outbox events delivering the items, in the same order.
+func (r *Repository) UpdateSubscriptionPlan(ctx context.Context, subscriptionID, plan string, planAmount int64,
+	billID string, items []*LineItem) ([]*OutboxEvent, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(ctx, `
+		UPDATE subscriptions SET plan = $1, plan_amount = $2, updated_at = $3
+		WHERE id = $4
+	`, plan, planAmount, time.Now(), subscriptionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update subscription plan: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return nil, ErrSubscriptionNotFound
+	}
+
+	events := make([]*OutboxEvent, 0, len(items))
+	for _, item := range items {
+		event, err := insertLineItem(ctx, tx, billID, item)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit subscription plan: %w", err)
+	}
+	return events, nil
+}
@@ -2,25 +2,75 @@ package fees
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"strings"
 	"time"
 
+	"pave-fees/fees/internal/proration"
 	"pave-fees/fees/internal/temporal"
 
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
 	"go.temporal.io/sdk/client"
 )
 
 type BillService struct {
-	repo     RepositoryInterface
-	temporal TemporalClientInterface
+	repo        RepositoryInterface
+	temporal    TemporalClientInterface
+	rates       RateProvider
+	renderer    *InvoiceRenderer
+	operators   *Operators
+	reopenGrace time.Duration
 }
 
-func NewBillService(repo RepositoryInterface, temporalClient TemporalClientInterface) *BillService {
-	return &BillService{
-		repo:     repo,
-		temporal: temporalClient,
+// ServiceOption configures optional BillService dependencies.
+type ServiceOption func(*BillService)
+
+// WithRateProvider sets where exchange rates for foreign-currency line items
+// come from. Without one, only items in the bill currency are accepted.
+func WithRateProvider(rates RateProvider) ServiceOption {
+	return func(s *BillService) {
+		s.rates = rates
+	}
+}
+
+// WithInvoiceRenderer sets the branding invoices are rendered with.
+func WithInvoiceRenderer(renderer *InvoiceRenderer) ServiceOption {
+	return func(s *BillService) {
+		s.renderer = renderer
+	}
+}
+
+// WithOperators sets who can authenticate as an operator. Without it nobody
+// can.
+func WithOperators(operators *Operators) ServiceOption {
+	return func(s *BillService) {
+		s.operators = operators
+	}
+}
+
+// WithReopenGracePeriod sets how long after closing a bill can be reopened.
+func WithReopenGracePeriod(grace time.Duration) ServiceOption {
+	return func(s *BillService) {
+		s.reopenGrace = grace
+	}
+}
+
+func NewBillService(repo RepositoryInterface, temporalClient TemporalClientInterface, opts ...ServiceOption) *BillService {
+	s := &BillService{
+		repo:        repo,
+		temporal:    temporalClient,
+		rates:       NewStaticRateProvider(nil, time.Time{}),
+		renderer:    defaultInvoiceRenderer(),
+		reopenGrace: DefaultReopenGracePeriod,
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
 }
 
 func (s *BillService) CreateBill(ctx context.Context, req *CreateBillRequest) (*CreateBillResponse, error) {
@@ -29,163 +79,1402 @@ func (s *BillService) CreateBill(ctx context.Context, req *CreateBillRequest) (*
 		return nil, fmt.Errorf("validation failed: %w", err)
 	}
 
-	billID := fmt.Sprintf("bill-%s-%d", req.CustomerID, time.Now().UnixNano())
+	if req.IdempotencyKey != "" {
+		resp, err := s.replayCreateBill(ctx, req)
+		if err != nil || resp != nil {
+			return resp, err
+		}
+	}
+
+	if req.FeeScheduleID != "" {
+		schedule, err := s.repo.GetFeeSchedule(ctx, req.FeeScheduleID)
+		if err != nil {
+			slog.Error("failed to get fee schedule for bill", "fee_schedule_id", req.FeeScheduleID, "error", err)
+			return nil, err
+		}
+		if schedule.Currency != req.Currency {
+			return nil, fmt.Errorf("validation failed: %w: schedule %s is priced in %s, bill is %s",
+				ErrInvalidFeeSchedule, schedule.ID, schedule.Currency, req.Currency)
+		}
+	}
+
+	now := time.Now()
+	billID := fmt.Sprintf("bill-%s-%d", req.CustomerID, now.UnixNano())
+	periodStart, periodEnd := req.BillingWindow(now)
+
+	discounts, err := s.resolvePromoCodes(ctx, req.PromoCodes, req.Currency, now)
+	if err != nil {
+		return nil, err
+	}
+
+	legalEntity := req.LegalEntity
+	if legalEntity == "" {
+		legalEntity = DefaultLegalEntity
+	}
+	paymentTerms := DefaultPaymentTermsDays
+	if req.PaymentTermsDays != nil {
+		paymentTerms = *req.PaymentTermsDays
+	}
 
 	bill := &Bill{
-		ID:          billID,
-		CustomerID:  req.CustomerID,
-		Currency:    req.Currency,
-		Status:      BillStatusOpen,
-		TotalAmount: 0,
-		CreatedAt:   time.Now(),
-		LineItems:   make([]LineItem, 0),
+		ID:                 billID,
+		CustomerID:         req.CustomerID,
+		Currency:           req.Currency,
+		Status:             BillStatusOpen,
+		TotalAmount:        0,
+		CreatedAt:          now,
+		LineItems:          make([]LineItem, 0),
+		PeriodStart:        periodStart,
+		PeriodEnd:          periodEnd,
+		FeeScheduleID:      req.FeeScheduleID,
+		AllowNegativeTotal: req.AllowNegativeTotal,
+		IdempotencyKey:     req.IdempotencyKey,
+		Discounts:          discounts,
+		MinimumAmount:      req.MinimumAmount,
+		MaximumAmount:      req.MaximumAmount,
+		LegalEntity:        legalEntity,
+		PaymentTermsDays:   &paymentTerms,
 	}
 
-	if err := s.repo.CreateBill(ctx, bill); err != nil {
+	event, err := s.repo.CreateBill(ctx, bill)
+	if errors.Is(err, errDuplicateIdempotencyKey) {
+		// A concurrent request with the same key won the insert.
+		resp, err := s.replayCreateBill(ctx, req)
+		if err == nil && resp == nil {
+			err = ErrBillNotFound
+		}
+		return resp, err
+	}
+	if err != nil {
 		slog.Error("failed to create bill in repository", "bill_id", billID, "error", err)
 		return nil, fmt.Errorf("failed to create bill: %w", err)
 	}
 
-	workflowOptions := client.StartWorkflowOptions{
-		ID:        billID,
-		TaskQueue: temporal.TaskQueue,
+	s.startBillWorkflow(ctx, event)
+
+	slog.Info("bill created successfully", "bill_id", billID, "customer_id", req.CustomerID)
+	return &CreateBillResponse{BillID: billID}, nil
+}
+
+// startBillWorkflow delivers the event that starts a bill's workflow run.
+// The bill row and the event are committed together; if the start fails here
+// the outbox relay picks it up later.
+func (s *BillService) startBillWorkflow(ctx context.Context, event *OutboxEvent) {
+	if err := s.deliverOutboxEvent(ctx, event); err != nil {
+		slog.Warn("failed to start bill workflow, left for outbox relay", "bill_id", event.BillID, "kind", event.Kind, "error", err)
+	} else if err := s.repo.MarkOutboxEventDone(ctx, event.ID); err != nil {
+		slog.Warn("failed to mark bill start event done", "bill_id", event.BillID, "kind", event.Kind, "error", err)
+	}
+}
+
+// resolvePromoCodes checks that each code can go on a new bill in currency
+// and returns the discounts to attach with it.
+func (s *BillService) resolvePromoCodes(ctx context.Context, codes []string, currency Currency, now time.Time) ([]BillDiscount, error) {
+	var discounts []BillDiscount
+	for _, code := range codes {
+		promo, err := s.repo.GetPromoCode(ctx, NormalizePromoCode(code))
+		if err != nil {
+			slog.Error("failed to get promo code for bill", "code", code, "error", err)
+			return nil, err
+		}
+		if err := promo.CheckApplicable(currency, now); err != nil {
+			return nil, fmt.Errorf("validation failed: %w", err)
+		}
+		discounts = append(discounts, newBillDiscount(promo, now))
 	}
+	return discounts, nil
+}
 
-	_, err := s.temporal.ExecuteWorkflow(ctx, workflowOptions, BillWorkflow, *bill)
+// replayCreateBill returns the bill already created under req's idempotency
+// key, or nil if the key is unused.
+func (s *BillService) replayCreateBill(ctx context.Context, req *CreateBillRequest) (*CreateBillResponse, error) {
+	bill, err := s.repo.GetBillByIdempotencyKey(ctx, req.CustomerID, req.IdempotencyKey)
+	if errors.Is(err, ErrBillNotFound) {
+		return nil, nil
+	}
 	if err != nil {
-		slog.Error("failed to start bill workflow", "bill_id", billID, "error", err)
-		return nil, fmt.Errorf("failed to start bill workflow: %w", err)
+		slog.Error("failed to look up bill by idempotency key", "customer_id", req.CustomerID, "error", err)
+		return nil, err
+	}
+	if bill.Currency != req.Currency {
+		return nil, fmt.Errorf("%w: bill %s was created in %s", ErrIdempotencyKeyReused, bill.ID, bill.Currency)
 	}
 
-	slog.Info("bill created successfully", "bill_id", billID, "customer_id", req.CustomerID)
-	return &CreateBillResponse{BillID: billID}, nil
+	slog.Info("replayed create bill request", "bill_id", bill.ID, "customer_id", req.CustomerID)
+	return &CreateBillResponse{BillID: bill.ID}, nil
 }
 
-func (s *BillService) AddLineItem(ctx context.Context, billID string, req *AddLineItemRequest) error {
+func (s *BillService) AddLineItem(ctx context.Context, billID string, req *AddLineItemRequest) (*AddLineItemResponse, error) {
 	if err := req.Validate(); err != nil {
 		slog.Error("invalid add line item request", "bill_id", billID, "error", err)
-		return fmt.Errorf("validation failed: %w", err)
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+
+	lineItem, err := req.LineItem(time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	item := &lineItem
+
+	// A retry is answered before conversion: the stored item keeps its
+	// original pricing and the rate source needn't be asked again.
+	if req.IdempotencyKey != "" {
+		resp, err := s.replayAddLineItem(ctx, billID, item)
+		if err != nil || resp != nil {
+			return resp, err
+		}
+	}
+
+	if item.FX != nil {
+		if err := s.convertLineItem(ctx, billID, item); err != nil {
+			return nil, err
+		}
 	}
 
 	status, err := s.repo.GetBillStatus(ctx, billID)
 	if err != nil {
 		slog.Error("failed to get bill status", "bill_id", billID, "error", err)
-		return err
+		return nil, err
 	}
 
 	if status == BillStatusClosed {
 		slog.Warn("attempted to add line item to closed bill", "bill_id", billID)
-		return ErrBillAlreadyClosed
+		return nil, ErrBillAlreadyClosed
 	}
-
-	item := &LineItem{
-		Description: req.Description,
-		Amount:      req.Amount,
-		Timestamp:   time.Now(),
+	if status == BillStatusVoided {
+		slog.Warn("attempted to add line item to voided bill", "bill_id", billID)
+		return nil, ErrBillVoided
 	}
 
-	if err := s.repo.AddLineItem(ctx, billID, item); err != nil {
+	item.ID = newLineItemID()
+
+	event, err := s.repo.AddLineItem(ctx, billID, item)
+	if errors.Is(err, errDuplicateIdempotencyKey) {
+		resp, err := s.replayAddLineItem(ctx, billID, item)
+		if err == nil && resp == nil {
+			err = ErrLineItemNotFound
+		}
+		return resp, err
+	}
+	if err != nil {
 		slog.Error("failed to add line item to repository", "bill_id", billID, "error", err)
-		return fmt.Errorf("failed to save line item: %w", err)
+		return nil, fmt.Errorf("failed to save line item: %w", err)
+	}
+
+	state, err := s.sendOutboxUpdate(ctx, event, AddLineItemUpdate, *item)
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		return &AddLineItemResponse{ItemID: item.ID, Queued: true}, nil
+	}
+
+	slog.Info("line item added successfully", "bill_id", billID, "item_id", item.ID, "description", req.Description, "kind", item.Kind, "amount", item.Amount)
+	return &AddLineItemResponse{
+		ItemID:        item.ID,
+		LineItemCount: state.ItemCount,
+		TotalAmount:   state.TotalAmount,
+	}, nil
+}
+
+// convertLineItem prices an item given in a foreign currency in the bill
+// currency at the provider's current rate. An item already in the bill
+// currency is left as it is.
+func (s *BillService) convertLineItem(ctx context.Context, billID string, item *LineItem) error {
+	bill, err := s.repo.GetBillByID(ctx, billID)
+	if err != nil {
+		slog.Error("failed to get bill for currency conversion", "bill_id", billID, "error", err)
+		return err
+	}
+	if item.FX.Currency == bill.Currency {
+		item.FX = nil
+		return nil
 	}
 
-	if err := s.temporal.SignalWorkflow(ctx, billID, "", AddLineItemSignal, *item); err != nil {
-		slog.Warn("failed to signal workflow for new line item", "bill_id", billID, "error", err)
-		return fmt.Errorf("failed to signal workflow: %w", err)
+	rate, err := s.rates.Rate(ctx, item.FX.Currency, bill.Currency, item.Timestamp)
+	if err != nil {
+		slog.Error("failed to get exchange rate", "bill_id", billID, "from", item.FX.Currency, "to", bill.Currency, "error", err)
+		return fmt.Errorf("failed to convert %s to %s: %w", item.FX.Currency, bill.Currency, err)
+	}
+	if err := convertLineItem(item, bill.Currency, rate); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
 	}
 
-	slog.Info("line item added successfully", "bill_id", billID, "description", req.Description, "amount", req.Amount)
+	slog.Info("converted line item", "bill_id", billID, "from", item.FX.Currency, "to", bill.Currency,
+		"original_amount", item.FX.Amount, "rate", item.FX.Rate.String(), "amount", item.Amount)
 	return nil
 }
 
-func (s *BillService) CloseBill(ctx context.Context, billID string) error {
+// replayAddLineItem answers a retried AddLineItem from the database without
+// touching the workflow. It returns nil if the item's idempotency key is unused.
+// Items in another currency are compared by their original pricing, so it
+// doesn't matter whether item has been converted yet.
+func (s *BillService) replayAddLineItem(ctx context.Context, billID string, item *LineItem) (*AddLineItemResponse, error) {
+	existing, err := s.repo.GetLineItemByIdempotencyKey(ctx, billID, item.IdempotencyKey)
+	if errors.Is(err, ErrLineItemNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		slog.Error("failed to look up line item by idempotency key", "bill_id", billID, "error", err)
+		return nil, err
+	}
+
+	bill, err := s.repo.GetBillByID(ctx, billID)
+	if err != nil {
+		slog.Error("failed to get bill for replayed line item", "bill_id", billID, "error", err)
+		return nil, err
+	}
+
+	// An item priced in the bill's own currency was stored without FX.
+	requested := *item
+	if requested.FX != nil && requested.FX.Currency == bill.Currency {
+		requested.FX = nil
+	}
+	if !existing.SamePricing(&requested) {
+		return nil, fmt.Errorf("%w: item %s has different contents", ErrIdempotencyKeyReused, existing.ID)
+	}
+
+	slog.Info("replayed add line item request", "bill_id", billID, "item_id", existing.ID)
+	return &AddLineItemResponse{
+		ItemID:        existing.ID,
+		LineItemCount: bill.ActiveItemCount(),
+		TotalAmount:   bill.CalculateTotal(),
+	}, nil
+}
+
+func (s *BillService) VoidLineItem(ctx context.Context, billID, itemID, reason string) (*VoidLineItemResponse, error) {
+	status, err := s.repo.GetBillStatus(ctx, billID)
+	if err != nil {
+		slog.Error("failed to get bill status", "bill_id", billID, "error", err)
+		return nil, err
+	}
+
+	if status == BillStatusClosed {
+		slog.Warn("attempted to void line item on closed bill", "bill_id", billID, "item_id", itemID)
+		return nil, ErrBillAlreadyClosed
+	}
+	if status == BillStatusVoided {
+		slog.Warn("attempted to void line item on voided bill", "bill_id", billID, "item_id", itemID)
+		return nil, ErrBillVoided
+	}
+
+	event, err := s.repo.VoidLineItem(ctx, billID, itemID, reason)
+	if err != nil {
+		slog.Error("failed to void line item in repository", "bill_id", billID, "item_id", itemID, "error", err)
+		return nil, err
+	}
+
+	// The stored void carries the item's amount for the workflow.
+	var void LineItemVoid
+	if err := json.Unmarshal(event.Payload, &void); err != nil {
+		slog.Error("failed to decode line item void", "bill_id", billID, "item_id", itemID, "error", err)
+		return nil, fmt.Errorf("failed to decode line item void: %w", err)
+	}
+
+	state, err := s.sendOutboxUpdate(ctx, event, VoidLineItemUpdate, void)
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		return &VoidLineItemResponse{ItemID: itemID, Queued: true}, nil
+	}
+
+	slog.Info("line item voided successfully", "bill_id", billID, "item_id", itemID, "reason", reason)
+	return &VoidLineItemResponse{
+		ItemID:        itemID,
+		LineItemCount: state.ItemCount,
+		TotalAmount:   state.TotalAmount,
+	}, nil
+}
+
+// sendOutboxUpdate delivers an outbox event to the bill workflow as an update
+// straight away. A rejection fails the event, which undoes its row change; if
+// the workflow can't be reached the event is left for the relay and a nil
+// state is returned.
+func (s *BillService) sendOutboxUpdate(ctx context.Context, event *OutboxEvent, updateName string, arg interface{}) (*BillState, error) {
+	var state BillState
+	err := s.updateWorkflow(ctx, event.BillID, updateName, lineItemUpdateID(event), &state, arg)
+	if errors.Is(err, ErrUpdateRejected) {
+		slog.Warn("workflow rejected update", "bill_id", event.BillID, "update", updateName, "error", err)
+		if markErr := s.repo.MarkOutboxEventFailed(ctx, event.ID, err.Error()); markErr != nil {
+			slog.Error("failed to discard rejected outbox event", "bill_id", event.BillID, "event_id", event.ID, "error", markErr)
+		}
+		return nil, err
+	}
+	if err != nil {
+		slog.Warn("failed to deliver update to workflow, left for outbox relay", "bill_id", event.BillID, "update", updateName, "error", err)
+		return nil, nil
+	}
+
+	if err := s.repo.MarkOutboxEventDone(ctx, event.ID); err != nil {
+		slog.Warn("failed to mark outbox event done", "bill_id", event.BillID, "event_id", event.ID, "error", err)
+	}
+	return &state, nil
+}
+
+// ApplyDiscount attaches a promo code to an open bill. It takes effect when
+// the bill closes.
+func (s *BillService) ApplyDiscount(ctx context.Context, billID string, req *ApplyDiscountRequest) (*ApplyDiscountResponse, error) {
+	code := NormalizePromoCode(req.Code)
+	if code == "" {
+		return nil, fmt.Errorf("validation failed: %w: code cannot be empty", ErrInvalidPromoCode)
+	}
+
+	bill, err := s.repo.GetBillByID(ctx, billID)
+	if err != nil {
+		slog.Error("failed to get bill for discount", "bill_id", billID, "error", err)
+		return nil, err
+	}
+	if bill.Status == BillStatusVoided {
+		slog.Warn("attempted to apply discount to voided bill", "bill_id", billID, "code", code)
+		return nil, ErrBillVoided
+	}
+	if bill.Status != BillStatusOpen {
+		slog.Warn("attempted to apply discount to closed bill", "bill_id", billID, "code", code)
+		return nil, ErrBillAlreadyClosed
+	}
+
+	promo, err := s.repo.GetPromoCode(ctx, code)
+	if err != nil {
+		slog.Error("failed to get promo code", "bill_id", billID, "code", code, "error", err)
+		return nil, err
+	}
+	now := time.Now()
+	if err := promo.CheckApplicable(bill.Currency, now); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+
+	// The workflow stores the discount so it can't slip in once the bill is
+	// being closed. A bill whose workflow hasn't started can't be closing.
+	discount := newBillDiscount(promo, now)
+	var attached BillDiscount
+	err = s.updateWorkflow(ctx, billID, AttachDiscountUpdate, "", &attached, discount)
+	var notFound *serviceerror.NotFound
+	if errors.As(err, &notFound) {
+		err = s.repo.AttachDiscount(ctx, billID, &discount)
+	}
+	if err != nil {
+		slog.Error("failed to attach discount", "bill_id", billID, "code", code, "error", err)
+		return nil, err
+	}
+
+	slog.Info("discount applied to bill", "bill_id", billID, "code", code, "kind", discount.Kind)
+	return &ApplyDiscountResponse{BillID: billID, Discount: discount}, nil
+}
+
+func (s *BillService) CloseBill(ctx context.Context, billID string) (*CloseBillResponse, error) {
 	bill, err := s.repo.GetBillByID(ctx, billID)
 	if err != nil {
 		slog.Error("failed to get bill for closing", "bill_id", billID, "error", err)
-		return err
+		return nil, err
 	}
 
 	if bill.Status == BillStatusClosed {
 		slog.Warn("attempted to close already closed bill", "bill_id", billID)
-		return ErrBillAlreadyClosed
+		return nil, ErrBillAlreadyClosed
+	}
+	if bill.Status == BillStatusVoided {
+		slog.Warn("attempted to close voided bill", "bill_id", billID)
+		return nil, ErrBillVoided
 	}
 
-	if err := s.temporal.SignalWorkflow(ctx, billID, "", CloseBillSignal, struct{}{}); err != nil {
-		slog.Error("failed to signal bill to close", "bill_id", billID, "error", err)
-		return fmt.Errorf("failed to signal bill to close: %w", err)
+	var state BillState
+	if err := s.updateWorkflow(ctx, billID, CloseBillUpdate, "", &state); err != nil {
+		slog.Error("workflow did not close bill", "bill_id", billID, "error", err)
+		return nil, err
 	}
 
-	slog.Info("bill close signal sent successfully", "bill_id", billID)
-	return nil
+	slog.Info("bill closed successfully", "bill_id", billID, "total_amount", state.TotalAmount)
+	return &CloseBillResponse{
+		BillID:      billID,
+		Status:      BillStatusClosed,
+		TotalAmount: state.TotalAmount,
+	}, nil
 }
 
-func (s *BillService) GetBill(ctx context.Context, billID string) (*GetBillResponse, error) {
-	bill, err := s.repo.GetBillByID(ctx, billID)
+// VoidBill abandons an open bill, e.g. one created by mistake. The workflow
+// stops without pricing the bill, so it gets no fees, tax or invoice and no
+// dunning. Its items stay stored for audit.
+func (s *BillService) VoidBill(ctx context.Context, billID string, req *VoidBillRequest) (*VoidBillResponse, error) {
+	if err := req.Validate(); err != nil {
+		slog.Error("invalid void bill request", "bill_id", billID, "error", err)
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	reason := strings.TrimSpace(req.Reason)
+
+	status, err := s.repo.GetBillStatus(ctx, billID)
 	if err != nil {
-		slog.Error("failed to get bill", "bill_id", billID, "error", err)
+		slog.Error("failed to get bill status", "bill_id", billID, "error", err)
 		return nil, err
 	}
+	if status == BillStatusVoided {
+		slog.Warn("attempted to void already voided bill", "bill_id", billID)
+		return nil, ErrBillVoided
+	}
+	if status == BillStatusClosed {
+		slog.Warn("attempted to void closed bill", "bill_id", billID)
+		return nil, ErrBillAlreadyClosed
+	}
 
-	var calculatedTotal int64 = 0
-	for _, item := range bill.LineItems {
-		calculatedTotal += item.Amount
+	var state BillState
+	if err := s.updateWorkflow(ctx, billID, VoidBillUpdate, "", &state, BillVoid{Reason: reason}); err != nil {
+		slog.Error("workflow did not void bill", "bill_id", billID, "error", err)
+		return nil, err
 	}
-	bill.TotalAmount = calculatedTotal
 
-	slog.Debug("bill retrieved successfully", "bill_id", billID, "status", bill.Status, "calculated_total", calculatedTotal)
-	return &GetBillResponse{Bill: bill}, nil
+	slog.Info("bill voided", "bill_id", billID, "reason", reason, "line_items", state.ItemCount)
+	return &VoidBillResponse{
+		BillID:     billID,
+		Status:     BillStatusVoided,
+		VoidReason: reason,
+	}, nil
 }
 
-func (s *BillService) ListBills(ctx context.Context, req *ListBillsRequest) (*ListBillsResponse, error) {
+// Authenticate returns the operator an API bearer token belongs to.
+func (s *BillService) Authenticate(token string) (*Operator, error) {
+	return s.operators.Authenticate(token)
+}
+
+// ReopenBill puts a bill that was closed too early back to OPEN, for the
+// operator to add to, void items on, and close again. Only bills closed
+// within the reopen grace period with no payments can be reopened; the
+// bill's invoice stays issued and is superseded by the one it gets when it
+// closes again. Every reopen is recorded with who made it and why.
+func (s *BillService) ReopenBill(ctx context.Context, operator *Operator, billID string, req *ReopenBillRequest) (*ReopenBillResponse, error) {
+	if err := operator.Require(OperatorRoleFinance); err != nil {
+		slog.Warn("bill reopen denied", "bill_id", billID, "error", err)
+		return nil, err
+	}
 	if err := req.Validate(); err != nil {
-		slog.Error("invalid list bills request", "error", err)
+		slog.Error("invalid reopen bill request", "bill_id", billID, "error", err)
 		return nil, fmt.Errorf("validation failed: %w", err)
 	}
 
-	bills, err := s.repo.ListBillsByCustomer(ctx, req.CustomerID, req.Status, req.Limit, req.Offset)
+	bill, err := s.repo.GetBillByID(ctx, billID)
 	if err != nil {
-		slog.Error("failed to list bills", "customer_id", req.CustomerID, "error", err)
-		return nil, fmt.Errorf("failed to list bills: %w", err)
+		slog.Error("failed to get bill for reopen", "bill_id", billID, "error", err)
+		return nil, err
+	}
+	now := time.Now()
+	closedAfter := now.Add(-s.reopenGrace)
+	if err := bill.CheckReopen(closedAfter); err != nil {
+		slog.Warn("bill cannot be reopened", "bill_id", billID, "status", bill.Status, "error", err)
+		return nil, err
 	}
 
-	billSummaries := make([]*BillSummary, len(bills))
-	for i, bill := range bills {
-		billSummaries[i] = &BillSummary{
-			ID:         bill.ID,
-			CustomerID: bill.CustomerID,
-			Currency:   bill.Currency,
-			Status:     bill.Status,
-			CreatedAt:  bill.CreatedAt,
-		}
+	correction := newBillCorrection(bill, CorrectionReopened, operator, strings.TrimSpace(req.Reason), now)
+	event, err := s.repo.ReopenBill(ctx, billID, closedAfter, correction)
+	if err != nil {
+		slog.Error("failed to reopen bill", "bill_id", billID, "error", err)
+		return nil, err
 	}
+	s.startBillWorkflow(ctx, event)
 
-	slog.Debug("bills listed successfully", "customer_id", req.CustomerID, "count", len(bills))
-	return &ListBillsResponse{Bills: billSummaries, Total: len(bills)}, nil
+	slog.Info("bill reopened", "bill_id", billID, "operator_id", operator.ID, "previous_invoice", correction.PreviousInvoiceNumber,
+		"reason", correction.Reason)
+	return &ReopenBillResponse{BillID: billID, Status: BillStatusOpen, Correction: correction}, nil
 }
 
-func (s *BillService) ListAllBills(ctx context.Context, req *ListAllBillsRequest) (*ListBillsResponse, error) {
+// CreateAdjustmentBill corrects a closed bill that can't be reopened by
+// opening an adjustment bill against it: credits on it make it a credit
+// note, charges an extra bill. It is charged exactly what its items say,
+// and its invoice names the original's.
+func (s *BillService) CreateAdjustmentBill(ctx context.Context, operator *Operator, billID string, req *CreateAdjustmentBillRequest) (*CreateAdjustmentBillResponse, error) {
+	if err := operator.Require(OperatorRoleFinance); err != nil {
+		slog.Warn("adjustment bill denied", "bill_id", billID, "error", err)
+		return nil, err
+	}
 	if err := req.Validate(); err != nil {
-		slog.Error("invalid list all bills request", "error", err)
+		slog.Error("invalid adjustment bill request", "bill_id", billID, "error", err)
 		return nil, fmt.Errorf("validation failed: %w", err)
 	}
 
-	bills, err := s.repo.ListAllBills(ctx, req.Status, req.Limit, req.Offset)
+	original, err := s.repo.GetBillByID(ctx, billID)
 	if err != nil {
-		slog.Error("failed to list all bills", "error", err)
-		return nil, fmt.Errorf("failed to list all bills: %w", err)
+		slog.Error("failed to get bill for adjustment", "bill_id", billID, "error", err)
+		return nil, err
+	}
+	if req.IdempotencyKey != "" {
+		resp, err := s.replayCreateAdjustmentBill(ctx, original, req.IdempotencyKey)
+		if err != nil || resp != nil {
+			return resp, err
+		}
+	}
+	if original.Status == BillStatusVoided {
+		return nil, ErrBillVoided
+	}
+	if original.Status != BillStatusClosed {
+		slog.Warn("attempted to adjust open bill", "bill_id", billID)
+		return nil, ErrBillNotClosed
 	}
 
-	billSummaries := make([]*BillSummary, len(bills))
-	for i, bill := range bills {
-		billSummaries[i] = &BillSummary{
-			ID:         bill.ID,
-			CustomerID: bill.CustomerID,
-			Currency:   bill.Currency,
-			Status:     bill.Status,
-			CreatedAt:  bill.CreatedAt,
+	now := time.Now()
+	bill := &Bill{
+		ID:                 fmt.Sprintf("bill-%s-%d", original.CustomerID, now.UnixNano()),
+		CustomerID:         original.CustomerID,
+		Currency:           original.Currency,
+		Status:             BillStatusOpen,
+		CreatedAt:          now,
+		LineItems:          make([]LineItem, 0),
+		AllowNegativeTotal: true,
+		LegalEntity:        original.LegalEntity,
+		PaymentTermsDays:   original.PaymentTermsDays,
+		CorrectsBillID:     original.ID,
+		IdempotencyKey:     req.IdempotencyKey,
+	}
+	correction := newBillCorrection(original, CorrectionAdjustmentIssued, operator, strings.TrimSpace(req.Reason), now)
+	correction.AdjustmentBillID = bill.ID
+
+	event, err := s.repo.CreateAdjustmentBill(ctx, bill, correction)
+	if errors.Is(err, errDuplicateIdempotencyKey) {
+		// A concurrent request with the same key won the insert.
+		resp, err := s.replayCreateAdjustmentBill(ctx, original, req.IdempotencyKey)
+		if err == nil && resp == nil {
+			err = ErrBillNotFound
 		}
+		return resp, err
+	}
+	if err != nil {
+		slog.Error("failed to create adjustment bill", "bill_id", billID, "error", err)
+		return nil, err
 	}
+	s.startBillWorkflow(ctx, event)
 
-	slog.Debug("all bills listed successfully", "count", len(bills))
-	return &ListBillsResponse{Bills: billSummaries, Total: len(bills)}, nil
+	slog.Info("adjustment bill created", "bill_id", billID, "adjustment_bill_id", bill.ID, "operator_id", operator.ID,
+		"reason", correction.Reason)
+	return &CreateAdjustmentBillResponse{BillID: bill.ID, CorrectsBillID: original.ID, Correction: correction}, nil
+}
+
+// replayCreateAdjustmentBill returns the adjustment bill already created
+// against original under key, or nil if the key is unused.
+func (s *BillService) replayCreateAdjustmentBill(ctx context.Context, original *Bill, key string) (*CreateAdjustmentBillResponse, error) {
+	bill, err := s.repo.GetBillByIdempotencyKey(ctx, original.CustomerID, key)
+	if errors.Is(err, ErrBillNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		slog.Error("failed to look up bill by idempotency key", "customer_id", original.CustomerID, "error", err)
+		return nil, err
+	}
+	if bill.CorrectsBillID != original.ID {
+		return nil, fmt.Errorf("%w: bill %s does not correct %s", ErrIdempotencyKeyReused, bill.ID, original.ID)
+	}
+
+	corrections, err := s.repo.ListBillCorrections(ctx, original.ID)
+	if err != nil {
+		slog.Error("failed to list bill corrections", "bill_id", original.ID, "error", err)
+		return nil, err
+	}
+	resp := &CreateAdjustmentBillResponse{BillID: bill.ID, CorrectsBillID: original.ID}
+	for _, correction := range corrections {
+		if correction.AdjustmentBillID == bill.ID {
+			resp.Correction = correction
+		}
+	}
+
+	slog.Info("replayed adjustment bill request", "bill_id", original.ID, "adjustment_bill_id", bill.ID)
+	return resp, nil
+}
+
+// ListBillCorrections returns a bill's correction history.
+func (s *BillService) ListBillCorrections(ctx context.Context, billID string) (*ListBillCorrectionsResponse, error) {
+	if _, err := s.repo.GetBillStatus(ctx, billID); err != nil {
+		slog.Error("failed to get bill status", "bill_id", billID, "error", err)
+		return nil, err
+	}
+	corrections, err := s.repo.ListBillCorrections(ctx, billID)
+	if err != nil {
+		slog.Error("failed to list bill corrections", "bill_id", billID, "error", err)
+		return nil, err
+	}
+	return &ListBillCorrectionsResponse{Corrections: corrections}, nil
+}
+
+// updateWorkflow sends an update to the bill workflow and waits for it to
+// complete, translating validator rejections back into domain errors wrapped
+// in ErrUpdateRejected.
+func (s *BillService) updateWorkflow(ctx context.Context, billID, updateName, updateID string, result interface{}, args ...interface{}) error {
+	handle, err := s.temporal.UpdateWorkflow(ctx, client.UpdateWorkflowOptions{
+		UpdateID:     updateID,
+		WorkflowID:   billID,
+		UpdateName:   updateName,
+		Args:         args,
+		WaitForStage: client.WorkflowUpdateStageCompleted,
+	})
+	if err != nil {
+		if rejection := workflowRejection(err); rejection != nil {
+			return fmt.Errorf("%w: %w", ErrUpdateRejected, rejection)
+		}
+		return fmt.Errorf("failed to update workflow: %w", err)
+	}
+
+	if err := handle.Get(ctx, result); err != nil {
+		if rejection := workflowRejection(err); rejection != nil {
+			return fmt.Errorf("%w: %w", ErrUpdateRejected, rejection)
+		}
+		return fmt.Errorf("failed to update workflow: %w", err)
+	}
+	return nil
+}
+
+func (s *BillService) GetBill(ctx context.Context, billID string) (*GetBillResponse, error) {
+	bill, err := s.repo.GetBillByID(ctx, billID)
+	if err != nil {
+		slog.Error("failed to get bill", "bill_id", billID, "error", err)
+		return nil, err
+	}
+
+	calculatedTotal := bill.CalculateTotal()
+	bill.TotalAmount = calculatedTotal
+	bill.settle()
+
+	slog.Debug("bill retrieved successfully", "bill_id", billID, "status", bill.Status, "calculated_total", calculatedTotal)
+	return &GetBillResponse{Bill: bill, Balance: bill.Balance()}, nil
+}
+
+func (s *BillService) GetLiveBill(ctx context.Context, billID string) (*GetLiveBillResponse, error) {
+	bill, err := s.repo.GetBillByID(ctx, billID)
+	if err != nil {
+		slog.Error("failed to get bill", "bill_id", billID, "error", err)
+		return nil, err
+	}
+
+	value, err := s.temporal.QueryWorkflow(ctx, billID, "", GetBillStateQuery)
+	if err != nil {
+		slog.Error("failed to query bill workflow", "bill_id", billID, "error", err)
+		return nil, fmt.Errorf("failed to query bill workflow: %w", err)
+	}
+
+	var state BillState
+	if err := value.Get(&state); err != nil {
+		slog.Error("failed to decode bill workflow state", "bill_id", billID, "error", err)
+		return nil, fmt.Errorf("failed to decode bill workflow state: %w", err)
+	}
+
+	storedTotal := bill.CalculateTotal()
+
+	storedCount := bill.ActiveItemCount()
+	inSync := storedTotal == state.TotalAmount && storedCount == state.ItemCount
+	if !inSync {
+		slog.Warn("bill workflow state drifted from stored line items",
+			"bill_id", billID,
+			"workflow_total", state.TotalAmount,
+			"stored_total", storedTotal,
+			"workflow_items", state.ItemCount,
+			"stored_items", storedCount)
+	}
+
+	return &GetLiveBillResponse{
+		State:           &state,
+		StoredTotal:     storedTotal,
+		StoredItemCount: storedCount,
+		InSync:          inSync,
+	}, nil
+}
+
+func (s *BillService) ListBills(ctx context.Context, req *ListBillsRequest) (*ListBillsResponse, error) {
+	if err := req.Validate(); err != nil {
+		slog.Error("invalid list bills request", "error", err)
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+
+	bills, err := s.repo.ListBillsByCustomer(ctx, req.CustomerID, req.Status, req.IncludeVoided, req.Limit, req.Offset)
+	if err != nil {
+		slog.Error("failed to list bills", "customer_id", req.CustomerID, "error", err)
+		return nil, fmt.Errorf("failed to list bills: %w", err)
+	}
+
+	billSummaries := make([]*BillSummary, len(bills))
+	for i, bill := range bills {
+		billSummaries[i] = &BillSummary{
+			ID:         bill.ID,
+			CustomerID: bill.CustomerID,
+			Currency:   bill.Currency,
+			Status:     bill.Status,
+			CreatedAt:  bill.CreatedAt,
+		}
+	}
+
+	slog.Debug("bills listed successfully", "customer_id", req.CustomerID, "count", len(bills))
+	return &ListBillsResponse{Bills: billSummaries, Total: len(bills)}, nil
+}
+
+func (s *BillService) ListAllBills(ctx context.Context, req *ListAllBillsRequest) (*ListBillsResponse, error) {
+	if err := req.Validate(); err != nil {
+		slog.Error("invalid list all bills request", "error", err)
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+
+	bills, err := s.repo.ListAllBills(ctx, req.Status, req.IncludeVoided, req.Limit, req.Offset)
+	if err != nil {
+		slog.Error("failed to list all bills", "error", err)
+		return nil, fmt.Errorf("failed to list all bills: %w", err)
+	}
+
+	billSummaries := make([]*BillSummary, len(bills))
+	for i, bill := range bills {
+		billSummaries[i] = &BillSummary{
+			ID:         bill.ID,
+			CustomerID: bill.CustomerID,
+			Currency:   bill.Currency,
+			Status:     bill.Status,
+			CreatedAt:  bill.CreatedAt,
+		}
+	}
+
+	slog.Debug("all bills listed successfully", "count", len(bills))
+	return &ListBillsResponse{Bills: billSummaries, Total: len(bills)}, nil
+}
+
+func (s *BillService) CreateFeeSchedule(ctx context.Context, req *CreateFeeScheduleRequest) (*FeeSchedule, error) {
+	if err := req.Validate(); err != nil {
+		slog.Error("invalid create fee schedule request", "error", err)
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+
+	schedule := req.toSchedule()
+	if err := s.repo.CreateFeeSchedule(ctx, schedule); err != nil {
+		slog.Error("failed to create fee schedule", "fee_schedule_id", req.ID, "error", err)
+		return nil, fmt.Errorf("failed to create fee schedule: %w", err)
+	}
+
+	slog.Info("fee schedule created", "fee_schedule_id", schedule.ID, "version", schedule.Version)
+	return schedule, nil
+}
+
+func (s *BillService) GetFeeSchedule(ctx context.Context, scheduleID string) (*FeeSchedule, error) {
+	schedule, err := s.repo.GetFeeSchedule(ctx, scheduleID)
+	if err != nil {
+		slog.Error("failed to get fee schedule", "fee_schedule_id", scheduleID, "error", err)
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// SaveTaxRule creates the rule or replaces the one with the same ID. Bills
+// closed earlier keep the tax they were charged.
+func (s *BillService) SaveTaxRule(ctx context.Context, req *SaveTaxRuleRequest) (*TaxRule, error) {
+	if err := req.Validate(); err != nil {
+		slog.Error("invalid save tax rule request", "error", err)
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+
+	rule := req.toRule()
+	if err := s.repo.SaveTaxRule(ctx, rule); err != nil {
+		slog.Error("failed to save tax rule", "tax_rule_id", req.ID, "error", err)
+		return nil, fmt.Errorf("failed to save tax rule: %w", err)
+	}
+
+	slog.Info("tax rule saved", "tax_rule_id", rule.ID, "jurisdiction", rule.Jurisdiction, "rate_bps", rule.RateBps)
+	return rule, nil
+}
+
+func (s *BillService) GetTaxRule(ctx context.Context, ruleID string) (*TaxRule, error) {
+	rule, err := s.repo.GetTaxRule(ctx, ruleID)
+	if err != nil {
+		slog.Error("failed to get tax rule", "tax_rule_id", ruleID, "error", err)
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (s *BillService) ListTaxRules(ctx context.Context, req *ListTaxRulesRequest) (*ListTaxRulesResponse, error) {
+	if req.Currency != "" && !req.Currency.IsKnown() {
+		return nil, fmt.Errorf("validation failed: %w: %s", ErrInvalidCurrency, req.Currency)
+	}
+
+	rules, err := s.repo.ListTaxRules(ctx, req.Currency)
+	if err != nil {
+		slog.Error("failed to list tax rules", "currency", req.Currency, "error", err)
+		return nil, fmt.Errorf("failed to list tax rules: %w", err)
+	}
+	if rules == nil {
+		rules = []*TaxRule{}
+	}
+	return &ListTaxRulesResponse{Rules: rules}, nil
+}
+
+func (s *BillService) CreatePromoCode(ctx context.Context, req *CreatePromoCodeRequest) (*PromoCode, error) {
+	if err := req.Validate(); err != nil {
+		slog.Error("invalid create promo code request", "error", err)
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+
+	promo := req.toPromoCode()
+	if err := s.repo.CreatePromoCode(ctx, promo); err != nil {
+		slog.Error("failed to create promo code", "code", promo.Code, "error", err)
+		return nil, err
+	}
+
+	slog.Info("promo code created", "code", promo.Code, "kind", promo.Kind)
+	return promo, nil
+}
+
+func (s *BillService) GetPromoCode(ctx context.Context, code string) (*PromoCode, error) {
+	promo, err := s.repo.GetPromoCode(ctx, NormalizePromoCode(code))
+	if err != nil {
+		slog.Error("failed to get promo code", "code", code, "error", err)
+		return nil, err
+	}
+	return promo, nil
+}
+
+// GetInvoice returns an invoice exactly as it was issued.
+func (s *BillService) GetInvoice(ctx context.Context, number string) (*Invoice, error) {
+	invoice, err := s.repo.GetInvoice(ctx, strings.ToUpper(strings.TrimSpace(number)))
+	if err != nil {
+		slog.Error("failed to get invoice", "invoice_number", number, "error", err)
+		return nil, err
+	}
+	return invoice, nil
+}
+
+// RecordPayment records money received against a closed bill. Partial
+// payments add up; a payment that repeats an external reference already on
+// the bill returns the first one instead of being counted twice.
+func (s *BillService) RecordPayment(ctx context.Context, billID string, req *RecordPaymentRequest) (*RecordPaymentResponse, error) {
+	if err := req.Validate(); err != nil {
+		slog.Error("invalid record payment request", "bill_id", billID, "error", err)
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+
+	bill, err := s.repo.GetBillByID(ctx, billID)
+	if err != nil {
+		slog.Error("failed to get bill for payment", "bill_id", billID, "error", err)
+		return nil, err
+	}
+	if bill.Status != BillStatusClosed {
+		slog.Warn("attempted to record payment on open bill", "bill_id", billID)
+		return nil, ErrBillNotClosed
+	}
+	if req.Currency != "" && req.Currency != bill.Currency {
+		return nil, fmt.Errorf("validation failed: %w: payment is in %s, bill is %s", ErrInvalidPayment, req.Currency, bill.Currency)
+	}
+
+	payment := req.toPayment(bill, time.Now())
+	stored, err := s.repo.RecordPayment(ctx, payment)
+	if err != nil {
+		slog.Error("failed to record payment", "bill_id", billID, "error", err)
+		return nil, err
+	}
+	if stored.ID != payment.ID {
+		if !stored.SamePayment(payment) {
+			slog.Warn("payment reference reused for a different payment", "bill_id", billID,
+				"external_reference", payment.ExternalReference, "payment_id", stored.ID)
+			return nil, fmt.Errorf("%w: %s", ErrPaymentReferenceConflict, payment.ExternalReference)
+		}
+		slog.Info("payment replayed by external reference", "bill_id", billID, "payment_id", stored.ID)
+	}
+
+	bill, err = s.repo.GetBillByID(ctx, billID)
+	if err != nil {
+		slog.Error("failed to get bill after payment", "bill_id", billID, "error", err)
+		return nil, err
+	}
+
+	slog.Info("payment recorded", "bill_id", billID, "payment_id", stored.ID, "amount", stored.Amount,
+		"paid_amount", bill.PaidAmount, "payment_status", bill.PaymentStatus)
+	s.notifyDunning(ctx, bill, stored.ID)
+	return &RecordPaymentResponse{
+		Payment:       stored,
+		PaidAmount:    bill.PaidAmount,
+		Balance:       bill.Balance(),
+		PaymentStatus: bill.PaymentStatus,
+	}, nil
+}
+
+// notifyDunning tells the bill's dunning workflow about a payment so it can
+// stop chasing a settled bill. Failures are only logged: the payment is
+// already stored, and the workflow checks the balance before every reminder.
+func (s *BillService) notifyDunning(ctx context.Context, bill *Bill, paymentID string) {
+	err := s.temporal.SignalWorkflow(ctx, DunningWorkflowID(bill.ID), "", PaymentRecordedSignal, PaymentRecorded{
+		PaymentID:     paymentID,
+		PaidAmount:    bill.PaidAmount,
+		Balance:       bill.Balance(),
+		PaymentStatus: bill.PaymentStatus,
+	})
+	var notFound *serviceerror.NotFound
+	if errors.As(err, &notFound) {
+		slog.Debug("no dunning workflow running for bill", "bill_id", bill.ID)
+		return
+	}
+	if err != nil {
+		slog.Warn("failed to signal dunning workflow", "bill_id", bill.ID, "payment_id", paymentID, "error", err)
+	}
+}
+
+func (s *BillService) ListPayments(ctx context.Context, billID string) (*ListPaymentsResponse, error) {
+	if _, err := s.repo.GetBillByID(ctx, billID); err != nil {
+		slog.Error("failed to get bill", "bill_id", billID, "error", err)
+		return nil, err
+	}
+	payments, err := s.repo.ListPayments(ctx, billID)
+	if err != nil {
+		slog.Error("failed to list payments", "bill_id", billID, "error", err)
+		return nil, err
+	}
+	return &ListPaymentsResponse{Payments: payments}, nil
+}
+
+// RenderInvoice renders a bill's invoice as HTML or PDF. Closed bills render
+// the invoice issued when they closed; open bills render a draft of what
+// they hold so far.
+func (s *BillService) RenderInvoice(ctx context.Context, billID string, format InvoiceFormat) (*RenderedInvoice, error) {
+	bill, err := s.repo.GetBillByID(ctx, billID)
+	if err != nil {
+		slog.Error("failed to get bill", "bill_id", billID, "error", err)
+		return nil, err
+	}
+
+	if bill.Status == BillStatusVoided {
+		return nil, ErrBillVoided
+	}
+
+	invoice := invoiceFromBill(bill, time.Now())
+	if bill.InvoiceNumber != "" {
+		invoice, err = s.repo.GetInvoice(ctx, bill.InvoiceNumber)
+		if err != nil {
+			slog.Error("failed to get invoice", "bill_id", billID, "invoice_number", bill.InvoiceNumber, "error", err)
+			return nil, err
+		}
+	}
+
+	rendered, err := s.renderer.Render(invoice, bill.Status == BillStatusOpen, format)
+	if err != nil {
+		slog.Error("failed to render invoice", "bill_id", billID, "format", format, "error", err)
+		return nil, err
+	}
+	return rendered, nil
+}
+
+// SaveBillingLimits sets a customer's minimum charge and cap for bills in one
+// currency. Bills that set their own limits keep them.
+func (s *BillService) SaveBillingLimits(ctx context.Context, customerID string, req *SaveBillingLimitsRequest) (*CustomerBillingLimits, error) {
+	if strings.TrimSpace(customerID) == "" {
+		return nil, fmt.Errorf("validation failed: %w", ErrEmptyCustomerID)
+	}
+	if err := req.Validate(); err != nil {
+		slog.Error("invalid save billing limits request", "customer_id", customerID, "error", err)
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+
+	limits := req.toCustomerLimits(customerID)
+	if err := s.repo.SaveCustomerBillingLimits(ctx, limits); err != nil {
+		slog.Error("failed to save billing limits", "customer_id", customerID, "currency", req.Currency, "error", err)
+		return nil, fmt.Errorf("failed to save billing limits: %w", err)
+	}
+
+	slog.Info("billing limits saved", "customer_id", limits.CustomerID, "currency", limits.Currency)
+	return limits, nil
+}
+
+func (s *BillService) GetBillingLimits(ctx context.Context, customerID string, currency Currency) (*CustomerBillingLimits, error) {
+	if !currency.IsKnown() {
+		return nil, fmt.Errorf("validation failed: %w: %q", ErrInvalidCurrency, currency)
+	}
+
+	limits, err := s.repo.GetCustomerBillingLimits(ctx, customerID, currency)
+	if err != nil {
+		slog.Error("failed to get billing limits", "customer_id", customerID, "currency", currency, "error", err)
+		return nil, err
+	}
+	return limits, nil
+}
+
+func (s *BillService) CreateMeter(ctx context.Context, req *CreateMeterRequest) (*Meter, error) {
+	if err := req.Validate(); err != nil {
+		slog.Error("invalid create meter request", "error", err)
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+
+	meter := req.toMeter()
+	if err := s.repo.CreateMeter(ctx, meter); err != nil {
+		slog.Error("failed to create meter", "meter", meter.Name, "error", err)
+		return nil, err
+	}
+
+	slog.Info("meter created", "meter", meter.Name, "aggregation", meter.Aggregation)
+	return meter, nil
+}
+
+func (s *BillService) GetMeter(ctx context.Context, name string) (*Meter, error) {
+	meter, err := s.repo.GetMeter(ctx, NormalizeMeterName(name))
+	if err != nil {
+		slog.Error("failed to get meter", "meter", name, "error", err)
+		return nil, err
+	}
+	return meter, nil
+}
+
+// RecordUsage stores a batch of usage events, each on the customer's open
+// bill it belongs to (see RouteUsageEvent). Events no open bill can take are
+// stored FLAGGED rather than rejected, so nothing reported is lost. An
+// unknown meter rejects the whole batch before anything is stored; other
+// failures can leave the batch partly stored, and retrying it with the same
+// idempotency keys fills in the rest.
+func (s *BillService) RecordUsage(ctx context.Context, req *RecordUsageRequest) (*RecordUsageResponse, error) {
+	now := time.Now()
+	if err := req.Validate(now); err != nil {
+		slog.Error("invalid record usage request", "error", err)
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+
+	meters := make(map[string]*Meter)
+	for _, input := range req.Events {
+		name := NormalizeMeterName(input.Meter)
+		if _, ok := meters[name]; ok {
+			continue
+		}
+		meter, err := s.repo.GetMeter(ctx, name)
+		if err != nil {
+			slog.Error("failed to get meter for usage", "meter", name, "error", err)
+			return nil, err
+		}
+		meters[name] = meter
+	}
+
+	openBills := make(map[string][]*Bill)
+	resp := &RecordUsageResponse{Events: make([]*UsageEvent, 0, len(req.Events))}
+	flagged := 0
+	for i := range req.Events {
+		event := req.Events[i].toUsageEvent(now)
+		stored, err := s.recordUsageEvent(ctx, event, meters[event.Meter], openBills)
+		if err != nil {
+			return nil, fmt.Errorf("event %d: %w", i, err)
+		}
+		if stored.Status == UsageEventStatusFlagged {
+			flagged++
+		}
+		resp.Events = append(resp.Events, stored)
+	}
+
+	slog.Info("usage recorded", "events", len(resp.Events), "flagged", flagged)
+	return resp, nil
+}
+
+// recordUsageEvent routes and stores one event. openBills caches each
+// customer's open bills across the batch; if the chosen bill closes before
+// the event is stored, the cache is refreshed and the event routed again.
+func (s *BillService) recordUsageEvent(ctx context.Context, event *UsageEvent, meter *Meter, openBills map[string][]*Bill) (*UsageEvent, error) {
+	for attempt := 1; ; attempt++ {
+		bills, ok := openBills[event.CustomerID]
+		if !ok {
+			status := BillStatusOpen
+			var err error
+			bills, err = s.repo.ListBillsByCustomer(ctx, event.CustomerID, &status, false, maxOpenBillsPerCustomer, 0)
+			if err != nil {
+				slog.Error("failed to list open bills for usage", "customer_id", event.CustomerID, "error", err)
+				return nil, err
+			}
+			openBills[event.CustomerID] = bills
+		}
+		event.assign(meter, bills)
+
+		stored, err := s.repo.RecordUsageEvent(ctx, event)
+		if attempt == 1 && errors.Is(err, ErrBillAlreadyClosed) {
+			slog.Info("bill closed while routing usage, routing again", "bill_id", event.BillID, "event_id", event.ID)
+			delete(openBills, event.CustomerID)
+			continue
+		}
+		if attempt == 1 && errors.Is(err, errDuplicateIdempotencyKey) {
+			// A concurrent request with the same key won the insert.
+			continue
+		}
+		if err != nil {
+			slog.Error("failed to record usage event", "customer_id", event.CustomerID, "meter", event.Meter, "error", err)
+			return nil, err
+		}
+
+		if stored.ID != event.ID {
+			if !stored.SameUsage(event) {
+				return nil, fmt.Errorf("%w: %s", ErrIdempotencyKeyReused, event.IdempotencyKey)
+			}
+			slog.Info("usage event replayed by idempotency key", "event_id", stored.ID)
+		} else if stored.Status == UsageEventStatusFlagged {
+			slog.Warn("usage event flagged", "event_id", stored.ID, "customer_id", stored.CustomerID, "reason", stored.FlagReason)
+		}
+		return stored, nil
+	}
+}
+
+// GetBillUsage shows an open bill's metered usage so far and what it would
+// be charged if the bill closed now. Usage on closed bills is on their
+// line items.
+func (s *BillService) GetBillUsage(ctx context.Context, billID string) (*GetBillUsageResponse, error) {
+	bill, err := s.repo.GetBillByID(ctx, billID)
+	if err != nil {
+		slog.Error("failed to get bill for usage", "bill_id", billID, "error", err)
+		return nil, err
+	}
+
+	usage, err := s.repo.AggregateUsage(ctx, billID)
+	if err != nil {
+		slog.Error("failed to aggregate usage", "bill_id", billID, "error", err)
+		return nil, err
+	}
+	items, err := usage.Price(bill.Currency, time.Now())
+	if err != nil {
+		slog.Error("failed to price usage", "bill_id", billID, "error", err)
+		return nil, err
+	}
+
+	resp := &GetBillUsageResponse{BillID: billID, Meters: usage.Meters, LineItems: items}
+	if resp.Meters == nil {
+		resp.Meters = []MeterUsage{}
+	}
+	if resp.LineItems == nil {
+		resp.LineItems = []LineItem{}
+	}
+	return resp, nil
+}
+
+// ListUsageEvents lists a customer's most recent usage events, e.g. the
+// FLAGGED ones that need looking at.
+func (s *BillService) ListUsageEvents(ctx context.Context, customerID string, params ListUsageEventsParams) (*ListUsageEventsResponse, error) {
+	if params.Status != "" && !params.Status.IsValid() {
+		return nil, fmt.Errorf("validation failed: %w: unknown status %q. Supported statuses: PENDING, BILLED, FLAGGED",
+			ErrInvalidUsageEvent, params.Status)
+	}
+	limit := params.Limit
+	if limit <= 0 {
+		limit = defaultUsageEventsLimit
+	}
+	if limit > maxUsageBatchSize {
+		limit = maxUsageBatchSize
+	}
+
+	events, err := s.repo.ListUsageEvents(ctx, customerID, params.Status, limit)
+	if err != nil {
+		slog.Error("failed to list usage events", "customer_id", customerID, "error", err)
+		return nil, err
+	}
+	return &ListUsageEventsResponse{Events: events}, nil
+}
+
+func (s *BillService) CreateSubscription(ctx context.Context, req *CreateSubscriptionRequest) (*Subscription, error) {
+	if err := req.Validate(); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+
+	now := time.Now()
+	anchorDay := req.AnchorDay
+	if anchorDay == 0 {
+		anchorDay = now.UTC().Day()
+	}
+	sub := &Subscription{
+		ID:         fmt.Sprintf("sub-%s-%d", req.CustomerID, now.UnixNano()),
+		CustomerID: req.CustomerID,
+		Plan:       strings.TrimSpace(req.Plan),
+		PlanAmount: req.PlanAmount,
+		Currency:   req.Currency,
+		AnchorDay:  anchorDay,
+		Status:     SubscriptionStatusActive,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := s.repo.CreateSubscription(ctx, sub); err != nil {
+		slog.Error("failed to create subscription", "customer_id", req.CustomerID, "error", err)
+		return nil, err
+	}
+
+	if err := s.startSubscription(ctx, sub, now); err != nil {
+		return nil, fmt.Errorf("subscription %s was saved but not started, resume it to retry: %w", sub.ID, err)
+	}
+
+	slog.Info("subscription created", "subscription_id", sub.ID, "customer_id", sub.CustomerID, "plan", sub.Plan)
+	return sub, nil
+}
+
+// startSubscription starts the subscription's workflow unless it is already
+// running. One that failed can be started again; one that ended because the
+// subscription was cancelled can't.
+func (s *BillService) startSubscription(ctx context.Context, sub *Subscription, now time.Time) error {
+	options := client.StartWorkflowOptions{
+		ID:                    sub.ID,
+		TaskQueue:             temporal.TaskQueue,
+		WorkflowIDReusePolicy: enumspb.WORKFLOW_ID_REUSE_POLICY_ALLOW_DUPLICATE_FAILED_ONLY,
+	}
+	_, err := s.temporal.ExecuteWorkflow(ctx, options, SubscriptionWorkflow, SubscriptionInput{
+		SubscriptionID: sub.ID,
+		AnchorDay:      sub.AnchorDay,
+		PeriodStart:    sub.nextPeriodStart(now),
+	})
+	var alreadyStarted *serviceerror.WorkflowExecutionAlreadyStarted
+	if err != nil && !errors.As(err, &alreadyStarted) {
+		slog.Error("failed to start subscription workflow", "subscription_id", sub.ID, "error", err)
+		return fmt.Errorf("failed to start subscription workflow: %w", err)
+	}
+	return nil
+}
+
+func (s *BillService) GetSubscription(ctx context.Context, subscriptionID string) (*Subscription, error) {
+	sub, err := s.repo.GetSubscription(ctx, subscriptionID)
+	if err != nil {
+		slog.Error("failed to get subscription", "subscription_id", subscriptionID, "error", err)
+		return nil, err
+	}
+	return sub, nil
+}
+
+// PauseSubscription stops the subscription from billing from its next
+// period on. The bill of the current period still closes as usual.
+func (s *BillService) PauseSubscription(ctx context.Context, subscriptionID string) (*Subscription, error) {
+	return s.changeSubscriptionStatus(ctx, subscriptionID, SubscriptionStatusPaused)
+}
+
+// ResumeSubscription bills a paused subscription again from its next
+// period. It also restarts the workflow of an active subscription that
+// isn't running.
+func (s *BillService) ResumeSubscription(ctx context.Context, subscriptionID string) (*Subscription, error) {
+	sub, err := s.changeSubscriptionStatus(ctx, subscriptionID, SubscriptionStatusActive)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.startSubscription(ctx, sub, time.Now()); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// CancelSubscription stops all future bills. The bill of the current period
+// still closes as usual.
+func (s *BillService) CancelSubscription(ctx context.Context, subscriptionID string) (*Subscription, error) {
+	sub, err := s.changeSubscriptionStatus(ctx, subscriptionID, SubscriptionStatusCancelled)
+	if err != nil {
+		return nil, err
+	}
+
+	// The workflow checks the status before every renewal, so a lost
+	// signal only keeps it around until then.
+	err = s.temporal.SignalWorkflow(ctx, sub.ID, "", CancelSubscriptionSignal, nil)
+	var notFound *serviceerror.NotFound
+	if errors.As(err, &notFound) {
+		slog.Debug("no workflow running for subscription", "subscription_id", sub.ID)
+	} else if err != nil {
+		slog.Warn("failed to signal subscription workflow", "subscription_id", sub.ID, "error", err)
+	}
+	return sub, nil
+}
+
+// changeSubscriptionStatus moves the subscription to status to. Asking for
+// the status it already has changes nothing.
+func (s *BillService) changeSubscriptionStatus(ctx context.Context, subscriptionID string, to SubscriptionStatus) (*Subscription, error) {
+	sub, err := s.repo.GetSubscription(ctx, subscriptionID)
+	if err != nil {
+		slog.Error("failed to get subscription", "subscription_id", subscriptionID, "error", err)
+		return nil, err
+	}
+	if sub.Status == to {
+		return sub, nil
+	}
+	if !sub.Status.CanBecome(to) {
+		return nil, fmt.Errorf("%w: %s subscription cannot become %s", ErrSubscriptionStatusChanged, sub.Status, to)
+	}
+
+	if err := s.repo.UpdateSubscriptionStatus(ctx, subscriptionID, sub.Status, to); err != nil {
+		slog.Error("failed to update subscription status", "subscription_id", subscriptionID, "status", to, "error", err)
+		return nil, err
+	}
+
+	slog.Info("subscription status changed", "subscription_id", subscriptionID, "from", sub.Status, "to", to)
+	sub.Status = to
+	return sub, nil
+}
+
+// ChangeSubscriptionPlan switches the subscription to a new plan or price.
+// If the current period has an open bill, the change is prorated onto it
+// from EffectiveAt to the end of the period. The items and the new plan are
+// saved together; the items then reach the bill workflow like any other.
+func (s *BillService) ChangeSubscriptionPlan(ctx context.Context, subscriptionID string, req *ChangePlanRequest) (*ChangePlanResponse, error) {
+	now := time.Now()
+	if err := req.Validate(now); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+
+	sub, err := s.repo.GetSubscription(ctx, subscriptionID)
+	if err != nil {
+		slog.Error("failed to get subscription", "subscription_id", subscriptionID, "error", err)
+		return nil, err
+	}
+	if sub.Status == SubscriptionStatusCancelled {
+		return nil, fmt.Errorf("%w: cancelled subscription cannot change plan", ErrSubscriptionStatusChanged)
+	}
+
+	plan := strings.TrimSpace(req.Plan)
+	if plan == "" {
+		plan = sub.Plan
+	}
+	resp := &ChangePlanResponse{Subscription: sub}
+	if plan == sub.Plan && req.PlanAmount == sub.PlanAmount {
+		return resp, nil
+	}
+
+	var items []*LineItem
+	if sub.Status == SubscriptionStatusActive && sub.CurrentBillID != "" {
+		granularity := req.Granularity
+		if granularity == "" {
+			granularity = proration.Day
+		}
+		if items, err = s.prorateSubscription(ctx, sub, plan, req.PlanAmount, *req.EffectiveAt, granularity, resp); err != nil {
+			return nil, err
+		}
+	}
+
+	events, err := s.repo.UpdateSubscriptionPlan(ctx, sub.ID, plan, req.PlanAmount, resp.BillID, items)
+	if errors.Is(err, errDuplicateIdempotencyKey) {
+		return nil, fmt.Errorf("%w: plan change at %s is already prorated on bill %s", ErrIdempotencyKeyReused,
+			req.EffectiveAt.UTC().Format(time.RFC3339Nano), resp.BillID)
+	}
+	if err != nil {
+		slog.Error("failed to update subscription plan", "subscription_id", sub.ID, "error", err)
+		return nil, err
+	}
+	for i, event := range events {
+		if _, err := s.sendOutboxUpdate(ctx, event, AddLineItemUpdate, *items[i]); err != nil {
+			return nil, fmt.Errorf("failed to prorate plan change: %w", err)
+		}
+	}
+
+	slog.Info("subscription plan changed", "subscription_id", sub.ID, "from", sub.Plan, "to", plan,
+		"credit_amount", resp.CreditAmount, "charge_amount", resp.ChargeAmount)
+	sub.Plan, sub.PlanAmount = plan, req.PlanAmount
+	return resp, nil
+}
+
+// prorateSubscription prices a plan change at at on the subscription's open
+// bill and returns the credit and charge to add to it.
+func (s *BillService) prorateSubscription(ctx context.Context, sub *Subscription, plan string, planAmount int64,
+	at time.Time, granularity proration.Granularity, resp *ChangePlanResponse) ([]*LineItem, error) {
+	bill, err := s.repo.GetBillByID(ctx, sub.CurrentBillID)
+	if err != nil {
+		slog.Error("failed to get subscription bill", "subscription_id", sub.ID, "bill_id", sub.CurrentBillID, "error", err)
+		return nil, err
+	}
+	if !bill.CanAddLineItem() {
+		return nil, fmt.Errorf("%w: %s", ErrBillAlreadyClosed, bill.ID)
+	}
+	if bill.PeriodStart == nil || bill.PeriodEnd == nil {
+		return nil, fmt.Errorf("%w: bill %s has no billing period to prorate over", ErrInvalidPeriod, bill.ID)
+	}
+
+	period := proration.Period{Start: *bill.PeriodStart, End: *bill.PeriodEnd}
+	change, err := proration.Compute(period, at, sub.PlanAmount, planAmount, granularity, bill.Currency.ProrationRounding())
+	if err != nil {
+		return nil, fmt.Errorf("validation failed: %w: %w", ErrInvalidSubscription, err)
+	}
+
+	var items []*LineItem
+	for _, req := range sub.prorationItems(change, plan, at, period.End) {
+		item, err := req.LineItem(time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("validation failed: %w", err)
+		}
+		item.ID = newLineItemID()
+		items = append(items, &item)
+	}
+
+	resp.BillID = bill.ID
+	resp.CreditAmount = change.Credit
+	resp.ChargeAmount = change.Charge
+	return items, nil
 }
@@ -2,13 +2,20 @@ package fees
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
+	"pave-fees/fees/internal/proration"
+
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/client"
 )
 
 func TestBillService_CreateBill(t *testing.T) {
@@ -28,19 +35,29 @@ func TestBillService_CreateBill(t *testing.T) {
 
 		mockRepo.EXPECT().
 			CreateBill(ctx, gomock.Any()).
-			DoAndReturn(func(ctx context.Context, bill *Bill) error {
+			DoAndReturn(func(ctx context.Context, bill *Bill) (*OutboxEvent, error) {
 				assert.Equal(t, req.CustomerID, bill.CustomerID)
 				assert.Equal(t, req.Currency, bill.Currency)
 				assert.Equal(t, BillStatusOpen, bill.Status)
 				assert.Equal(t, int64(0), bill.TotalAmount)
+				assert.Equal(t, DefaultLegalEntity, bill.LegalEntity)
+				require.NotNil(t, bill.PaymentTermsDays)
+				assert.Equal(t, DefaultPaymentTermsDays, *bill.PaymentTermsDays)
 				assert.NotEmpty(t, bill.ID)
 				assert.True(t, time.Since(bill.CreatedAt) < time.Second)
-				return nil
+				return newTestOutboxEvent(t, 1, bill.ID, OutboxStartBill, bill), nil
 			})
 
 		mockTemporal.EXPECT().
-			ExecuteWorkflow(ctx, gomock.Any(), gomock.Any(), gomock.Any()).
-			Return(nil, nil)
+			ExecuteWorkflow(ctx, gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, options client.StartWorkflowOptions, workflow interface{}, args ...interface{}) (client.WorkflowRun, error) {
+				assert.Contains(t, options.ID, req.CustomerID)
+				return nil, nil
+			})
+
+		mockRepo.EXPECT().
+			MarkOutboxEventDone(ctx, int64(1)).
+			Return(nil)
 
 		response, err := service.CreateBill(ctx, req)
 
@@ -73,7 +90,7 @@ func TestBillService_CreateBill(t *testing.T) {
 
 		mockRepo.EXPECT().
 			CreateBill(ctx, gomock.Any()).
-			Return(errors.New("database error"))
+			Return(nil, errors.New("database error"))
 
 		response, err := service.CreateBill(ctx, req)
 
@@ -82,7 +99,7 @@ func TestBillService_CreateBill(t *testing.T) {
 		assert.Contains(t, err.Error(), "failed to create bill")
 	})
 
-	t.Run("TemporalError", func(t *testing.T) {
+	t.Run("TemporalErrorLeavesEventForRelay", func(t *testing.T) {
 		ctx := context.Background()
 		req := &CreateBillRequest{
 			CustomerID: "customer-123",
@@ -91,17 +108,186 @@ func TestBillService_CreateBill(t *testing.T) {
 
 		mockRepo.EXPECT().
 			CreateBill(ctx, gomock.Any()).
-			Return(nil)
+			DoAndReturn(func(ctx context.Context, bill *Bill) (*OutboxEvent, error) {
+				return newTestOutboxEvent(t, 2, bill.ID, OutboxStartBill, bill), nil
+			})
 
 		mockTemporal.EXPECT().
-			ExecuteWorkflow(ctx, gomock.Any(), gomock.Any(), gomock.Any()).
+			ExecuteWorkflow(ctx, gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
 			Return(nil, errors.New("workflow error"))
 
 		response, err := service.CreateBill(ctx, req)
 
+		require.NoError(t, err)
+		require.NotNil(t, response)
+		assert.NotEmpty(t, response.BillID)
+	})
+
+	t.Run("PinnedFeeSchedule", func(t *testing.T) {
+		ctx := context.Background()
+		req := &CreateBillRequest{
+			CustomerID:    "customer-123",
+			Currency:      USD,
+			FeeScheduleID: "standard",
+		}
+
+		mockRepo.EXPECT().
+			GetFeeSchedule(ctx, "standard").
+			Return(&FeeSchedule{ID: "standard", Version: 1, Currency: USD}, nil)
+
+		mockRepo.EXPECT().
+			CreateBill(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, bill *Bill) (*OutboxEvent, error) {
+				assert.Equal(t, "standard", bill.FeeScheduleID)
+				return newTestOutboxEvent(t, 3, bill.ID, OutboxStartBill, bill), nil
+			})
+
+		mockTemporal.EXPECT().
+			ExecuteWorkflow(ctx, gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(nil, nil)
+
+		mockRepo.EXPECT().
+			MarkOutboxEventDone(ctx, int64(3)).
+			Return(nil)
+
+		response, err := service.CreateBill(ctx, req)
+
+		require.NoError(t, err)
+		require.NotNil(t, response)
+	})
+
+	t.Run("FeeScheduleCurrencyMismatch", func(t *testing.T) {
+		ctx := context.Background()
+		req := &CreateBillRequest{
+			CustomerID:    "customer-123",
+			Currency:      GEL,
+			FeeScheduleID: "standard",
+		}
+
+		mockRepo.EXPECT().
+			GetFeeSchedule(ctx, "standard").
+			Return(&FeeSchedule{ID: "standard", Version: 1, Currency: USD}, nil)
+
+		response, err := service.CreateBill(ctx, req)
+
 		require.Error(t, err)
 		assert.Nil(t, response)
-		assert.Contains(t, err.Error(), "failed to start bill workflow")
+		assert.ErrorIs(t, err, ErrInvalidFeeSchedule)
+	})
+
+	t.Run("FeeScheduleNotFound", func(t *testing.T) {
+		ctx := context.Background()
+		req := &CreateBillRequest{
+			CustomerID:    "customer-123",
+			Currency:      USD,
+			FeeScheduleID: "missing",
+		}
+
+		mockRepo.EXPECT().
+			GetFeeSchedule(ctx, "missing").
+			Return(nil, ErrFeeScheduleNotFound)
+
+		response, err := service.CreateBill(ctx, req)
+
+		assert.ErrorIs(t, err, ErrFeeScheduleNotFound)
+		assert.Nil(t, response)
+	})
+
+	t.Run("IdempotencyKeyStoredOnBill", func(t *testing.T) {
+		ctx := context.Background()
+		req := &CreateBillRequest{
+			CustomerID:     "customer-123",
+			Currency:       USD,
+			IdempotencyKey: "create-1",
+		}
+
+		mockRepo.EXPECT().
+			GetBillByIdempotencyKey(ctx, "customer-123", "create-1").
+			Return(nil, ErrBillNotFound)
+
+		mockRepo.EXPECT().
+			CreateBill(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, bill *Bill) (*OutboxEvent, error) {
+				assert.Equal(t, "create-1", bill.IdempotencyKey)
+				return newTestOutboxEvent(t, 4, bill.ID, OutboxStartBill, bill), nil
+			})
+
+		mockTemporal.EXPECT().
+			ExecuteWorkflow(ctx, gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(nil, nil)
+
+		mockRepo.EXPECT().
+			MarkOutboxEventDone(ctx, int64(4)).
+			Return(nil)
+
+		response, err := service.CreateBill(ctx, req)
+
+		require.NoError(t, err)
+		require.NotNil(t, response)
+	})
+
+	t.Run("IdempotencyKeyReplay", func(t *testing.T) {
+		ctx := context.Background()
+		req := &CreateBillRequest{
+			CustomerID:     "customer-123",
+			Currency:       USD,
+			IdempotencyKey: "create-1",
+		}
+
+		mockRepo.EXPECT().
+			GetBillByIdempotencyKey(ctx, "customer-123", "create-1").
+			Return(&Bill{ID: "bill-first", CustomerID: "customer-123", Currency: USD}, nil)
+
+		response, err := service.CreateBill(ctx, req)
+
+		require.NoError(t, err)
+		require.NotNil(t, response)
+		assert.Equal(t, "bill-first", response.BillID)
+	})
+
+	t.Run("IdempotencyKeyReusedForDifferentCurrency", func(t *testing.T) {
+		ctx := context.Background()
+		req := &CreateBillRequest{
+			CustomerID:     "customer-123",
+			Currency:       GEL,
+			IdempotencyKey: "create-1",
+		}
+
+		mockRepo.EXPECT().
+			GetBillByIdempotencyKey(ctx, "customer-123", "create-1").
+			Return(&Bill{ID: "bill-first", CustomerID: "customer-123", Currency: USD}, nil)
+
+		response, err := service.CreateBill(ctx, req)
+
+		assert.ErrorIs(t, err, ErrIdempotencyKeyReused)
+		assert.Nil(t, response)
+	})
+
+	t.Run("IdempotencyKeyRace", func(t *testing.T) {
+		ctx := context.Background()
+		req := &CreateBillRequest{
+			CustomerID:     "customer-123",
+			Currency:       USD,
+			IdempotencyKey: "create-2",
+		}
+
+		gomock.InOrder(
+			mockRepo.EXPECT().
+				GetBillByIdempotencyKey(ctx, "customer-123", "create-2").
+				Return(nil, ErrBillNotFound),
+			mockRepo.EXPECT().
+				CreateBill(ctx, gomock.Any()).
+				Return(nil, errDuplicateIdempotencyKey),
+			mockRepo.EXPECT().
+				GetBillByIdempotencyKey(ctx, "customer-123", "create-2").
+				Return(&Bill{ID: "bill-winner", CustomerID: "customer-123", Currency: USD}, nil),
+		)
+
+		response, err := service.CreateBill(ctx, req)
+
+		require.NoError(t, err)
+		require.NotNil(t, response)
+		assert.Equal(t, "bill-winner", response.BillID)
 	})
 }
 
@@ -127,20 +313,84 @@ func TestBillService_AddLineItem(t *testing.T) {
 
 		mockRepo.EXPECT().
 			AddLineItem(ctx, billID, gomock.Any()).
-			DoAndReturn(func(ctx context.Context, billID string, item *LineItem) error {
+			DoAndReturn(func(ctx context.Context, billID string, item *LineItem) (*OutboxEvent, error) {
 				assert.Equal(t, req.Description, item.Description)
 				assert.Equal(t, req.Amount, item.Amount)
 				assert.True(t, time.Since(item.Timestamp) < time.Second)
-				return nil
+				return newTestOutboxEvent(t, 7, billID, OutboxAddLineItem, item), nil
+			})
+
+		mockTemporal.EXPECT().
+			UpdateWorkflow(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, options client.UpdateWorkflowOptions) (client.WorkflowUpdateHandle, error) {
+				assert.Equal(t, billID, options.WorkflowID)
+				assert.Equal(t, AddLineItemUpdate, options.UpdateName)
+				assert.Equal(t, "outbox-7", options.UpdateID)
+				assert.Equal(t, client.WorkflowUpdateStageCompleted, options.WaitForStage)
+				require.Len(t, options.Args, 1)
+				item := options.Args[0].(LineItem)
+				assert.Equal(t, req.Amount, item.Amount)
+				return fakeUpdateHandle{result: BillState{
+					LineItems:   []LineItem{item},
+					ItemCount:   1,
+					TotalAmount: 1000,
+				}}, nil
+			})
+
+		mockRepo.EXPECT().
+			MarkOutboxEventDone(ctx, int64(7)).
+			Return(nil)
+
+		response, err := service.AddLineItem(ctx, billID, req)
+
+		require.NoError(t, err)
+		require.NotNil(t, response)
+		assert.True(t, strings.HasPrefix(response.ItemID, "item-"))
+		assert.Equal(t, 1, response.LineItemCount)
+		assert.Equal(t, int64(1000), response.TotalAmount)
+	})
+
+	t.Run("QuantityAndUnitPrice", func(t *testing.T) {
+		ctx := context.Background()
+		billID := "bill-123"
+		req := &AddLineItemRequest{
+			Description: "API calls",
+			Quantity:    quantityPtr(12 * QuantityScale),
+			UnitPrice:   int64Ptr(5),
+		}
+
+		mockRepo.EXPECT().
+			GetBillStatus(ctx, billID).
+			Return(BillStatusOpen, nil)
+
+		mockRepo.EXPECT().
+			AddLineItem(ctx, billID, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, billID string, item *LineItem) (*OutboxEvent, error) {
+				assert.Equal(t, int64(60), item.Amount)
+				assert.Equal(t, Quantity(12*QuantityScale), item.Quantity)
+				assert.Equal(t, int64(5), item.UnitPrice)
+				return newTestOutboxEvent(t, 8, billID, OutboxAddLineItem, item), nil
 			})
 
 		mockTemporal.EXPECT().
-			SignalWorkflow(ctx, billID, "", AddLineItemSignal, gomock.Any()).
+			UpdateWorkflow(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, options client.UpdateWorkflowOptions) (client.WorkflowUpdateHandle, error) {
+				item := options.Args[0].(LineItem)
+				assert.NoError(t, item.Validate())
+				return fakeUpdateHandle{result: BillState{
+					LineItems:   []LineItem{item},
+					TotalAmount: item.Amount,
+				}}, nil
+			})
+
+		mockRepo.EXPECT().
+			MarkOutboxEventDone(ctx, int64(8)).
 			Return(nil)
 
-		err := service.AddLineItem(ctx, billID, req)
+		response, err := service.AddLineItem(ctx, billID, req)
 
 		require.NoError(t, err)
+		assert.Equal(t, int64(60), response.TotalAmount)
 	})
 
 	t.Run("ValidationError", func(t *testing.T) {
@@ -151,7 +401,7 @@ func TestBillService_AddLineItem(t *testing.T) {
 			Amount:      1000,
 		}
 
-		err := service.AddLineItem(ctx, billID, req)
+		_, err := service.AddLineItem(ctx, billID, req)
 
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "validation failed")
@@ -169,7 +419,7 @@ func TestBillService_AddLineItem(t *testing.T) {
 			GetBillStatus(ctx, billID).
 			Return(BillStatusOpen, ErrBillNotFound)
 
-		err := service.AddLineItem(ctx, billID, req)
+		_, err := service.AddLineItem(ctx, billID, req)
 
 		require.Error(t, err)
 		assert.ErrorIs(t, err, ErrBillNotFound)
@@ -187,13 +437,24 @@ func TestBillService_AddLineItem(t *testing.T) {
 			GetBillStatus(ctx, billID).
 			Return(BillStatusClosed, nil)
 
-		err := service.AddLineItem(ctx, billID, req)
+		_, err := service.AddLineItem(ctx, billID, req)
 
 		require.Error(t, err)
 		assert.ErrorIs(t, err, ErrBillAlreadyClosed)
 	})
 
-	t.Run("RepositoryError", func(t *testing.T) {
+	t.Run("BillVoided", func(t *testing.T) {
+		ctx := context.Background()
+		mockRepo.EXPECT().
+			GetBillStatus(ctx, "bill-123").
+			Return(BillStatusVoided, nil)
+
+		_, err := service.AddLineItem(ctx, "bill-123", &AddLineItemRequest{Description: "Test item", Amount: 1000})
+
+		assert.ErrorIs(t, err, ErrBillVoided)
+	})
+
+	t.Run("RejectedByWorkflow", func(t *testing.T) {
 		ctx := context.Background()
 		billID := "bill-123"
 		req := &AddLineItemRequest{
@@ -207,15 +468,24 @@ func TestBillService_AddLineItem(t *testing.T) {
 
 		mockRepo.EXPECT().
 			AddLineItem(ctx, billID, gomock.Any()).
-			Return(errors.New("database error"))
+			Return(&OutboxEvent{ID: 8, BillID: billID, Kind: OutboxAddLineItem}, nil)
+
+		mockTemporal.EXPECT().
+			UpdateWorkflow(ctx, gomock.Any()).
+			Return(fakeUpdateHandle{err: toWorkflowError(ErrBillAlreadyClosed)}, nil)
+
+		mockRepo.EXPECT().
+			MarkOutboxEventFailed(ctx, int64(8), gomock.Any()).
+			Return(nil)
 
-		err := service.AddLineItem(ctx, billID, req)
+		_, err := service.AddLineItem(ctx, billID, req)
 
 		require.Error(t, err)
-		assert.Contains(t, err.Error(), "failed to save line item")
+		assert.ErrorIs(t, err, ErrBillAlreadyClosed)
+		assert.ErrorIs(t, err, ErrUpdateRejected)
 	})
 
-	t.Run("SignalError", func(t *testing.T) {
+	t.Run("RepositoryError", func(t *testing.T) {
 		ctx := context.Background()
 		billID := "bill-123"
 		req := &AddLineItemRequest{
@@ -229,154 +499,365 @@ func TestBillService_AddLineItem(t *testing.T) {
 
 		mockRepo.EXPECT().
 			AddLineItem(ctx, billID, gomock.Any()).
-			Return(nil)
-
-		mockTemporal.EXPECT().
-			SignalWorkflow(ctx, billID, "", AddLineItemSignal, gomock.Any()).
-			Return(errors.New("signal error"))
+			Return(nil, errors.New("database error"))
 
-		err := service.AddLineItem(ctx, billID, req)
+		_, err := service.AddLineItem(ctx, billID, req)
 
 		require.Error(t, err)
-		assert.Contains(t, err.Error(), "failed to signal workflow")
+		assert.Contains(t, err.Error(), "failed to save line item")
 	})
-}
-
-func TestBillService_CloseBill(t *testing.T) {
-	ctrl := gomock.NewController(t)
-	defer ctrl.Finish()
-
-	mockRepo := NewMockRepositoryInterface(ctrl)
-	mockTemporal := NewMockTemporalClientInterface(ctrl)
-	service := NewBillService(mockRepo, mockTemporal)
 
-	t.Run("Success", func(t *testing.T) {
+	t.Run("WorkflowUnavailableQueuesItem", func(t *testing.T) {
 		ctx := context.Background()
 		billID := "bill-123"
-
-		bill := &Bill{
-			ID:     billID,
-			Status: BillStatusOpen,
+		req := &AddLineItemRequest{
+			Description: "Test item",
+			Amount:      1000,
 		}
 
 		mockRepo.EXPECT().
-			GetBillByID(ctx, billID).
-			Return(bill, nil)
+			GetBillStatus(ctx, billID).
+			Return(BillStatusOpen, nil)
+
+		mockRepo.EXPECT().
+			AddLineItem(ctx, billID, gomock.Any()).
+			Return(&OutboxEvent{ID: 9, BillID: billID, Kind: OutboxAddLineItem}, nil)
 
 		mockTemporal.EXPECT().
-			SignalWorkflow(ctx, billID, "", CloseBillSignal, gomock.Any()).
-			Return(nil)
+			UpdateWorkflow(ctx, gomock.Any()).
+			Return(nil, errors.New("update error"))
 
-		err := service.CloseBill(ctx, billID)
+		response, err := service.AddLineItem(ctx, billID, req)
 
 		require.NoError(t, err)
+		require.NotNil(t, response)
+		assert.True(t, response.Queued)
 	})
 
-	t.Run("BillNotFound", func(t *testing.T) {
+	t.Run("IdempotencyKeyReplay", func(t *testing.T) {
 		ctx := context.Background()
-		billID := "nonexistent-bill"
+		billID := "bill-123"
+		req := &AddLineItemRequest{
+			Description:    "Test item",
+			Amount:         1000,
+			IdempotencyKey: "add-1",
+		}
+		existing := LineItem{
+			ID:             "item-first",
+			Description:    "Test item",
+			Amount:         1000,
+			Quantity:       QuantityOne,
+			UnitPrice:      1000,
+			Kind:           LineItemKindCharge,
+			IdempotencyKey: "add-1",
+		}
+
+		mockRepo.EXPECT().
+			GetLineItemByIdempotencyKey(ctx, billID, "add-1").
+			Return(&existing, nil)
 
 		mockRepo.EXPECT().
 			GetBillByID(ctx, billID).
-			Return(nil, ErrBillNotFound)
+			Return(&Bill{ID: billID, Status: BillStatusOpen, LineItems: []LineItem{
+				existing,
+				{ID: "item-other", Description: "Other", Amount: 500},
+			}}, nil)
 
-		err := service.CloseBill(ctx, billID)
+		response, err := service.AddLineItem(ctx, billID, req)
 
-		require.Error(t, err)
-		assert.ErrorIs(t, err, ErrBillNotFound)
+		require.NoError(t, err)
+		require.NotNil(t, response)
+		assert.Equal(t, "item-first", response.ItemID)
+		assert.Equal(t, 2, response.LineItemCount)
+		assert.Equal(t, int64(1500), response.TotalAmount)
+		assert.False(t, response.Queued)
 	})
 
-	t.Run("BillAlreadyClosed", func(t *testing.T) {
+	t.Run("IdempotencyKeyReusedForDifferentItem", func(t *testing.T) {
 		ctx := context.Background()
 		billID := "bill-123"
-
-		bill := &Bill{
-			ID:     billID,
-			Status: BillStatusClosed,
+		req := &AddLineItemRequest{
+			Description:    "Test item",
+			Amount:         2000,
+			IdempotencyKey: "add-1",
 		}
 
+		mockRepo.EXPECT().
+			GetLineItemByIdempotencyKey(ctx, billID, "add-1").
+			Return(&LineItem{
+				ID:          "item-first",
+				Description: "Test item",
+				Amount:      1000,
+				Quantity:    QuantityOne,
+				UnitPrice:   1000,
+				Kind:        LineItemKindCharge,
+			}, nil)
+
 		mockRepo.EXPECT().
 			GetBillByID(ctx, billID).
-			Return(bill, nil)
+			Return(&Bill{ID: billID, Status: BillStatusOpen}, nil)
 
-		err := service.CloseBill(ctx, billID)
+		response, err := service.AddLineItem(ctx, billID, req)
 
-		require.Error(t, err)
-		assert.ErrorIs(t, err, ErrBillAlreadyClosed)
+		assert.ErrorIs(t, err, ErrIdempotencyKeyReused)
+		assert.Nil(t, response)
 	})
 
-	t.Run("SignalError", func(t *testing.T) {
+	t.Run("IdempotencyKeyFirstUse", func(t *testing.T) {
 		ctx := context.Background()
 		billID := "bill-123"
-
-		bill := &Bill{
-			ID:     billID,
-			Status: BillStatusOpen,
+		req := &AddLineItemRequest{
+			Description:    "Test item",
+			Amount:         1000,
+			IdempotencyKey: "add-2",
 		}
 
 		mockRepo.EXPECT().
-			GetBillByID(ctx, billID).
-			Return(bill, nil)
+			GetLineItemByIdempotencyKey(ctx, billID, "add-2").
+			Return(nil, ErrLineItemNotFound)
+
+		mockRepo.EXPECT().
+			GetBillStatus(ctx, billID).
+			Return(BillStatusOpen, nil)
+
+		mockRepo.EXPECT().
+			AddLineItem(ctx, billID, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, billID string, item *LineItem) (*OutboxEvent, error) {
+				assert.Equal(t, "add-2", item.IdempotencyKey)
+				return &OutboxEvent{ID: 10, BillID: billID, Kind: OutboxAddLineItem}, nil
+			})
 
 		mockTemporal.EXPECT().
-			SignalWorkflow(ctx, billID, "", CloseBillSignal, gomock.Any()).
-			Return(errors.New("signal error"))
+			UpdateWorkflow(ctx, gomock.Any()).
+			Return(nil, errors.New("update error"))
 
-		err := service.CloseBill(ctx, billID)
+		response, err := service.AddLineItem(ctx, billID, req)
 
-		require.Error(t, err)
-		assert.Contains(t, err.Error(), "failed to signal bill to close")
+		require.NoError(t, err)
+		require.NotNil(t, response)
+		assert.True(t, response.Queued)
 	})
 }
 
-func TestBillService_GetBill(t *testing.T) {
+func TestBillService_AddLineItem_ForeignCurrency(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockRepo := NewMockRepositoryInterface(ctrl)
 	mockTemporal := NewMockTemporalClientInterface(ctrl)
-	service := NewBillService(mockRepo, mockTemporal)
+	mockRates := NewMockRateProvider(ctrl)
+	service := NewBillService(mockRepo, mockTemporal, WithRateProvider(mockRates))
 
-	t.Run("Success", func(t *testing.T) {
-		ctx := context.Background()
-		billID := "bill-123"
+	rateAt := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
 
-		expectedBill := &Bill{
-			ID:          billID,
-			CustomerID:  "customer-456",
+	t.Run("ConvertsIntoBillCurrency", func(t *testing.T) {
+		ctx := context.Background()
+		billID := "bill-gel"
+		req := &AddLineItemRequest{
+			Description: "Hosting (USD invoice)",
+			Amount:      1000,
 			Currency:    USD,
-			Status:      BillStatusOpen,
-			TotalAmount: 1500,
 		}
 
 		mockRepo.EXPECT().
 			GetBillByID(ctx, billID).
-			Return(expectedBill, nil)
-
-		response, err := service.GetBill(ctx, billID)
-
-		require.NoError(t, err)
-		require.NotNil(t, response)
-		assert.Equal(t, expectedBill, response.Bill)
-	})
+			Return(&Bill{ID: billID, Currency: GEL, Status: BillStatusOpen}, nil)
 
-	t.Run("BillNotFound", func(t *testing.T) {
-		ctx := context.Background()
-		billID := "nonexistent-bill"
+		mockRates.EXPECT().
+			Rate(ctx, USD, GEL, gomock.Any()).
+			Return(&ExchangeRate{Base: USD, Quote: GEL, Rate: 271530000, AsOf: rateAt}, nil)
 
 		mockRepo.EXPECT().
-			GetBillByID(ctx, billID).
-			Return(nil, ErrBillNotFound)
+			GetBillStatus(ctx, billID).
+			Return(BillStatusOpen, nil)
 
-		response, err := service.GetBill(ctx, billID)
+		mockRepo.EXPECT().
+			AddLineItem(ctx, billID, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, billID string, item *LineItem) (*OutboxEvent, error) {
+				assert.Equal(t, int64(2715), item.Amount)
+				require.NotNil(t, item.FX)
+				assert.Equal(t, USD, item.FX.Currency)
+				assert.Equal(t, int64(1000), item.FX.Amount)
+				assert.Equal(t, Rate(271530000), item.FX.Rate)
+				assert.Equal(t, rateAt, item.FX.RateAt)
+				return &OutboxEvent{ID: 11, BillID: billID, Kind: OutboxAddLineItem}, nil
+			})
 
-		require.Error(t, err)
+		mockTemporal.EXPECT().
+			UpdateWorkflow(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, options client.UpdateWorkflowOptions) (client.WorkflowUpdateHandle, error) {
+				item := options.Args[0].(LineItem)
+				return fakeUpdateHandle{result: BillState{
+					LineItems:   []LineItem{item},
+					TotalAmount: item.Amount,
+				}}, nil
+			})
+
+		mockRepo.EXPECT().
+			MarkOutboxEventDone(ctx, int64(11)).
+			Return(nil)
+
+		response, err := service.AddLineItem(ctx, billID, req)
+
+		require.NoError(t, err)
+		assert.Equal(t, int64(2715), response.TotalAmount)
+	})
+
+	t.Run("SameCurrencyIsNotConverted", func(t *testing.T) {
+		ctx := context.Background()
+		billID := "bill-gel"
+		req := &AddLineItemRequest{
+			Description: "Local item",
+			Amount:      500,
+			Currency:    GEL,
+		}
+
+		mockRepo.EXPECT().
+			GetBillByID(ctx, billID).
+			Return(&Bill{ID: billID, Currency: GEL, Status: BillStatusOpen}, nil)
+
+		mockRepo.EXPECT().
+			GetBillStatus(ctx, billID).
+			Return(BillStatusOpen, nil)
+
+		mockRepo.EXPECT().
+			AddLineItem(ctx, billID, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, billID string, item *LineItem) (*OutboxEvent, error) {
+				assert.Equal(t, int64(500), item.Amount)
+				assert.Nil(t, item.FX)
+				return &OutboxEvent{ID: 12, BillID: billID, Kind: OutboxAddLineItem}, nil
+			})
+
+		mockTemporal.EXPECT().
+			UpdateWorkflow(ctx, gomock.Any()).
+			Return(nil, errors.New("update error"))
+
+		response, err := service.AddLineItem(ctx, billID, req)
+
+		require.NoError(t, err)
+		assert.True(t, response.Queued)
+	})
+
+	t.Run("ReplayDoesNotFetchRate", func(t *testing.T) {
+		ctx := context.Background()
+		billID := "bill-gel"
+		req := &AddLineItemRequest{
+			Description:    "Hosting (USD invoice)",
+			Amount:         1000,
+			Currency:       USD,
+			IdempotencyKey: "add-usd",
+		}
+		existing := LineItem{
+			ID:             "item-usd",
+			Description:    "Hosting (USD invoice)",
+			Amount:         2715,
+			Quantity:       QuantityOne,
+			UnitPrice:      2715,
+			Kind:           LineItemKindCharge,
+			IdempotencyKey: "add-usd",
+			FX: &FXConversion{
+				Currency:  USD,
+				Amount:    1000,
+				Quantity:  QuantityOne,
+				UnitPrice: 1000,
+				Rate:      271530000,
+				RateAt:    rateAt,
+			},
+		}
+
+		mockRepo.EXPECT().
+			GetLineItemByIdempotencyKey(ctx, billID, "add-usd").
+			Return(&existing, nil)
+
+		// The rate source may be down or have moved on; a retry mustn't ask it.
+		mockRepo.EXPECT().
+			GetBillByID(ctx, billID).
+			Return(&Bill{ID: billID, Currency: GEL, Status: BillStatusOpen, LineItems: []LineItem{existing}}, nil)
+
+		response, err := service.AddLineItem(ctx, billID, req)
+
+		require.NoError(t, err)
+		assert.Equal(t, "item-usd", response.ItemID)
+		assert.Equal(t, int64(2715), response.TotalAmount)
+	})
+
+	t.Run("ReplaySameCurrency", func(t *testing.T) {
+		ctx := context.Background()
+		billID := "bill-gel"
+		req := &AddLineItemRequest{
+			Description:    "Local item",
+			Amount:         500,
+			Currency:       GEL,
+			IdempotencyKey: "add-gel",
+		}
+		existing := LineItem{
+			ID:             "item-gel",
+			Description:    "Local item",
+			Amount:         500,
+			Quantity:       QuantityOne,
+			UnitPrice:      500,
+			Kind:           LineItemKindCharge,
+			IdempotencyKey: "add-gel",
+		}
+
+		mockRepo.EXPECT().
+			GetLineItemByIdempotencyKey(ctx, billID, "add-gel").
+			Return(&existing, nil)
+
+		mockRepo.EXPECT().
+			GetBillByID(ctx, billID).
+			Return(&Bill{ID: billID, Currency: GEL, Status: BillStatusOpen, LineItems: []LineItem{existing}}, nil)
+
+		response, err := service.AddLineItem(ctx, billID, req)
+
+		require.NoError(t, err)
+		assert.Equal(t, "item-gel", response.ItemID)
+	})
+
+	t.Run("RateNotFound", func(t *testing.T) {
+		ctx := context.Background()
+		billID := "bill-gel"
+		req := &AddLineItemRequest{
+			Description: "Hosting (EUR invoice)",
+			Amount:      1000,
+			Currency:    "EUR",
+		}
+
+		mockRepo.EXPECT().
+			GetBillByID(ctx, billID).
+			Return(&Bill{ID: billID, Currency: GEL, Status: BillStatusOpen}, nil)
+
+		mockRates.EXPECT().
+			Rate(ctx, Currency("EUR"), GEL, gomock.Any()).
+			Return(nil, ErrRateNotFound)
+
+		response, err := service.AddLineItem(ctx, billID, req)
+
+		assert.ErrorIs(t, err, ErrRateNotFound)
+		assert.Nil(t, response)
+	})
+
+	t.Run("DefaultProviderHasNoRates", func(t *testing.T) {
+		ctx := context.Background()
+		billID := "bill-gel"
+		req := &AddLineItemRequest{
+			Description: "Hosting (USD invoice)",
+			Amount:      1000,
+			Currency:    USD,
+		}
+
+		mockRepo.EXPECT().
+			GetBillByID(ctx, billID).
+			Return(&Bill{ID: billID, Currency: GEL, Status: BillStatusOpen}, nil)
+
+		response, err := NewBillService(mockRepo, mockTemporal).AddLineItem(ctx, billID, req)
+
+		assert.ErrorIs(t, err, ErrRateNotFound)
 		assert.Nil(t, response)
-		assert.ErrorIs(t, err, ErrBillNotFound)
 	})
 }
 
-func TestBillService_ListBills(t *testing.T) {
+func TestBillService_VoidLineItem(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
@@ -386,68 +867,123 @@ func TestBillService_ListBills(t *testing.T) {
 
 	t.Run("Success", func(t *testing.T) {
 		ctx := context.Background()
-		req := &ListBillsRequest{
-			CustomerID: "customer-123",
-			Limit:      10,
-			Offset:     0,
-		}
+		billID := "bill-123"
 
-		expectedBills := []*Bill{
-			{ID: "bill-1", CustomerID: "customer-123", Status: BillStatusOpen, Currency: USD},
-			{ID: "bill-2", CustomerID: "customer-123", Status: BillStatusClosed, Currency: USD},
-		}
+		mockRepo.EXPECT().
+			GetBillStatus(ctx, billID).
+			Return(BillStatusOpen, nil)
 
-		expectedSummaries := []*BillSummary{
-			{ID: "bill-1", CustomerID: "customer-123", Status: BillStatusOpen, Currency: USD},
-			{ID: "bill-2", CustomerID: "customer-123", Status: BillStatusClosed, Currency: USD},
-		}
+		mockRepo.EXPECT().
+			VoidLineItem(ctx, billID, "item-1", "duplicate").
+			Return(newTestOutboxEvent(t, 20, billID, OutboxVoidLineItem, LineItemVoid{ItemID: "item-1", Reason: "duplicate", Amount: 500}), nil)
+
+		mockTemporal.EXPECT().
+			UpdateWorkflow(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, options client.UpdateWorkflowOptions) (client.WorkflowUpdateHandle, error) {
+				assert.Equal(t, VoidLineItemUpdate, options.UpdateName)
+				assert.Equal(t, "outbox-20", options.UpdateID)
+				require.Len(t, options.Args, 1)
+				assert.Equal(t, LineItemVoid{ItemID: "item-1", Reason: "duplicate", Amount: 500}, options.Args[0])
+				return fakeUpdateHandle{result: BillState{
+					LineItems:   []LineItem{{ID: "item-2", Description: "Kept", Amount: 300}},
+					ItemCount:   1,
+					TotalAmount: 300,
+				}}, nil
+			})
 
 		mockRepo.EXPECT().
-			ListBillsByCustomer(ctx, req.CustomerID, req.Status, req.Limit, req.Offset).
-			Return(expectedBills, nil)
+			MarkOutboxEventDone(ctx, int64(20)).
+			Return(nil)
 
-		response, err := service.ListBills(ctx, req)
+		response, err := service.VoidLineItem(ctx, billID, "item-1", "duplicate")
 
 		require.NoError(t, err)
-		require.NotNil(t, response)
-		assert.Equal(t, expectedSummaries, response.Bills)
-		assert.Equal(t, len(expectedBills), response.Total)
+		assert.Equal(t, "item-1", response.ItemID)
+		assert.Equal(t, 1, response.LineItemCount)
+		assert.Equal(t, int64(300), response.TotalAmount)
+		assert.False(t, response.Queued)
 	})
 
-	t.Run("ValidationError", func(t *testing.T) {
+	t.Run("BillClosed", func(t *testing.T) {
 		ctx := context.Background()
-		req := &ListBillsRequest{
-			CustomerID: "",
-		}
+		billID := "bill-closed"
 
-		response, err := service.ListBills(ctx, req)
+		mockRepo.EXPECT().
+			GetBillStatus(ctx, billID).
+			Return(BillStatusClosed, nil)
 
-		require.Error(t, err)
-		assert.Nil(t, response)
-		assert.Contains(t, err.Error(), "validation failed")
+		_, err := service.VoidLineItem(ctx, billID, "item-1", "")
+
+		assert.ErrorIs(t, err, ErrBillAlreadyClosed)
 	})
 
-	t.Run("RepositoryError", func(t *testing.T) {
+	t.Run("ItemNotFound", func(t *testing.T) {
 		ctx := context.Background()
-		req := &ListBillsRequest{
-			CustomerID: "customer-123",
-			Limit:      10,
-			Offset:     0,
-		}
+		billID := "bill-123"
 
 		mockRepo.EXPECT().
-			ListBillsByCustomer(ctx, req.CustomerID, req.Status, req.Limit, req.Offset).
-			Return(nil, errors.New("database error"))
+			GetBillStatus(ctx, billID).
+			Return(BillStatusOpen, nil)
 
-		response, err := service.ListBills(ctx, req)
+		mockRepo.EXPECT().
+			VoidLineItem(ctx, billID, "item-missing", "").
+			Return(nil, ErrLineItemNotFound)
 
-		require.Error(t, err)
-		assert.Nil(t, response)
-		assert.Contains(t, err.Error(), "failed to list bills")
+		_, err := service.VoidLineItem(ctx, billID, "item-missing", "")
+
+		assert.ErrorIs(t, err, ErrLineItemNotFound)
+	})
+
+	t.Run("RejectedByWorkflowRestoresItem", func(t *testing.T) {
+		ctx := context.Background()
+		billID := "bill-123"
+
+		mockRepo.EXPECT().
+			GetBillStatus(ctx, billID).
+			Return(BillStatusOpen, nil)
+
+		mockRepo.EXPECT().
+			VoidLineItem(ctx, billID, "item-1", "").
+			Return(newTestOutboxEvent(t, 21, billID, OutboxVoidLineItem, LineItemVoid{ItemID: "item-1"}), nil)
+
+		mockTemporal.EXPECT().
+			UpdateWorkflow(ctx, gomock.Any()).
+			Return(fakeUpdateHandle{err: toWorkflowError(ErrNegativeTotal)}, nil)
+
+		mockRepo.EXPECT().
+			MarkOutboxEventFailed(ctx, int64(21), gomock.Any()).
+			Return(nil)
+
+		_, err := service.VoidLineItem(ctx, billID, "item-1", "")
+
+		assert.ErrorIs(t, err, ErrUpdateRejected)
+		assert.ErrorIs(t, err, ErrNegativeTotal)
+	})
+
+	t.Run("WorkflowUnavailableQueuesVoid", func(t *testing.T) {
+		ctx := context.Background()
+		billID := "bill-123"
+
+		mockRepo.EXPECT().
+			GetBillStatus(ctx, billID).
+			Return(BillStatusOpen, nil)
+
+		mockRepo.EXPECT().
+			VoidLineItem(ctx, billID, "item-1", "").
+			Return(newTestOutboxEvent(t, 22, billID, OutboxVoidLineItem, LineItemVoid{ItemID: "item-1"}), nil)
+
+		mockTemporal.EXPECT().
+			UpdateWorkflow(ctx, gomock.Any()).
+			Return(nil, errors.New("update error"))
+
+		response, err := service.VoidLineItem(ctx, billID, "item-1", "")
+
+		require.NoError(t, err)
+		assert.True(t, response.Queued)
 	})
 }
 
-func TestBillService_ListAllBills(t *testing.T) {
+func TestBillService_CloseBill(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
@@ -455,65 +991,1891 @@ func TestBillService_ListAllBills(t *testing.T) {
 	mockTemporal := NewMockTemporalClientInterface(ctrl)
 	service := NewBillService(mockRepo, mockTemporal)
 
-	t.Run("success", func(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
 		ctx := context.Background()
-		req := &ListAllBillsRequest{
-			Status: nil,
-			Limit:  50,
-			Offset: 0,
-		}
-
-		expectedBills := []*Bill{
-			{ID: "bill-1", CustomerID: "customer-1", Currency: USD, Status: BillStatusOpen},
-			{ID: "bill-2", CustomerID: "customer-2", Currency: GEL, Status: BillStatusClosed},
-		}
+		billID := "bill-123"
 
-		expectedSummaries := []*BillSummary{
-			{ID: "bill-1", CustomerID: "customer-1", Currency: USD, Status: BillStatusOpen},
-			{ID: "bill-2", CustomerID: "customer-2", Currency: GEL, Status: BillStatusClosed},
+		bill := &Bill{
+			ID:     billID,
+			Status: BillStatusOpen,
 		}
 
 		mockRepo.EXPECT().
-			ListAllBills(ctx, req.Status, req.Limit, req.Offset).
-			Return(expectedBills, nil)
+			GetBillByID(ctx, billID).
+			Return(bill, nil)
 
-		response, err := service.ListAllBills(ctx, req)
+		mockTemporal.EXPECT().
+			UpdateWorkflow(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, options client.UpdateWorkflowOptions) (client.WorkflowUpdateHandle, error) {
+				assert.Equal(t, billID, options.WorkflowID)
+				assert.Equal(t, CloseBillUpdate, options.UpdateName)
+				return fakeUpdateHandle{result: BillState{TotalAmount: 2500, IsClosed: true}}, nil
+			})
 
-		assert.NoError(t, err)
-		assert.NotNil(t, response)
-		assert.Equal(t, expectedSummaries, response.Bills)
-		assert.Equal(t, len(expectedBills), response.Total)
+		response, err := service.CloseBill(ctx, billID)
+
+		require.NoError(t, err)
+		require.NotNil(t, response)
+		assert.Equal(t, BillStatusClosed, response.Status)
+		assert.Equal(t, int64(2500), response.TotalAmount)
 	})
 
-	t.Run("validation error", func(t *testing.T) {
+	t.Run("BillNotFound", func(t *testing.T) {
 		ctx := context.Background()
-		req := &ListAllBillsRequest{
-			Limit: 1001,
-		}
+		billID := "nonexistent-bill"
 
-		response, err := service.ListAllBills(ctx, req)
+		mockRepo.EXPECT().
+			GetBillByID(ctx, billID).
+			Return(nil, ErrBillNotFound)
 
-		assert.Error(t, err)
-		assert.Nil(t, response)
-		assert.Contains(t, err.Error(), "validation failed")
+		_, err := service.CloseBill(ctx, billID)
+
+		require.Error(t, err)
+		assert.ErrorIs(t, err, ErrBillNotFound)
 	})
 
-	t.Run("repository error", func(t *testing.T) {
+	t.Run("BillAlreadyClosed", func(t *testing.T) {
 		ctx := context.Background()
-		req := &ListAllBillsRequest{
-			Status: nil,
-			Limit:  50,
-			Offset: 0,
+		billID := "bill-123"
+
+		bill := &Bill{
+			ID:     billID,
+			Status: BillStatusClosed,
 		}
 
 		mockRepo.EXPECT().
-			ListAllBills(ctx, req.Status, req.Limit, req.Offset).
-			Return(nil, errors.New("database error"))
+			GetBillByID(ctx, billID).
+			Return(bill, nil)
 
-		response, err := service.ListAllBills(ctx, req)
+		_, err := service.CloseBill(ctx, billID)
 
-		assert.Error(t, err)
-		assert.Nil(t, response)
-		assert.Contains(t, err.Error(), "failed to list all bills")
+		require.Error(t, err)
+		assert.ErrorIs(t, err, ErrBillAlreadyClosed)
+	})
+
+	t.Run("RejectedByWorkflow", func(t *testing.T) {
+		ctx := context.Background()
+		billID := "bill-123"
+
+		bill := &Bill{
+			ID:     billID,
+			Status: BillStatusOpen,
+		}
+
+		mockRepo.EXPECT().
+			GetBillByID(ctx, billID).
+			Return(bill, nil)
+
+		mockTemporal.EXPECT().
+			UpdateWorkflow(ctx, gomock.Any()).
+			Return(nil, toWorkflowError(ErrBillAlreadyClosed))
+
+		_, err := service.CloseBill(ctx, billID)
+
+		require.Error(t, err)
+		assert.ErrorIs(t, err, ErrBillAlreadyClosed)
+	})
+
+	t.Run("UpdateError", func(t *testing.T) {
+		ctx := context.Background()
+		billID := "bill-123"
+
+		bill := &Bill{
+			ID:     billID,
+			Status: BillStatusOpen,
+		}
+
+		mockRepo.EXPECT().
+			GetBillByID(ctx, billID).
+			Return(bill, nil)
+
+		mockTemporal.EXPECT().
+			UpdateWorkflow(ctx, gomock.Any()).
+			Return(nil, errors.New("update error"))
+
+		_, err := service.CloseBill(ctx, billID)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to update workflow")
+	})
+}
+
+func TestBillService_VoidBill(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := NewMockRepositoryInterface(ctrl)
+	mockTemporal := NewMockTemporalClientInterface(ctrl)
+	service := NewBillService(mockRepo, mockTemporal)
+
+	t.Run("Success", func(t *testing.T) {
+		ctx := context.Background()
+		billID := "bill-123"
+
+		mockRepo.EXPECT().
+			GetBillStatus(ctx, billID).
+			Return(BillStatusOpen, nil)
+
+		mockTemporal.EXPECT().
+			UpdateWorkflow(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, options client.UpdateWorkflowOptions) (client.WorkflowUpdateHandle, error) {
+				assert.Equal(t, billID, options.WorkflowID)
+				assert.Equal(t, VoidBillUpdate, options.UpdateName)
+				assert.Equal(t, []interface{}{BillVoid{Reason: "Created by mistake"}}, options.Args)
+				return fakeUpdateHandle{result: BillState{IsClosed: true, IsVoided: true, VoidReason: "Created by mistake"}}, nil
+			})
+
+		response, err := service.VoidBill(ctx, billID, &VoidBillRequest{Reason: "  Created by mistake "})
+
+		require.NoError(t, err)
+		assert.Equal(t, &VoidBillResponse{BillID: billID, Status: BillStatusVoided, VoidReason: "Created by mistake"}, response)
+	})
+
+	t.Run("ReasonRequired", func(t *testing.T) {
+		_, err := service.VoidBill(context.Background(), "bill-123", &VoidBillRequest{Reason: " "})
+
+		assert.ErrorIs(t, err, ErrInvalidVoidReason)
+	})
+
+	t.Run("BillNotOpen", func(t *testing.T) {
+		tests := []struct {
+			status  BillStatus
+			wantErr error
+		}{
+			{BillStatusClosed, ErrBillAlreadyClosed},
+			{BillStatusVoided, ErrBillVoided},
+		}
+
+		for _, tt := range tests {
+			t.Run(string(tt.status), func(t *testing.T) {
+				ctx := context.Background()
+				mockRepo.EXPECT().
+					GetBillStatus(ctx, "bill-123").
+					Return(tt.status, nil)
+
+				_, err := service.VoidBill(ctx, "bill-123", &VoidBillRequest{Reason: "Created by mistake"})
+
+				assert.ErrorIs(t, err, tt.wantErr)
+			})
+		}
+	})
+
+	t.Run("RejectedByWorkflow", func(t *testing.T) {
+		ctx := context.Background()
+		billID := "bill-123"
+
+		mockRepo.EXPECT().
+			GetBillStatus(ctx, billID).
+			Return(BillStatusOpen, nil)
+
+		mockTemporal.EXPECT().
+			UpdateWorkflow(ctx, gomock.Any()).
+			Return(nil, toWorkflowError(ErrBillAlreadyClosed))
+
+		_, err := service.VoidBill(ctx, billID, &VoidBillRequest{Reason: "Created by mistake"})
+
+		assert.ErrorIs(t, err, ErrUpdateRejected)
+		assert.ErrorIs(t, err, ErrBillAlreadyClosed)
+	})
+}
+
+func TestBillService_ReopenBill(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := NewMockRepositoryInterface(ctrl)
+	mockTemporal := NewMockTemporalClientInterface(ctrl)
+	service := NewBillService(mockRepo, mockTemporal, WithReopenGracePeriod(24*time.Hour))
+
+	finance := &Operator{ID: "nino", Roles: []OperatorRole{OperatorRoleFinance}}
+	closedAt := time.Now().Add(-time.Hour)
+	closedBill := func() *Bill {
+		return &Bill{ID: "bill-123", CustomerID: "customer-1", Currency: GEL, Status: BillStatusClosed,
+			TotalAmount: 11800, InvoiceNumber: "PAVE-000007", ClosedAt: &closedAt}
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		ctx := context.Background()
+		mockRepo.EXPECT().
+			GetBillByID(ctx, "bill-123").
+			Return(closedBill(), nil)
+
+		mockRepo.EXPECT().
+			ReopenBill(ctx, "bill-123", gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, billID string, closedAfter time.Time, correction *BillCorrection) (*OutboxEvent, error) {
+				assert.WithinDuration(t, time.Now().Add(-24*time.Hour), closedAfter, time.Minute)
+				assert.Equal(t, CorrectionReopened, correction.Action)
+				assert.Equal(t, "nino", correction.OperatorID)
+				assert.Equal(t, "Usage was still arriving", correction.Reason)
+				assert.Equal(t, "PAVE-000007", correction.PreviousInvoiceNumber)
+				assert.Equal(t, int64(11800), correction.PreviousTotalAmount)
+				correction.ID = 1
+				return newTestOutboxEvent(t, 42, billID, OutboxReopenBill, BillReopen{Bill: *closedBill(), Progress: reopenProgress(nil)}), nil
+			})
+
+		// A delivery failure leaves the event for the relay.
+		mockTemporal.EXPECT().
+			CancelWorkflow(ctx, gomock.Any(), gomock.Any()).
+			Return(errors.New("temporal unavailable"))
+
+		response, err := service.ReopenBill(ctx, finance, "bill-123", &ReopenBillRequest{Reason: " Usage was still arriving "})
+
+		require.NoError(t, err)
+		assert.Equal(t, "bill-123", response.BillID)
+		assert.Equal(t, BillStatusOpen, response.Status)
+		assert.Equal(t, int64(1), response.Correction.ID)
+	})
+
+	t.Run("PermissionDenied", func(t *testing.T) {
+		for _, operator := range []*Operator{nil, {ID: "giorgi"}} {
+			_, err := service.ReopenBill(context.Background(), operator, "bill-123", &ReopenBillRequest{Reason: "Closed early"})
+
+			assert.ErrorIs(t, err, ErrPermissionDenied)
+		}
+	})
+
+	t.Run("ReasonRequired", func(t *testing.T) {
+		_, err := service.ReopenBill(context.Background(), finance, "bill-123", &ReopenBillRequest{})
+
+		assert.ErrorIs(t, err, ErrInvalidCorrectionReason)
+	})
+
+	t.Run("NotReopenable", func(t *testing.T) {
+		longAgo := time.Now().Add(-25 * time.Hour)
+		tests := []struct {
+			name    string
+			change  func(*Bill)
+			wantErr error
+		}{
+			{"open", func(b *Bill) { b.Status = BillStatusOpen }, ErrBillNotClosed},
+			{"voided", func(b *Bill) { b.Status = BillStatusVoided }, ErrBillVoided},
+			{"paid", func(b *Bill) { b.PaidAmount = 500 }, ErrBillHasPayments},
+			{"window passed", func(b *Bill) { b.ClosedAt = &longAgo }, ErrReopenWindowPassed},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				bill := closedBill()
+				tt.change(bill)
+				mockRepo.EXPECT().
+					GetBillByID(ctx, "bill-123").
+					Return(bill, nil)
+
+				_, err := service.ReopenBill(ctx, finance, "bill-123", &ReopenBillRequest{Reason: "Closed early"})
+
+				assert.ErrorIs(t, err, tt.wantErr)
+			})
+		}
+	})
+
+	t.Run("PaidWhileReopening", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().
+			GetBillByID(ctx, "bill-123").
+			Return(closedBill(), nil)
+		mockRepo.EXPECT().
+			ReopenBill(ctx, "bill-123", gomock.Any(), gomock.Any()).
+			Return(nil, ErrBillHasPayments)
+
+		_, err := service.ReopenBill(ctx, finance, "bill-123", &ReopenBillRequest{Reason: "Closed early"})
+
+		assert.ErrorIs(t, err, ErrBillHasPayments)
+	})
+}
+
+func TestBillService_CreateAdjustmentBill(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := NewMockRepositoryInterface(ctrl)
+	mockTemporal := NewMockTemporalClientInterface(ctrl)
+	service := NewBillService(mockRepo, mockTemporal)
+
+	finance := &Operator{ID: "nino", Roles: []OperatorRole{OperatorRoleFinance}}
+	terms := 14
+	original := &Bill{ID: "bill-123", CustomerID: "customer-1", Currency: GEL, Status: BillStatusClosed,
+		TotalAmount: 11800, PaidAmount: 11800, InvoiceNumber: "PAVE-000007", LegalEntity: "PAVEGE", PaymentTermsDays: &terms}
+
+	t.Run("Success", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().
+			GetBillByID(ctx, "bill-123").
+			Return(original, nil)
+
+		var created *Bill
+		mockRepo.EXPECT().
+			CreateAdjustmentBill(ctx, gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, bill *Bill, correction *BillCorrection) (*OutboxEvent, error) {
+				created = bill
+				assert.Equal(t, "customer-1", bill.CustomerID)
+				assert.Equal(t, GEL, bill.Currency)
+				assert.Equal(t, BillStatusOpen, bill.Status)
+				assert.Equal(t, "bill-123", bill.CorrectsBillID)
+				assert.Equal(t, "PAVEGE", bill.LegalEntity)
+				assert.Equal(t, &terms, bill.PaymentTermsDays)
+				assert.True(t, bill.AllowNegativeTotal)
+				assert.Nil(t, bill.PeriodEnd)
+
+				assert.Equal(t, CorrectionAdjustmentIssued, correction.Action)
+				assert.Equal(t, "bill-123", correction.BillID)
+				assert.Equal(t, bill.ID, correction.AdjustmentBillID)
+				assert.Equal(t, "nino", correction.OperatorID)
+				assert.Equal(t, "PAVE-000007", correction.PreviousInvoiceNumber)
+				return newTestOutboxEvent(t, 43, bill.ID, OutboxStartBill, bill), nil
+			})
+
+		mockTemporal.EXPECT().
+			ExecuteWorkflow(ctx, gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(nil, nil)
+		mockRepo.EXPECT().MarkOutboxEventDone(ctx, int64(43)).Return(nil)
+
+		response, err := service.CreateAdjustmentBill(ctx, finance, "bill-123", &CreateAdjustmentBillRequest{Reason: "Refund overcharge"})
+
+		require.NoError(t, err)
+		assert.Equal(t, created.ID, response.BillID)
+		assert.Equal(t, "bill-123", response.CorrectsBillID)
+		assert.Equal(t, "Refund overcharge", response.Correction.Reason)
+	})
+
+	t.Run("IdempotencyKeyStoredOnBill", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().
+			GetBillByID(ctx, "bill-123").
+			Return(original, nil)
+		mockRepo.EXPECT().
+			GetBillByIdempotencyKey(ctx, "customer-1", "adjust-1").
+			Return(nil, ErrBillNotFound)
+		mockRepo.EXPECT().
+			CreateAdjustmentBill(ctx, gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, bill *Bill, correction *BillCorrection) (*OutboxEvent, error) {
+				assert.Equal(t, "adjust-1", bill.IdempotencyKey)
+				return newTestOutboxEvent(t, 44, bill.ID, OutboxStartBill, bill), nil
+			})
+		mockTemporal.EXPECT().
+			ExecuteWorkflow(ctx, gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(nil, nil)
+		mockRepo.EXPECT().MarkOutboxEventDone(ctx, int64(44)).Return(nil)
+
+		_, err := service.CreateAdjustmentBill(ctx, finance, "bill-123",
+			&CreateAdjustmentBillRequest{Reason: "Refund overcharge", IdempotencyKey: "adjust-1"})
+
+		require.NoError(t, err)
+	})
+
+	t.Run("IdempotencyKeyReplay", func(t *testing.T) {
+		ctx := context.Background()
+		correction := &BillCorrection{ID: 5, BillID: "bill-123", Action: CorrectionAdjustmentIssued,
+			Reason: "Refund overcharge", AdjustmentBillID: "bill-adjust"}
+
+		mockRepo.EXPECT().
+			GetBillByID(ctx, "bill-123").
+			Return(original, nil)
+		mockRepo.EXPECT().
+			GetBillByIdempotencyKey(ctx, "customer-1", "adjust-1").
+			Return(&Bill{ID: "bill-adjust", CustomerID: "customer-1", CorrectsBillID: "bill-123"}, nil)
+		mockRepo.EXPECT().
+			ListBillCorrections(ctx, "bill-123").
+			Return([]*BillCorrection{correction}, nil)
+
+		response, err := service.CreateAdjustmentBill(ctx, finance, "bill-123",
+			&CreateAdjustmentBillRequest{Reason: "Refund overcharge", IdempotencyKey: "adjust-1"})
+
+		require.NoError(t, err)
+		assert.Equal(t, "bill-adjust", response.BillID)
+		assert.Equal(t, "bill-123", response.CorrectsBillID)
+		assert.Equal(t, correction, response.Correction)
+	})
+
+	t.Run("IdempotencyKeyReusedForDifferentBill", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().
+			GetBillByID(ctx, "bill-123").
+			Return(original, nil)
+		mockRepo.EXPECT().
+			GetBillByIdempotencyKey(ctx, "customer-1", "adjust-1").
+			Return(&Bill{ID: "bill-other", CustomerID: "customer-1"}, nil)
+
+		response, err := service.CreateAdjustmentBill(ctx, finance, "bill-123",
+			&CreateAdjustmentBillRequest{Reason: "Refund overcharge", IdempotencyKey: "adjust-1"})
+
+		assert.ErrorIs(t, err, ErrIdempotencyKeyReused)
+		assert.Nil(t, response)
+	})
+
+	t.Run("PermissionDenied", func(t *testing.T) {
+		_, err := service.CreateAdjustmentBill(context.Background(), &Operator{ID: "giorgi"}, "bill-123",
+			&CreateAdjustmentBillRequest{Reason: "Refund overcharge"})
+
+		assert.ErrorIs(t, err, ErrPermissionDenied)
+	})
+
+	t.Run("BillNotClosed", func(t *testing.T) {
+		tests := []struct {
+			status  BillStatus
+			wantErr error
+		}{
+			{BillStatusOpen, ErrBillNotClosed},
+			{BillStatusVoided, ErrBillVoided},
+		}
+
+		for _, tt := range tests {
+			t.Run(string(tt.status), func(t *testing.T) {
+				ctx := context.Background()
+				mockRepo.EXPECT().
+					GetBillByID(ctx, "bill-123").
+					Return(&Bill{ID: "bill-123", Status: tt.status}, nil)
+
+				_, err := service.CreateAdjustmentBill(ctx, finance, "bill-123", &CreateAdjustmentBillRequest{Reason: "Refund overcharge"})
+
+				assert.ErrorIs(t, err, tt.wantErr)
+			})
+		}
+	})
+}
+
+func TestBillService_ListBillCorrections(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := NewMockRepositoryInterface(ctrl)
+	mockTemporal := NewMockTemporalClientInterface(ctrl)
+	service := NewBillService(mockRepo, mockTemporal)
+
+	t.Run("Success", func(t *testing.T) {
+		ctx := context.Background()
+		corrections := []*BillCorrection{
+			{ID: 1, BillID: "bill-123", Action: CorrectionReopened, OperatorID: "nino", Reason: "Closed early"},
+			{ID: 2, BillID: "bill-123", Action: CorrectionAdjustmentIssued, OperatorID: "nino", Reason: "Refund", AdjustmentBillID: "bill-456"},
+		}
+
+		mockRepo.EXPECT().GetBillStatus(ctx, "bill-123").Return(BillStatusClosed, nil)
+		mockRepo.EXPECT().ListBillCorrections(ctx, "bill-123").Return(corrections, nil)
+
+		response, err := service.ListBillCorrections(ctx, "bill-123")
+
+		require.NoError(t, err)
+		assert.Equal(t, corrections, response.Corrections)
+	})
+
+	t.Run("BillNotFound", func(t *testing.T) {
+		ctx := context.Background()
+		mockRepo.EXPECT().GetBillStatus(ctx, "missing").Return(BillStatus(""), ErrBillNotFound)
+
+		_, err := service.ListBillCorrections(ctx, "missing")
+
+		assert.ErrorIs(t, err, ErrBillNotFound)
+	})
+}
+
+func TestBillService_GetBill(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := NewMockRepositoryInterface(ctrl)
+	mockTemporal := NewMockTemporalClientInterface(ctrl)
+	service := NewBillService(mockRepo, mockTemporal)
+
+	t.Run("Success", func(t *testing.T) {
+		ctx := context.Background()
+		billID := "bill-123"
+
+		expectedBill := &Bill{
+			ID:          billID,
+			CustomerID:  "customer-456",
+			Currency:    USD,
+			Status:      BillStatusOpen,
+			TotalAmount: 1500,
+		}
+
+		mockRepo.EXPECT().
+			GetBillByID(ctx, billID).
+			Return(expectedBill, nil)
+
+		response, err := service.GetBill(ctx, billID)
+
+		require.NoError(t, err)
+		require.NotNil(t, response)
+		assert.Equal(t, expectedBill, response.Bill)
+	})
+
+	t.Run("BillNotFound", func(t *testing.T) {
+		ctx := context.Background()
+		billID := "nonexistent-bill"
+
+		mockRepo.EXPECT().
+			GetBillByID(ctx, billID).
+			Return(nil, ErrBillNotFound)
+
+		response, err := service.GetBill(ctx, billID)
+
+		require.Error(t, err)
+		assert.Nil(t, response)
+		assert.ErrorIs(t, err, ErrBillNotFound)
+	})
+}
+
+type fakeEncodedValue struct {
+	value interface{}
+}
+
+func (f fakeEncodedValue) HasValue() bool {
+	return f.value != nil
+}
+
+func (f fakeEncodedValue) Get(valuePtr interface{}) error {
+	data, err := json.Marshal(f.value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, valuePtr)
+}
+
+type fakeUpdateHandle struct {
+	result interface{}
+	err    error
+}
+
+func (f fakeUpdateHandle) WorkflowID() string { return "" }
+
+func (f fakeUpdateHandle) RunID() string { return "" }
+
+func (f fakeUpdateHandle) UpdateID() string { return "" }
+
+func (f fakeUpdateHandle) Get(ctx context.Context, valuePtr interface{}) error {
+	if f.err != nil {
+		return f.err
+	}
+	return fakeEncodedValue{value: f.result}.Get(valuePtr)
+}
+
+func TestBillService_GetLiveBill(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := NewMockRepositoryInterface(ctrl)
+	mockTemporal := NewMockTemporalClientInterface(ctrl)
+	service := NewBillService(mockRepo, mockTemporal)
+
+	storedBill := &Bill{
+		ID:       "bill-123",
+		Currency: USD,
+		Status:   BillStatusOpen,
+		LineItems: []LineItem{
+			{Description: "Item 1", Amount: 1000},
+			{Description: "Item 2", Amount: 500},
+		},
+	}
+
+	t.Run("InSync", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().
+			GetBillByID(ctx, "bill-123").
+			Return(storedBill, nil)
+
+		mockTemporal.EXPECT().
+			QueryWorkflow(ctx, "bill-123", "", GetBillStateQuery).
+			Return(fakeEncodedValue{value: BillState{
+				BillID:      "bill-123",
+				LineItems:   storedBill.LineItems,
+				ItemCount:   2,
+				TotalAmount: 1500,
+			}}, nil)
+
+		response, err := service.GetLiveBill(ctx, "bill-123")
+
+		require.NoError(t, err)
+		assert.Equal(t, int64(1500), response.State.TotalAmount)
+		assert.Equal(t, int64(1500), response.StoredTotal)
+		assert.Equal(t, 2, response.StoredItemCount)
+		assert.True(t, response.InSync)
+	})
+
+	t.Run("Drift", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().
+			GetBillByID(ctx, "bill-123").
+			Return(storedBill, nil)
+
+		mockTemporal.EXPECT().
+			QueryWorkflow(ctx, "bill-123", "", GetBillStateQuery).
+			Return(fakeEncodedValue{value: BillState{
+				BillID:      "bill-123",
+				LineItems:   storedBill.LineItems[:1],
+				ItemCount:   1,
+				TotalAmount: 1000,
+			}}, nil)
+
+		response, err := service.GetLiveBill(ctx, "bill-123")
+
+		require.NoError(t, err)
+		assert.Equal(t, int64(1000), response.State.TotalAmount)
+		assert.Equal(t, int64(1500), response.StoredTotal)
+		assert.False(t, response.InSync)
+	})
+
+	t.Run("BillNotFound", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().
+			GetBillByID(ctx, "nonexistent-bill").
+			Return(nil, ErrBillNotFound)
+
+		response, err := service.GetLiveBill(ctx, "nonexistent-bill")
+
+		require.Error(t, err)
+		assert.Nil(t, response)
+		assert.ErrorIs(t, err, ErrBillNotFound)
+	})
+
+	t.Run("QueryError", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().
+			GetBillByID(ctx, "bill-123").
+			Return(storedBill, nil)
+
+		mockTemporal.EXPECT().
+			QueryWorkflow(ctx, "bill-123", "", GetBillStateQuery).
+			Return(nil, errors.New("query error"))
+
+		response, err := service.GetLiveBill(ctx, "bill-123")
+
+		require.Error(t, err)
+		assert.Nil(t, response)
+		assert.Contains(t, err.Error(), "failed to query bill workflow")
+	})
+}
+
+func TestBillService_ListBills(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := NewMockRepositoryInterface(ctrl)
+	mockTemporal := NewMockTemporalClientInterface(ctrl)
+	service := NewBillService(mockRepo, mockTemporal)
+
+	t.Run("Success", func(t *testing.T) {
+		ctx := context.Background()
+		req := &ListBillsRequest{
+			CustomerID: "customer-123",
+			Limit:      10,
+			Offset:     0,
+		}
+
+		expectedBills := []*Bill{
+			{ID: "bill-1", CustomerID: "customer-123", Status: BillStatusOpen, Currency: USD},
+			{ID: "bill-2", CustomerID: "customer-123", Status: BillStatusClosed, Currency: USD},
+		}
+
+		expectedSummaries := []*BillSummary{
+			{ID: "bill-1", CustomerID: "customer-123", Status: BillStatusOpen, Currency: USD},
+			{ID: "bill-2", CustomerID: "customer-123", Status: BillStatusClosed, Currency: USD},
+		}
+
+		mockRepo.EXPECT().
+			ListBillsByCustomer(ctx, req.CustomerID, req.Status, false, req.Limit, req.Offset).
+			Return(expectedBills, nil)
+
+		response, err := service.ListBills(ctx, req)
+
+		require.NoError(t, err)
+		require.NotNil(t, response)
+		assert.Equal(t, expectedSummaries, response.Bills)
+		assert.Equal(t, len(expectedBills), response.Total)
+	})
+
+	t.Run("IncludeVoided", func(t *testing.T) {
+		ctx := context.Background()
+		voided := BillStatusVoided
+		for _, req := range []*ListBillsRequest{
+			{CustomerID: "customer-123", IncludeVoided: true},
+			{CustomerID: "customer-123", Status: &voided},
+		} {
+			mockRepo.EXPECT().
+				ListBillsByCustomer(ctx, "customer-123", req.Status, req.IncludeVoided, 10, 0).
+				Return([]*Bill{{ID: "bill-3", CustomerID: "customer-123", Status: BillStatusVoided, Currency: USD}}, nil)
+
+			response, err := service.ListBills(ctx, req)
+
+			require.NoError(t, err)
+			require.Len(t, response.Bills, 1)
+			assert.Equal(t, BillStatusVoided, response.Bills[0].Status)
+		}
+	})
+
+	t.Run("ValidationError", func(t *testing.T) {
+		ctx := context.Background()
+		req := &ListBillsRequest{
+			CustomerID: "",
+		}
+
+		response, err := service.ListBills(ctx, req)
+
+		require.Error(t, err)
+		assert.Nil(t, response)
+		assert.Contains(t, err.Error(), "validation failed")
+	})
+
+	t.Run("RepositoryError", func(t *testing.T) {
+		ctx := context.Background()
+		req := &ListBillsRequest{
+			CustomerID: "customer-123",
+			Limit:      10,
+			Offset:     0,
+		}
+
+		mockRepo.EXPECT().
+			ListBillsByCustomer(ctx, req.CustomerID, req.Status, false, req.Limit, req.Offset).
+			Return(nil, errors.New("database error"))
+
+		response, err := service.ListBills(ctx, req)
+
+		require.Error(t, err)
+		assert.Nil(t, response)
+		assert.Contains(t, err.Error(), "failed to list bills")
+	})
+}
+
+func TestBillService_ListAllBills(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := NewMockRepositoryInterface(ctrl)
+	mockTemporal := NewMockTemporalClientInterface(ctrl)
+	service := NewBillService(mockRepo, mockTemporal)
+
+	t.Run("success", func(t *testing.T) {
+		ctx := context.Background()
+		req := &ListAllBillsRequest{
+			Status: nil,
+			Limit:  50,
+			Offset: 0,
+		}
+
+		expectedBills := []*Bill{
+			{ID: "bill-1", CustomerID: "customer-1", Currency: USD, Status: BillStatusOpen},
+			{ID: "bill-2", CustomerID: "customer-2", Currency: GEL, Status: BillStatusClosed},
+		}
+
+		expectedSummaries := []*BillSummary{
+			{ID: "bill-1", CustomerID: "customer-1", Currency: USD, Status: BillStatusOpen},
+			{ID: "bill-2", CustomerID: "customer-2", Currency: GEL, Status: BillStatusClosed},
+		}
+
+		mockRepo.EXPECT().
+			ListAllBills(ctx, req.Status, false, req.Limit, req.Offset).
+			Return(expectedBills, nil)
+
+		response, err := service.ListAllBills(ctx, req)
+
+		assert.NoError(t, err)
+		assert.NotNil(t, response)
+		assert.Equal(t, expectedSummaries, response.Bills)
+		assert.Equal(t, len(expectedBills), response.Total)
+	})
+
+	t.Run("validation error", func(t *testing.T) {
+		ctx := context.Background()
+		req := &ListAllBillsRequest{
+			Limit: 1001,
+		}
+
+		response, err := service.ListAllBills(ctx, req)
+
+		assert.Error(t, err)
+		assert.Nil(t, response)
+		assert.Contains(t, err.Error(), "validation failed")
+	})
+
+	t.Run("repository error", func(t *testing.T) {
+		ctx := context.Background()
+		req := &ListAllBillsRequest{
+			Status: nil,
+			Limit:  50,
+			Offset: 0,
+		}
+
+		mockRepo.EXPECT().
+			ListAllBills(ctx, req.Status, false, req.Limit, req.Offset).
+			Return(nil, errors.New("database error"))
+
+		response, err := service.ListAllBills(ctx, req)
+
+		assert.Error(t, err)
+		assert.Nil(t, response)
+		assert.Contains(t, err.Error(), "failed to list all bills")
+	})
+}
+
+func TestBillService_TaxRules(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := NewMockRepositoryInterface(ctrl)
+	mockTemporal := NewMockTemporalClientInterface(ctrl)
+	service := NewBillService(mockRepo, mockTemporal)
+
+	t.Run("Save", func(t *testing.T) {
+		ctx := context.Background()
+		req := &SaveTaxRuleRequest{
+			ID:              "ge-vat",
+			Name:            "VAT",
+			Jurisdiction:    "GE",
+			Currency:        GEL,
+			RateBps:         1800,
+			ExemptCustomers: []string{"customer-ngo"},
+		}
+
+		mockRepo.EXPECT().
+			SaveTaxRule(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, rule *TaxRule) error {
+				assert.Equal(t, int64(1800), rule.RateBps)
+				assert.True(t, rule.Exempts("customer-ngo"))
+				return nil
+			})
+
+		rule, err := service.SaveTaxRule(ctx, req)
+
+		require.NoError(t, err)
+		assert.Equal(t, "ge-vat", rule.ID)
+	})
+
+	t.Run("SaveValidationError", func(t *testing.T) {
+		rule, err := service.SaveTaxRule(context.Background(), &SaveTaxRuleRequest{ID: "bad", Name: "Bad", Jurisdiction: "GE", Currency: GEL, RateBps: -5})
+
+		assert.ErrorIs(t, err, ErrInvalidTaxRule)
+		assert.Nil(t, rule)
+	})
+
+	t.Run("ListByCurrency", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().
+			ListTaxRules(ctx, GEL).
+			Return([]*TaxRule{{ID: "ge-vat"}}, nil)
+
+		response, err := service.ListTaxRules(ctx, &ListTaxRulesRequest{Currency: GEL})
+
+		require.NoError(t, err)
+		require.Len(t, response.Rules, 1)
+	})
+
+	t.Run("ListEmpty", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().
+			ListTaxRules(ctx, Currency("")).
+			Return(nil, nil)
+
+		response, err := service.ListTaxRules(ctx, &ListTaxRulesRequest{})
+
+		require.NoError(t, err)
+		assert.NotNil(t, response.Rules)
+	})
+
+	t.Run("GetNotFound", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().
+			GetTaxRule(ctx, "missing").
+			Return(nil, ErrTaxRuleNotFound)
+
+		_, err := service.GetTaxRule(ctx, "missing")
+
+		assert.ErrorIs(t, err, ErrTaxRuleNotFound)
+	})
+}
+
+func TestBillService_Discounts(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := NewMockRepositoryInterface(ctrl)
+	mockTemporal := NewMockTemporalClientInterface(ctrl)
+	service := NewBillService(mockRepo, mockTemporal)
+
+	spring := &PromoCode{Code: "SPRING10", Description: "Spring sale", Kind: DiscountKindPercentage, PercentBps: 1000}
+
+	t.Run("CreatePromoCode", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().
+			CreatePromoCode(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, promo *PromoCode) error {
+				assert.Equal(t, "WELCOME", promo.Code)
+				return nil
+			})
+
+		promo, err := service.CreatePromoCode(ctx, &CreatePromoCodeRequest{
+			Code: " welcome ", Description: "Welcome credit", Kind: DiscountKindFixed, Amount: 500, Currency: USD,
+			MaxUsesPerCustomer: 1,
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, "WELCOME", promo.Code)
+	})
+
+	t.Run("CreatePromoCodeValidationError", func(t *testing.T) {
+		_, err := service.CreatePromoCode(context.Background(), &CreatePromoCodeRequest{
+			Code: "BAD", Description: "Bad", Kind: DiscountKindPercentage,
+		})
+
+		assert.ErrorIs(t, err, ErrInvalidPromoCode)
+	})
+
+	t.Run("CreateBillWithPromoCode", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().GetPromoCode(ctx, "SPRING10").Return(spring, nil)
+		mockRepo.EXPECT().
+			CreateBill(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, bill *Bill) (*OutboxEvent, error) {
+				require.Len(t, bill.Discounts, 1)
+				assert.Equal(t, "SPRING10", bill.Discounts[0].Code)
+				assert.Equal(t, int64(1000), bill.Discounts[0].PercentBps)
+				return newTestOutboxEvent(t, 1, bill.ID, OutboxStartBill, bill), nil
+			})
+		mockTemporal.EXPECT().ExecuteWorkflow(ctx, gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil)
+		mockRepo.EXPECT().MarkOutboxEventDone(ctx, int64(1)).Return(nil)
+
+		_, err := service.CreateBill(ctx, &CreateBillRequest{CustomerID: "customer-1", Currency: USD, PromoCodes: []string{"spring10"}})
+
+		require.NoError(t, err)
+	})
+
+	t.Run("CreateBillWithExpiredPromoCode", func(t *testing.T) {
+		ctx := context.Background()
+		expired := time.Now().Add(-time.Hour)
+
+		mockRepo.EXPECT().
+			GetPromoCode(ctx, "OLD").
+			Return(&PromoCode{Code: "OLD", Kind: DiscountKindPercentage, PercentBps: 500, ValidUntil: &expired}, nil)
+
+		_, err := service.CreateBill(ctx, &CreateBillRequest{CustomerID: "customer-1", Currency: USD, PromoCodes: []string{"OLD"}})
+
+		assert.ErrorIs(t, err, ErrPromoCodeInactive)
+	})
+
+	t.Run("ApplyToOpenBill", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().GetBillByID(ctx, "bill-1").Return(&Bill{ID: "bill-1", Currency: USD, Status: BillStatusOpen}, nil)
+		mockRepo.EXPECT().GetPromoCode(ctx, "SPRING10").Return(spring, nil)
+		mockTemporal.EXPECT().
+			UpdateWorkflow(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, options client.UpdateWorkflowOptions) (client.WorkflowUpdateHandle, error) {
+				assert.Equal(t, "bill-1", options.WorkflowID)
+				assert.Equal(t, AttachDiscountUpdate, options.UpdateName)
+				require.Len(t, options.Args, 1)
+				discount := options.Args[0].(BillDiscount)
+				assert.Equal(t, "SPRING10", discount.Code)
+				assert.False(t, discount.AttachedAt.IsZero())
+				return fakeUpdateHandle{result: discount}, nil
+			})
+
+		response, err := service.ApplyDiscount(ctx, "bill-1", &ApplyDiscountRequest{Code: "Spring10"})
+
+		require.NoError(t, err)
+		assert.Equal(t, "SPRING10", response.Discount.Code)
+	})
+
+	t.Run("ApplyWhileClosing", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().GetBillByID(ctx, "bill-1").Return(&Bill{ID: "bill-1", Currency: USD, Status: BillStatusOpen}, nil)
+		mockRepo.EXPECT().GetPromoCode(ctx, "SPRING10").Return(spring, nil)
+		mockTemporal.EXPECT().
+			UpdateWorkflow(ctx, gomock.Any()).
+			Return(nil, toWorkflowError(ErrBillAlreadyClosed))
+
+		_, err := service.ApplyDiscount(ctx, "bill-1", &ApplyDiscountRequest{Code: "SPRING10"})
+
+		assert.ErrorIs(t, err, ErrBillAlreadyClosed)
+	})
+
+	t.Run("ApplyBeforeWorkflowStarted", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().GetBillByID(ctx, "bill-1").Return(&Bill{ID: "bill-1", Currency: USD, Status: BillStatusOpen}, nil)
+		mockRepo.EXPECT().GetPromoCode(ctx, "SPRING10").Return(spring, nil)
+		mockTemporal.EXPECT().
+			UpdateWorkflow(ctx, gomock.Any()).
+			Return(nil, serviceerror.NewNotFound("workflow not found"))
+		mockRepo.EXPECT().AttachDiscount(ctx, "bill-1", gomock.Any()).Return(nil)
+
+		response, err := service.ApplyDiscount(ctx, "bill-1", &ApplyDiscountRequest{Code: "SPRING10"})
+
+		require.NoError(t, err)
+		assert.Equal(t, "SPRING10", response.Discount.Code)
+	})
+
+	t.Run("ApplyToClosedBill", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().GetBillByID(ctx, "bill-1").Return(&Bill{ID: "bill-1", Currency: USD, Status: BillStatusClosed}, nil)
+
+		_, err := service.ApplyDiscount(ctx, "bill-1", &ApplyDiscountRequest{Code: "SPRING10"})
+
+		assert.ErrorIs(t, err, ErrBillAlreadyClosed)
+	})
+
+	t.Run("ApplyFixedDiscountInOtherCurrency", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().GetBillByID(ctx, "bill-1").Return(&Bill{ID: "bill-1", Currency: GEL, Status: BillStatusOpen}, nil)
+		mockRepo.EXPECT().
+			GetPromoCode(ctx, "WELCOME").
+			Return(&PromoCode{Code: "WELCOME", Kind: DiscountKindFixed, Amount: 500, Currency: USD}, nil)
+
+		_, err := service.ApplyDiscount(ctx, "bill-1", &ApplyDiscountRequest{Code: "WELCOME"})
+
+		assert.ErrorIs(t, err, ErrInvalidPromoCode)
+	})
+
+	t.Run("ApplyOverUsageLimit", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().GetBillByID(ctx, "bill-1").Return(&Bill{ID: "bill-1", Currency: USD, Status: BillStatusOpen}, nil)
+		mockRepo.EXPECT().GetPromoCode(ctx, "SPRING10").Return(spring, nil)
+		mockTemporal.EXPECT().
+			UpdateWorkflow(ctx, gomock.Any()).
+			Return(fakeUpdateHandle{err: toWorkflowError(fmt.Errorf("%w: SPRING10 used 1 of 1 times", ErrPromoCodeUsageExceeded))}, nil)
+
+		_, err := service.ApplyDiscount(ctx, "bill-1", &ApplyDiscountRequest{Code: "SPRING10"})
+
+		assert.ErrorIs(t, err, ErrPromoCodeUsageExceeded)
+	})
+
+	t.Run("ApplyEmptyCode", func(t *testing.T) {
+		_, err := service.ApplyDiscount(context.Background(), "bill-1", &ApplyDiscountRequest{Code: " "})
+
+		assert.ErrorIs(t, err, ErrInvalidPromoCode)
+	})
+}
+
+func TestBillService_BillingLimits(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := NewMockRepositoryInterface(ctrl)
+	mockTemporal := NewMockTemporalClientInterface(ctrl)
+	service := NewBillService(mockRepo, mockTemporal)
+
+	t.Run("Save", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().
+			SaveCustomerBillingLimits(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, limits *CustomerBillingLimits) error {
+				assert.Equal(t, "customer-1", limits.CustomerID)
+				assert.Equal(t, USD, limits.Currency)
+				assert.Equal(t, int64(5000), *limits.MinimumAmount)
+				assert.Nil(t, limits.MaximumAmount)
+				return nil
+			})
+
+		limits, err := service.SaveBillingLimits(ctx, "customer-1", &SaveBillingLimitsRequest{Currency: USD, MinimumAmount: int64Ptr(5000)})
+
+		require.NoError(t, err)
+		assert.Equal(t, int64(5000), *limits.MinimumAmount)
+	})
+
+	t.Run("SaveValidationError", func(t *testing.T) {
+		_, err := service.SaveBillingLimits(context.Background(), "customer-1", &SaveBillingLimitsRequest{
+			Currency:      USD,
+			MinimumAmount: int64Ptr(5000),
+			MaximumAmount: int64Ptr(100),
+		})
+
+		assert.ErrorIs(t, err, ErrInvalidBillingLimits)
+	})
+
+	t.Run("GetNotFound", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().
+			GetCustomerBillingLimits(ctx, "customer-1", GEL).
+			Return(nil, ErrBillingLimitsNotFound)
+
+		_, err := service.GetBillingLimits(ctx, "customer-1", GEL)
+
+		assert.ErrorIs(t, err, ErrBillingLimitsNotFound)
+	})
+
+	t.Run("CreateBillWithLimits", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().
+			CreateBill(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, bill *Bill) (*OutboxEvent, error) {
+				assert.Equal(t, int64(5000), *bill.MinimumAmount)
+				assert.Equal(t, int64(1000000), *bill.MaximumAmount)
+				return newTestOutboxEvent(t, 1, bill.ID, OutboxStartBill, bill), nil
+			})
+		mockTemporal.EXPECT().ExecuteWorkflow(ctx, gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil)
+		mockRepo.EXPECT().MarkOutboxEventDone(ctx, int64(1)).Return(nil)
+
+		_, err := service.CreateBill(ctx, &CreateBillRequest{
+			CustomerID:    "customer-1",
+			Currency:      USD,
+			MinimumAmount: int64Ptr(5000),
+			MaximumAmount: int64Ptr(1000000),
+		})
+
+		require.NoError(t, err)
+	})
+}
+
+func TestBillService_Invoices(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := NewMockRepositoryInterface(ctrl)
+	mockTemporal := NewMockTemporalClientInterface(ctrl)
+	service := NewBillService(mockRepo, mockTemporal)
+
+	t.Run("Get", func(t *testing.T) {
+		ctx := context.Background()
+		expected := &Invoice{Number: "PAVE-000042", LegalEntity: "PAVE", Sequence: 42, BillID: "bill-123"}
+
+		mockRepo.EXPECT().GetInvoice(ctx, "PAVE-000042").Return(expected, nil)
+
+		invoice, err := service.GetInvoice(ctx, " pave-000042 ")
+
+		require.NoError(t, err)
+		assert.Equal(t, expected, invoice)
+	})
+
+	t.Run("GetNotFound", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().GetInvoice(ctx, "PAVE-999999").Return(nil, ErrInvoiceNotFound)
+
+		_, err := service.GetInvoice(ctx, "PAVE-999999")
+
+		assert.ErrorIs(t, err, ErrInvoiceNotFound)
+	})
+
+	t.Run("CreateBillWithLegalEntity", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().
+			CreateBill(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, bill *Bill) (*OutboxEvent, error) {
+				assert.Equal(t, "PAVEGE", bill.LegalEntity)
+				return newTestOutboxEvent(t, 1, bill.ID, OutboxStartBill, bill), nil
+			})
+		mockTemporal.EXPECT().ExecuteWorkflow(ctx, gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil)
+		mockRepo.EXPECT().MarkOutboxEventDone(ctx, int64(1)).Return(nil)
+
+		_, err := service.CreateBill(ctx, &CreateBillRequest{CustomerID: "customer-1", Currency: GEL, LegalEntity: "PAVEGE"})
+
+		require.NoError(t, err)
+	})
+
+	t.Run("CreateBillInvalidLegalEntity", func(t *testing.T) {
+		_, err := service.CreateBill(context.Background(), &CreateBillRequest{CustomerID: "customer-1", Currency: GEL, LegalEntity: "pave ge"})
+
+		assert.ErrorIs(t, err, ErrInvalidLegalEntity)
+	})
+}
+
+func TestBillService_RenderInvoice(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := NewMockRepositoryInterface(ctrl)
+	mockTemporal := NewMockTemporalClientInterface(ctrl)
+	service := NewBillService(mockRepo, mockTemporal)
+
+	t.Run("ClosedBillRendersIssuedInvoice", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().GetBillByID(ctx, "bill-1").
+			Return(&Bill{ID: "bill-1", Status: BillStatusClosed, Currency: GEL, InvoiceNumber: "PAVE-000042"}, nil)
+		mockRepo.EXPECT().GetInvoice(ctx, "PAVE-000042").Return(testInvoice(), nil)
+
+		rendered, err := service.RenderInvoice(ctx, "bill-1", InvoiceFormatPDF)
+
+		require.NoError(t, err)
+		assert.Equal(t, "PAVE-000042.pdf", rendered.Filename)
+		assert.NotContains(t, string(rendered.Body), "(DRAFT)")
+	})
+
+	t.Run("VoidedBillHasNoInvoice", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().GetBillByID(ctx, "bill-void").
+			Return(&Bill{ID: "bill-void", Status: BillStatusVoided, Currency: USD}, nil)
+
+		_, err := service.RenderInvoice(ctx, "bill-void", InvoiceFormatHTML)
+
+		assert.ErrorIs(t, err, ErrBillVoided)
+	})
+
+	t.Run("OpenBillRendersDraft", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().GetBillByID(ctx, "bill-2").Return(&Bill{
+			ID:        "bill-2",
+			Status:    BillStatusOpen,
+			Currency:  USD,
+			LineItems: []LineItem{{ID: "item-1", Description: "Usage", Amount: 1250}},
+		}, nil)
+
+		rendered, err := service.RenderInvoice(ctx, "bill-2", InvoiceFormatHTML)
+
+		require.NoError(t, err)
+		assert.Equal(t, "bill-2-draft.html", rendered.Filename)
+		assert.Contains(t, string(rendered.Body), "USD 12.50")
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().GetBillByID(ctx, "missing").Return(nil, ErrBillNotFound)
+
+		_, err := service.RenderInvoice(ctx, "missing", InvoiceFormatPDF)
+
+		assert.ErrorIs(t, err, ErrBillNotFound)
+	})
+
+	t.Run("WithBranding", func(t *testing.T) {
+		ctx := context.Background()
+		renderer, err := NewInvoiceRenderer(InvoiceBranding{CompanyName: "Pave Georgia"})
+		require.NoError(t, err)
+		branded := NewBillService(mockRepo, mockTemporal, WithInvoiceRenderer(renderer))
+
+		mockRepo.EXPECT().GetBillByID(ctx, "bill-3").Return(&Bill{ID: "bill-3", Status: BillStatusOpen, Currency: USD}, nil)
+
+		rendered, err := branded.RenderInvoice(ctx, "bill-3", InvoiceFormatHTML)
+
+		require.NoError(t, err)
+		assert.Contains(t, string(rendered.Body), "Pave Georgia")
+	})
+}
+
+func TestBillService_Payments(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := NewMockRepositoryInterface(ctrl)
+	mockTemporal := NewMockTemporalClientInterface(ctrl)
+	service := NewBillService(mockRepo, mockTemporal)
+
+	closedBill := func(paid int64) *Bill {
+		bill := &Bill{ID: "bill-1", Currency: USD, Status: BillStatusClosed, TotalAmount: 10000, PaidAmount: paid}
+		bill.settle()
+		return bill
+	}
+
+	t.Run("PartialPayment", func(t *testing.T) {
+		ctx := context.Background()
+
+		gomock.InOrder(
+			mockRepo.EXPECT().GetBillByID(ctx, "bill-1").Return(closedBill(0), nil),
+			mockRepo.EXPECT().RecordPayment(ctx, gomock.Any()).
+				DoAndReturn(func(ctx context.Context, payment *Payment) (*Payment, error) {
+					assert.Equal(t, "bill-1", payment.BillID)
+					assert.Equal(t, int64(4000), payment.Amount)
+					assert.Equal(t, USD, payment.Currency)
+					assert.Equal(t, PaymentMethodBankTransfer, payment.Method)
+					assert.Equal(t, "wire-1", payment.ExternalReference)
+					return payment, nil
+				}),
+			mockRepo.EXPECT().GetBillByID(ctx, "bill-1").Return(closedBill(4000), nil),
+			mockTemporal.EXPECT().SignalWorkflow(ctx, "dunning-bill-1", "", PaymentRecordedSignal, gomock.Any()).
+				DoAndReturn(func(ctx context.Context, workflowID, runID, signalName string, arg interface{}) error {
+					signal := arg.(PaymentRecorded)
+					assert.Equal(t, int64(6000), signal.Balance)
+					assert.Equal(t, PaymentStatusPartiallyPaid, signal.PaymentStatus)
+					return nil
+				}),
+		)
+
+		resp, err := service.RecordPayment(ctx, "bill-1", &RecordPaymentRequest{
+			Amount:            4000,
+			Method:            PaymentMethodBankTransfer,
+			ExternalReference: "wire-1",
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, int64(4000), resp.PaidAmount)
+		assert.Equal(t, int64(6000), resp.Balance)
+		assert.Equal(t, PaymentStatusPartiallyPaid, resp.PaymentStatus)
+	})
+
+	t.Run("NoDunningWorkflow", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().GetBillByID(ctx, "bill-1").Return(closedBill(0), nil)
+		mockRepo.EXPECT().RecordPayment(ctx, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, payment *Payment) (*Payment, error) { return payment, nil })
+		mockRepo.EXPECT().GetBillByID(ctx, "bill-1").Return(closedBill(10000), nil)
+		mockTemporal.EXPECT().SignalWorkflow(ctx, "dunning-bill-1", "", PaymentRecordedSignal, gomock.Any()).
+			Return(serviceerror.NewNotFound("workflow not found"))
+
+		resp, err := service.RecordPayment(ctx, "bill-1", &RecordPaymentRequest{Amount: 10000, Method: PaymentMethodCard})
+
+		require.NoError(t, err)
+		assert.Equal(t, PaymentStatusPaid, resp.PaymentStatus)
+	})
+
+	t.Run("ReplayedReference", func(t *testing.T) {
+		ctx := context.Background()
+		existing := &Payment{ID: "pay-first", BillID: "bill-1", Amount: 4000, Currency: USD, Method: PaymentMethodBankTransfer, ExternalReference: "wire-1"}
+
+		mockRepo.EXPECT().GetBillByID(ctx, "bill-1").Return(closedBill(4000), nil).Times(2)
+		mockRepo.EXPECT().RecordPayment(ctx, gomock.Any()).Return(existing, nil)
+		mockTemporal.EXPECT().SignalWorkflow(ctx, "dunning-bill-1", "", PaymentRecordedSignal, gomock.Any()).Return(nil)
+
+		resp, err := service.RecordPayment(ctx, "bill-1", &RecordPaymentRequest{
+			Amount:            4000,
+			Method:            PaymentMethodBankTransfer,
+			ExternalReference: "wire-1",
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, "pay-first", resp.Payment.ID)
+		assert.Equal(t, int64(4000), resp.PaidAmount)
+	})
+
+	t.Run("ReferenceConflict", func(t *testing.T) {
+		ctx := context.Background()
+		existing := &Payment{ID: "pay-first", BillID: "bill-1", Amount: 4000, Currency: USD, Method: PaymentMethodBankTransfer, ExternalReference: "wire-1"}
+
+		mockRepo.EXPECT().GetBillByID(ctx, "bill-1").Return(closedBill(4000), nil)
+		mockRepo.EXPECT().RecordPayment(ctx, gomock.Any()).Return(existing, nil)
+
+		_, err := service.RecordPayment(ctx, "bill-1", &RecordPaymentRequest{
+			Amount:            9999,
+			Method:            PaymentMethodBankTransfer,
+			ExternalReference: "wire-1",
+		})
+
+		assert.ErrorIs(t, err, ErrPaymentReferenceConflict)
+	})
+
+	t.Run("OpenBill", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().GetBillByID(ctx, "bill-2").Return(&Bill{ID: "bill-2", Currency: USD, Status: BillStatusOpen}, nil)
+
+		_, err := service.RecordPayment(ctx, "bill-2", &RecordPaymentRequest{Amount: 100, Method: PaymentMethodCash})
+
+		assert.ErrorIs(t, err, ErrBillNotClosed)
+	})
+
+	t.Run("CurrencyMismatch", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().GetBillByID(ctx, "bill-1").Return(closedBill(0), nil)
+
+		_, err := service.RecordPayment(ctx, "bill-1", &RecordPaymentRequest{Amount: 100, Method: PaymentMethodCash, Currency: GEL})
+
+		assert.ErrorIs(t, err, ErrInvalidPayment)
+	})
+
+	t.Run("ValidationError", func(t *testing.T) {
+		_, err := service.RecordPayment(context.Background(), "bill-1", &RecordPaymentRequest{Amount: 100})
+
+		assert.ErrorIs(t, err, ErrInvalidPayment)
+	})
+
+	t.Run("List", func(t *testing.T) {
+		ctx := context.Background()
+		payments := []*Payment{{ID: "pay-1", BillID: "bill-1", Amount: 4000}}
+
+		mockRepo.EXPECT().GetBillByID(ctx, "bill-1").Return(closedBill(4000), nil)
+		mockRepo.EXPECT().ListPayments(ctx, "bill-1").Return(payments, nil)
+
+		resp, err := service.ListPayments(ctx, "bill-1")
+
+		require.NoError(t, err)
+		assert.Equal(t, payments, resp.Payments)
+	})
+
+	t.Run("GetBillBalance", func(t *testing.T) {
+		ctx := context.Background()
+		bill := &Bill{ID: "bill-1", Currency: USD, Status: BillStatusClosed, PaidAmount: 12000,
+			LineItems: []LineItem{{ID: "item-1", Description: "Usage", Amount: 10000}}}
+
+		mockRepo.EXPECT().GetBillByID(ctx, "bill-1").Return(bill, nil)
+
+		resp, err := service.GetBill(ctx, "bill-1")
+
+		require.NoError(t, err)
+		assert.Equal(t, int64(-2000), resp.Balance)
+		assert.Equal(t, PaymentStatusOverpaid, resp.Bill.PaymentStatus)
+	})
+}
+
+func TestBillService_Usage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := NewMockRepositoryInterface(ctrl)
+	mockTemporal := NewMockTemporalClientInterface(ctrl)
+	service := NewBillService(mockRepo, mockTemporal)
+
+	periodStart := time.Now().Add(-24 * time.Hour)
+	openBill := func(id string) *Bill {
+		return &Bill{ID: id, CustomerID: "customer-1", Currency: USD, Status: BillStatusOpen, PeriodStart: &periodStart}
+	}
+	open := BillStatusOpen
+	stored := func(ctx context.Context, event *UsageEvent) (*UsageEvent, error) { return event, nil }
+
+	t.Run("RoutesToOpenBill", func(t *testing.T) {
+		ctx := context.Background()
+		late := periodStart.Add(-time.Hour)
+
+		mockRepo.EXPECT().GetMeter(ctx, "api_calls").Return(testMeter(), nil)
+		mockRepo.EXPECT().ListBillsByCustomer(ctx, "customer-1", &open, false, maxOpenBillsPerCustomer, 0).
+			Return([]*Bill{openBill("bill-1")}, nil)
+		mockRepo.EXPECT().RecordUsageEvent(ctx, gomock.Any()).DoAndReturn(stored).Times(2)
+
+		resp, err := service.RecordUsage(ctx, &RecordUsageRequest{Events: []UsageEventInput{
+			{CustomerID: "customer-1", Meter: "API_CALLS", Quantity: 10 * QuantityOne},
+			{CustomerID: "customer-1", Meter: "api_calls", Quantity: 5 * QuantityOne, Timestamp: &late},
+		}})
+
+		require.NoError(t, err)
+		require.Len(t, resp.Events, 2)
+		assert.Equal(t, "bill-1", resp.Events[0].BillID)
+		assert.Equal(t, UsageEventStatusPending, resp.Events[0].Status)
+		assert.Equal(t, "api_calls", resp.Events[0].Meter)
+		assert.False(t, resp.Events[0].Late)
+		assert.Equal(t, "bill-1", resp.Events[1].BillID)
+		assert.True(t, resp.Events[1].Late)
+	})
+
+	t.Run("FlagsEventWithNoBill", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().GetMeter(ctx, "api_calls").Return(testMeter(), nil)
+		mockRepo.EXPECT().ListBillsByCustomer(ctx, "customer-2", &open, false, maxOpenBillsPerCustomer, 0).Return(nil, nil)
+		mockRepo.EXPECT().RecordUsageEvent(ctx, gomock.Any()).DoAndReturn(stored)
+
+		resp, err := service.RecordUsage(ctx, &RecordUsageRequest{Events: []UsageEventInput{
+			{CustomerID: "customer-2", Meter: "api_calls", Quantity: QuantityOne},
+		}})
+
+		require.NoError(t, err)
+		assert.Equal(t, UsageEventStatusFlagged, resp.Events[0].Status)
+		assert.Empty(t, resp.Events[0].BillID)
+		assert.NotEmpty(t, resp.Events[0].FlagReason)
+	})
+
+	t.Run("BillClosedWhileRouting", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().GetMeter(ctx, "api_calls").Return(testMeter(), nil)
+		gomock.InOrder(
+			mockRepo.EXPECT().ListBillsByCustomer(ctx, "customer-1", &open, false, maxOpenBillsPerCustomer, 0).
+				Return([]*Bill{openBill("bill-old")}, nil),
+			mockRepo.EXPECT().RecordUsageEvent(ctx, gomock.Any()).Return(nil, ErrBillAlreadyClosed),
+			mockRepo.EXPECT().ListBillsByCustomer(ctx, "customer-1", &open, false, maxOpenBillsPerCustomer, 0).
+				Return([]*Bill{openBill("bill-new")}, nil),
+			mockRepo.EXPECT().RecordUsageEvent(ctx, gomock.Any()).DoAndReturn(stored),
+		)
+
+		resp, err := service.RecordUsage(ctx, &RecordUsageRequest{Events: []UsageEventInput{
+			{CustomerID: "customer-1", Meter: "api_calls", Quantity: QuantityOne},
+		}})
+
+		require.NoError(t, err)
+		assert.Equal(t, "bill-new", resp.Events[0].BillID)
+	})
+
+	t.Run("ReplayedIdempotencyKey", func(t *testing.T) {
+		ctx := context.Background()
+		timestamp := time.Now().Add(-time.Minute).UTC()
+		existing := &UsageEvent{ID: "usage-first", CustomerID: "customer-1", Meter: "api_calls", Quantity: QuantityOne,
+			Timestamp: timestamp, IdempotencyKey: "evt-1", BillID: "bill-1", Status: UsageEventStatusPending}
+
+		mockRepo.EXPECT().GetMeter(ctx, "api_calls").Return(testMeter(), nil).Times(2)
+		mockRepo.EXPECT().ListBillsByCustomer(ctx, "customer-1", &open, false, maxOpenBillsPerCustomer, 0).
+			Return([]*Bill{openBill("bill-1")}, nil).Times(2)
+		mockRepo.EXPECT().RecordUsageEvent(ctx, gomock.Any()).Return(existing, nil).Times(2)
+
+		resp, err := service.RecordUsage(ctx, &RecordUsageRequest{Events: []UsageEventInput{
+			{CustomerID: "customer-1", Meter: "api_calls", Quantity: QuantityOne, Timestamp: &timestamp, IdempotencyKey: "evt-1"},
+		}})
+		require.NoError(t, err)
+		assert.Equal(t, "usage-first", resp.Events[0].ID)
+
+		_, err = service.RecordUsage(ctx, &RecordUsageRequest{Events: []UsageEventInput{
+			{CustomerID: "customer-1", Meter: "api_calls", Quantity: 2 * QuantityOne, Timestamp: &timestamp, IdempotencyKey: "evt-1"},
+		}})
+		assert.ErrorIs(t, err, ErrIdempotencyKeyReused)
+	})
+
+	t.Run("UnknownMeter", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().GetMeter(ctx, "api_calls").Return(testMeter(), nil)
+		mockRepo.EXPECT().GetMeter(ctx, "gpu_hours").Return(nil, ErrMeterNotFound)
+
+		_, err := service.RecordUsage(ctx, &RecordUsageRequest{Events: []UsageEventInput{
+			{CustomerID: "customer-1", Meter: "api_calls", Quantity: QuantityOne},
+			{CustomerID: "customer-1", Meter: "gpu_hours", Quantity: QuantityOne},
+		}})
+
+		assert.ErrorIs(t, err, ErrMeterNotFound)
+	})
+
+	t.Run("BillUsage", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().GetBillByID(ctx, "bill-1").Return(openBill("bill-1"), nil)
+		mockRepo.EXPECT().AggregateUsage(ctx, "bill-1").
+			Return(&BillUsage{Meters: []MeterUsage{{Meter: *testMeter(), Quantity: 100 * QuantityOne, Events: 4}}, LastEventSeq: 7}, nil)
+
+		resp, err := service.GetBillUsage(ctx, "bill-1")
+
+		require.NoError(t, err)
+		require.Len(t, resp.Meters, 1)
+		assert.Equal(t, 4, resp.Meters[0].Events)
+		require.Len(t, resp.LineItems, 1)
+		assert.Equal(t, int64(200), resp.LineItems[0].Amount)
+	})
+
+	t.Run("ListFlagged", func(t *testing.T) {
+		ctx := context.Background()
+		events := []*UsageEvent{{ID: "usage-1", Status: UsageEventStatusFlagged}}
+
+		mockRepo.EXPECT().ListUsageEvents(ctx, "customer-1", UsageEventStatusFlagged, defaultUsageEventsLimit).Return(events, nil)
+
+		resp, err := service.ListUsageEvents(ctx, "customer-1", ListUsageEventsParams{Status: UsageEventStatusFlagged})
+
+		require.NoError(t, err)
+		assert.Equal(t, events, resp.Events)
+
+		_, err = service.ListUsageEvents(ctx, "customer-1", ListUsageEventsParams{Status: "LOST"})
+		assert.ErrorIs(t, err, ErrInvalidUsageEvent)
+	})
+
+	t.Run("CreateMeter", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().CreateMeter(ctx, gomock.Any()).Return(fmt.Errorf("%w: api_calls", ErrMeterExists))
+
+		_, err := service.CreateMeter(ctx, &CreateMeterRequest{Name: "api_calls", Description: "API calls",
+			Aggregation: MeterAggregationSum, UnitPrices: map[Currency]int64{USD: 2}})
+
+		assert.ErrorIs(t, err, ErrMeterExists)
+	})
+}
+
+func TestBillService_Subscriptions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := NewMockRepositoryInterface(ctrl)
+	mockTemporal := NewMockTemporalClientInterface(ctrl)
+	service := NewBillService(mockRepo, mockTemporal)
+
+	periodEnd := time.Date(2026, 4, 15, 0, 0, 0, 0, time.UTC)
+	subscription := func(status SubscriptionStatus) *Subscription {
+		return &Subscription{ID: "sub-1", CustomerID: "customer-1", Plan: "Pro", PlanAmount: 4900, Currency: USD,
+			AnchorDay: 15, Status: status, CurrentPeriodEnd: &periodEnd, CurrentBillID: "bill-1"}
+	}
+
+	t.Run("Create", func(t *testing.T) {
+		ctx := context.Background()
+		var created *Subscription
+
+		mockRepo.EXPECT().CreateSubscription(ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, sub *Subscription) error {
+			created = sub
+			return nil
+		})
+		mockTemporal.EXPECT().ExecuteWorkflow(ctx, gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, options client.StartWorkflowOptions, wf interface{}, args ...interface{}) (client.WorkflowRun, error) {
+				assert.Equal(t, created.ID, options.ID)
+				input := args[0].(SubscriptionInput)
+				assert.Equal(t, created.ID, input.SubscriptionID)
+				assert.Equal(t, created.AnchorDay, input.AnchorDay)
+				assert.Equal(t, created.CreatedAt, input.PeriodStart)
+				return nil, nil
+			})
+
+		sub, err := service.CreateSubscription(ctx, &CreateSubscriptionRequest{CustomerID: "customer-1", Plan: " Pro ",
+			PlanAmount: 4900, Currency: USD})
+
+		require.NoError(t, err)
+		assert.Equal(t, SubscriptionStatusActive, sub.Status)
+		assert.Equal(t, "Pro", sub.Plan)
+		assert.Equal(t, sub.CreatedAt.UTC().Day(), sub.AnchorDay)
+	})
+
+	t.Run("CreateWorkflowFails", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().CreateSubscription(ctx, gomock.Any()).Return(nil)
+		mockTemporal.EXPECT().ExecuteWorkflow(ctx, gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(nil, errors.New("temporal unavailable"))
+
+		_, err := service.CreateSubscription(ctx, &CreateSubscriptionRequest{CustomerID: "customer-1", Plan: "Pro",
+			PlanAmount: 4900, Currency: USD, AnchorDay: 1})
+
+		assert.ErrorContains(t, err, "resume it to retry")
+	})
+
+	t.Run("Pause", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().GetSubscription(ctx, "sub-1").Return(subscription(SubscriptionStatusActive), nil)
+		mockRepo.EXPECT().UpdateSubscriptionStatus(ctx, "sub-1", SubscriptionStatusActive, SubscriptionStatusPaused).Return(nil)
+
+		sub, err := service.PauseSubscription(ctx, "sub-1")
+
+		require.NoError(t, err)
+		assert.Equal(t, SubscriptionStatusPaused, sub.Status)
+	})
+
+	t.Run("PauseTwice", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().GetSubscription(ctx, "sub-1").Return(subscription(SubscriptionStatusPaused), nil)
+
+		sub, err := service.PauseSubscription(ctx, "sub-1")
+
+		require.NoError(t, err)
+		assert.Equal(t, SubscriptionStatusPaused, sub.Status)
+	})
+
+	t.Run("Resume", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().GetSubscription(ctx, "sub-1").Return(subscription(SubscriptionStatusPaused), nil)
+		mockRepo.EXPECT().UpdateSubscriptionStatus(ctx, "sub-1", SubscriptionStatusPaused, SubscriptionStatusActive).Return(nil)
+		mockTemporal.EXPECT().ExecuteWorkflow(ctx, gomock.Any(), gomock.Any(), SubscriptionInput{
+			SubscriptionID: "sub-1", AnchorDay: 15, PeriodStart: periodEnd,
+		}).Return(nil, nil)
+
+		sub, err := service.ResumeSubscription(ctx, "sub-1")
+
+		require.NoError(t, err)
+		assert.Equal(t, SubscriptionStatusActive, sub.Status)
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().GetSubscription(ctx, "sub-1").Return(subscription(SubscriptionStatusPaused), nil)
+		mockRepo.EXPECT().UpdateSubscriptionStatus(ctx, "sub-1", SubscriptionStatusPaused, SubscriptionStatusCancelled).Return(nil)
+		mockTemporal.EXPECT().SignalWorkflow(ctx, "sub-1", "", CancelSubscriptionSignal, nil).
+			Return(serviceerror.NewNotFound("workflow not found"))
+
+		sub, err := service.CancelSubscription(ctx, "sub-1")
+
+		require.NoError(t, err)
+		assert.Equal(t, SubscriptionStatusCancelled, sub.Status)
+	})
+
+	t.Run("ResumeCancelled", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().GetSubscription(ctx, "sub-1").Return(subscription(SubscriptionStatusCancelled), nil)
+
+		_, err := service.ResumeSubscription(ctx, "sub-1")
+
+		assert.ErrorIs(t, err, ErrSubscriptionStatusChanged)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().GetSubscription(ctx, "sub-2").Return(nil, ErrSubscriptionNotFound)
+
+		_, err := service.CancelSubscription(ctx, "sub-2")
+
+		assert.ErrorIs(t, err, ErrSubscriptionNotFound)
+	})
+}
+
+func TestBillService_ChangeSubscriptionPlan(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := NewMockRepositoryInterface(ctrl)
+	mockTemporal := NewMockTemporalClientInterface(ctrl)
+	service := NewBillService(mockRepo, mockTemporal)
+
+	// The current period ends 21 days after today, so a change made today
+	// has 21 of its 30 days left.
+	effectiveAt := time.Now().UTC().Truncate(24 * time.Hour)
+	periodEnd := effectiveAt.AddDate(0, 0, 21)
+	periodStart := periodEnd.AddDate(0, 0, -30)
+	subscription := func(status SubscriptionStatus) *Subscription {
+		return &Subscription{ID: "sub-1", CustomerID: "customer-1", Plan: "Pro", PlanAmount: 3000, Currency: USD,
+			AnchorDay: periodEnd.Day(), Status: status, CurrentPeriodStart: &periodStart, CurrentPeriodEnd: &periodEnd,
+			CurrentBillID: "bill-1"}
+	}
+	openBill := func(currency Currency) *Bill {
+		return &Bill{ID: "bill-1", CustomerID: "customer-1", Currency: currency, Status: BillStatusOpen,
+			PeriodStart: &periodStart, PeriodEnd: &periodEnd}
+	}
+
+	// expectPlanChange saves the plan with the prorated items on bill-1,
+	// collects them and delivers each to the workflow.
+	expectPlanChange := func(ctx context.Context, plan string, amount int64, items *[]LineItem, count int) {
+		mockRepo.EXPECT().UpdateSubscriptionPlan(ctx, "sub-1", plan, amount, "bill-1", gomock.Len(count)).
+			DoAndReturn(func(ctx context.Context, _, _ string, _ int64, billID string, added []*LineItem) ([]*OutboxEvent, error) {
+				var events []*OutboxEvent
+				for _, item := range added {
+					*items = append(*items, *item)
+					events = append(events, newTestOutboxEvent(t, int64(len(*items)), billID, OutboxAddLineItem, item))
+				}
+				return events, nil
+			})
+		mockTemporal.EXPECT().UpdateWorkflow(ctx, gomock.Any()).Return(fakeUpdateHandle{result: BillState{}}, nil).Times(count)
+		mockRepo.EXPECT().MarkOutboxEventDone(ctx, gomock.Any()).Return(nil).Times(count)
+	}
+
+	t.Run("Upgrade", func(t *testing.T) {
+		ctx := context.Background()
+		var items []LineItem
+
+		mockRepo.EXPECT().GetSubscription(ctx, "sub-1").Return(subscription(SubscriptionStatusActive), nil)
+		mockRepo.EXPECT().GetBillByID(ctx, "bill-1").Return(openBill(USD), nil)
+		expectPlanChange(ctx, "Business", 9000, &items, 2)
+
+		resp, err := service.ChangeSubscriptionPlan(ctx, "sub-1", &ChangePlanRequest{Plan: "Business", PlanAmount: 9000,
+			EffectiveAt: &effectiveAt})
+
+		require.NoError(t, err)
+		assert.Equal(t, "bill-1", resp.BillID)
+		assert.Equal(t, int64(2100), resp.CreditAmount)
+		assert.Equal(t, int64(6300), resp.ChargeAmount)
+		assert.Equal(t, "Business", resp.Subscription.Plan)
+		assert.Equal(t, int64(9000), resp.Subscription.PlanAmount)
+
+		require.Len(t, items, 2)
+		assert.Equal(t, LineItemKindCredit, items[0].Kind)
+		assert.Equal(t, CreditReasonProration, items[0].ReasonCode)
+		assert.Equal(t, int64(-2100), items[0].Amount)
+		assert.True(t, strings.HasPrefix(items[0].Description, "Unused Pro plan, "))
+		assert.Equal(t, LineItemKindCharge, items[1].Kind)
+		assert.Equal(t, int64(6300), items[1].Amount)
+		assert.True(t, strings.HasPrefix(items[1].Description, "Business plan, "))
+		assert.NotEqual(t, items[0].IdempotencyKey, items[1].IdempotencyKey)
+	})
+
+	t.Run("RoundsPerCurrency", func(t *testing.T) {
+		require.NoError(t, SetEnabledCurrencies([]Currency{USD, GEL, "CHF"}))
+		defer SetEnabledCurrencies([]Currency{USD, GEL})
+		ctx := context.Background()
+		var items []LineItem
+		sub := subscription(SubscriptionStatusActive)
+		sub.Currency, sub.PlanAmount = "CHF", 1010
+
+		mockRepo.EXPECT().GetSubscription(ctx, "sub-1").Return(sub, nil)
+		mockRepo.EXPECT().GetBillByID(ctx, "bill-1").Return(openBill("CHF"), nil)
+		expectPlanChange(ctx, "Pro", 0, &items, 1)
+
+		resp, err := service.ChangeSubscriptionPlan(ctx, "sub-1", &ChangePlanRequest{PlanAmount: 0, EffectiveAt: &effectiveAt})
+
+		require.NoError(t, err)
+		// 1010 x 21/30 is 707 centimes, rounded to 5.
+		assert.Equal(t, int64(705), resp.CreditAmount)
+		assert.Zero(t, resp.ChargeAmount)
+		require.Len(t, items, 1)
+	})
+
+	t.Run("PausedOnlyChangesPlan", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().GetSubscription(ctx, "sub-1").Return(subscription(SubscriptionStatusPaused), nil)
+		mockRepo.EXPECT().UpdateSubscriptionPlan(ctx, "sub-1", "Business", int64(9000), "", gomock.Len(0)).Return(nil, nil)
+
+		resp, err := service.ChangeSubscriptionPlan(ctx, "sub-1", &ChangePlanRequest{Plan: "Business", PlanAmount: 9000,
+			EffectiveAt: &effectiveAt})
+
+		require.NoError(t, err)
+		assert.Empty(t, resp.BillID)
+	})
+
+	t.Run("SamePlanChangesNothing", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().GetSubscription(ctx, "sub-1").Return(subscription(SubscriptionStatusActive), nil)
+
+		resp, err := service.ChangeSubscriptionPlan(ctx, "sub-1", &ChangePlanRequest{Plan: "Pro", PlanAmount: 3000,
+			EffectiveAt: &effectiveAt})
+
+		require.NoError(t, err)
+		assert.Empty(t, resp.BillID)
+	})
+
+	t.Run("AlreadyProrated", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().GetSubscription(ctx, "sub-1").Return(subscription(SubscriptionStatusActive), nil)
+		mockRepo.EXPECT().GetBillByID(ctx, "bill-1").Return(openBill(USD), nil)
+		mockRepo.EXPECT().UpdateSubscriptionPlan(ctx, "sub-1", "Business", int64(9000), "bill-1", gomock.Len(2)).
+			Return(nil, errDuplicateIdempotencyKey)
+
+		_, err := service.ChangeSubscriptionPlan(ctx, "sub-1", &ChangePlanRequest{Plan: "Business", PlanAmount: 9000,
+			EffectiveAt: &effectiveAt})
+
+		assert.ErrorIs(t, err, ErrIdempotencyKeyReused)
+	})
+
+	t.Run("NoEffectiveAt", func(t *testing.T) {
+		_, err := service.ChangeSubscriptionPlan(context.Background(), "sub-1", &ChangePlanRequest{PlanAmount: 9000})
+
+		assert.ErrorIs(t, err, ErrInvalidSubscription)
+	})
+
+	t.Run("BeforeThePeriod", func(t *testing.T) {
+		ctx := context.Background()
+		before := periodStart.Add(-time.Hour)
+
+		mockRepo.EXPECT().GetSubscription(ctx, "sub-1").Return(subscription(SubscriptionStatusActive), nil)
+		mockRepo.EXPECT().GetBillByID(ctx, "bill-1").Return(openBill(USD), nil)
+
+		_, err := service.ChangeSubscriptionPlan(ctx, "sub-1", &ChangePlanRequest{PlanAmount: 9000, EffectiveAt: &before})
+
+		assert.ErrorIs(t, err, ErrInvalidSubscription)
+		assert.ErrorIs(t, err, proration.ErrOutsidePeriod)
+	})
+
+	t.Run("BillClosed", func(t *testing.T) {
+		ctx := context.Background()
+		closed := openBill(USD)
+		closed.Status = BillStatusClosed
+
+		mockRepo.EXPECT().GetSubscription(ctx, "sub-1").Return(subscription(SubscriptionStatusActive), nil)
+		mockRepo.EXPECT().GetBillByID(ctx, "bill-1").Return(closed, nil)
+
+		_, err := service.ChangeSubscriptionPlan(ctx, "sub-1", &ChangePlanRequest{PlanAmount: 9000, EffectiveAt: &effectiveAt})
+
+		assert.ErrorIs(t, err, ErrBillAlreadyClosed)
+	})
+
+	t.Run("Cancelled", func(t *testing.T) {
+		ctx := context.Background()
+
+		mockRepo.EXPECT().GetSubscription(ctx, "sub-1").Return(subscription(SubscriptionStatusCancelled), nil)
+
+		_, err := service.ChangeSubscriptionPlan(ctx, "sub-1", &ChangePlanRequest{PlanAmount: 9000, EffectiveAt: &effectiveAt})
+
+		assert.ErrorIs(t, err, ErrSubscriptionStatusChanged)
+	})
+
+	t.Run("FutureChange", func(t *testing.T) {
+		future := time.Now().Add(time.Hour)
+
+		_, err := service.ChangeSubscriptionPlan(context.Background(), "sub-1", &ChangePlanRequest{PlanAmount: 9000, EffectiveAt: &future})
+
+		assert.ErrorIs(t, err, ErrInvalidSubscription)
 	})
 }
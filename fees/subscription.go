@@ -0,0 +1,236 @@
+package fees
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"pave-fees/fees/internal/proration"
+)
+
+var (
+	ErrSubscriptionNotFound      = errors.New("subscription not found")
+	ErrInvalidSubscription       = errors.New("invalid subscription")
+	ErrSubscriptionStatusChanged = errors.New("subscription status cannot change")
+)
+
+const maxPlanLength = 64
+
+type SubscriptionStatus string
+
+const (
+	// Active subscriptions get a new bill at every anchor date.
+	SubscriptionStatusActive SubscriptionStatus = "ACTIVE"
+	// Paused subscriptions skip their bills until they are resumed.
+	SubscriptionStatusPaused SubscriptionStatus = "PAUSED"
+	// Cancelled subscriptions never bill again.
+	SubscriptionStatusCancelled SubscriptionStatus = "CANCELLED"
+)
+
+func (s SubscriptionStatus) IsValid() bool {
+	return s == SubscriptionStatusActive || s == SubscriptionStatusPaused || s == SubscriptionStatusCancelled
+}
+
+// CanBecome reports whether a subscription in status s can move to status
+// to. Cancelling is final.
+func (s SubscriptionStatus) CanBecome(to SubscriptionStatus) bool {
+	switch to {
+	case SubscriptionStatusActive:
+		return s == SubscriptionStatusPaused
+	case SubscriptionStatusPaused:
+		return s == SubscriptionStatusActive
+	case SubscriptionStatusCancelled:
+		return s == SubscriptionStatusActive || s == SubscriptionStatusPaused
+	}
+	return false
+}
+
+// Subscription bills a customer PlanAmount for Plan every month, on
+// AnchorDay. Each period gets its own bill; CurrentBillID is the bill of
+// the period running now, empty while paused.
+type Subscription struct {
+	ID         string             `json:"id"`
+	CustomerID string             `json:"customerId"`
+	Plan       string             `json:"plan"`
+	PlanAmount int64              `json:"planAmount"`
+	Currency   Currency           `json:"currency"`
+	AnchorDay  int                `json:"anchorDay"`
+	Status     SubscriptionStatus `json:"status"`
+	CreatedAt  time.Time          `json:"createdAt"`
+	UpdatedAt  time.Time          `json:"updatedAt"`
+
+	CurrentPeriodStart *time.Time `json:"currentPeriodStart,omitempty"`
+	CurrentPeriodEnd   *time.Time `json:"currentPeriodEnd,omitempty"`
+	CurrentBillID      string     `json:"currentBillId,omitempty"`
+}
+
+// AnchorDate is midnight UTC on day of the given month, clamped to the last
+// day of shorter months, so a subscription anchored on the 31st renews on
+// Feb 28/29.
+func AnchorDate(year int, month time.Month, day int) time.Time {
+	lastDay := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// NextAnchorDate is the first anchor date strictly after t, which is when
+// the period running at t ends.
+func NextAnchorDate(anchorDay int, t time.Time) time.Time {
+	t = t.UTC()
+	next := AnchorDate(t.Year(), t.Month(), anchorDay)
+	if !next.After(t) {
+		next = AnchorDate(t.Year(), t.Month()+1, anchorDay)
+	}
+	return next
+}
+
+// nextPeriodStart is when the subscription's next bill is due: straight away
+// for a subscription that has never billed, otherwise when the current
+// period ends.
+func (s *Subscription) nextPeriodStart(now time.Time) time.Time {
+	if s.CurrentPeriodEnd != nil {
+		return *s.CurrentPeriodEnd
+	}
+	return now
+}
+
+// billRequest opens the bill for the period starting at start. The
+// idempotency key makes a retried renewal return the same bill.
+func (s *Subscription) billRequest(start, end time.Time) *CreateBillRequest {
+	return &CreateBillRequest{
+		CustomerID:     s.CustomerID,
+		Currency:       s.Currency,
+		PeriodStart:    &start,
+		PeriodEnd:      &end,
+		IdempotencyKey: fmt.Sprintf("%s-%s", s.ID, start.UTC().Format(time.RFC3339)),
+	}
+}
+
+// planCharge is the recurring charge for the period from start to end. A
+// period that starts after its anchor date, like the first one of a
+// subscription created between anchors, is prorated by day over the whole
+// anchor period it ends.
+func (s *Subscription) planCharge(start, end time.Time) (*AddLineItemRequest, error) {
+	end = end.UTC()
+	anchored := proration.Period{Start: AnchorDate(end.Year(), end.Month()-1, s.AnchorDay), End: end}
+	fraction, err := proration.Remaining(anchored, start, proration.Day)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prorate plan charge: %w", err)
+	}
+	amount, err := proration.Prorate(s.PlanAmount, fraction, s.Currency.ProrationRounding())
+	if err != nil {
+		return nil, fmt.Errorf("failed to prorate plan charge: %w", err)
+	}
+	return &AddLineItemRequest{
+		Description:    fmt.Sprintf("%s plan, %s to %s", s.Plan, start.UTC().Format(time.DateOnly), end.Format(time.DateOnly)),
+		Amount:         amount,
+		Kind:           LineItemKindCharge,
+		IdempotencyKey: fmt.Sprintf("plan-%s", start.UTC().Format(time.RFC3339)),
+	}, nil
+}
+
+type CreateSubscriptionRequest struct {
+	CustomerID string `json:"customerId"`
+	Plan       string `json:"plan"`
+	// PlanAmount is charged at the start of every period, in minor units.
+	// Free plans still get a bill for usage and other items.
+	PlanAmount int64    `json:"planAmount"`
+	Currency   Currency `json:"currency"`
+	// AnchorDay is the day of month periods start on, the day the
+	// subscription is created if unset.
+	AnchorDay int `json:"anchorDay,omitempty"`
+}
+
+func (r *CreateSubscriptionRequest) Validate() error {
+	if strings.TrimSpace(r.CustomerID) == "" {
+		return ErrEmptyCustomerID
+	}
+	plan := strings.TrimSpace(r.Plan)
+	if plan == "" || len(plan) > maxPlanLength {
+		return fmt.Errorf("%w: plan must be 1 to %d characters", ErrInvalidSubscription, maxPlanLength)
+	}
+	if r.PlanAmount < 0 {
+		return fmt.Errorf("%w: planAmount cannot be negative", ErrInvalidSubscription)
+	}
+	if err := r.Currency.Validate(); err != nil {
+		return err
+	}
+	if r.AnchorDay < 0 || r.AnchorDay > 31 {
+		return fmt.Errorf("%w: anchorDay must be between 1 and 31", ErrInvalidSubscription)
+	}
+	return nil
+}
+
+// ChangePlanRequest moves a subscription to another plan or price at
+// EffectiveAt. The bill of the current period gets a PRORATION credit for
+// what is left of the old price and a charge for the same time at the new
+// one; later periods are billed at the new price.
+type ChangePlanRequest struct {
+	// Plan is the current plan if unset, to change only the price.
+	Plan       string `json:"plan,omitempty"`
+	PlanAmount int64  `json:"planAmount"`
+	// EffectiveAt is required and can't be in the future. It keys the
+	// proration items, so retries with the same EffectiveAt don't prorate
+	// twice.
+	EffectiveAt *time.Time `json:"effectiveAt"`
+	// Granularity is DAY unless set to SECOND.
+	Granularity proration.Granularity `json:"granularity,omitempty"`
+}
+
+func (r *ChangePlanRequest) Validate(now time.Time) error {
+	if len(strings.TrimSpace(r.Plan)) > maxPlanLength {
+		return fmt.Errorf("%w: plan must be at most %d characters", ErrInvalidSubscription, maxPlanLength)
+	}
+	if r.PlanAmount < 0 {
+		return fmt.Errorf("%w: planAmount cannot be negative", ErrInvalidSubscription)
+	}
+	if r.EffectiveAt == nil || r.EffectiveAt.IsZero() {
+		return fmt.Errorf("%w: effectiveAt is required", ErrInvalidSubscription)
+	}
+	if r.EffectiveAt.After(now) {
+		return fmt.Errorf("%w: effectiveAt cannot be in the future", ErrInvalidSubscription)
+	}
+	if r.Granularity != "" && !r.Granularity.IsValid() {
+		return fmt.Errorf("%w: granularity must be DAY or SECOND", ErrInvalidSubscription)
+	}
+	return nil
+}
+
+type ChangePlanResponse struct {
+	Subscription *Subscription `json:"subscription"`
+	// BillID is the bill the change was prorated on, empty if there was
+	// no open bill.
+	BillID       string `json:"billId,omitempty"`
+	CreditAmount int64  `json:"creditAmount"`
+	ChargeAmount int64  `json:"chargeAmount"`
+}
+
+// prorationItems are the credit for the old plan and the charge for the new
+// one from at to the end of the period. Zero amounts are left out.
+func (s *Subscription) prorationItems(change proration.Change, plan string, at, end time.Time) []*AddLineItemRequest {
+	span := fmt.Sprintf("%s to %s", at.UTC().Format(time.DateOnly), end.UTC().Format(time.DateOnly))
+	key := fmt.Sprintf("plan-change-%s", at.UTC().Format(time.RFC3339Nano))
+
+	var items []*AddLineItemRequest
+	if change.Credit > 0 {
+		items = append(items, &AddLineItemRequest{
+			Description:    fmt.Sprintf("Unused %s plan, %s", s.Plan, span),
+			Amount:         change.Credit,
+			Kind:           LineItemKindCredit,
+			ReasonCode:     CreditReasonProration,
+			IdempotencyKey: key + "-credit",
+		})
+	}
+	if change.Charge > 0 {
+		items = append(items, &AddLineItemRequest{
+			Description:    fmt.Sprintf("%s plan, %s", plan, span),
+			Amount:         change.Charge,
+			Kind:           LineItemKindCharge,
+			IdempotencyKey: key + "-charge",
+		})
+	}
+	return items
+}
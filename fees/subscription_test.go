@@ -0,0 +1,180 @@
+package fees
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"pave-fees/fees/internal/proration"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNextAnchorDate(t *testing.T) {
+	at := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2026, month, day, hour, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name      string
+		anchorDay int
+		t         time.Time
+		want      time.Time
+	}{
+		{"later this month", 15, at(3, 10, 12), at(3, 15, 0)},
+		{"on the anchor", 15, at(3, 15, 0), at(4, 15, 0)},
+		{"later on the anchor day", 15, at(3, 15, 12), at(4, 15, 0)},
+		{"clamped to february", 31, at(2, 1, 0), at(2, 28, 0)},
+		{"after a clamped anchor", 31, at(2, 28, 0), at(3, 31, 0)},
+		{"across the year", 1, at(12, 20, 0), time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"converts to UTC", 10, time.Date(2026, 3, 10, 1, 0, 0, 0, time.FixedZone("GET", 4*60*60)), at(3, 10, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, NextAnchorDate(tt.anchorDay, tt.t))
+		})
+	}
+}
+
+func TestSubscriptionStatus_CanBecome(t *testing.T) {
+	tests := []struct {
+		from SubscriptionStatus
+		to   SubscriptionStatus
+		want bool
+	}{
+		{SubscriptionStatusActive, SubscriptionStatusPaused, true},
+		{SubscriptionStatusActive, SubscriptionStatusCancelled, true},
+		{SubscriptionStatusPaused, SubscriptionStatusActive, true},
+		{SubscriptionStatusPaused, SubscriptionStatusCancelled, true},
+		{SubscriptionStatusCancelled, SubscriptionStatusActive, false},
+		{SubscriptionStatusCancelled, SubscriptionStatusPaused, false},
+		{SubscriptionStatusActive, SubscriptionStatusActive, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.from)+" to "+string(tt.to), func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.from.CanBecome(tt.to))
+		})
+	}
+}
+
+func TestSubscription_Renewal(t *testing.T) {
+	start := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2026, 4, 15, 0, 0, 0, 0, time.UTC)
+	sub := &Subscription{ID: "sub-1", CustomerID: "customer-1", Plan: "Pro", PlanAmount: 4900, Currency: USD, AnchorDay: 15}
+
+	bill := sub.billRequest(start, end)
+	assert.NoError(t, bill.Validate())
+	assert.Equal(t, &start, bill.PeriodStart)
+	assert.Equal(t, &end, bill.PeriodEnd)
+	assert.Equal(t, "sub-1-2026-03-15T00:00:00Z", bill.IdempotencyKey)
+
+	charge, err := sub.planCharge(start, end)
+	require.NoError(t, err)
+	assert.NoError(t, charge.Validate())
+	assert.Equal(t, "Pro plan, 2026-03-15 to 2026-04-15", charge.Description)
+	assert.Equal(t, int64(4900), charge.Amount)
+
+	// Created on the 25th, 21 of the 31 days to the anchor are billed.
+	stub, err := sub.planCharge(time.Date(2026, 3, 25, 10, 30, 0, 0, time.UTC), end)
+	require.NoError(t, err)
+	assert.Equal(t, "Pro plan, 2026-03-25 to 2026-04-15", stub.Description)
+	assert.Equal(t, int64(3319), stub.Amount)
+	assert.Equal(t, "plan-2026-03-25T10:30:00Z", stub.IdempotencyKey)
+
+	// Any time on the anchor day is a full period.
+	full, err := sub.planCharge(start.Add(9*time.Hour), end)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4900), full.Amount)
+
+	now := start.Add(-time.Hour)
+	assert.Equal(t, now, sub.nextPeriodStart(now))
+	sub.CurrentPeriodEnd = &end
+	assert.Equal(t, end, sub.nextPeriodStart(now))
+}
+
+func TestCreateSubscriptionRequest_Validate(t *testing.T) {
+	valid := func() CreateSubscriptionRequest {
+		return CreateSubscriptionRequest{CustomerID: "customer-1", Plan: "Pro", PlanAmount: 4900, Currency: USD}
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(r *CreateSubscriptionRequest)
+		wantErr error
+	}{
+		{"valid", func(r *CreateSubscriptionRequest) {}, nil},
+		{"free plan", func(r *CreateSubscriptionRequest) { r.PlanAmount = 0 }, nil},
+		{"anchor day", func(r *CreateSubscriptionRequest) { r.AnchorDay = 31 }, nil},
+		{"no customer", func(r *CreateSubscriptionRequest) { r.CustomerID = " " }, ErrEmptyCustomerID},
+		{"no plan", func(r *CreateSubscriptionRequest) { r.Plan = "" }, ErrInvalidSubscription},
+		{"negative amount", func(r *CreateSubscriptionRequest) { r.PlanAmount = -1 }, ErrInvalidSubscription},
+		{"bad currency", func(r *CreateSubscriptionRequest) { r.Currency = "ABC" }, ErrInvalidCurrency},
+		{"anchor day too late", func(r *CreateSubscriptionRequest) { r.AnchorDay = 32 }, ErrInvalidSubscription},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := valid()
+			tt.modify(&req)
+			err := req.Validate()
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestChangePlanRequest_Validate(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		req     ChangePlanRequest
+		wantErr error
+	}{
+		{"price only", ChangePlanRequest{PlanAmount: 9900, EffectiveAt: &past}, nil},
+		{"in the past by the second", ChangePlanRequest{Plan: "Business", EffectiveAt: &past, Granularity: proration.Second}, nil},
+		{"plan too long", ChangePlanRequest{Plan: strings.Repeat("x", maxPlanLength+1), EffectiveAt: &past}, ErrInvalidSubscription},
+		{"negative amount", ChangePlanRequest{PlanAmount: -1, EffectiveAt: &past}, ErrInvalidSubscription},
+		{"in the future", ChangePlanRequest{EffectiveAt: &future}, ErrInvalidSubscription},
+		{"no effectiveAt", ChangePlanRequest{PlanAmount: 9900}, ErrInvalidSubscription},
+		{"unknown granularity", ChangePlanRequest{EffectiveAt: &past, Granularity: "HOUR"}, ErrInvalidSubscription},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate(now)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSubscription_ProrationItems(t *testing.T) {
+	at := time.Date(2026, 4, 25, 9, 30, 0, 0, time.UTC)
+	end := time.Date(2026, 5, 15, 0, 0, 0, 0, time.UTC)
+	sub := &Subscription{ID: "sub-1", Plan: "Pro", PlanAmount: 4900}
+
+	items := sub.prorationItems(proration.Change{Credit: 3267, Charge: 6600}, "Business", at, end)
+
+	assert.Len(t, items, 2)
+	for _, item := range items {
+		assert.NoError(t, item.Validate())
+	}
+	assert.Equal(t, "Unused Pro plan, 2026-04-25 to 2026-05-15", items[0].Description)
+	assert.Equal(t, CreditReasonProration, items[0].ReasonCode)
+	assert.Equal(t, "plan-change-2026-04-25T09:30:00Z-credit", items[0].IdempotencyKey)
+	assert.Equal(t, "Business plan, 2026-04-25 to 2026-05-15", items[1].Description)
+	assert.Equal(t, "plan-change-2026-04-25T09:30:00Z-charge", items[1].IdempotencyKey)
+
+	assert.Len(t, sub.prorationItems(proration.Change{Credit: 3267}, "Free", at, end), 1)
+}
@@ -0,0 +1,176 @@
+package fees
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrTaxRuleNotFound = errors.New("tax rule not found")
+	ErrInvalidTaxRule  = errors.New("invalid tax rule")
+)
+
+// TaxRule taxes every bill in its currency when it closes, except for the
+// listed customers. Exclusive rules add tax on top of the pre-tax total;
+// inclusive rules treat the total as already containing the tax and only
+// break it out.
+type TaxRule struct {
+	ID              string    `json:"id"`
+	Name            string    `json:"name"`
+	Jurisdiction    string    `json:"jurisdiction"`
+	Currency        Currency  `json:"currency"`
+	RateBps         int64     `json:"rateBps"`
+	Inclusive       bool      `json:"inclusive"`
+	ExemptCustomers []string  `json:"exemptCustomers,omitempty"`
+	UpdatedAt       time.Time `json:"updatedAt"`
+}
+
+func (tr *TaxRule) Validate() error {
+	if strings.TrimSpace(tr.ID) == "" {
+		return fmt.Errorf("%w: id cannot be empty", ErrInvalidTaxRule)
+	}
+	if strings.TrimSpace(tr.Name) == "" {
+		return fmt.Errorf("%w: name cannot be empty", ErrInvalidTaxRule)
+	}
+	if strings.TrimSpace(tr.Jurisdiction) == "" {
+		return fmt.Errorf("%w: jurisdiction cannot be empty", ErrInvalidTaxRule)
+	}
+	if err := tr.Currency.Validate(); err != nil {
+		return err
+	}
+	if tr.RateBps < 0 || tr.RateBps > basisPointsPerUnit {
+		return fmt.Errorf("%w: rate must be between 0 and %d bps", ErrInvalidTaxRule, basisPointsPerUnit)
+	}
+	for _, customerID := range tr.ExemptCustomers {
+		if strings.TrimSpace(customerID) == "" {
+			return fmt.Errorf("%w: exempt customer IDs cannot be empty", ErrInvalidTaxRule)
+		}
+	}
+	return nil
+}
+
+func (tr *TaxRule) Exempts(customerID string) bool {
+	for _, exempt := range tr.ExemptCustomers {
+		if exempt == customerID {
+			return true
+		}
+	}
+	return false
+}
+
+// TaxLine is the tax one rule charged on a bill. The rule's name, rate and
+// mode are copied so the line survives later edits to the rule.
+type TaxLine struct {
+	RuleID        string `json:"ruleId"`
+	Name          string `json:"name"`
+	Jurisdiction  string `json:"jurisdiction"`
+	RateBps       int64  `json:"rateBps"`
+	Inclusive     bool   `json:"inclusive"`
+	TaxableAmount int64  `json:"taxableAmount"`
+	Amount        int64  `json:"amount"`
+}
+
+// BillTax splits a bill's pre-tax total into net subtotal and tax. Total is
+// always Subtotal + TaxAmount.
+type BillTax struct {
+	Subtotal  int64
+	TaxAmount int64
+	Total     int64
+	Lines     []TaxLine
+}
+
+// ApplyTaxRules taxes amount, the bill total after credits and fees, under
+// every rule that doesn't exempt the customer. Inclusive tax is extracted
+// from amount first; exclusive tax is then charged on the remaining net.
+// Nothing is taxed when amount is zero or negative. Rounds half up per rule.
+func ApplyTaxRules(rules []TaxRule, customerID string, amount int64) BillTax {
+	tax := BillTax{Subtotal: amount, Total: amount}
+	if amount <= 0 {
+		return tax
+	}
+
+	var applicable []TaxRule
+	var inclusiveBps int64
+	for _, rule := range rules {
+		if rule.Exempts(customerID) {
+			continue
+		}
+		applicable = append(applicable, rule)
+		if rule.Inclusive {
+			inclusiveBps += rule.RateBps
+		}
+	}
+
+	net := amount
+	for _, rule := range applicable {
+		if !rule.Inclusive {
+			continue
+		}
+		divisor := basisPointsPerUnit + inclusiveBps
+		line := taxLine(rule, amount, (amount*rule.RateBps+divisor/2)/divisor)
+		net -= line.Amount
+		tax.Lines = append(tax.Lines, line)
+	}
+	for _, rule := range applicable {
+		if rule.Inclusive {
+			continue
+		}
+		line := taxLine(rule, net, (net*rule.RateBps+basisPointsPerUnit/2)/basisPointsPerUnit)
+		tax.Lines = append(tax.Lines, line)
+	}
+
+	tax.Subtotal = net
+	for _, line := range tax.Lines {
+		tax.TaxAmount += line.Amount
+	}
+	tax.Total = tax.Subtotal + tax.TaxAmount
+	return tax
+}
+
+func taxLine(rule TaxRule, taxable, amount int64) TaxLine {
+	return TaxLine{
+		RuleID:        rule.ID,
+		Name:          rule.Name,
+		Jurisdiction:  rule.Jurisdiction,
+		RateBps:       rule.RateBps,
+		Inclusive:     rule.Inclusive,
+		TaxableAmount: taxable,
+		Amount:        amount,
+	}
+}
+
+type SaveTaxRuleRequest struct {
+	ID              string   `json:"id"`
+	Name            string   `json:"name"`
+	Jurisdiction    string   `json:"jurisdiction"`
+	Currency        Currency `json:"currency"`
+	RateBps         int64    `json:"rateBps"`
+	Inclusive       bool     `json:"inclusive"`
+	ExemptCustomers []string `json:"exemptCustomers,omitempty"`
+}
+
+func (r *SaveTaxRuleRequest) Validate() error {
+	return r.toRule().Validate()
+}
+
+func (r *SaveTaxRuleRequest) toRule() *TaxRule {
+	return &TaxRule{
+		ID:              r.ID,
+		Name:            r.Name,
+		Jurisdiction:    r.Jurisdiction,
+		Currency:        r.Currency,
+		RateBps:         r.RateBps,
+		Inclusive:       r.Inclusive,
+		ExemptCustomers: r.ExemptCustomers,
+	}
+}
+
+type ListTaxRulesRequest struct {
+	Currency Currency `query:"currency"`
+}
+
+type ListTaxRulesResponse struct {
+	Rules []*TaxRule `json:"rules"`
+}
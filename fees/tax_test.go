@@ -0,0 +1,89 @@
+package fees
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaxRule_Validate(t *testing.T) {
+	valid := TaxRule{ID: "ge-vat", Name: "VAT", Jurisdiction: "GE", Currency: GEL, RateBps: 1800}
+
+	tests := []struct {
+		name    string
+		modify  func(*TaxRule)
+		wantErr error
+	}{
+		{"valid", func(r *TaxRule) {}, nil},
+		{"zero rate", func(r *TaxRule) { r.RateBps = 0 }, nil},
+		{"missing id", func(r *TaxRule) { r.ID = " " }, ErrInvalidTaxRule},
+		{"missing name", func(r *TaxRule) { r.Name = "" }, ErrInvalidTaxRule},
+		{"missing jurisdiction", func(r *TaxRule) { r.Jurisdiction = "" }, ErrInvalidTaxRule},
+		{"negative rate", func(r *TaxRule) { r.RateBps = -1 }, ErrInvalidTaxRule},
+		{"rate above 100%", func(r *TaxRule) { r.RateBps = 10001 }, ErrInvalidTaxRule},
+		{"empty exempt customer", func(r *TaxRule) { r.ExemptCustomers = []string{""} }, ErrInvalidTaxRule},
+		{"disabled currency", func(r *TaxRule) { r.Currency = "EUR" }, ErrInvalidCurrency},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := valid
+			tt.modify(&rule)
+			err := rule.Validate()
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestApplyTaxRules(t *testing.T) {
+	geVAT := TaxRule{ID: "ge-vat", Name: "VAT", Jurisdiction: "GE", Currency: GEL, RateBps: 1800,
+		ExemptCustomers: []string{"customer-exempt"}}
+	inclusiveVAT := TaxRule{ID: "ge-vat-incl", Name: "VAT", Jurisdiction: "GE", Currency: GEL, RateBps: 1800, Inclusive: true}
+
+	tests := []struct {
+		name         string
+		rules        []TaxRule
+		customerID   string
+		amount       int64
+		wantSubtotal int64
+		wantTax      int64
+		wantTotal    int64
+	}{
+		{"georgian VAT on top", []TaxRule{geVAT}, "customer-1", 10000, 10000, 1800, 11800},
+		{"rounds half up", []TaxRule{geVAT}, "customer-1", 25, 25, 5, 30},
+		{"exempt customer", []TaxRule{geVAT}, "customer-exempt", 10000, 10000, 0, 10000},
+		{"inclusive VAT is broken out", []TaxRule{inclusiveVAT}, "customer-1", 11800, 10000, 1800, 11800},
+		{"inclusive then exclusive", []TaxRule{inclusiveVAT, {ID: "levy", Name: "Levy", Jurisdiction: "GE", Currency: GEL, RateBps: 100}},
+			"customer-1", 11800, 10000, 1900, 11900},
+		{"no rules", nil, "customer-1", 10000, 10000, 0, 10000},
+		{"zero total", []TaxRule{geVAT}, "customer-1", 0, 0, 0, 0},
+		{"negative total is not taxed", []TaxRule{geVAT}, "customer-1", -500, -500, 0, -500},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tax := ApplyTaxRules(tt.rules, tt.customerID, tt.amount)
+			assert.Equal(t, tt.wantSubtotal, tax.Subtotal)
+			assert.Equal(t, tt.wantTax, tax.TaxAmount)
+			assert.Equal(t, tt.wantTotal, tax.Total)
+		})
+	}
+
+	t.Run("records a line per rule", func(t *testing.T) {
+		tax := ApplyTaxRules([]TaxRule{geVAT}, "customer-1", 10000)
+		require.Len(t, tax.Lines, 1)
+		assert.Equal(t, TaxLine{
+			RuleID:        "ge-vat",
+			Name:          "VAT",
+			Jurisdiction:  "GE",
+			RateBps:       1800,
+			TaxableAmount: 10000,
+			Amount:        1800,
+		}, tax.Lines[0])
+	})
+}
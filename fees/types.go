@@ -1,6 +1,8 @@
 package fees
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"strings"
@@ -15,8 +17,33 @@ var (
 	ErrEmptyDescription  = errors.New("description cannot be empty")
 	ErrEmptyCustomerID   = errors.New("customer ID cannot be empty")
 	ErrInvalidBillID     = errors.New("invalid bill ID format")
+	ErrUpdateRejected    = errors.New("rejected by bill workflow")
+	ErrInvalidPeriod     = errors.New("invalid billing period")
+	ErrInvalidItemKind   = errors.New("invalid line item kind")
+	ErrInvalidReasonCode = errors.New("invalid credit reason code")
+	ErrNegativeTotal     = errors.New("bill total cannot go below zero")
+	ErrLineItemNotFound  = errors.New("line item not found")
+	ErrLineItemVoided    = errors.New("line item is already voided")
+	ErrBillVoided        = errors.New("bill is voided")
+	ErrInvalidVoidReason = errors.New("invalid void reason")
+
+	ErrInvalidIdempotencyKey = errors.New("invalid idempotency key")
+	ErrIdempotencyKeyReused  = errors.New("idempotency key was already used for a different request")
 )
 
+// errDuplicateIdempotencyKey is returned by the repository when a row with the
+// same idempotency key already exists.
+var errDuplicateIdempotencyKey = errors.New("duplicate idempotency key")
+
+const maxIdempotencyKeyLength = 255
+
+func validateIdempotencyKey(key string) error {
+	if len(key) > maxIdempotencyKeyLength {
+		return fmt.Errorf("%w: longer than %d characters", ErrInvalidIdempotencyKey, maxIdempotencyKeyLength)
+	}
+	return nil
+}
+
 type Currency string
 
 const (
@@ -24,44 +51,267 @@ const (
 	GEL Currency = "GEL"
 )
 
+// IsValid reports whether c is an ISO 4217 code enabled on this deployment.
 func (c Currency) IsValid() bool {
-	return c == USD || c == GEL
+	if !c.IsKnown() {
+		return false
+	}
+	for _, enabled := range EnabledCurrencies() {
+		if c == enabled {
+			return true
+		}
+	}
+	return false
 }
 
 func (c Currency) Validate() error {
 	if !c.IsValid() {
-		return fmt.Errorf("%w: %s. Supported currencies: USD, GEL", ErrInvalidCurrency, c)
+		enabled := EnabledCurrencies()
+		codes := make([]string, len(enabled))
+		for i, e := range enabled {
+			codes[i] = string(e)
+		}
+		return fmt.Errorf("%w: %s. Supported currencies: %s", ErrInvalidCurrency, c, strings.Join(codes, ", "))
 	}
 	return nil
 }
 
+// IsKnown reports whether c is in the ISO 4217 registry, enabled or not.
+func (c Currency) IsKnown() bool {
+	_, ok := currencyExponents[c]
+	return ok
+}
+
+// Exponent is the number of minor-unit digits, 2 for USD and 0 for JPY.
+// Codes outside the registry report 2.
+func (c Currency) Exponent() int {
+	if exponent, ok := currencyExponents[c]; ok {
+		return exponent
+	}
+	return 2
+}
+
+// FormatAmount renders an amount in minor units as a decimal in the major
+// unit, e.g. 123456 is "1234.56" in USD, "123456" in JPY and "123.456" in KWD.
+func (c Currency) FormatAmount(amount int64) string {
+	return formatFixedPoint(amount, c.Exponent(), true)
+}
+
+// ParseAmount converts a decimal in the major unit ("12.50") to minor units.
+// It rejects more decimal places than the currency has.
+func (c Currency) ParseAmount(s string) (int64, error) {
+	if strings.HasSuffix(s, ".") {
+		return 0, fmt.Errorf("%w: %q is not a number", ErrInvalidAmount, s)
+	}
+	amount, err := parseFixedPoint(s, c.Exponent())
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s: %v", ErrInvalidAmount, c, err)
+	}
+	return amount, nil
+}
+
 type BillStatus string
 
 const (
 	BillStatusOpen   BillStatus = "OPEN"
 	BillStatusClosed BillStatus = "CLOSED"
+	// Voided bills were abandoned while open. They were never priced or
+	// invoiced and are left out of bill lists unless asked for.
+	BillStatusVoided BillStatus = "VOIDED"
 )
 
 func (bs BillStatus) IsValid() bool {
-	return bs == BillStatusOpen || bs == BillStatusClosed
+	return bs == BillStatusOpen || bs == BillStatusClosed || bs == BillStatusVoided
+}
+
+type BillingPeriod string
+
+const (
+	BillingPeriodDaily   BillingPeriod = "DAILY"
+	BillingPeriodWeekly  BillingPeriod = "WEEKLY"
+	BillingPeriodMonthly BillingPeriod = "MONTHLY"
+)
+
+func (p BillingPeriod) IsValid() bool {
+	return p == BillingPeriodDaily || p == BillingPeriodWeekly || p == BillingPeriodMonthly
+}
+
+// End returns when a period of this length that starts at start is over.
+// Monthly periods keep the day of month, clamped to the last day of shorter
+// months, so a bill opened on Jan 31 closes on Feb 28/29.
+func (p BillingPeriod) End(start time.Time) time.Time {
+	switch p {
+	case BillingPeriodDaily:
+		return start.AddDate(0, 0, 1)
+	case BillingPeriodWeekly:
+		return start.AddDate(0, 0, 7)
+	case BillingPeriodMonthly:
+		firstOfNext := time.Date(start.Year(), start.Month()+1, 1, start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location())
+		lastDay := firstOfNext.AddDate(0, 1, -1).Day()
+		day := start.Day()
+		if day > lastDay {
+			day = lastDay
+		}
+		return firstOfNext.AddDate(0, 0, day-1)
+	}
+	return start
+}
+
+type LineItemKind string
+
+const (
+	LineItemKindCharge LineItemKind = "CHARGE"
+	LineItemKindFee    LineItemKind = "FEE"
+	// Credits carry a negative amount and reduce the bill total.
+	LineItemKindCredit LineItemKind = "CREDIT"
+	// Discounts are negative items generated from promo codes at close.
+	LineItemKindDiscount LineItemKind = "DISCOUNT"
+	// True-ups raise a bill to its minimum charge at close; cap credits
+	// bring it down to its maximum.
+	LineItemKindTrueUp    LineItemKind = "TRUE_UP"
+	LineItemKindCapCredit LineItemKind = "CAP_CREDIT"
+	// Usage items price a bill's metered usage at close.
+	LineItemKindUsage LineItemKind = "USAGE"
+)
+
+type CreditReason string
+
+const (
+	CreditReasonOutage       CreditReason = "OUTAGE"
+	CreditReasonBillingError CreditReason = "BILLING_ERROR"
+	CreditReasonRefund       CreditReason = "REFUND"
+	CreditReasonGoodwill     CreditReason = "GOODWILL"
+	CreditReasonOther        CreditReason = "OTHER"
+	// Proration credits give back the unused part of a plan that changed
+	// mid-period.
+	CreditReasonProration CreditReason = "PRORATION"
+)
+
+func (r CreditReason) IsValid() bool {
+	switch r {
+	case CreditReasonOutage, CreditReasonBillingError, CreditReasonRefund, CreditReasonGoodwill, CreditReasonOther,
+		CreditReasonProration:
+		return true
+	}
+	return false
+}
+
+func (r CreditReason) Validate() error {
+	if !r.IsValid() {
+		return fmt.Errorf("%w: %q. Supported reasons: OUTAGE, BILLING_ERROR, REFUND, GOODWILL, OTHER, PRORATION", ErrInvalidReasonCode, r)
+	}
+	return nil
 }
 
+// LineItem.Amount is always the extended amount, Quantity x UnitPrice.
+// Items recorded before quantities existed carry neither and count as a
+// single unit of Amount. Credits have a negative UnitPrice and Amount.
 type LineItem struct {
-	Description string    `json:"description"`
-	Amount      int64     `json:"amount"`
-	Timestamp   time.Time `json:"timestamp"`
+	ID          string       `json:"id,omitempty"`
+	Description string       `json:"description"`
+	Amount      int64        `json:"amount"`
+	Quantity    Quantity     `json:"quantity,omitempty"`
+	UnitPrice   int64        `json:"unitPrice,omitempty"`
+	Timestamp   time.Time    `json:"timestamp"`
+	Kind        LineItemKind `json:"kind,omitempty"`
+	ReasonCode  CreditReason `json:"reasonCode,omitempty"`
+	// Voided items stay on the bill for audit but no longer count.
+	VoidedAt   *time.Time `json:"voidedAt,omitempty"`
+	VoidReason string     `json:"voidReason,omitempty"`
+	// IdempotencyKey is only persisted, never sent to the workflow.
+	IdempotencyKey string `json:"-"`
+	// FX is set on items priced in another currency and converted into the
+	// bill currency; Amount is the converted amount.
+	FX *FXConversion `json:"fx,omitempty"`
+	// SystemGenerated marks items the bill added itself at close (usage,
+	// fees, discounts, true-ups and cap credits) rather than ones sent by a
+	// client.
+	SystemGenerated bool `json:"systemGenerated,omitempty"`
+}
+
+func newLineItemID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("failed to generate line item ID: %v", err))
+	}
+	return "item-" + hex.EncodeToString(b)
+}
+
+func (li *LineItem) IsVoided() bool {
+	return li.VoidedAt != nil
+}
+
+// SamePricing reports whether two items describe the same charge, ignoring
+// IDs, timestamps and void state.
+// Converted items are compared by their original pricing, not by the
+// converted amount, so a retry at a different rate still matches.
+func (li *LineItem) SamePricing(other *LineItem) bool {
+	if li.Description != other.Description || li.Kind != other.Kind || li.ReasonCode != other.ReasonCode {
+		return false
+	}
+	if li.FX != nil || other.FX != nil {
+		return li.FX != nil && other.FX != nil &&
+			li.FX.Currency == other.FX.Currency &&
+			li.FX.Amount == other.FX.Amount &&
+			li.FX.Quantity == other.FX.Quantity &&
+			li.FX.UnitPrice == other.FX.UnitPrice
+	}
+	return li.Amount == other.Amount &&
+		li.Quantity == other.Quantity &&
+		li.UnitPrice == other.UnitPrice
 }
 
 func (li *LineItem) Validate() error {
 	if strings.TrimSpace(li.Description) == "" {
 		return ErrEmptyDescription
 	}
-	if li.Amount <= 0 {
+
+	sign := int64(1)
+	switch li.Kind {
+	case "", LineItemKindCharge, LineItemKindFee, LineItemKindTrueUp, LineItemKindUsage:
+	case LineItemKindCredit:
+		if err := li.ReasonCode.Validate(); err != nil {
+			return err
+		}
+		sign = -1
+	case LineItemKindDiscount, LineItemKindCapCredit:
+		sign = -1
+	default:
+		return fmt.Errorf("%w: %s", ErrInvalidItemKind, li.Kind)
+	}
+
+	if li.Quantity != 0 || li.UnitPrice != 0 {
+		if li.Quantity <= 0 {
+			return ErrInvalidQuantity
+		}
+		if li.UnitPrice*sign <= 0 {
+			return ErrInvalidAmount
+		}
+		amount, err := ExtendedAmount(li.Quantity, li.UnitPrice)
+		if err != nil {
+			return err
+		}
+		if amount != li.Amount {
+			return fmt.Errorf("%w: %d != %s x %d", ErrAmountMismatch, li.Amount, li.Quantity, li.UnitPrice)
+		}
+	}
+	if li.Amount*sign <= 0 {
 		return ErrInvalidAmount
 	}
 	return nil
 }
 
+func (li *LineItem) ExtendedAmount() int64 {
+	if li.Quantity == 0 && li.UnitPrice == 0 {
+		return li.Amount
+	}
+	amount, err := ExtendedAmount(li.Quantity, li.UnitPrice)
+	if err != nil {
+		return li.Amount
+	}
+	return amount
+}
+
 type Bill struct {
 	ID          string     `json:"id"`
 	CustomerID  string     `json:"customerId"`
@@ -70,6 +320,47 @@ type Bill struct {
 	LineItems   []LineItem `json:"lineItems"`
 	TotalAmount int64      `json:"totalAmount"`
 	CreatedAt   time.Time  `json:"createdAt"`
+	PeriodStart *time.Time `json:"periodStart,omitempty"`
+	PeriodEnd   *time.Time `json:"periodEnd,omitempty"`
+	// FeeScheduleID pins a schedule to the bill; otherwise the customer's
+	// schedule for the currency is used. FeeScheduleVersion is set at close.
+	FeeScheduleID      string `json:"feeScheduleId,omitempty"`
+	FeeScheduleVersion *int   `json:"feeScheduleVersion,omitempty"`
+	// AllowNegativeTotal lets credits take the bill below zero.
+	AllowNegativeTotal bool   `json:"allowNegativeTotal,omitempty"`
+	IdempotencyKey     string `json:"-"`
+	// MinimumAmount and MaximumAmount override the customer's billing
+	// limits for this bill.
+	MinimumAmount *int64 `json:"minimumAmount,omitempty"`
+	MaximumAmount *int64 `json:"maximumAmount,omitempty"`
+	// LegalEntity issues the bill's invoice; InvoiceNumber is set at close.
+	LegalEntity   string `json:"legalEntity,omitempty"`
+	InvoiceNumber string `json:"invoiceNumber,omitempty"`
+	// PaymentTermsDays sets DueAt that many days after close. DunningStatus
+	// and DunningStage follow the reminders sent once the bill is past due.
+	PaymentTermsDays *int          `json:"paymentTermsDays,omitempty"`
+	DueAt            *time.Time    `json:"dueAt,omitempty"`
+	DunningStatus    DunningStatus `json:"dunningStatus,omitempty"`
+	DunningStage     int           `json:"dunningStage,omitempty"`
+	// Set at close: SubtotalAmount is the net before tax and TotalAmount is
+	// SubtotalAmount + TaxAmount.
+	SubtotalAmount int64     `json:"subtotalAmount"`
+	TaxAmount      int64     `json:"taxAmount"`
+	Taxes          []TaxLine `json:"taxes,omitempty"`
+	// Discounts are the promo codes attached to the bill, in the order they
+	// apply at close.
+	Discounts []BillDiscount `json:"discounts,omitempty"`
+	// PaidAmount sums the payments recorded against the bill. PaymentStatus
+	// is derived from it once the bill is closed.
+	PaidAmount    int64         `json:"paidAmount"`
+	PaymentStatus PaymentStatus `json:"paymentStatus,omitempty"`
+	// VoidedAt and VoidReason are set on voided bills.
+	VoidedAt   *time.Time `json:"voidedAt,omitempty"`
+	VoidReason string     `json:"voidReason,omitempty"`
+	// ClosedAt is set while the bill is closed. CorrectsBillID is set on
+	// adjustment bills, naming the closed bill they correct.
+	ClosedAt       *time.Time `json:"closedAt,omitempty"`
+	CorrectsBillID string     `json:"correctsBillId,omitempty"`
 }
 
 type BillSummary struct {
@@ -93,14 +384,52 @@ func (b *Bill) Validate() error {
 	return nil
 }
 
+// CalculateTotal sums the bill's active items plus any exclusive tax charged
+// at close; inclusive tax is already part of the items.
 func (b *Bill) CalculateTotal() int64 {
 	var total int64
 	for _, item := range b.LineItems {
-		total += item.Amount
+		if item.IsVoided() {
+			continue
+		}
+		total += item.ExtendedAmount()
+	}
+	for _, tax := range b.Taxes {
+		if !tax.Inclusive {
+			total += tax.Amount
+		}
 	}
 	return total
 }
 
+func (b *Bill) ActiveItemCount() int {
+	count := 0
+	for _, item := range b.LineItems {
+		if !item.IsVoided() {
+			count++
+		}
+	}
+	return count
+}
+
+// Balance is what is still owed on the bill; negative when overpaid.
+func (b *Bill) Balance() int64 {
+	return b.TotalAmount - b.PaidAmount
+}
+
+// settle derives PaymentStatus for closed bills.
+func (b *Bill) settle() {
+	b.PaymentStatus = ""
+	if b.Status == BillStatusClosed {
+		b.PaymentStatus = SettlementStatus(b.TotalAmount, b.PaidAmount)
+	}
+}
+
+// Limits returns the billing limits set on the bill itself.
+func (b *Bill) Limits() BillingLimits {
+	return BillingLimits{MinimumAmount: b.MinimumAmount, MaximumAmount: b.MaximumAmount}
+}
+
 func (b *Bill) CanAddLineItem() bool {
 	return b.Status == BillStatusOpen
 }
@@ -108,36 +437,290 @@ func (b *Bill) CanAddLineItem() bool {
 type CreateBillRequest struct {
 	CustomerID string   `json:"customerId"`
 	Currency   Currency `json:"currency"`
+	// Either a named Period or an explicit PeriodEnd closes the bill
+	// automatically. PeriodStart defaults to the creation time.
+	Period        BillingPeriod `json:"period,omitempty"`
+	PeriodStart   *time.Time    `json:"periodStart,omitempty"`
+	PeriodEnd     *time.Time    `json:"periodEnd,omitempty"`
+	FeeScheduleID string        `json:"feeScheduleId,omitempty"`
+	// AllowNegativeTotal lets credits take the bill below zero.
+	AllowNegativeTotal bool `json:"allowNegativeTotal,omitempty"`
+	// MinimumAmount and MaximumAmount override the customer's billing
+	// limits for this bill.
+	MinimumAmount *int64 `json:"minimumAmount,omitempty"`
+	MaximumAmount *int64 `json:"maximumAmount,omitempty"`
+	// LegalEntity issues the bill's invoice, DefaultLegalEntity if unset.
+	LegalEntity string `json:"legalEntity,omitempty"`
+	// PaymentTermsDays is how many days after close the bill is due,
+	// DefaultPaymentTermsDays if unset.
+	PaymentTermsDays *int `json:"paymentTermsDays,omitempty"`
+	// PromoCodes are attached to the bill as it is created.
+	PromoCodes []string `json:"promoCodes,omitempty"`
+	// Retries with the same Idempotency-Key return the bill created first.
+	IdempotencyKey string `header:"Idempotency-Key"`
 }
 
 func (r *CreateBillRequest) Validate() error {
 	if strings.TrimSpace(r.CustomerID) == "" {
 		return ErrEmptyCustomerID
 	}
-	return r.Currency.Validate()
+	if err := validateIdempotencyKey(r.IdempotencyKey); err != nil {
+		return err
+	}
+	if err := r.Currency.Validate(); err != nil {
+		return err
+	}
+	if r.Period != "" && !r.Period.IsValid() {
+		return fmt.Errorf("%w: unknown period %s", ErrInvalidPeriod, r.Period)
+	}
+	if r.Period != "" && r.PeriodEnd != nil {
+		return fmt.Errorf("%w: period and periodEnd are mutually exclusive", ErrInvalidPeriod)
+	}
+	if r.PeriodStart != nil && r.Period == "" && r.PeriodEnd == nil {
+		return fmt.Errorf("%w: periodStart needs a period or periodEnd", ErrInvalidPeriod)
+	}
+	if r.PeriodStart != nil && r.PeriodEnd != nil && !r.PeriodEnd.After(*r.PeriodStart) {
+		return fmt.Errorf("%w: periodEnd must be after periodStart", ErrInvalidPeriod)
+	}
+	if r.LegalEntity != "" {
+		if err := ValidateLegalEntity(r.LegalEntity); err != nil {
+			return err
+		}
+	}
+	if r.PaymentTermsDays != nil {
+		if err := ValidatePaymentTerms(*r.PaymentTermsDays); err != nil {
+			return err
+		}
+	}
+	limits := BillingLimits{MinimumAmount: r.MinimumAmount, MaximumAmount: r.MaximumAmount}
+	if err := limits.Validate(); err != nil {
+		return err
+	}
+	seen := make(map[string]bool, len(r.PromoCodes))
+	for _, code := range r.PromoCodes {
+		code = NormalizePromoCode(code)
+		if code == "" {
+			return fmt.Errorf("%w: code cannot be empty", ErrInvalidPromoCode)
+		}
+		if seen[code] {
+			return fmt.Errorf("%w: %s", ErrDiscountAlreadyApplied, code)
+		}
+		seen[code] = true
+	}
+	return nil
+}
+
+// BillingWindow resolves the requested period into concrete bounds, or nil
+// bounds when the bill is closed manually.
+func (r *CreateBillRequest) BillingWindow(now time.Time) (*time.Time, *time.Time) {
+	if r.Period == "" && r.PeriodEnd == nil {
+		return nil, nil
+	}
+
+	start := now
+	if r.PeriodStart != nil {
+		start = *r.PeriodStart
+	}
+
+	end := r.Period.End(start)
+	if r.PeriodEnd != nil {
+		end = *r.PeriodEnd
+	}
+	return &start, &end
 }
 
 type CreateBillResponse struct {
 	BillID string `json:"billId"`
 }
 
+// AddLineItemRequest takes either a bare Amount (one unit) or a UnitPrice
+// with an optional Quantity, in which case Amount is derived and, if sent
+// anyway, has to match. Credits are sent with positive amounts and a
+// ReasonCode; they are stored negated.
 type AddLineItemRequest struct {
-	Description string `json:"description"`
-	Amount      int64  `json:"amount"`
+	Description string       `json:"description"`
+	Amount      int64        `json:"amount,omitempty"`
+	Quantity    *Quantity    `json:"quantity,omitempty"`
+	UnitPrice   *int64       `json:"unitPrice,omitempty"`
+	Kind        LineItemKind `json:"kind,omitempty"`
+	ReasonCode  CreditReason `json:"reasonCode,omitempty"`
+	// Currency prices the item in something other than the bill currency;
+	// it is converted at the current rate when added.
+	Currency Currency `json:"currency,omitempty"`
+	// Retries with the same Idempotency-Key return the item added first.
+	IdempotencyKey string `header:"Idempotency-Key"`
 }
 
 func (r *AddLineItemRequest) Validate() error {
 	if strings.TrimSpace(r.Description) == "" {
 		return ErrEmptyDescription
 	}
-	if r.Amount <= 0 {
-		return ErrInvalidAmount
+	if err := validateIdempotencyKey(r.IdempotencyKey); err != nil {
+		return err
+	}
+	if r.Currency != "" && !r.Currency.IsKnown() {
+		return fmt.Errorf("%w: %s is not an ISO 4217 code", ErrInvalidCurrency, r.Currency)
+	}
+	switch r.Kind {
+	case "", LineItemKindCharge:
+		if r.ReasonCode != "" {
+			return fmt.Errorf("%w: reasonCode only applies to credits", ErrInvalidReasonCode)
+		}
+	case LineItemKindCredit:
+		if err := r.ReasonCode.Validate(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%w: %s. Supported kinds: CHARGE, CREDIT", ErrInvalidItemKind, r.Kind)
+	}
+	_, _, _, err := r.pricing()
+	return err
+}
+
+// LineItem builds the item the request describes, negating credits. Items in
+// a foreign currency still carry the original amount until converted.
+func (r *AddLineItemRequest) LineItem(timestamp time.Time) (LineItem, error) {
+	if err := r.Validate(); err != nil {
+		return LineItem{}, err
+	}
+	quantity, unitPrice, amount, err := r.pricing()
+	if err != nil {
+		return LineItem{}, err
+	}
+
+	item := LineItem{
+		Description:    r.Description,
+		Amount:         amount,
+		Quantity:       quantity,
+		UnitPrice:      unitPrice,
+		Timestamp:      timestamp,
+		Kind:           LineItemKindCharge,
+		IdempotencyKey: r.IdempotencyKey,
+	}
+	if r.Kind == LineItemKindCredit {
+		item.Kind = LineItemKindCredit
+		item.ReasonCode = r.ReasonCode
+		item.Amount = -amount
+		item.UnitPrice = -unitPrice
+	}
+	if r.Currency != "" {
+		// Converted by the service once the bill currency and rate are known.
+		item.FX = &FXConversion{
+			Currency:  r.Currency,
+			Amount:    item.Amount,
+			Quantity:  item.Quantity,
+			UnitPrice: item.UnitPrice,
+		}
+	}
+	return item, nil
+}
+
+// pricing resolves the quantity, unit price and extended amount of the item.
+func (r *AddLineItemRequest) pricing() (Quantity, int64, int64, error) {
+	quantity := QuantityOne
+	if r.Quantity != nil {
+		quantity = *r.Quantity
+	}
+	if quantity <= 0 {
+		return 0, 0, 0, ErrInvalidQuantity
+	}
+
+	if r.UnitPrice == nil {
+		if quantity != QuantityOne {
+			return 0, 0, 0, fmt.Errorf("%w: unitPrice is required when quantity is set", ErrInvalidQuantity)
+		}
+		if r.Amount <= 0 {
+			return 0, 0, 0, ErrInvalidAmount
+		}
+		return quantity, r.Amount, r.Amount, nil
+	}
+
+	unitPrice := *r.UnitPrice
+	if unitPrice <= 0 {
+		return 0, 0, 0, ErrInvalidAmount
+	}
+	amount, err := ExtendedAmount(quantity, unitPrice)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if r.Amount != 0 && r.Amount != amount {
+		return 0, 0, 0, fmt.Errorf("%w: %d != %s x %d", ErrAmountMismatch, r.Amount, quantity, unitPrice)
+	}
+	if amount <= 0 {
+		return 0, 0, 0, ErrInvalidAmount
+	}
+	return quantity, unitPrice, amount, nil
+}
+
+type AddLineItemResponse struct {
+	ItemID        string `json:"itemId"`
+	LineItemCount int    `json:"lineItemCount"`
+	TotalAmount   int64  `json:"totalAmount"`
+	// Queued is set when the item was stored but the workflow could not be
+	// reached; the outbox relay delivers it later.
+	Queued bool `json:"queued"`
+}
+
+// LineItemVoid asks the bill workflow to stop counting an item.
+type LineItemVoid struct {
+	ItemID string `json:"itemId"`
+	Reason string `json:"reason,omitempty"`
+	// Amount is the item's extended amount, for a workflow that continued
+	// as new after the item was added and no longer holds it.
+	Amount int64 `json:"amount,omitempty"`
+}
+
+type VoidLineItemResponse struct {
+	ItemID        string `json:"itemId"`
+	LineItemCount int    `json:"lineItemCount"`
+	TotalAmount   int64  `json:"totalAmount"`
+	Queued        bool   `json:"queued"`
+}
+
+const maxVoidReasonLength = 500
+
+// VoidBillRequest abandons an open bill. The reason is kept on the bill for
+// audit.
+type VoidBillRequest struct {
+	Reason string `json:"reason"`
+}
+
+func (r *VoidBillRequest) Validate() error {
+	reason := strings.TrimSpace(r.Reason)
+	if reason == "" || len(reason) > maxVoidReasonLength {
+		return fmt.Errorf("%w: reason must be 1 to %d characters", ErrInvalidVoidReason, maxVoidReasonLength)
 	}
 	return nil
 }
 
+// BillVoid asks the bill workflow to stop without pricing the bill.
+type BillVoid struct {
+	Reason string `json:"reason"`
+}
+
+type VoidBillResponse struct {
+	BillID     string     `json:"billId"`
+	Status     BillStatus `json:"status"`
+	VoidReason string     `json:"voidReason"`
+}
+
+type CloseBillResponse struct {
+	BillID      string     `json:"billId"`
+	Status      BillStatus `json:"status"`
+	TotalAmount int64      `json:"totalAmount"`
+}
+
 type GetBillResponse struct {
 	Bill *Bill `json:"bill"`
+	// Balance is the total less payments recorded so far.
+	Balance int64 `json:"balance"`
+}
+
+type GetLiveBillResponse struct {
+	State           *BillState `json:"state"`
+	StoredTotal     int64      `json:"storedTotal"`
+	StoredItemCount int        `json:"storedItemCount"`
+	InSync          bool       `json:"inSync"`
 }
 
 type ListBillsRequest struct {
@@ -145,6 +728,9 @@ type ListBillsRequest struct {
 	Status     *BillStatus `json:"status,omitempty"`
 	Limit      int         `json:"limit,omitempty"`
 	Offset     int         `json:"offset,omitempty"`
+	// IncludeVoided lists voided bills along with the others when no
+	// status is given.
+	IncludeVoided bool `json:"includeVoided,omitempty"`
 }
 
 func (r *ListBillsRequest) Validate() error {
@@ -172,9 +758,10 @@ type ListBillsResponse struct {
 }
 
 type ListAllBillsRequest struct {
-	Status *BillStatus `json:"status,omitempty"`
-	Limit  int         `json:"limit,omitempty"`
-	Offset int         `json:"offset,omitempty"`
+	Status        *BillStatus `json:"status,omitempty"`
+	Limit         int         `json:"limit,omitempty"`
+	Offset        int         `json:"offset,omitempty"`
+	IncludeVoided bool        `json:"includeVoided,omitempty"`
 }
 
 func (r *ListAllBillsRequest) Validate() error {
@@ -3,10 +3,12 @@ package fees
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCurrency_Validate(t *testing.T) {
@@ -60,6 +62,7 @@ func TestBillStatus_IsValid(t *testing.T) {
 	}{
 		{BillStatusOpen, true},
 		{BillStatusClosed, true},
+		{BillStatusVoided, true},
 		{"INVALID", false},
 		{"", false},
 	}
@@ -93,6 +96,70 @@ func TestCreateBillRequest_Validate(t *testing.T) {
 			},
 			wantErr: nil,
 		},
+		{
+			name: "idempotency key too long",
+			req: CreateBillRequest{
+				CustomerID:     "customer123",
+				Currency:       USD,
+				IdempotencyKey: strings.Repeat("k", maxIdempotencyKeyLength+1),
+			},
+			wantErr: ErrInvalidIdempotencyKey,
+		},
+		{
+			name: "promo codes",
+			req: CreateBillRequest{
+				CustomerID: "customer123",
+				Currency:   USD,
+				PromoCodes: []string{"SPRING10", "welcome"},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "minimum above maximum",
+			req: CreateBillRequest{
+				CustomerID:    "customer123",
+				Currency:      USD,
+				MinimumAmount: int64Ptr(5000),
+				MaximumAmount: int64Ptr(1000),
+			},
+			wantErr: ErrInvalidBillingLimits,
+		},
+		{
+			name: "lowercase legal entity",
+			req: CreateBillRequest{
+				CustomerID:  "customer123",
+				Currency:    USD,
+				LegalEntity: "pave",
+			},
+			wantErr: ErrInvalidLegalEntity,
+		},
+		{
+			name: "payment terms over a year",
+			req: CreateBillRequest{
+				CustomerID:       "customer123",
+				Currency:         USD,
+				PaymentTermsDays: intPtr(400),
+			},
+			wantErr: ErrInvalidPaymentTerms,
+		},
+		{
+			name: "empty promo code",
+			req: CreateBillRequest{
+				CustomerID: "customer123",
+				Currency:   USD,
+				PromoCodes: []string{" "},
+			},
+			wantErr: ErrInvalidPromoCode,
+		},
+		{
+			name: "promo code listed twice",
+			req: CreateBillRequest{
+				CustomerID: "customer123",
+				Currency:   USD,
+				PromoCodes: []string{"SPRING10", "spring10"},
+			},
+			wantErr: ErrDiscountAlreadyApplied,
+		},
 		{
 			name: "empty customer ID",
 			req: CreateBillRequest{
@@ -131,6 +198,107 @@ func TestCreateBillRequest_Validate(t *testing.T) {
 	}
 }
 
+func TestCreateBillRequest_Validate_Period(t *testing.T) {
+	start := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+	before := start.AddDate(0, 0, -1)
+
+	tests := []struct {
+		name    string
+		req     CreateBillRequest
+		wantErr error
+	}{
+		{
+			name:    "named period",
+			req:     CreateBillRequest{CustomerID: "customer123", Currency: USD, Period: BillingPeriodMonthly},
+			wantErr: nil,
+		},
+		{
+			name:    "explicit window",
+			req:     CreateBillRequest{CustomerID: "customer123", Currency: USD, PeriodStart: &start, PeriodEnd: &end},
+			wantErr: nil,
+		},
+		{
+			name:    "unknown period",
+			req:     CreateBillRequest{CustomerID: "customer123", Currency: USD, Period: "YEARLY"},
+			wantErr: ErrInvalidPeriod,
+		},
+		{
+			name:    "period and end",
+			req:     CreateBillRequest{CustomerID: "customer123", Currency: USD, Period: BillingPeriodWeekly, PeriodEnd: &end},
+			wantErr: ErrInvalidPeriod,
+		},
+		{
+			name:    "start without end",
+			req:     CreateBillRequest{CustomerID: "customer123", Currency: USD, PeriodStart: &start},
+			wantErr: ErrInvalidPeriod,
+		},
+		{
+			name:    "end before start",
+			req:     CreateBillRequest{CustomerID: "customer123", Currency: USD, PeriodStart: &start, PeriodEnd: &before},
+			wantErr: ErrInvalidPeriod,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate()
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestCreateBillRequest_BillingWindow(t *testing.T) {
+	now := time.Date(2025, 1, 31, 9, 30, 0, 0, time.UTC)
+	explicitEnd := now.Add(48 * time.Hour)
+
+	t.Run("no period", func(t *testing.T) {
+		req := CreateBillRequest{CustomerID: "customer123", Currency: USD}
+		start, end := req.BillingWindow(now)
+		assert.Nil(t, start)
+		assert.Nil(t, end)
+	})
+
+	t.Run("monthly from now", func(t *testing.T) {
+		req := CreateBillRequest{CustomerID: "customer123", Currency: USD, Period: BillingPeriodMonthly}
+		start, end := req.BillingWindow(now)
+		assert.Equal(t, now, *start)
+		assert.Equal(t, time.Date(2025, 2, 28, 9, 30, 0, 0, time.UTC), *end)
+	})
+
+	t.Run("explicit end", func(t *testing.T) {
+		req := CreateBillRequest{CustomerID: "customer123", Currency: USD, PeriodEnd: &explicitEnd}
+		start, end := req.BillingWindow(now)
+		assert.Equal(t, now, *start)
+		assert.Equal(t, explicitEnd, *end)
+	})
+}
+
+func TestBillingPeriod_End(t *testing.T) {
+	tests := []struct {
+		name   string
+		period BillingPeriod
+		start  time.Time
+		want   time.Time
+	}{
+		{"daily", BillingPeriodDaily, time.Date(2025, 5, 10, 0, 0, 0, 0, time.UTC), time.Date(2025, 5, 11, 0, 0, 0, 0, time.UTC)},
+		{"weekly", BillingPeriodWeekly, time.Date(2025, 5, 10, 0, 0, 0, 0, time.UTC), time.Date(2025, 5, 17, 0, 0, 0, 0, time.UTC)},
+		{"monthly", BillingPeriodMonthly, time.Date(2025, 5, 10, 0, 0, 0, 0, time.UTC), time.Date(2025, 6, 10, 0, 0, 0, 0, time.UTC)},
+		{"monthly clamps to short month", BillingPeriodMonthly, time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"monthly across year end", BillingPeriodMonthly, time.Date(2025, 12, 15, 0, 0, 0, 0, time.UTC), time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.period.End(tt.start))
+		})
+	}
+}
+
 func TestAddLineItemRequest_Validate(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -161,6 +329,33 @@ func TestAddLineItemRequest_Validate(t *testing.T) {
 			},
 			wantErr: ErrEmptyDescription,
 		},
+		{
+			name: "unknown item currency",
+			req: AddLineItemRequest{
+				Description: "Test item",
+				Amount:      1000,
+				Currency:    "XYZ",
+			},
+			wantErr: ErrInvalidCurrency,
+		},
+		{
+			name: "item currency need not be enabled",
+			req: AddLineItemRequest{
+				Description: "Test item",
+				Amount:      1000,
+				Currency:    "EUR",
+			},
+			wantErr: nil,
+		},
+		{
+			name: "idempotency key too long",
+			req: AddLineItemRequest{
+				Description:    "Test item",
+				Amount:         1000,
+				IdempotencyKey: strings.Repeat("k", maxIdempotencyKeyLength+1),
+			},
+			wantErr: ErrInvalidIdempotencyKey,
+		},
 		{
 			name: "zero amount",
 			req: AddLineItemRequest{
@@ -177,6 +372,108 @@ func TestAddLineItemRequest_Validate(t *testing.T) {
 			},
 			wantErr: ErrInvalidAmount,
 		},
+		{
+			name: "quantity and unit price",
+			req: AddLineItemRequest{
+				Description: "API calls",
+				Quantity:    quantityPtr(12 * QuantityScale),
+				UnitPrice:   int64Ptr(5),
+			},
+			wantErr: nil,
+		},
+		{
+			name: "matching amount with unit price",
+			req: AddLineItemRequest{
+				Description: "API calls",
+				Amount:      60,
+				Quantity:    quantityPtr(12 * QuantityScale),
+				UnitPrice:   int64Ptr(5),
+			},
+			wantErr: nil,
+		},
+		{
+			name: "mismatched amount",
+			req: AddLineItemRequest{
+				Description: "API calls",
+				Amount:      61,
+				Quantity:    quantityPtr(12 * QuantityScale),
+				UnitPrice:   int64Ptr(5),
+			},
+			wantErr: ErrAmountMismatch,
+		},
+		{
+			name: "quantity without unit price",
+			req: AddLineItemRequest{
+				Description: "API calls",
+				Amount:      60,
+				Quantity:    quantityPtr(12 * QuantityScale),
+			},
+			wantErr: ErrInvalidQuantity,
+		},
+		{
+			name: "zero quantity",
+			req: AddLineItemRequest{
+				Description: "API calls",
+				Quantity:    quantityPtr(0),
+				UnitPrice:   int64Ptr(5),
+			},
+			wantErr: ErrInvalidQuantity,
+		},
+		{
+			name: "zero unit price",
+			req: AddLineItemRequest{
+				Description: "API calls",
+				Quantity:    quantityPtr(QuantityOne),
+				UnitPrice:   int64Ptr(0),
+			},
+			wantErr: ErrInvalidAmount,
+		},
+		{
+			name: "credit with reason",
+			req: AddLineItemRequest{
+				Description: "Outage credit",
+				Amount:      500,
+				Kind:        LineItemKindCredit,
+				ReasonCode:  CreditReasonOutage,
+			},
+			wantErr: nil,
+		},
+		{
+			name: "credit without reason",
+			req: AddLineItemRequest{
+				Description: "Outage credit",
+				Amount:      500,
+				Kind:        LineItemKindCredit,
+			},
+			wantErr: ErrInvalidReasonCode,
+		},
+		{
+			name: "charge with reason",
+			req: AddLineItemRequest{
+				Description: "Service",
+				Amount:      500,
+				ReasonCode:  CreditReasonOutage,
+			},
+			wantErr: ErrInvalidReasonCode,
+		},
+		{
+			name: "fee kind not accepted",
+			req: AddLineItemRequest{
+				Description: "Sneaky fee",
+				Amount:      500,
+				Kind:        LineItemKindFee,
+			},
+			wantErr: ErrInvalidItemKind,
+		},
+		{
+			name: "extended amount rounds to zero",
+			req: AddLineItemRequest{
+				Description: "Storage",
+				Quantity:    quantityPtr(1000),
+				UnitPrice:   int64Ptr(1),
+			},
+			wantErr: ErrInvalidAmount,
+		},
 	}
 
 	for _, tt := range tests {
@@ -191,6 +488,78 @@ func TestAddLineItemRequest_Validate(t *testing.T) {
 	}
 }
 
+func TestAddLineItemRequest_LineItem(t *testing.T) {
+	now := time.Now()
+
+	t.Run("amount only charge", func(t *testing.T) {
+		req := AddLineItemRequest{Description: "Service", Amount: 1000}
+		item, err := req.LineItem(now)
+		require.NoError(t, err)
+		assert.Equal(t, LineItem{
+			Description: "Service",
+			Amount:      1000,
+			Quantity:    QuantityOne,
+			UnitPrice:   1000,
+			Timestamp:   now,
+			Kind:        LineItemKindCharge,
+		}, item)
+	})
+
+	t.Run("credit is negated", func(t *testing.T) {
+		req := AddLineItemRequest{
+			Description: "Outage credit",
+			Quantity:    quantityPtr(2 * QuantityScale),
+			UnitPrice:   int64Ptr(150),
+			Kind:        LineItemKindCredit,
+			ReasonCode:  CreditReasonOutage,
+		}
+		item, err := req.LineItem(now)
+		require.NoError(t, err)
+		assert.Equal(t, int64(-300), item.Amount)
+		assert.Equal(t, int64(-150), item.UnitPrice)
+		assert.Equal(t, LineItemKindCredit, item.Kind)
+		assert.Equal(t, CreditReasonOutage, item.ReasonCode)
+		assert.NoError(t, item.Validate())
+		assert.Equal(t, int64(-300), item.ExtendedAmount())
+	})
+
+	t.Run("foreign currency keeps original pricing", func(t *testing.T) {
+		req := AddLineItemRequest{
+			Description: "Hosting",
+			Quantity:    quantityPtr(2 * QuantityScale),
+			UnitPrice:   int64Ptr(450),
+			Currency:    USD,
+		}
+		item, err := req.LineItem(now)
+		require.NoError(t, err)
+		assert.Equal(t, &FXConversion{Currency: USD, Amount: 900, Quantity: 2 * QuantityScale, UnitPrice: 450}, item.FX)
+	})
+
+	t.Run("invalid request", func(t *testing.T) {
+		req := AddLineItemRequest{Description: "Credit", Amount: 100, Kind: LineItemKindCredit}
+		_, err := req.LineItem(now)
+		assert.ErrorIs(t, err, ErrInvalidReasonCode)
+	})
+}
+
+func TestLineItem_SamePricing(t *testing.T) {
+	base := LineItem{Description: "Hosting", Amount: 2715, Quantity: QuantityOne, UnitPrice: 2715, Kind: LineItemKindCharge,
+		FX: &FXConversion{Currency: USD, Amount: 1000, Quantity: QuantityOne, UnitPrice: 1000, Rate: 271530000}}
+
+	retry := base
+	retry.Amount, retry.UnitPrice = 2800, 2800
+	retry.FX = &FXConversion{Currency: USD, Amount: 1000, Quantity: QuantityOne, UnitPrice: 1000, Rate: 280000000}
+	assert.True(t, base.SamePricing(&retry), "a different rate is still the same request")
+
+	otherCurrency := retry
+	otherCurrency.FX = &FXConversion{Currency: "EUR", Amount: 1000, Quantity: QuantityOne, UnitPrice: 1000}
+	assert.False(t, base.SamePricing(&otherCurrency))
+
+	local := LineItem{Description: "Hosting", Amount: 2715, Quantity: QuantityOne, UnitPrice: 2715, Kind: LineItemKindCharge}
+	assert.False(t, base.SamePricing(&local))
+	assert.True(t, local.SamePricing(&local))
+}
+
 func TestLineItem_Validate(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -233,6 +602,73 @@ func TestLineItem_Validate(t *testing.T) {
 			},
 			wantErr: ErrInvalidAmount,
 		},
+		{
+			name: "priced item",
+			item: LineItem{
+				Description: "Storage",
+				Amount:      38,
+				Quantity:    1500000,
+				UnitPrice:   25,
+				Timestamp:   time.Now(),
+			},
+			wantErr: nil,
+		},
+		{
+			name: "amount does not match quantity",
+			item: LineItem{
+				Description: "Storage",
+				Amount:      100,
+				Quantity:    1500000,
+				UnitPrice:   25,
+				Timestamp:   time.Now(),
+			},
+			wantErr: ErrAmountMismatch,
+		},
+		{
+			name: "credit item",
+			item: LineItem{
+				Description: "Refund",
+				Amount:      -250,
+				Quantity:    QuantityOne,
+				UnitPrice:   -250,
+				Kind:        LineItemKindCredit,
+				ReasonCode:  CreditReasonRefund,
+				Timestamp:   time.Now(),
+			},
+			wantErr: nil,
+		},
+		{
+			name: "credit with positive amount",
+			item: LineItem{
+				Description: "Refund",
+				Amount:      250,
+				Kind:        LineItemKindCredit,
+				ReasonCode:  CreditReasonRefund,
+				Timestamp:   time.Now(),
+			},
+			wantErr: ErrInvalidAmount,
+		},
+		{
+			name: "credit with unknown reason",
+			item: LineItem{
+				Description: "Refund",
+				Amount:      -250,
+				Kind:        LineItemKindCredit,
+				ReasonCode:  "BECAUSE",
+				Timestamp:   time.Now(),
+			},
+			wantErr: ErrInvalidReasonCode,
+		},
+		{
+			name: "unit price without quantity",
+			item: LineItem{
+				Description: "Storage",
+				Amount:      25,
+				UnitPrice:   25,
+				Timestamp:   time.Now(),
+			},
+			wantErr: ErrInvalidQuantity,
+		},
 	}
 
 	for _, tt := range tests {
@@ -352,6 +788,29 @@ func TestBill_CalculateTotal(t *testing.T) {
 	}
 }
 
+func TestBill_CalculateTotal_SkipsVoidedItems(t *testing.T) {
+	voidedAt := time.Now()
+	bill := Bill{LineItems: []LineItem{
+		{ID: "item-1", Amount: 1000},
+		{ID: "item-2", Amount: 500, VoidedAt: &voidedAt, VoidReason: "duplicate"},
+		{ID: "item-3", Amount: -200, Kind: LineItemKindCredit, ReasonCode: CreditReasonGoodwill},
+	}}
+
+	assert.Equal(t, int64(800), bill.CalculateTotal())
+	assert.Equal(t, 2, bill.ActiveItemCount())
+}
+
+func TestBill_CalculateTotal_IncludesExclusiveTax(t *testing.T) {
+	bill := Bill{
+		LineItems: []LineItem{{Description: "Consulting", Amount: 10000}},
+		Taxes: []TaxLine{
+			{RuleID: "ge-vat", RateBps: 1800, Amount: 1800},
+			{RuleID: "inclusive", RateBps: 500, Inclusive: true, Amount: 476},
+		},
+	}
+	assert.Equal(t, int64(11800), bill.CalculateTotal())
+}
+
 func TestBill_CanAddLineItem(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -360,6 +819,7 @@ func TestBill_CanAddLineItem(t *testing.T) {
 	}{
 		{"open bill", BillStatusOpen, true},
 		{"closed bill", BillStatusClosed, false},
+		{"voided bill", BillStatusVoided, false},
 	}
 
 	for _, tt := range tests {
@@ -370,6 +830,31 @@ func TestBill_CanAddLineItem(t *testing.T) {
 	}
 }
 
+func TestVoidBillRequest_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		reason  string
+		wantErr bool
+	}{
+		{"reason", "Created by mistake", false},
+		{"longest reason", strings.Repeat("x", maxVoidReasonLength), false},
+		{"no reason", "", true},
+		{"blank reason", "  ", true},
+		{"reason too long", strings.Repeat("x", maxVoidReasonLength+1), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&VoidBillRequest{Reason: tt.reason}).Validate()
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidVoidReason)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
 func TestListBillsRequest_Validate(t *testing.T) {
 	openStatus := BillStatusOpen
 	closedStatus := BillStatusClosed
@@ -1,9 +1,11 @@
 package fees
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
+	enumspb "go.temporal.io/api/enums/v1"
 	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/workflow"
 )
@@ -11,17 +13,459 @@ import (
 const (
 	AddLineItemSignal = "ADD_LINE_ITEM"
 	CloseBillSignal   = "CLOSE_BILL"
+	GetBillStateQuery = "GET_BILL_STATE"
+	AddLineItemUpdate = "ADD_LINE_ITEM_UPDATE"
+	CloseBillUpdate   = "CLOSE_BILL_UPDATE"
+
+	VoidLineItemSignal = "VOID_LINE_ITEM"
+	VoidLineItemUpdate = "VOID_LINE_ITEM_UPDATE"
+
+	VoidBillSignal = "VOID_BILL"
+	VoidBillUpdate = "VOID_BILL_UPDATE"
+
+	AttachDiscountUpdate = "ATTACH_DISCOUNT_UPDATE"
+)
+
+// BillState.LineItems and VoidedItems only hold what the current run of the
+// workflow has seen; once it has continued as new the earlier items are only
+// in the database. ItemCount and TotalAmount always cover the whole bill.
+type BillState struct {
+	BillID      string     `json:"billId"`
+	CustomerID  string     `json:"customerId"`
+	Currency    Currency   `json:"currency"`
+	LineItems   []LineItem `json:"lineItems"`
+	VoidedItems []LineItem `json:"voidedItems,omitempty"`
+	ItemCount   int        `json:"itemCount"`
+	TotalAmount int64      `json:"totalAmount"`
+	IsClosed    bool       `json:"isClosed"`
+	// A voided bill is also closed, but was never priced.
+	IsVoided   bool   `json:"isVoided,omitempty"`
+	VoidReason string `json:"voidReason,omitempty"`
+	// TaxAmount and Taxes are only known once the bill has closed.
+	TaxAmount int64     `json:"taxAmount,omitempty"`
+	Taxes     []TaxLine `json:"taxes,omitempty"`
+}
+
+// workflowErrorTypes maps domain errors onto application error types so that
+// update rejections can be turned back into the same sentinel errors on the
+// client side.
+var workflowErrorTypes = map[string]error{
+	"BillAlreadyClosed": ErrBillAlreadyClosed,
+	"InvalidAmount":     ErrInvalidAmount,
+	"EmptyDescription":  ErrEmptyDescription,
+	"InvalidQuantity":   ErrInvalidQuantity,
+	"AmountMismatch":    ErrAmountMismatch,
+	"InvalidItemKind":   ErrInvalidItemKind,
+	"InvalidReasonCode": ErrInvalidReasonCode,
+	"NegativeTotal":     ErrNegativeTotal,
+	"LineItemNotFound":  ErrLineItemNotFound,
+	"LineItemVoided":    ErrLineItemVoided,
+	"InvalidVoidReason": ErrInvalidVoidReason,
+
+	"PromoCodeNotFound":      ErrPromoCodeNotFound,
+	"PromoCodeUsageExceeded": ErrPromoCodeUsageExceeded,
+	"DiscountAlreadyApplied": ErrDiscountAlreadyApplied,
+}
+
+func toWorkflowError(err error) error {
+	for errType, sentinel := range workflowErrorTypes {
+		if errors.Is(err, sentinel) {
+			return temporal.NewNonRetryableApplicationError(err.Error(), errType, nil)
+		}
+	}
+	return err
+}
+
+func workflowRejection(err error) error {
+	var appErr *temporal.ApplicationError
+	if errors.As(err, &appErr) {
+		if sentinel, ok := workflowErrorTypes[appErr.Type()]; ok {
+			return sentinel
+		}
+	}
+	return nil
+}
+
+// maxBillRunItems is how many items and voids a bill workflow run takes
+// before it continues as new, which keeps its history and the state it holds
+// well inside Temporal's limits.
+const maxBillRunItems = 1000
+
+// Change IDs for workflow.GetVersion. Open bills replay their history on
+// every worker restart, so a change to the commands BillWorkflow issues
+// has to keep the old behavior for runs that started before it. Recorded
+// histories in testdata are replayed by TestBillWorkflow_Replay.
+const (
+	// continueAsNewChange lets busy bills continue as new. Runs started
+	// before it carry on in one run until they close.
+	continueAsNewChange = "bill-continue-as-new"
+	// settleOutboxChange marks the outbox events a run applied done before
+	// it continues as new.
+	settleOutboxChange = "bill-settle-outbox"
+	// taxChange taxes bills at close. Runs started before it close untaxed.
+	taxChange = "bill-tax"
+	// discountsChange applies attached discounts at close.
+	discountsChange = "bill-discounts"
+	// dunningChange starts a DunningWorkflow once the bill has closed. Runs
+	// started before it close without one.
+	dunningChange = "bill-dunning"
+	// usagePricingChange prices metered usage at close. Runs started before
+	// it had no usage to price.
+	usagePricingChange = "bill-usage-pricing"
 )
 
-func BillWorkflow(ctx workflow.Context, initialBill Bill) error {
+// BillProgress is what a bill workflow carries into its next run when it
+// continues as new. The items themselves are in the database, so the next
+// run only needs their count and total. The IDs the previous run saw keep an
+// item or void redelivered across the switch from being applied twice; the
+// outbox events of older ones have been settled.
+// A reopened bill starts its new run the same way.
+type BillProgress struct {
+	// Runs counts the runs before this one; zero for a new bill.
+	Runs          int
+	ItemCount     int
+	RunningTotal  int64
+	RecentItemIDs []string
+	RecentVoidIDs []string
+	// Reopened bills have already been closed for their billing period once,
+	// so they stay open until closed explicitly.
+	Reopened bool
+}
+
+func BillWorkflow(ctx workflow.Context, initialBill Bill, progress BillProgress) error {
 	logger := workflow.GetLogger(ctx)
-	logger.Info("Starting bill workflow", "bill_id", initialBill.ID)
+	logger.Info("Starting bill workflow", "bill_id", initialBill.ID, "run", progress.Runs+1)
 
 	isClosed := false
+	billVoided := false
+	var voidReason string
+	isFinalized := false
+	var finalizeErr error
 	var lineItems []LineItem
+	var voidedItems []LineItem
+	// earlierVoids are items from earlier runs voided in this one.
+	var earlierVoids []string
+	itemCount := progress.ItemCount
+	runningTotal := progress.RunningTotal
+	var taxAmount int64
+	var taxes []TaxLine
+	// attachingDiscounts counts discounts still being stored; the bill is
+	// priced once they are.
+	attachingDiscounts := 0
+
+	currentState := func() BillState {
+		return BillState{
+			BillID:      initialBill.ID,
+			CustomerID:  initialBill.CustomerID,
+			Currency:    initialBill.Currency,
+			LineItems:   lineItems,
+			VoidedItems: voidedItems,
+			ItemCount:   itemCount,
+			TotalAmount: runningTotal,
+			IsClosed:    isClosed,
+			IsVoided:    billVoided,
+			VoidReason:  voidReason,
+			TaxAmount:   taxAmount,
+			Taxes:       taxes,
+		}
+	}
+
+	// checkLineItem guards both the update validator and the legacy signal
+	// path; credits may not take the total below zero unless the bill allows it.
+	checkLineItem := func(item LineItem) error {
+		if err := item.Validate(); err != nil {
+			return err
+		}
+		if item.Kind == LineItemKindCredit && !initialBill.AllowNegativeTotal && runningTotal+item.ExtendedAmount() < 0 {
+			return fmt.Errorf("%w: credit of %d exceeds running total %d", ErrNegativeTotal, -item.ExtendedAmount(), runningTotal)
+		}
+		return nil
+	}
+
+	addLineItem := func(item LineItem) {
+		logger.Info("Received line item", "description", item.Description, "kind", item.Kind, "amount", item.Amount)
+		lineItems = append(lineItems, item)
+		itemCount++
+		runningTotal += item.ExtendedAmount()
+	}
+
+	// hasLineItem makes redelivered items (outbox retries, replayed signals)
+	// no-ops; items without an ID predate public IDs and are never deduped.
+	hasLineItem := func(itemID string) bool {
+		if itemID == "" {
+			return false
+		}
+		for _, items := range [][]LineItem{lineItems, voidedItems} {
+			for _, existing := range items {
+				if existing.ID == itemID {
+					return true
+				}
+			}
+		}
+		return containsID(progress.RecentItemIDs, itemID)
+	}
+
+	findLineItem := func(itemID string) int {
+		for i := range lineItems {
+			if itemID != "" && lineItems[i].ID == itemID {
+				return i
+			}
+		}
+		return -1
+	}
+
+	isVoided := func(itemID string) bool {
+		for _, voided := range voidedItems {
+			if voided.ID == itemID {
+				return true
+			}
+		}
+		return containsID(earlierVoids, itemID) || containsID(progress.RecentVoidIDs, itemID)
+	}
+
+	// checkVoid takes the amount of an item added before the workflow last
+	// continued as new from the void itself; the database has already
+	// checked that the item is on the bill.
+	checkVoid := func(void LineItemVoid) error {
+		amount := void.Amount
+		if i := findLineItem(void.ItemID); i >= 0 {
+			amount = lineItems[i].ExtendedAmount()
+		} else if isVoided(void.ItemID) {
+			return ErrLineItemVoided
+		} else if progress.Runs == 0 {
+			return ErrLineItemNotFound
+		}
+		if !initialBill.AllowNegativeTotal && runningTotal-amount < 0 {
+			return fmt.Errorf("%w: voiding %s leaves %d", ErrNegativeTotal, void.ItemID, runningTotal-amount)
+		}
+		return nil
+	}
+
+	voidLineItem := func(ctx workflow.Context, void LineItemVoid) {
+		i := findLineItem(void.ItemID)
+		if i < 0 {
+			logger.Info("Voiding line item from an earlier run", "item_id", void.ItemID, "amount", void.Amount, "reason", void.Reason)
+			earlierVoids = append(earlierVoids, void.ItemID)
+			itemCount--
+			runningTotal -= void.Amount
+			return
+		}
+		item := lineItems[i]
+		logger.Info("Voiding line item", "item_id", item.ID, "amount", item.Amount, "reason", void.Reason)
+
+		voidedAt := workflow.Now(ctx)
+		item.VoidedAt = &voidedAt
+		item.VoidReason = void.Reason
+		lineItems = append(lineItems[:i:i], lineItems[i+1:]...)
+		voidedItems = append(voidedItems, item)
+		itemCount--
+		runningTotal -= item.ExtendedAmount()
+	}
+
+	err := workflow.SetQueryHandler(ctx, GetBillStateQuery, func() (BillState, error) {
+		return currentState(), nil
+	})
+	if err != nil {
+		logger.Error("Failed to register bill state query handler", "error", err)
+		return fmt.Errorf("failed to register bill state query handler: %w", err)
+	}
+
+	err = workflow.SetUpdateHandlerWithOptions(ctx, AddLineItemUpdate,
+		func(ctx workflow.Context, item LineItem) (BillState, error) {
+			if hasLineItem(item.ID) {
+				logger.Info("Line item already on bill, skipping", "item_id", item.ID)
+				return currentState(), nil
+			}
+			addLineItem(item)
+			return currentState(), nil
+		},
+		workflow.UpdateHandlerOptions{
+			Validator: func(ctx workflow.Context, item LineItem) error {
+				if isClosed {
+					return toWorkflowError(ErrBillAlreadyClosed)
+				}
+				if hasLineItem(item.ID) {
+					return nil
+				}
+				if err := checkLineItem(item); err != nil {
+					return toWorkflowError(err)
+				}
+				return nil
+			},
+		},
+	)
+	if err != nil {
+		logger.Error("Failed to register add line item update handler", "error", err)
+		return fmt.Errorf("failed to register add line item update handler: %w", err)
+	}
+
+	err = workflow.SetUpdateHandlerWithOptions(ctx, VoidLineItemUpdate,
+		func(ctx workflow.Context, void LineItemVoid) (BillState, error) {
+			voidLineItem(ctx, void)
+			return currentState(), nil
+		},
+		workflow.UpdateHandlerOptions{
+			Validator: func(ctx workflow.Context, void LineItemVoid) error {
+				if isClosed {
+					return toWorkflowError(ErrBillAlreadyClosed)
+				}
+				if err := checkVoid(void); err != nil {
+					return toWorkflowError(err)
+				}
+				return nil
+			},
+		},
+	)
+	if err != nil {
+		logger.Error("Failed to register void line item update handler", "error", err)
+		return fmt.Errorf("failed to register void line item update handler: %w", err)
+	}
+
+	closeRequestChan := workflow.NewBufferedChannel(ctx, 1)
+	err = workflow.SetUpdateHandlerWithOptions(ctx, CloseBillUpdate,
+		func(ctx workflow.Context) (BillState, error) {
+			isClosed = true
+			closeRequestChan.SendAsync(struct{}{})
+			if err := workflow.Await(ctx, func() bool { return isFinalized }); err != nil {
+				return BillState{}, err
+			}
+			if finalizeErr != nil {
+				return BillState{}, finalizeErr
+			}
+			return currentState(), nil
+		},
+		workflow.UpdateHandlerOptions{
+			Validator: func(ctx workflow.Context) error {
+				if isClosed {
+					return toWorkflowError(ErrBillAlreadyClosed)
+				}
+				return nil
+			},
+		},
+	)
+	if err != nil {
+		logger.Error("Failed to register close bill update handler", "error", err)
+		return fmt.Errorf("failed to register close bill update handler: %w", err)
+	}
+
+	// Discounts are stored by the workflow so none can be attached once it
+	// has started closing the bill.
+	err = workflow.SetUpdateHandlerWithOptions(ctx, AttachDiscountUpdate,
+		func(ctx workflow.Context, discount BillDiscount) (BillDiscount, error) {
+			attachingDiscounts++
+			defer func() { attachingDiscounts-- }()
+			ctx = workflow.WithActivityOptions(ctx, billActivityOptions)
+			err := workflow.ExecuteActivity(ctx, "AttachDiscountActivity", AttachDiscountInput{
+				BillID:   initialBill.ID,
+				Discount: discount,
+			}).Get(ctx, nil)
+			if err != nil {
+				logger.Warn("Failed to attach discount", "code", discount.Code, "error", err)
+				return BillDiscount{}, err
+			}
+			return discount, nil
+		},
+		workflow.UpdateHandlerOptions{
+			Validator: func(ctx workflow.Context, discount BillDiscount) error {
+				if isClosed {
+					return toWorkflowError(ErrBillAlreadyClosed)
+				}
+				return nil
+			},
+		},
+	)
+	if err != nil {
+		logger.Error("Failed to register attach discount update handler", "error", err)
+		return fmt.Errorf("failed to register attach discount update handler: %w", err)
+	}
+
+	// checkBillVoid guards both the void update and the signal.
+	checkBillVoid := func(void BillVoid) error {
+		if isClosed {
+			return ErrBillAlreadyClosed
+		}
+		return (&VoidBillRequest{Reason: void.Reason}).Validate()
+	}
+
+	voidBill := func(void BillVoid) {
+		logger.Info("Voiding bill", "reason", void.Reason, "total_line_items", itemCount)
+		isClosed = true
+		billVoided = true
+		voidReason = void.Reason
+	}
+
+	err = workflow.SetUpdateHandlerWithOptions(ctx, VoidBillUpdate,
+		func(ctx workflow.Context, void BillVoid) (BillState, error) {
+			voidBill(void)
+			closeRequestChan.SendAsync(struct{}{})
+			if err := workflow.Await(ctx, func() bool { return isFinalized }); err != nil {
+				return BillState{}, err
+			}
+			if finalizeErr != nil {
+				return BillState{}, finalizeErr
+			}
+			return currentState(), nil
+		},
+		workflow.UpdateHandlerOptions{
+			Validator: func(ctx workflow.Context, void BillVoid) error {
+				if err := checkBillVoid(void); err != nil {
+					return toWorkflowError(err)
+				}
+				return nil
+			},
+		},
+	)
+	if err != nil {
+		logger.Error("Failed to register void bill update handler", "error", err)
+		return fmt.Errorf("failed to register void bill update handler: %w", err)
+	}
 
 	addLineItemChan := workflow.GetSignalChannel(ctx, AddLineItemSignal)
 	closeBillChan := workflow.GetSignalChannel(ctx, CloseBillSignal)
+	voidLineItemChan := workflow.GetSignalChannel(ctx, VoidLineItemSignal)
+	voidBillChan := workflow.GetSignalChannel(ctx, VoidBillSignal)
+
+	// A bill with a billing period closes itself when the period ends, unless
+	// it is closed explicitly first.
+	var periodEndTimer workflow.Future
+	timerCtx, cancelTimer := workflow.WithCancel(ctx)
+	if initialBill.PeriodEnd != nil && !progress.Reopened {
+		untilEnd := initialBill.PeriodEnd.Sub(workflow.Now(ctx))
+		if untilEnd <= 0 {
+			logger.Info("Billing period already ended, closing bill", "period_end", *initialBill.PeriodEnd)
+			isClosed = true
+		} else {
+			periodEndTimer = workflow.NewTimer(timerCtx, untilEnd)
+		}
+	}
+
+	receiveLineItem := func(item LineItem) {
+		if hasLineItem(item.ID) {
+			logger.Info("Ignoring duplicate line item signal", "item_id", item.ID)
+			return
+		}
+		if err := checkLineItem(item); err != nil {
+			logger.Warn("Ignoring invalid line item signal", "description", item.Description, "amount", item.Amount, "error", err)
+			return
+		}
+		addLineItem(item)
+	}
+
+	receiveVoid := func(void LineItemVoid) {
+		if err := checkVoid(void); err != nil {
+			logger.Warn("Ignoring invalid void line item signal", "item_id", void.ItemID, "error", err)
+			return
+		}
+		voidLineItem(ctx, void)
+	}
+
+	receiveBillVoid := func(void BillVoid) {
+		if err := checkBillVoid(void); err != nil {
+			logger.Warn("Ignoring invalid void bill signal", "reason", void.Reason, "error", err)
+			return
+		}
+		voidBill(void)
+	}
 
 	for !isClosed {
 		selector := workflow.NewSelector(ctx)
@@ -29,53 +473,586 @@ func BillWorkflow(ctx workflow.Context, initialBill Bill) error {
 		selector.AddReceive(addLineItemChan, func(c workflow.ReceiveChannel, more bool) {
 			var item LineItem
 			c.Receive(ctx, &item)
-			logger.Info("Received line item", "description", item.Description, "amount", item.Amount)
-			lineItems = append(lineItems, item)
+			receiveLineItem(item)
+		})
+
+		selector.AddReceive(voidLineItemChan, func(c workflow.ReceiveChannel, more bool) {
+			var void LineItemVoid
+			c.Receive(ctx, &void)
+			receiveVoid(void)
 		})
 
 		selector.AddReceive(closeBillChan, func(c workflow.ReceiveChannel, more bool) {
 			c.Receive(ctx, nil)
-			logger.Info("Received close bill signal", "total_line_items", len(lineItems))
+			logger.Info("Received close bill signal", "total_line_items", itemCount)
 			isClosed = true
 		})
 
+		selector.AddReceive(voidBillChan, func(c workflow.ReceiveChannel, more bool) {
+			var void BillVoid
+			c.Receive(ctx, &void)
+			receiveBillVoid(void)
+		})
+
+		selector.AddReceive(closeRequestChan, func(c workflow.ReceiveChannel, more bool) {
+			c.Receive(ctx, nil)
+			logger.Info("Received close bill update", "total_line_items", itemCount)
+		})
+
+		if periodEndTimer != nil {
+			selector.AddFuture(periodEndTimer, func(f workflow.Future) {
+				if err := f.Get(ctx, nil); err != nil {
+					logger.Warn("Billing period timer failed", "error", err)
+					periodEndTimer = nil
+					return
+				}
+				logger.Info("Billing period ended, closing bill", "total_line_items", itemCount)
+				isClosed = true
+			})
+		}
+
 		selector.Select(ctx)
+
+		busy := len(lineItems)+len(voidedItems) >= maxBillRunItems || workflow.GetInfo(ctx).GetContinueAsNewSuggested()
+		if !isClosed && busy && workflow.GetVersion(ctx, continueAsNewChange, workflow.DefaultVersion, 1) >= 1 {
+			// The next run only knows the IDs of this one, so the outbox
+			// must not redeliver anything older.
+			settled := true
+			if workflow.GetVersion(ctx, settleOutboxChange, workflow.DefaultVersion, 1) >= 1 {
+				settled = settleOutbox(ctx, initialBill.ID, progress, lineItems, voidedItems, earlierVoids)
+			}
+			if err := workflow.Await(ctx, func() bool { return workflow.AllHandlersFinished(ctx) }); err != nil {
+				return fmt.Errorf("failed waiting for update handlers: %w", err)
+			}
+			if isClosed {
+				break
+			}
+			// Signals that are already buffered would be lost with this run.
+			for {
+				var item LineItem
+				if !addLineItemChan.ReceiveAsync(&item) {
+					break
+				}
+				receiveLineItem(item)
+			}
+			for {
+				var void LineItemVoid
+				if !voidLineItemChan.ReceiveAsync(&void) {
+					break
+				}
+				receiveVoid(void)
+			}
+			if closeBillChan.ReceiveAsync(nil) {
+				logger.Info("Received close bill signal", "total_line_items", itemCount)
+				isClosed = true
+				break
+			}
+			var void BillVoid
+			if voidBillChan.ReceiveAsync(&void) {
+				receiveBillVoid(void)
+				if isClosed {
+					break
+				}
+			}
+
+			next := BillProgress{
+				Runs:          progress.Runs + 1,
+				ItemCount:     itemCount,
+				RunningTotal:  runningTotal,
+				RecentVoidIDs: earlierVoids,
+				Reopened:      progress.Reopened,
+			}
+			for _, items := range [][]LineItem{lineItems, voidedItems} {
+				for _, existing := range items {
+					if existing.ID != "" {
+						next.RecentItemIDs = append(next.RecentItemIDs, existing.ID)
+					}
+				}
+			}
+			for _, voided := range voidedItems {
+				next.RecentVoidIDs = append(next.RecentVoidIDs, voided.ID)
+			}
+			if !settled {
+				next.RecentItemIDs = append(next.RecentItemIDs, progress.RecentItemIDs...)
+				next.RecentVoidIDs = append(next.RecentVoidIDs, progress.RecentVoidIDs...)
+			}
+			cancelTimer()
+			logger.Info("Continuing bill workflow as new", "bill_id", initialBill.ID, "items", itemCount, "total", runningTotal)
+			return workflow.NewContinueAsNewError(ctx, BillWorkflow, initialBill, next)
+		}
+	}
+	cancelTimer()
+
+	if billVoided {
+		finalizeErr = saveVoidedBill(ctx, initialBill.ID, voidReason)
+		isFinalized = true
+		if err := workflow.Await(ctx, func() bool { return workflow.AllHandlersFinished(ctx) }); err != nil {
+			return fmt.Errorf("failed waiting for update handlers: %w", err)
+		}
+		if finalizeErr != nil {
+			return finalizeErr
+		}
+		logger.Info("Bill workflow voided", "bill_id", initialBill.ID, "reason", voidReason, "line_items_count", itemCount)
+		return nil
+	}
+
+	if err := workflow.Await(ctx, func() bool { return attachingDiscounts == 0 }); err != nil {
+		return fmt.Errorf("failed waiting for discounts: %w", err)
+	}
+	dueAt := initialBill.DueDate(workflow.Now(ctx))
+	totals, err := closeBill(ctx, initialBill, dueAt)
+	if err == nil {
+		lineItems = append(lineItems, totals.Usage...)
+		lineItems = append(lineItems, totals.Fees...)
+		lineItems = append(lineItems, totals.Adjustments...)
+		lineItems = append(lineItems, totals.Discounts...)
+		runningTotal = totals.Total
+		taxAmount = totals.TaxAmount
+		taxes = totals.Taxes
+	}
+	finalizeErr = err
+	isFinalized = true
+
+	// Let in-flight update handlers return their result before the run completes.
+	if err := workflow.Await(ctx, func() bool { return workflow.AllHandlersFinished(ctx) }); err != nil {
+		return fmt.Errorf("failed waiting for update handlers: %w", err)
+	}
+	if finalizeErr != nil {
+		return finalizeErr
+	}
+
+	if workflow.GetVersion(ctx, dunningChange, workflow.DefaultVersion, 1) >= 1 {
+		if err := startDunning(ctx, initialBill.ID, dueAt); err != nil {
+			return err
+		}
+	}
+
+	logger.Info("Bill workflow completed successfully",
+		"bill_id", initialBill.ID,
+		"total_amount", totals.Total,
+		"line_items_count", itemCount)
+
+	return nil
+}
+
+// settleOutbox marks done the outbox events for the items and voids this run
+// and the one before it applied, and reports whether it could. Items that
+// arrive while it runs are settled by the next run.
+func settleOutbox(ctx workflow.Context, billID string, progress BillProgress, lineItems, voidedItems []LineItem, earlierVoids []string) bool {
+	input := SettleOutboxInput{
+		BillID:  billID,
+		ItemIDs: append([]string(nil), progress.RecentItemIDs...),
+		VoidIDs: append(append([]string(nil), progress.RecentVoidIDs...), earlierVoids...),
+	}
+	for _, items := range [][]LineItem{lineItems, voidedItems} {
+		for _, item := range items {
+			if item.ID != "" {
+				input.ItemIDs = append(input.ItemIDs, item.ID)
+			}
+		}
+	}
+	for _, voided := range voidedItems {
+		input.VoidIDs = append(input.VoidIDs, voided.ID)
+	}
+
+	ctx = workflow.WithActivityOptions(ctx, billActivityOptions)
+	if err := workflow.ExecuteActivity(ctx, "SettleOutboxActivity", input).Get(ctx, nil); err != nil {
+		workflow.GetLogger(ctx).Warn("Failed to settle outbox events, carrying their IDs forward", "bill_id", billID, "error", err)
+		return false
+	}
+	return true
+}
+
+func containsID(ids []string, id string) bool {
+	for _, existing := range ids {
+		if existing == id {
+			return true
+		}
+	}
+	return false
+}
+
+// startDunning hands the closed bill over to a DunningWorkflow that outlives
+// this one. A bill that was reopened had its dunning cancelled, and gets a
+// new one when it closes again; one that completed is never restarted.
+func startDunning(ctx workflow.Context, billID string, dueAt time.Time) error {
+	cwo := workflow.ChildWorkflowOptions{
+		WorkflowID:            DunningWorkflowID(billID),
+		ParentClosePolicy:     enumspb.PARENT_CLOSE_POLICY_ABANDON,
+		WorkflowIDReusePolicy: enumspb.WORKFLOW_ID_REUSE_POLICY_ALLOW_DUPLICATE_FAILED_ONLY,
+	}
+	child := workflow.ExecuteChildWorkflow(workflow.WithChildOptions(ctx, cwo), DunningWorkflow, DunningInput{
+		BillID: billID,
+		DueAt:  dueAt,
+		Policy: CurrentDunningPolicy(),
+	})
+
+	// Only wait for the start; the dunning workflow runs for weeks.
+	if err := child.GetChildWorkflowExecution().Get(ctx, nil); err != nil {
+		if temporal.IsWorkflowExecutionAlreadyStartedError(err) {
+			return nil
+		}
+		workflow.GetLogger(ctx).Error("Failed to start dunning workflow", "bill_id", billID, "error", err)
+		return fmt.Errorf("failed to start dunning workflow: %w", err)
+	}
+	return nil
+}
+
+// billActivityOptions are shared by the activities that close or void a
+// bill.
+var billActivityOptions = workflow.ActivityOptions{
+	StartToCloseTimeout: 30 * time.Second,
+	RetryPolicy: &temporal.RetryPolicy{
+		MaximumAttempts:    3,
+		BackoffCoefficient: 2.0,
+		InitialInterval:    time.Second,
+		MaximumInterval:    30 * time.Second,
+	},
+}
+
+// saveVoidedBill marks the bill voided instead of pricing it.
+func saveVoidedBill(ctx workflow.Context, billID, reason string) error {
+	ctx = workflow.WithActivityOptions(ctx, billActivityOptions)
+	err := workflow.ExecuteActivity(ctx, "VoidBillActivity", VoidBillInput{
+		BillID:   billID,
+		Reason:   reason,
+		VoidedAt: workflow.Now(ctx),
+	}).Get(ctx, nil)
+	if err != nil {
+		workflow.GetLogger(ctx).Error("Failed to void bill", "bill_id", billID, "error", err)
+		return fmt.Errorf("failed to void bill: %w", err)
+	}
+	return nil
+}
+
+// closeBill prices and stores the closed bill. Its items are always read
+// from the database, which has every item the bill was given: a run that
+// continued as new only holds its own, and an item whose delivery to the
+// workflow is still pending has been accepted all the same.
+func closeBill(ctx workflow.Context, bill Bill, dueAt time.Time) (BillTotals, error) {
+	logger := workflow.GetLogger(ctx)
+
+	ctx = workflow.WithActivityOptions(ctx, billActivityOptions)
+
+	// Metered usage is priced first so fees and limits apply to it like any
+	// other charge.
+	var usage PricedUsage
+	if workflow.GetVersion(ctx, usagePricingChange, workflow.DefaultVersion, 1) >= 1 {
+		err := workflow.ExecuteActivity(ctx, "PriceUsageActivity", PriceUsageInput{
+			BillID:   bill.ID,
+			Currency: bill.Currency,
+		}).Get(ctx, &usage)
+		if err != nil {
+			logger.Error("Failed to price usage", "error", err)
+			return BillTotals{}, fmt.Errorf("failed to price usage: %w", err)
+		}
+	}
+
+	input := CalculateTotalInput{
+		BillID:        bill.ID,
+		CustomerID:    bill.CustomerID,
+		Currency:      bill.Currency,
+		FeeScheduleID: bill.FeeScheduleID,
+		LineItems:     usage.Items,
+		StoredItems:   true,
+		Limits:        bill.Limits(),
+		Adjustment:    bill.IsAdjustment(),
+	}
+
+	var totals BillTotals
+	err := workflow.ExecuteActivity(ctx, "CalculateTotalActivity", input).Get(ctx, &totals)
+	if err != nil {
+		logger.Error("Failed to calculate total", "error", err)
+		return BillTotals{}, fmt.Errorf("failed to calculate total: %w", err)
+	}
+	totals.Usage = usage.Items
+
+	// Discounts come off after fees and limits and before tax, in the order
+	// they were attached, and never take the bill below its minimum charge.
+	if workflow.GetVersion(ctx, discountsChange, workflow.DefaultVersion, 1) >= 1 {
+		var discounts BillDiscounts
+		err = workflow.ExecuteActivity(ctx, "ApplyDiscountsActivity", ApplyDiscountsInput{
+			BillID:  bill.ID,
+			Amount:  totals.Total,
+			Minimum: totals.MinimumAmount,
+		}).Get(ctx, &discounts)
+		if err != nil {
+			logger.Error("Failed to apply discounts", "error", err)
+			return BillTotals{}, fmt.Errorf("failed to apply discounts: %w", err)
+		}
+		totals.Discounts = discounts.Items
+		totals.DiscountTotal = discounts.DiscountTotal
+		totals.AppliedDiscounts = discounts.Applied
+		totals.Total = discounts.Total
+	}
+
+	taxInput := CalculateTaxInput{
+		BillID:     bill.ID,
+		CustomerID: bill.CustomerID,
+		Currency:   bill.Currency,
+		Amount:     totals.Total,
+	}
+
+	tax := BillTax{Subtotal: totals.Total, Total: totals.Total}
+	if workflow.GetVersion(ctx, taxChange, workflow.DefaultVersion, 1) >= 1 {
+		err = workflow.ExecuteActivity(ctx, "CalculateTaxActivity", taxInput).Get(ctx, &tax)
+		if err != nil {
+			logger.Error("Failed to calculate tax", "error", err)
+			return BillTotals{}, fmt.Errorf("failed to calculate tax: %w", err)
+		}
+	}
+	totals.TaxAmount = tax.TaxAmount
+	totals.Taxes = tax.Lines
+	totals.Total = tax.Total
+
+	var billed []LineItem
+	billed = append(billed, totals.Items...)
+	billed = append(billed, usage.Items...)
+	billed = append(billed, totals.Fees...)
+	billed = append(billed, totals.Adjustments...)
+	billed = append(billed, totals.Discounts...)
+
+	finalBill := FinalBill{
+		ID:                 bill.ID,
+		CustomerID:         bill.CustomerID,
+		Currency:           bill.Currency,
+		LegalEntity:        bill.LegalEntity,
+		PeriodStart:        bill.PeriodStart,
+		PeriodEnd:          bill.PeriodEnd,
+		LineItems:          billed,
+		SubtotalAmount:     tax.Subtotal,
+		TaxAmount:          tax.TaxAmount,
+		Taxes:              tax.Lines,
+		TotalAmount:        tax.Total,
+		Status:             BillStatusClosed,
+		Fees:               totals.Fees,
+		FeeScheduleID:      totals.FeeScheduleID,
+		FeeScheduleVersion: totals.FeeScheduleVersion,
+		Adjustments:        totals.Adjustments,
+		Discounts:          totals.Discounts,
+		AppliedDiscounts:   totals.AppliedDiscounts,
+		Usage:              usage.Items,
+		UsageThrough:       usage.Through,
+		UsageClaimed:       usage.Claimed,
+		DueAt:              dueAt,
+	}
+
+	err = workflow.ExecuteActivity(ctx, "SaveFinalBillActivity", finalBill).Get(ctx, nil)
+	if err != nil {
+		logger.Error("Failed to save final bill", "error", err)
+		return BillTotals{}, fmt.Errorf("failed to save final bill: %w", err)
 	}
 
+	return totals, nil
+}
+
+const (
+	PaymentRecordedSignal = "PAYMENT_RECORDED"
+	GetDunningStateQuery  = "GET_DUNNING_STATE"
+)
+
+type DunningInput struct {
+	BillID string
+	DueAt  time.Time
+	Policy DunningPolicy
+}
+
+type DunningState struct {
+	BillID        string        `json:"billId"`
+	DueAt         time.Time     `json:"dueAt"`
+	Status        DunningStatus `json:"status"`
+	RemindersSent int           `json:"remindersSent"`
+}
+
+// DunningWorkflow chases payment for a closed bill. It sleeps until each
+// reminder in the policy is due, sends it, and finally writes the bill off.
+// A PAYMENT_RECORDED signal that settles the bill stops it at once; the
+// activities also check the bill before each step, so a lost signal only
+// delays the stop until the next one.
+func DunningWorkflow(ctx workflow.Context, input DunningInput) error {
+	logger := workflow.GetLogger(ctx)
+	logger.Info("Starting dunning workflow", "bill_id", input.BillID, "due_at", input.DueAt)
+
 	ao := workflow.ActivityOptions{
 		StartToCloseTimeout: 30 * time.Second,
 		RetryPolicy: &temporal.RetryPolicy{
-			MaximumAttempts:    3,
+			MaximumAttempts:    10,
 			BackoffCoefficient: 2.0,
 			InitialInterval:    time.Second,
-			MaximumInterval:    30 * time.Second,
+			MaximumInterval:    10 * time.Minute,
 		},
 	}
 	ctx = workflow.WithActivityOptions(ctx, ao)
 
-	var total int64
-	err := workflow.ExecuteActivity(ctx, "CalculateTotalActivity", lineItems).Get(ctx, &total)
+	state := DunningState{BillID: input.BillID, DueAt: input.DueAt, Status: DunningStatusScheduled}
+	err := workflow.SetQueryHandler(ctx, GetDunningStateQuery, func() (DunningState, error) {
+		return state, nil
+	})
 	if err != nil {
-		logger.Error("Failed to calculate total", "error", err)
-		return fmt.Errorf("failed to calculate total: %w", err)
+		return fmt.Errorf("failed to register dunning state query handler: %w", err)
 	}
 
-	finalBill := FinalBill{
-		ID:          initialBill.ID,
-		TotalAmount: total,
-		Status:      BillStatusClosed,
+	settled := false
+	paymentChan := workflow.GetSignalChannel(ctx, PaymentRecordedSignal)
+
+	// waitUntil sleeps until at, returning false early if a payment settles
+	// the bill first.
+	waitUntil := func(at time.Time) bool {
+		for !settled {
+			wait := at.Sub(workflow.Now(ctx))
+			if wait <= 0 {
+				return true
+			}
+			timerCtx, cancelTimer := workflow.WithCancel(ctx)
+			fired := false
+			selector := workflow.NewSelector(ctx)
+			selector.AddFuture(workflow.NewTimer(timerCtx, wait), func(f workflow.Future) {
+				fired = true
+			})
+			selector.AddReceive(paymentChan, func(c workflow.ReceiveChannel, more bool) {
+				var payment PaymentRecorded
+				c.Receive(ctx, &payment)
+				logger.Info("Payment recorded", "payment_id", payment.PaymentID, "balance", payment.Balance, "payment_status", payment.PaymentStatus)
+				settled = payment.PaymentStatus.IsSettled()
+			})
+			selector.Select(ctx)
+			cancelTimer()
+			if fired {
+				return true
+			}
+		}
+		return false
 	}
 
-	err = workflow.ExecuteActivity(ctx, "SaveFinalBillActivity", finalBill).Get(ctx, nil)
-	if err != nil {
-		logger.Error("Failed to save final bill", "error", err)
-		return fmt.Errorf("failed to save final bill: %w", err)
+	step := func(activity string, stepInput DunningStepInput) error {
+		var result DunningStepResult
+		if err := workflow.ExecuteActivity(ctx, activity, stepInput).Get(ctx, &result); err != nil {
+			logger.Error("Dunning step failed", "activity", activity, "stage", stepInput.Stage, "error", err)
+			return fmt.Errorf("dunning step %s failed: %w", activity, err)
+		}
+		settled = result.Settled
+		return nil
 	}
 
-	logger.Info("Bill workflow completed successfully",
-		"bill_id", initialBill.ID,
-		"total_amount", total,
-		"line_items_count", len(lineItems))
+	reminders := input.Policy.ReminderDays
+	for i, days := range reminders {
+		if !waitUntil(input.DueAt.AddDate(0, 0, days)) {
+			break
+		}
+		err := step("SendDunningReminderActivity", DunningStepInput{
+			BillID:      input.BillID,
+			DueAt:       input.DueAt,
+			DaysOverdue: days,
+			Stage:       i + 1,
+			Final:       i == len(reminders)-1,
+		})
+		if err != nil {
+			return err
+		}
+		if settled {
+			break
+		}
+		state.Status = DunningStatusOverdue
+		state.RemindersSent = i + 1
+	}
+
+	if !settled && input.Policy.WriteOffAfterDays > 0 && waitUntil(input.DueAt.AddDate(0, 0, input.Policy.WriteOffAfterDays)) {
+		err := step("WriteOffBillActivity", DunningStepInput{
+			BillID:      input.BillID,
+			DueAt:       input.DueAt,
+			DaysOverdue: input.Policy.WriteOffAfterDays,
+			Stage:       state.RemindersSent,
+			Final:       true,
+		})
+		if err != nil {
+			return err
+		}
+		if !settled {
+			state.Status = DunningStatusWrittenOff
+			logger.Info("Bill written off", "bill_id", input.BillID)
+			return nil
+		}
+	}
+
+	if settled {
+		if err := workflow.ExecuteActivity(ctx, "StopDunningActivity", input.BillID).Get(ctx, nil); err != nil {
+			logger.Error("Failed to stop dunning", "error", err)
+			return fmt.Errorf("failed to stop dunning: %w", err)
+		}
+		state.Status = DunningStatusStopped
+	}
 
+	logger.Info("Dunning workflow completed", "bill_id", input.BillID, "status", state.Status, "reminders_sent", state.RemindersSent)
 	return nil
 }
+
+const CancelSubscriptionSignal = "CANCEL_SUBSCRIPTION"
+
+type SubscriptionInput struct {
+	SubscriptionID string
+	AnchorDay      int
+	// PeriodStart is when the next period starts and gets its bill.
+	PeriodStart time.Time
+}
+
+// SubscriptionWorkflow bills a subscription one period at a time: it sleeps
+// until the next period starts, renews the subscription onto a new bill and
+// then continues as new for the period after, so its history stays short.
+// Pausing is picked up at the next renewal; CANCEL_SUBSCRIPTION ends the
+// workflow straight away.
+func SubscriptionWorkflow(ctx workflow.Context, input SubscriptionInput) error {
+	logger := workflow.GetLogger(ctx)
+	cancelChan := workflow.GetSignalChannel(ctx, CancelSubscriptionSignal)
+
+	if wait := input.PeriodStart.Sub(workflow.Now(ctx)); wait > 0 {
+		timerCtx, cancelTimer := workflow.WithCancel(ctx)
+		cancelled := false
+		selector := workflow.NewSelector(ctx)
+		selector.AddFuture(workflow.NewTimer(timerCtx, wait), func(f workflow.Future) {})
+		selector.AddReceive(cancelChan, func(c workflow.ReceiveChannel, more bool) {
+			c.Receive(ctx, nil)
+			cancelled = true
+		})
+		selector.Select(ctx)
+		cancelTimer()
+		if cancelled {
+			logger.Info("Subscription cancelled", "subscription_id", input.SubscriptionID)
+			return nil
+		}
+	}
+
+	// Periods that ended while the workflow wasn't running are not billed
+	// after the fact.
+	start := input.PeriodStart
+	end := NextAnchorDate(input.AnchorDay, start)
+	for !end.After(workflow.Now(ctx)) {
+		start, end = end, NextAnchorDate(input.AnchorDay, end)
+	}
+
+	ao := workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute,
+		RetryPolicy: &temporal.RetryPolicy{
+			MaximumAttempts:    10,
+			BackoffCoefficient: 2.0,
+			InitialInterval:    time.Second,
+			MaximumInterval:    10 * time.Minute,
+		},
+	}
+	var renewal SubscriptionRenewal
+	err := workflow.ExecuteActivity(workflow.WithActivityOptions(ctx, ao), "RenewSubscriptionActivity", RenewSubscriptionInput{
+		SubscriptionID: input.SubscriptionID,
+		PeriodStart:    start,
+		PeriodEnd:      end,
+	}).Get(ctx, &renewal)
+	if err != nil {
+		logger.Error("Failed to renew subscription", "subscription_id", input.SubscriptionID, "error", err)
+		return fmt.Errorf("failed to renew subscription: %w", err)
+	}
+	if renewal.Status == SubscriptionStatusCancelled || cancelChan.ReceiveAsync(nil) {
+		logger.Info("Subscription cancelled", "subscription_id", input.SubscriptionID)
+		return nil
+	}
+
+	input.PeriodStart = end
+	return workflow.NewContinueAsNewError(ctx, SubscriptionWorkflow, input)
+}
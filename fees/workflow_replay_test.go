@@ -0,0 +1,39 @@
+package fees
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/worker"
+)
+
+// TestBillWorkflow_Replay replays bill workflow histories against the
+// current code. A failure means open bills would hit a nondeterminism error
+// on the next worker deploy: put the change behind workflow.GetVersion
+// instead. Add a history here for every versioned change, recorded with
+// `temporal workflow show --output json` before the change ships.
+//
+// The histories checked in so far weren't recorded from a Temporal server.
+// They were generated by running BillWorkflow in an SDK worker against a
+// stubbed frontend, with made-up bills, identities and request IDs. The
+// *_baseline ones ran the workflow as it was before any change was
+// versioned: an open bill, one that took more items while it closed, and
+// one caught between pricing and saving its final bill. Replace them with
+// recorded histories when they're available.
+func TestBillWorkflow_Replay(t *testing.T) {
+	histories, err := filepath.Glob(filepath.Join("testdata", "bill_*.json"))
+	require.NoError(t, err)
+	require.NotEmpty(t, histories)
+
+	for _, history := range histories {
+		t.Run(filepath.Base(history), func(t *testing.T) {
+			replayer := worker.NewWorkflowReplayer()
+			replayer.RegisterWorkflow(BillWorkflow)
+			replayer.RegisterWorkflow(DunningWorkflow)
+
+			assert.NoError(t, replayer.ReplayWorkflowHistoryFromJSONFile(nil, history))
+		})
+	}
+}
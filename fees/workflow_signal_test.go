@@ -1,12 +1,18 @@
 package fees
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"testing"
 	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/converter"
 	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
 )
 
 func TestBillWorkflow_Signals(t *testing.T) {
@@ -18,13 +24,17 @@ func TestBillWorkflow_Signals(t *testing.T) {
 		activities := &Activities{}
 		env.RegisterActivity(activities.CalculateTotalActivity)
 		env.RegisterActivity(activities.SaveFinalBillActivity)
+		withoutDiscounts(env, activities)
+		withoutUsage(env, activities)
+		withoutTax(env, activities)
+		withoutDunning(env)
 
 		expectedItems := []LineItem{
 			{Description: "Item 1", Amount: 1000},
 			{Description: "Item 2", Amount: 1500},
 		}
 
-		env.OnActivity("CalculateTotalActivity", mock.Anything, expectedItems).Return(int64(2500), nil)
+		env.OnActivity("CalculateTotalActivity", mock.Anything, pricesStoredItems(nil)).Return(BillTotals{Subtotal: 2500, Total: 2500}, nil)
 		env.OnActivity("SaveFinalBillActivity", mock.Anything, mock.MatchedBy(func(bill FinalBill) bool {
 			return bill.ID == "bill-123" && bill.TotalAmount == 2500 && bill.Status == BillStatusClosed
 		})).Return(nil)
@@ -48,11 +58,12 @@ func TestBillWorkflow_Signals(t *testing.T) {
 			Status:     BillStatusOpen,
 		}
 
-		env.ExecuteWorkflow(BillWorkflow, initialBill)
+		env.ExecuteWorkflow(BillWorkflow, initialBill, BillProgress{})
 
 		require.True(t, env.IsWorkflowCompleted())
 		require.NoError(t, env.GetWorkflowError())
 
+		assertAcceptedItems(t, env, expectedItems)
 		env.AssertExpectations(t)
 	})
 
@@ -62,9 +73,12 @@ func TestBillWorkflow_Signals(t *testing.T) {
 		activities := &Activities{}
 		env.RegisterActivity(activities.CalculateTotalActivity)
 		env.RegisterActivity(activities.SaveFinalBillActivity)
+		withoutDiscounts(env, activities)
+		withoutUsage(env, activities)
+		withoutTax(env, activities)
+		withoutDunning(env)
 
-		var emptyItems []LineItem = nil
-		env.OnActivity("CalculateTotalActivity", mock.Anything, emptyItems).Return(int64(0), nil)
+		env.OnActivity("CalculateTotalActivity", mock.Anything, pricesStoredItems(nil)).Return(BillTotals{}, nil)
 		env.OnActivity("SaveFinalBillActivity", mock.Anything, mock.MatchedBy(func(bill FinalBill) bool {
 			return bill.ID == "bill-empty" && bill.TotalAmount == 0 && bill.Status == BillStatusClosed
 		})).Return(nil)
@@ -80,7 +94,7 @@ func TestBillWorkflow_Signals(t *testing.T) {
 			Status:     BillStatusOpen,
 		}
 
-		env.ExecuteWorkflow(BillWorkflow, initialBill)
+		env.ExecuteWorkflow(BillWorkflow, initialBill, BillProgress{})
 
 		require.True(t, env.IsWorkflowCompleted())
 		require.NoError(t, env.GetWorkflowError())
@@ -94,6 +108,10 @@ func TestBillWorkflow_Signals(t *testing.T) {
 		activities := &Activities{}
 		env.RegisterActivity(activities.CalculateTotalActivity)
 		env.RegisterActivity(activities.SaveFinalBillActivity)
+		withoutDiscounts(env, activities)
+		withoutUsage(env, activities)
+		withoutTax(env, activities)
+		withoutDunning(env)
 
 		expectedItems := []LineItem{
 			{Description: "Item 1", Amount: 500},
@@ -101,9 +119,19 @@ func TestBillWorkflow_Signals(t *testing.T) {
 			{Description: "Item 3", Amount: 750},
 		}
 
-		env.OnActivity("CalculateTotalActivity", mock.Anything, expectedItems).Return(int64(2250), nil)
+		scheduleVersion := 2
+		fees := []LineItem{{Description: "Standard v2 tier 1", Amount: 45, Kind: LineItemKindFee}}
+		env.OnActivity("CalculateTotalActivity", mock.Anything, pricesStoredItems(nil)).Return(BillTotals{
+			Subtotal:           2250,
+			Fees:               fees,
+			FeeTotal:           45,
+			Total:              2295,
+			FeeScheduleID:      "standard",
+			FeeScheduleVersion: &scheduleVersion,
+		}, nil)
 		env.OnActivity("SaveFinalBillActivity", mock.Anything, mock.MatchedBy(func(bill FinalBill) bool {
-			return bill.ID == "bill-multi" && bill.TotalAmount == 2250 && bill.Status == BillStatusClosed
+			return bill.ID == "bill-multi" && bill.TotalAmount == 2295 && bill.Status == BillStatusClosed &&
+				len(bill.Fees) == 1 && bill.FeeScheduleID == "standard" && *bill.FeeScheduleVersion == 2
 		})).Return(nil)
 
 		env.RegisterDelayedCallback(func() {
@@ -129,11 +157,1406 @@ func TestBillWorkflow_Signals(t *testing.T) {
 			Status:     BillStatusOpen,
 		}
 
-		env.ExecuteWorkflow(BillWorkflow, initialBill)
+		env.ExecuteWorkflow(BillWorkflow, initialBill, BillProgress{})
 
 		require.True(t, env.IsWorkflowCompleted())
 		require.NoError(t, env.GetWorkflowError())
 
+		assertAcceptedItems(t, env, expectedItems)
 		env.AssertExpectations(t)
 	})
+
+	t.Run("Query_Live_State", func(t *testing.T) {
+		env := testSuite.NewTestWorkflowEnvironment()
+
+		activities := &Activities{}
+		env.RegisterActivity(activities.CalculateTotalActivity)
+		env.RegisterActivity(activities.SaveFinalBillActivity)
+		withoutDiscounts(env, activities)
+		withoutUsage(env, activities)
+		withoutTax(env, activities)
+		withoutDunning(env)
+
+		env.OnActivity("CalculateTotalActivity", mock.Anything, mock.Anything).Return(BillTotals{Subtotal: 1750, Total: 1750}, nil)
+		env.OnActivity("SaveFinalBillActivity", mock.Anything, mock.Anything).Return(nil)
+
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(AddLineItemSignal, LineItem{Description: "Item 1", Amount: 1000})
+		}, time.Millisecond*100)
+
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(AddLineItemSignal, LineItem{Description: "Item 2", Amount: 750})
+		}, time.Millisecond*200)
+
+		var liveState BillState
+		env.RegisterDelayedCallback(func() {
+			value, err := env.QueryWorkflow(GetBillStateQuery)
+			require.NoError(t, err)
+			require.NoError(t, value.Get(&liveState))
+		}, time.Millisecond*300)
+
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(CloseBillSignal, nil)
+		}, time.Millisecond*400)
+
+		initialBill := Bill{
+			ID:         "bill-live",
+			CustomerID: "customer-live",
+			Currency:   USD,
+			Status:     BillStatusOpen,
+		}
+
+		env.ExecuteWorkflow(BillWorkflow, initialBill, BillProgress{})
+
+		require.True(t, env.IsWorkflowCompleted())
+		require.NoError(t, env.GetWorkflowError())
+
+		assert.Equal(t, "bill-live", liveState.BillID)
+		assert.Equal(t, USD, liveState.Currency)
+		assert.Len(t, liveState.LineItems, 2)
+		assert.Equal(t, int64(1750), liveState.TotalAmount)
+		assert.False(t, liveState.IsClosed)
+	})
+
+	t.Run("Updates_Validate_And_Close", func(t *testing.T) {
+		env := testSuite.NewTestWorkflowEnvironment()
+
+		activities := &Activities{}
+		env.RegisterActivity(activities.CalculateTotalActivity)
+		env.RegisterActivity(activities.SaveFinalBillActivity)
+		withoutDiscounts(env, activities)
+		withoutUsage(env, activities)
+		withoutTax(env, activities)
+		withoutDunning(env)
+
+		expectedItems := []LineItem{
+			{Description: "Item 1", Amount: 1200},
+		}
+
+		env.OnActivity("CalculateTotalActivity", mock.Anything, pricesStoredItems(nil)).Return(BillTotals{Subtotal: 1200, Total: 1200}, nil).After(time.Second)
+		env.OnActivity("SaveFinalBillActivity", mock.Anything, mock.MatchedBy(func(bill FinalBill) bool {
+			return bill.ID == "bill-update" && bill.TotalAmount == 1200 && bill.Status == BillStatusClosed
+		})).Return(nil)
+
+		var addErr, rejectErr, lateErr error
+		var addResult, closeResult BillState
+
+		env.RegisterDelayedCallback(func() {
+			env.UpdateWorkflow(AddLineItemUpdate, "add-1", &testsuite.TestUpdateCallback{
+				OnAccept: func() {},
+				OnReject: func(err error) { addErr = err },
+				OnComplete: func(result interface{}, err error) {
+					if state, ok := result.(BillState); ok {
+						addResult = state
+					}
+				},
+			}, LineItem{Description: "Item 1", Amount: 1200})
+		}, time.Millisecond*100)
+
+		env.RegisterDelayedCallback(func() {
+			env.UpdateWorkflow(AddLineItemUpdate, "add-2", &testsuite.TestUpdateCallback{
+				OnAccept:   func() {},
+				OnReject:   func(err error) { rejectErr = err },
+				OnComplete: func(interface{}, error) {},
+			}, LineItem{Description: "Negative", Amount: -5})
+		}, time.Millisecond*200)
+
+		env.RegisterDelayedCallback(func() {
+			env.UpdateWorkflow(CloseBillUpdate, "close-1", &testsuite.TestUpdateCallback{
+				OnAccept: func() {},
+				OnReject: func(err error) { require.NoError(t, err) },
+				OnComplete: func(result interface{}, err error) {
+					require.NoError(t, err)
+					if state, ok := result.(BillState); ok {
+						closeResult = state
+					}
+				},
+			})
+		}, time.Millisecond*300)
+
+		env.RegisterDelayedCallback(func() {
+			env.UpdateWorkflow(AddLineItemUpdate, "add-3", &testsuite.TestUpdateCallback{
+				OnAccept:   func() {},
+				OnReject:   func(err error) { lateErr = err },
+				OnComplete: func(interface{}, error) {},
+			}, LineItem{Description: "Too late", Amount: 100})
+		}, time.Millisecond*500)
+
+		initialBill := Bill{
+			ID:         "bill-update",
+			CustomerID: "customer-update",
+			Currency:   USD,
+			Status:     BillStatusOpen,
+		}
+
+		env.ExecuteWorkflow(BillWorkflow, initialBill, BillProgress{})
+
+		require.True(t, env.IsWorkflowCompleted())
+		require.NoError(t, env.GetWorkflowError())
+
+		assert.NoError(t, addErr)
+		assert.Equal(t, int64(1200), addResult.TotalAmount)
+		assert.ErrorIs(t, workflowRejection(rejectErr), ErrInvalidAmount)
+		assert.ErrorIs(t, workflowRejection(lateErr), ErrBillAlreadyClosed)
+		assert.True(t, closeResult.IsClosed)
+		assert.Equal(t, int64(1200), closeResult.TotalAmount)
+
+		assertAcceptedItems(t, env, expectedItems)
+		env.AssertExpectations(t)
+	})
+
+	t.Run("Closes_At_Period_End", func(t *testing.T) {
+		env := testSuite.NewTestWorkflowEnvironment()
+
+		activities := &Activities{}
+		env.RegisterActivity(activities.CalculateTotalActivity)
+		env.RegisterActivity(activities.SaveFinalBillActivity)
+		withoutDiscounts(env, activities)
+		withoutUsage(env, activities)
+		withoutTax(env, activities)
+		withoutDunning(env)
+
+		expectedItems := []LineItem{
+			{Description: "Item 1", Amount: 300},
+		}
+
+		env.OnActivity("CalculateTotalActivity", mock.Anything, pricesStoredItems(nil)).Return(BillTotals{Subtotal: 300, Total: 300}, nil)
+		env.OnActivity("SaveFinalBillActivity", mock.Anything, mock.MatchedBy(func(bill FinalBill) bool {
+			return bill.ID == "bill-period" && bill.TotalAmount == 300 && bill.Status == BillStatusClosed
+		})).Return(nil)
+
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(AddLineItemSignal, LineItem{Description: "Item 1", Amount: 300})
+		}, time.Hour)
+
+		periodEnd := env.Now().Add(30 * 24 * time.Hour)
+		initialBill := Bill{
+			ID:         "bill-period",
+			CustomerID: "customer-period",
+			Currency:   USD,
+			Status:     BillStatusOpen,
+			PeriodEnd:  &periodEnd,
+		}
+
+		env.ExecuteWorkflow(BillWorkflow, initialBill, BillProgress{})
+
+		require.True(t, env.IsWorkflowCompleted())
+		require.NoError(t, env.GetWorkflowError())
+		assert.False(t, env.Now().Before(periodEnd))
+
+		assertAcceptedItems(t, env, expectedItems)
+		env.AssertExpectations(t)
+	})
+}
+
+func TestBillWorkflow_Credits(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+
+	charge := LineItem{Description: "Service", Amount: 1000, Kind: LineItemKindCharge}
+	outageCredit := LineItem{Description: "Outage", Amount: -400, Kind: LineItemKindCredit, ReasonCode: CreditReasonOutage}
+	refund := LineItem{Description: "Refund", Amount: -700, Kind: LineItemKindCredit, ReasonCode: CreditReasonRefund}
+
+	tests := []struct {
+		name          string
+		allowNegative bool
+		wantItems     []LineItem
+		wantTotal     int64
+		wantRejection error
+	}{
+		{"Rejects_Credit_Below_Zero", false, []LineItem{charge, outageCredit}, 600, ErrNegativeTotal},
+		{"Allows_Negative_Total_When_Enabled", true, []LineItem{charge, outageCredit, refund}, -100, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := testSuite.NewTestWorkflowEnvironment()
+
+			activities := &Activities{}
+			env.RegisterActivity(activities.CalculateTotalActivity)
+			env.RegisterActivity(activities.SaveFinalBillActivity)
+			withoutDiscounts(env, activities)
+			withoutUsage(env, activities)
+			withoutTax(env, activities)
+			withoutDunning(env)
+
+			env.OnActivity("CalculateTotalActivity", mock.Anything, pricesStoredItems(nil)).
+				Return(BillTotals{Subtotal: tt.wantTotal, Total: tt.wantTotal}, nil)
+			env.OnActivity("SaveFinalBillActivity", mock.Anything, mock.MatchedBy(func(bill FinalBill) bool {
+				return bill.TotalAmount == tt.wantTotal
+			})).Return(nil)
+
+			var refundErr error
+			for i, item := range []LineItem{charge, outageCredit} {
+				item := item
+				env.RegisterDelayedCallback(func() {
+					env.UpdateWorkflow(AddLineItemUpdate, fmt.Sprintf("add-%d", i), &testsuite.TestUpdateCallback{
+						OnAccept:   func() {},
+						OnReject:   func(err error) { require.NoError(t, err) },
+						OnComplete: func(interface{}, error) {},
+					}, item)
+				}, time.Millisecond*time.Duration(100*(i+1)))
+			}
+
+			env.RegisterDelayedCallback(func() {
+				env.UpdateWorkflow(AddLineItemUpdate, "add-refund", &testsuite.TestUpdateCallback{
+					OnAccept:   func() {},
+					OnReject:   func(err error) { refundErr = err },
+					OnComplete: func(interface{}, error) {},
+				}, refund)
+			}, time.Millisecond*300)
+
+			env.RegisterDelayedCallback(func() {
+				env.SignalWorkflow(CloseBillSignal, nil)
+			}, time.Millisecond*400)
+
+			env.ExecuteWorkflow(BillWorkflow, Bill{
+				ID:                 "bill-credit",
+				CustomerID:         "customer-credit",
+				Currency:           USD,
+				Status:             BillStatusOpen,
+				AllowNegativeTotal: tt.allowNegative,
+			}, BillProgress{})
+
+			require.True(t, env.IsWorkflowCompleted())
+			require.NoError(t, env.GetWorkflowError())
+
+			if tt.wantRejection != nil {
+				assert.ErrorIs(t, workflowRejection(refundErr), tt.wantRejection)
+			} else {
+				assert.NoError(t, refundErr)
+			}
+			assertAcceptedItems(t, env, tt.wantItems)
+			env.AssertExpectations(t)
+		})
+	}
+}
+
+func TestBillWorkflow_VoidLineItem(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	activities := &Activities{}
+	env.RegisterActivity(activities.CalculateTotalActivity)
+	env.RegisterActivity(activities.SaveFinalBillActivity)
+	withoutDiscounts(env, activities)
+	withoutUsage(env, activities)
+	withoutTax(env, activities)
+	withoutDunning(env)
+
+	first := LineItem{ID: "item-1", Description: "Item 1", Amount: 1000}
+	second := LineItem{ID: "item-2", Description: "Item 2", Amount: 400}
+	third := LineItem{ID: "item-3", Description: "Item 3", Amount: 250}
+
+	env.OnActivity("CalculateTotalActivity", mock.Anything, pricesStoredItems(nil)).
+		Return(BillTotals{Subtotal: 400, Total: 400}, nil)
+	env.OnActivity("SaveFinalBillActivity", mock.Anything, mock.MatchedBy(func(bill FinalBill) bool {
+		return bill.TotalAmount == 400
+	})).Return(nil)
+
+	for i, item := range []LineItem{first, second, third} {
+		item := item
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(AddLineItemSignal, item)
+		}, time.Millisecond*time.Duration(100*(i+1)))
+	}
+
+	var voidErr, repeatErr, unknownErr error
+	var voidResult BillState
+	env.RegisterDelayedCallback(func() {
+		env.UpdateWorkflow(VoidLineItemUpdate, "void-1", &testsuite.TestUpdateCallback{
+			OnAccept: func() {},
+			OnReject: func(err error) { voidErr = err },
+			OnComplete: func(result interface{}, err error) {
+				if state, ok := result.(BillState); ok {
+					voidResult = state
+				}
+			},
+		}, LineItemVoid{ItemID: "item-1", Reason: "duplicate"})
+	}, time.Millisecond*400)
+
+	env.RegisterDelayedCallback(func() {
+		env.UpdateWorkflow(VoidLineItemUpdate, "void-2", &testsuite.TestUpdateCallback{
+			OnAccept:   func() {},
+			OnReject:   func(err error) { repeatErr = err },
+			OnComplete: func(interface{}, error) {},
+		}, LineItemVoid{ItemID: "item-1"})
+	}, time.Millisecond*500)
+
+	env.RegisterDelayedCallback(func() {
+		env.UpdateWorkflow(VoidLineItemUpdate, "void-3", &testsuite.TestUpdateCallback{
+			OnAccept:   func() {},
+			OnReject:   func(err error) { unknownErr = err },
+			OnComplete: func(interface{}, error) {},
+		}, LineItemVoid{ItemID: "item-404"})
+	}, time.Millisecond*600)
+
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(VoidLineItemSignal, LineItemVoid{ItemID: "item-3"})
+	}, time.Millisecond*700)
+
+	var liveState BillState
+	env.RegisterDelayedCallback(func() {
+		value, err := env.QueryWorkflow(GetBillStateQuery)
+		require.NoError(t, err)
+		require.NoError(t, value.Get(&liveState))
+	}, time.Millisecond*800)
+
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(CloseBillSignal, nil)
+	}, time.Millisecond*900)
+
+	env.ExecuteWorkflow(BillWorkflow, Bill{ID: "bill-void", CustomerID: "customer-void", Currency: USD, Status: BillStatusOpen}, BillProgress{})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+
+	assert.NoError(t, voidErr)
+	assert.Equal(t, int64(650), voidResult.TotalAmount)
+	assert.ErrorIs(t, workflowRejection(repeatErr), ErrLineItemVoided)
+	assert.ErrorIs(t, workflowRejection(unknownErr), ErrLineItemNotFound)
+
+	assert.Equal(t, int64(400), liveState.TotalAmount)
+	require.Len(t, liveState.LineItems, 1)
+	assert.Equal(t, "item-2", liveState.LineItems[0].ID)
+	require.Len(t, liveState.VoidedItems, 2)
+	assert.Equal(t, "duplicate", liveState.VoidedItems[0].VoidReason)
+	assert.NotNil(t, liveState.VoidedItems[0].VoidedAt)
+
+	env.AssertExpectations(t)
+}
+
+func TestBillWorkflow_DedupesLineItemsByID(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	activities := &Activities{}
+	env.RegisterActivity(activities.CalculateTotalActivity)
+	env.RegisterActivity(activities.SaveFinalBillActivity)
+	withoutDiscounts(env, activities)
+	withoutUsage(env, activities)
+	withoutTax(env, activities)
+	withoutDunning(env)
+
+	item := LineItem{ID: "item-1", Description: "Item 1", Amount: 1000}
+	legacy := LineItem{Description: "Legacy", Amount: 100}
+
+	env.OnActivity("CalculateTotalActivity", mock.Anything, pricesStoredItems(nil)).
+		Return(BillTotals{Subtotal: 1200, Total: 1200}, nil)
+	env.OnActivity("SaveFinalBillActivity", mock.Anything, mock.Anything).Return(nil)
+
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(AddLineItemSignal, item)
+	}, time.Millisecond*100)
+
+	// The outbox relay redelivering the same item as an update.
+	var redeliverErr error
+	var redeliverResult BillState
+	env.RegisterDelayedCallback(func() {
+		env.UpdateWorkflow(AddLineItemUpdate, "outbox-1", &testsuite.TestUpdateCallback{
+			OnAccept: func() {},
+			OnReject: func(err error) { redeliverErr = err },
+			OnComplete: func(result interface{}, err error) {
+				if state, ok := result.(BillState); ok {
+					redeliverResult = state
+				}
+			},
+		}, item)
+	}, time.Millisecond*200)
+
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(AddLineItemSignal, item)
+	}, time.Millisecond*300)
+
+	// Items without an ID are never treated as duplicates.
+	for i := 0; i < 2; i++ {
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(AddLineItemSignal, legacy)
+		}, time.Millisecond*time.Duration(400+100*i))
+	}
+
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(CloseBillSignal, nil)
+	}, time.Millisecond*700)
+
+	env.ExecuteWorkflow(BillWorkflow, Bill{ID: "bill-dedupe", CustomerID: "customer-dedupe", Currency: USD, Status: BillStatusOpen}, BillProgress{})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+
+	assert.NoError(t, redeliverErr)
+	assert.Len(t, redeliverResult.LineItems, 1)
+	assert.Equal(t, int64(1000), redeliverResult.TotalAmount)
+
+	assertAcceptedItems(t, env, []LineItem{item, legacy, legacy})
+	env.AssertExpectations(t)
+}
+
+func TestBillWorkflow_ContinueAsNew(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+
+	t.Run("AfterMaxItems", func(t *testing.T) {
+		env := testSuite.NewTestWorkflowEnvironment()
+		withoutDunning(env)
+
+		// The outbox events of this run and the one before are settled so
+		// the next run can't be handed them again.
+		activities := &Activities{}
+		env.RegisterActivity(activities.SettleOutboxActivity)
+		var settled SettleOutboxInput
+		env.OnActivity("SettleOutboxActivity", mock.Anything, mock.Anything).
+			Return(func(ctx context.Context, input SettleOutboxInput) error {
+				settled = input
+				return nil
+			}).Once()
+
+		// The test environment only buffers so many signals, so they arrive
+		// in batches.
+		for start := 0; start < maxBillRunItems; start += 100 {
+			start := start
+			env.RegisterDelayedCallback(func() {
+				for i := start; i < start+100; i++ {
+					env.SignalWorkflow(AddLineItemSignal, LineItem{ID: fmt.Sprintf("item-%d", i), Description: "Call", Amount: 2})
+				}
+			}, time.Millisecond*time.Duration(100+start))
+		}
+
+		env.ExecuteWorkflow(BillWorkflow, Bill{ID: "bill-busy", CustomerID: "customer-busy", Currency: USD, Status: BillStatusOpen},
+			BillProgress{Runs: 1, RecentItemIDs: []string{"item-old"}})
+
+		require.True(t, env.IsWorkflowCompleted())
+		var can *workflow.ContinueAsNewError
+		require.ErrorAs(t, env.GetWorkflowError(), &can)
+
+		var bill Bill
+		var next BillProgress
+		require.NoError(t, converter.GetDefaultDataConverter().FromPayloads(can.Input, &bill, &next))
+		assert.Equal(t, "bill-busy", bill.ID)
+		assert.Equal(t, 2, next.Runs)
+		assert.Equal(t, maxBillRunItems, next.ItemCount)
+		assert.Equal(t, int64(2*maxBillRunItems), next.RunningTotal)
+		assert.Len(t, next.RecentItemIDs, maxBillRunItems)
+		assert.NotContains(t, next.RecentItemIDs, "item-old")
+
+		assert.Equal(t, "bill-busy", settled.BillID)
+		assert.Len(t, settled.ItemIDs, maxBillRunItems+1)
+		assert.Contains(t, settled.ItemIDs, "item-old")
+	})
+
+	t.Run("CarriesIDsWhenSettleFails", func(t *testing.T) {
+		env := testSuite.NewTestWorkflowEnvironment()
+		withoutDunning(env)
+
+		activities := &Activities{}
+		env.RegisterActivity(activities.SettleOutboxActivity)
+		env.OnActivity("SettleOutboxActivity", mock.Anything, mock.Anything).Return(errors.New("database unavailable"))
+
+		for start := 0; start < maxBillRunItems; start += 100 {
+			start := start
+			env.RegisterDelayedCallback(func() {
+				for i := start; i < start+100; i++ {
+					env.SignalWorkflow(AddLineItemSignal, LineItem{ID: fmt.Sprintf("item-%d", i), Description: "Call", Amount: 2})
+				}
+			}, time.Millisecond*time.Duration(100+start))
+		}
+
+		env.ExecuteWorkflow(BillWorkflow, Bill{ID: "bill-busy", CustomerID: "customer-busy", Currency: USD, Status: BillStatusOpen},
+			BillProgress{Runs: 1, RecentItemIDs: []string{"item-old"}, RecentVoidIDs: []string{"item-gone"}})
+
+		require.True(t, env.IsWorkflowCompleted())
+		var can *workflow.ContinueAsNewError
+		require.ErrorAs(t, env.GetWorkflowError(), &can)
+
+		var bill Bill
+		var next BillProgress
+		require.NoError(t, converter.GetDefaultDataConverter().FromPayloads(can.Input, &bill, &next))
+		assert.Len(t, next.RecentItemIDs, maxBillRunItems+1)
+		assert.Contains(t, next.RecentItemIDs, "item-old")
+		assert.Equal(t, []string{"item-gone"}, next.RecentVoidIDs)
+	})
+
+	t.Run("ClosesFromStoredItems", func(t *testing.T) {
+		env := testSuite.NewTestWorkflowEnvironment()
+
+		activities := &Activities{}
+		env.RegisterActivity(activities.CalculateTotalActivity)
+		env.RegisterActivity(activities.SaveFinalBillActivity)
+		withoutDiscounts(env, activities)
+		withoutUsage(env, activities)
+		withoutTax(env, activities)
+		withoutDunning(env)
+
+		env.OnActivity("CalculateTotalActivity", mock.Anything, pricesStoredItems(nil)).Return(BillTotals{Subtotal: 2500, Total: 2500}, nil)
+		env.OnActivity("SaveFinalBillActivity", mock.Anything, mock.MatchedBy(func(bill FinalBill) bool {
+			return bill.TotalAmount == 2500
+		})).Return(nil)
+
+		// item-1 was added in the previous run; redelivering it is a no-op.
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(AddLineItemSignal, LineItem{ID: "item-1", Description: "Item 1", Amount: 1000})
+		}, time.Millisecond*100)
+
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(AddLineItemSignal, LineItem{ID: "item-4", Description: "Item 4", Amount: 500})
+		}, time.Millisecond*200)
+
+		var voidErr, repeatErr error
+		var voidResult BillState
+		env.RegisterDelayedCallback(func() {
+			env.UpdateWorkflow(VoidLineItemUpdate, "void-1", &testsuite.TestUpdateCallback{
+				OnAccept: func() {},
+				OnReject: func(err error) { voidErr = err },
+				OnComplete: func(result interface{}, err error) {
+					if state, ok := result.(BillState); ok {
+						voidResult = state
+					}
+				},
+			}, LineItemVoid{ItemID: "item-2", Amount: 1000})
+		}, time.Millisecond*300)
+
+		env.RegisterDelayedCallback(func() {
+			env.UpdateWorkflow(VoidLineItemUpdate, "void-2", &testsuite.TestUpdateCallback{
+				OnAccept:   func() {},
+				OnReject:   func(err error) { repeatErr = err },
+				OnComplete: func(interface{}, error) {},
+			}, LineItemVoid{ItemID: "item-2", Amount: 1000})
+		}, time.Millisecond*400)
+
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(CloseBillSignal, nil)
+		}, time.Millisecond*500)
+
+		env.ExecuteWorkflow(BillWorkflow, Bill{ID: "bill-busy", CustomerID: "customer-busy", Currency: USD, Status: BillStatusOpen},
+			BillProgress{Runs: 1, ItemCount: 3, RunningTotal: 3000, RecentItemIDs: []string{"item-1", "item-2", "item-3"}})
+
+		require.True(t, env.IsWorkflowCompleted())
+		require.NoError(t, env.GetWorkflowError())
+
+		assert.NoError(t, voidErr)
+		assert.Equal(t, 3, voidResult.ItemCount)
+		assert.Equal(t, int64(2500), voidResult.TotalAmount)
+		require.Len(t, voidResult.LineItems, 1)
+		assert.Equal(t, "item-4", voidResult.LineItems[0].ID)
+		assert.ErrorIs(t, workflowRejection(repeatErr), ErrLineItemVoided)
+
+		env.AssertExpectations(t)
+	})
+}
+
+func TestBillWorkflow_Tax(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	activities := &Activities{}
+	env.RegisterActivity(activities.CalculateTotalActivity)
+	env.RegisterActivity(activities.CalculateTaxActivity)
+	env.RegisterActivity(activities.SaveFinalBillActivity)
+	withoutDiscounts(env, activities)
+	withoutUsage(env, activities)
+	withoutDunning(env)
+
+	vat := TaxLine{RuleID: "ge-vat", Name: "VAT", Jurisdiction: "GE", RateBps: 1800, TaxableAmount: 10000, Amount: 1800}
+
+	env.OnActivity("CalculateTotalActivity", mock.Anything, mock.Anything).
+		Return(BillTotals{Subtotal: 10000, Total: 10000}, nil)
+	env.OnActivity("CalculateTaxActivity", mock.Anything, mock.MatchedBy(func(input CalculateTaxInput) bool {
+		return input.Currency == GEL && input.CustomerID == "customer-gel" && input.Amount == 10000
+	})).Return(BillTax{Subtotal: 10000, TaxAmount: 1800, Total: 11800, Lines: []TaxLine{vat}}, nil)
+	env.OnActivity("SaveFinalBillActivity", mock.Anything, mock.MatchedBy(func(bill FinalBill) bool {
+		return bill.SubtotalAmount == 10000 && bill.TaxAmount == 1800 && bill.TotalAmount == 11800 && len(bill.Taxes) == 1
+	})).Return(nil)
+
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(AddLineItemSignal, LineItem{ID: "item-1", Description: "Consulting", Amount: 10000})
+	}, time.Millisecond*100)
+
+	var closed BillState
+	env.RegisterDelayedCallback(func() {
+		env.UpdateWorkflow(CloseBillUpdate, "close", &testsuite.TestUpdateCallback{
+			OnAccept: func() {},
+			OnReject: func(err error) { require.NoError(t, err) },
+			OnComplete: func(result interface{}, err error) {
+				require.NoError(t, err)
+				closed = result.(BillState)
+			},
+		})
+	}, time.Millisecond*200)
+
+	env.ExecuteWorkflow(BillWorkflow, Bill{ID: "bill-gel", CustomerID: "customer-gel", Currency: GEL, Status: BillStatusOpen}, BillProgress{})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+
+	assert.Equal(t, int64(11800), closed.TotalAmount)
+	assert.Equal(t, int64(1800), closed.TaxAmount)
+	assert.Equal(t, []TaxLine{vat}, closed.Taxes)
+
+	env.AssertExpectations(t)
+}
+
+func TestBillWorkflow_Discounts(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	activities := &Activities{}
+	env.RegisterActivity(activities.CalculateTotalActivity)
+	env.RegisterActivity(activities.ApplyDiscountsActivity)
+	env.RegisterActivity(activities.CalculateTaxActivity)
+	env.RegisterActivity(activities.SaveFinalBillActivity)
+	withoutUsage(env, activities)
+	withoutDunning(env)
+
+	applied := int64(1000)
+	discountItem := LineItem{ID: "item-discount", Description: "Discount SPRING10 (10%)", Amount: -1000,
+		Quantity: QuantityOne, UnitPrice: -1000, Kind: LineItemKindDiscount}
+	discount := BillDiscount{Code: "SPRING10", Kind: DiscountKindPercentage, PercentBps: 1000, AppliedAmount: &applied}
+
+	// Fees are in the total the discount applies to; tax is charged on what
+	// is left.
+	env.OnActivity("CalculateTotalActivity", mock.Anything, mock.Anything).
+		Return(BillTotals{Subtotal: 9500, FeeTotal: 500, Total: 10000}, nil)
+	env.OnActivity("ApplyDiscountsActivity", mock.Anything, ApplyDiscountsInput{BillID: "bill-promo", Amount: 10000}).
+		Return(BillDiscounts{Amount: 10000, DiscountTotal: 1000, Total: 9000,
+			Items: []LineItem{discountItem}, Applied: []BillDiscount{discount}}, nil)
+	env.OnActivity("CalculateTaxActivity", mock.Anything, mock.MatchedBy(func(input CalculateTaxInput) bool {
+		return input.Amount == 9000
+	})).Return(BillTax{Subtotal: 9000, TaxAmount: 1620, Total: 10620}, nil)
+	env.OnActivity("SaveFinalBillActivity", mock.Anything, mock.MatchedBy(func(bill FinalBill) bool {
+		return bill.TotalAmount == 10620 && bill.SubtotalAmount == 9000 &&
+			assert.ObjectsAreEqual([]LineItem{discountItem}, bill.Discounts) &&
+			assert.ObjectsAreEqual([]BillDiscount{discount}, bill.AppliedDiscounts)
+	})).Return(nil)
+
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(AddLineItemSignal, LineItem{ID: "item-1", Description: "Consulting", Amount: 9500})
+	}, time.Millisecond*100)
+
+	var closed BillState
+	env.RegisterDelayedCallback(func() {
+		env.UpdateWorkflow(CloseBillUpdate, "close", &testsuite.TestUpdateCallback{
+			OnAccept: func() {},
+			OnReject: func(err error) { require.NoError(t, err) },
+			OnComplete: func(result interface{}, err error) {
+				require.NoError(t, err)
+				closed = result.(BillState)
+			},
+		})
+	}, time.Millisecond*200)
+
+	env.ExecuteWorkflow(BillWorkflow, Bill{ID: "bill-promo", CustomerID: "customer-1", Currency: GEL, Status: BillStatusOpen}, BillProgress{})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+
+	assert.Equal(t, int64(10620), closed.TotalAmount)
+	require.Len(t, closed.LineItems, 2)
+	assert.Equal(t, discountItem, closed.LineItems[1])
+
+	env.AssertExpectations(t)
+}
+
+func TestBillWorkflow_AttachDiscount(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	activities := &Activities{}
+	env.RegisterActivity(activities.CalculateTotalActivity)
+	env.RegisterActivity(activities.AttachDiscountActivity)
+	env.RegisterActivity(activities.ApplyDiscountsActivity)
+	env.RegisterActivity(activities.SaveFinalBillActivity)
+	withoutUsage(env, activities)
+	withoutTax(env, activities)
+	withoutDunning(env)
+
+	env.OnActivity("CalculateTotalActivity", mock.Anything, mock.Anything).Return(BillTotals{}, nil)
+	env.OnActivity("SaveFinalBillActivity", mock.Anything, mock.Anything).Return(nil)
+
+	spring := BillDiscount{Code: "SPRING10", Kind: DiscountKindPercentage, PercentBps: 1000}
+
+	// The close arrives while the discount is still being stored; the bill
+	// is only priced once it is.
+	var calls []string
+	env.OnActivity("AttachDiscountActivity", mock.Anything, AttachDiscountInput{BillID: "bill-promo", Discount: spring}).
+		After(time.Second).
+		Return(func(ctx context.Context, input AttachDiscountInput) error {
+			calls = append(calls, "attach")
+			return nil
+		})
+	env.OnActivity("ApplyDiscountsActivity", mock.Anything, mock.Anything).
+		Return(func(ctx context.Context, input ApplyDiscountsInput) (BillDiscounts, error) {
+			calls = append(calls, "apply")
+			return BillDiscounts{Amount: input.Amount, Total: input.Amount}, nil
+		})
+
+	var attached BillDiscount
+	env.RegisterDelayedCallback(func() {
+		env.UpdateWorkflow(AttachDiscountUpdate, "attach", &testsuite.TestUpdateCallback{
+			OnAccept: func() {},
+			OnReject: func(err error) { require.NoError(t, err) },
+			OnComplete: func(result interface{}, err error) {
+				require.NoError(t, err)
+				attached = result.(BillDiscount)
+			},
+		}, spring)
+	}, time.Millisecond*100)
+
+	env.RegisterDelayedCallback(func() {
+		env.UpdateWorkflow(CloseBillUpdate, "close", &testsuite.TestUpdateCallback{
+			OnAccept:   func() {},
+			OnReject:   func(err error) { require.NoError(t, err) },
+			OnComplete: func(result interface{}, err error) { require.NoError(t, err) },
+		})
+	}, time.Millisecond*200)
+
+	var lateErr error
+	env.RegisterDelayedCallback(func() {
+		env.UpdateWorkflow(AttachDiscountUpdate, "late", &testsuite.TestUpdateCallback{
+			OnAccept:   func() { t.Error("discount accepted while the bill was closing") },
+			OnReject:   func(err error) { lateErr = err },
+			OnComplete: func(result interface{}, err error) {},
+		}, BillDiscount{Code: "WELCOME", Kind: DiscountKindFixed, Amount: 500})
+	}, time.Millisecond*300)
+
+	env.ExecuteWorkflow(BillWorkflow, Bill{ID: "bill-promo", CustomerID: "customer-1", Currency: USD, Status: BillStatusOpen}, BillProgress{})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+
+	assert.Equal(t, "SPRING10", attached.Code)
+	assert.Equal(t, []string{"attach", "apply"}, calls)
+	assert.ErrorIs(t, workflowRejection(lateErr), ErrBillAlreadyClosed)
+}
+
+func TestBillWorkflow_MinimumCharge(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	activities := &Activities{}
+	env.RegisterActivity(activities.CalculateTotalActivity)
+	env.RegisterActivity(activities.SaveFinalBillActivity)
+	withoutDiscounts(env, activities)
+	withoutUsage(env, activities)
+	withoutTax(env, activities)
+	withoutDunning(env)
+
+	minimum := int64(5000)
+	trueUp := LineItem{ID: "item-true-up", Description: "Minimum charge true-up to USD 50.00", Amount: 3000,
+		Quantity: QuantityOne, UnitPrice: 3000, Kind: LineItemKindTrueUp, SystemGenerated: true}
+
+	env.OnActivity("CalculateTotalActivity", mock.Anything, mock.MatchedBy(func(input CalculateTotalInput) bool {
+		return input.Limits.MinimumAmount != nil && *input.Limits.MinimumAmount == minimum
+	})).Return(BillTotals{Subtotal: 2000, Adjustments: []LineItem{trueUp}, AdjustmentTotal: 3000, Total: 5000}, nil)
+	env.OnActivity("SaveFinalBillActivity", mock.Anything, mock.MatchedBy(func(bill FinalBill) bool {
+		return bill.TotalAmount == 5000 && assert.ObjectsAreEqual([]LineItem{trueUp}, bill.Adjustments)
+	})).Return(nil)
+
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(AddLineItemSignal, LineItem{ID: "item-1", Description: "Usage", Amount: 2000})
+	}, time.Millisecond*100)
+
+	var closed BillState
+	env.RegisterDelayedCallback(func() {
+		env.UpdateWorkflow(CloseBillUpdate, "close", &testsuite.TestUpdateCallback{
+			OnAccept: func() {},
+			OnReject: func(err error) { require.NoError(t, err) },
+			OnComplete: func(result interface{}, err error) {
+				require.NoError(t, err)
+				closed = result.(BillState)
+			},
+		})
+	}, time.Millisecond*200)
+
+	env.ExecuteWorkflow(BillWorkflow, Bill{ID: "bill-min", CustomerID: "customer-1", Currency: USD, Status: BillStatusOpen,
+		MinimumAmount: &minimum}, BillProgress{})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+
+	assert.Equal(t, int64(5000), closed.TotalAmount)
+	require.Len(t, closed.LineItems, 2)
+	assert.Equal(t, trueUp, closed.LineItems[1])
+
+	env.AssertExpectations(t)
+}
+
+func TestBillWorkflow_InvoiceSnapshot(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	activities := &Activities{}
+	env.RegisterActivity(activities.CalculateTotalActivity)
+	env.RegisterActivity(activities.SaveFinalBillActivity)
+	withoutDiscounts(env, activities)
+	withoutUsage(env, activities)
+	withoutTax(env, activities)
+	withoutDunning(env)
+
+	usage := LineItem{ID: "item-1", Description: "Usage", Amount: 2000}
+	fee := LineItem{ID: "item-fee", Description: "Platform fee", Amount: 100, Quantity: QuantityOne, UnitPrice: 100,
+		Kind: LineItemKindFee, SystemGenerated: true}
+
+	env.OnActivity("CalculateTotalActivity", mock.Anything, pricesStoredItems(nil)).
+		Return(BillTotals{Items: []LineItem{usage}, Subtotal: 2000, Fees: []LineItem{fee}, FeeTotal: 100, Total: 2100}, nil)
+
+	var saved FinalBill
+	env.OnActivity("SaveFinalBillActivity", mock.Anything, mock.Anything).
+		Return(func(ctx context.Context, bill FinalBill) error {
+			saved = bill
+			return nil
+		})
+
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(AddLineItemSignal, usage)
+	}, time.Millisecond*100)
+
+	env.RegisterDelayedCallback(func() {
+		env.UpdateWorkflow(CloseBillUpdate, "close", &testsuite.TestUpdateCallback{
+			OnAccept:   func() {},
+			OnReject:   func(err error) { require.NoError(t, err) },
+			OnComplete: func(result interface{}, err error) { require.NoError(t, err) },
+		})
+	}, time.Millisecond*200)
+
+	env.ExecuteWorkflow(BillWorkflow, Bill{ID: "bill-inv", CustomerID: "customer-1", Currency: GEL, Status: BillStatusOpen,
+		LegalEntity: "PAVEGE"}, BillProgress{})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+
+	assert.Equal(t, "customer-1", saved.CustomerID)
+	assert.Equal(t, GEL, saved.Currency)
+	assert.Equal(t, "PAVEGE", saved.LegalEntity)
+	assert.Equal(t, int64(2100), saved.TotalAmount)
+	require.Len(t, saved.LineItems, 2)
+	assert.Equal(t, "item-1", saved.LineItems[0].ID)
+	assert.Equal(t, fee, saved.LineItems[1])
+}
+
+func TestBillWorkflow_Usage(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	activities := &Activities{}
+	env.RegisterActivity(activities.PriceUsageActivity)
+	env.RegisterActivity(activities.CalculateTotalActivity)
+	env.RegisterActivity(activities.SaveFinalBillActivity)
+	withoutDiscounts(env, activities)
+	withoutTax(env, activities)
+	withoutDunning(env)
+
+	manual := LineItem{ID: "item-1", Description: "Setup", Amount: 1000}
+	usage := LineItem{ID: "item-usage", Description: "API calls (1500 calls)", Amount: 3000, Quantity: 1500 * QuantityOne,
+		UnitPrice: 2, Kind: LineItemKindUsage, SystemGenerated: true}
+
+	env.OnActivity("PriceUsageActivity", mock.Anything, PriceUsageInput{BillID: "bill-usage", Currency: USD}).
+		Return(PricedUsage{Items: []LineItem{usage}, Through: 42, Claimed: true}, nil).Once()
+	// Usage is charged like any other item, so it is in the subtotal fees
+	// and limits see.
+	env.OnActivity("CalculateTotalActivity", mock.Anything, pricesStoredItems([]LineItem{usage})).
+		Return(BillTotals{Items: []LineItem{manual}, Subtotal: 4000, Total: 4000}, nil).Once()
+
+	var saved FinalBill
+	env.OnActivity("SaveFinalBillActivity", mock.Anything, mock.Anything).
+		Return(func(ctx context.Context, bill FinalBill) error {
+			saved = bill
+			return nil
+		})
+
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(AddLineItemSignal, manual)
+	}, time.Millisecond*100)
+
+	var closed BillState
+	env.RegisterDelayedCallback(func() {
+		env.UpdateWorkflow(CloseBillUpdate, "close", &testsuite.TestUpdateCallback{
+			OnAccept: func() {},
+			OnReject: func(err error) { require.NoError(t, err) },
+			OnComplete: func(result interface{}, err error) {
+				require.NoError(t, err)
+				closed = result.(BillState)
+			},
+		})
+	}, time.Millisecond*200)
+
+	env.ExecuteWorkflow(BillWorkflow, Bill{ID: "bill-usage", CustomerID: "customer-1", Currency: USD, Status: BillStatusOpen}, BillProgress{})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+
+	assert.Equal(t, []LineItem{usage}, saved.Usage)
+	assert.Equal(t, int64(42), saved.UsageThrough)
+	assert.True(t, saved.UsageClaimed)
+	assert.Equal(t, []LineItem{manual, usage}, saved.LineItems)
+	assert.Equal(t, int64(4000), closed.TotalAmount)
+	assert.Equal(t, []LineItem{manual, usage}, closed.LineItems)
+
+	env.AssertExpectations(t)
+}
+
+func TestBillWorkflow_StartsDunning(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	openedAt := time.Date(2026, 1, 31, 9, 0, 0, 0, time.UTC)
+	env.SetStartTime(openedAt)
+
+	activities := &Activities{}
+	env.RegisterActivity(activities.CalculateTotalActivity)
+	env.RegisterActivity(activities.SaveFinalBillActivity)
+	env.RegisterWorkflow(DunningWorkflow)
+	withoutDiscounts(env, activities)
+	withoutUsage(env, activities)
+	withoutTax(env, activities)
+
+	env.OnActivity("CalculateTotalActivity", mock.Anything, mock.Anything).
+		Return(BillTotals{Subtotal: 2000, Total: 2000}, nil)
+
+	var saved FinalBill
+	env.OnActivity("SaveFinalBillActivity", mock.Anything, mock.Anything).
+		Return(func(ctx context.Context, bill FinalBill) error {
+			saved = bill
+			return nil
+		})
+
+	var dunning DunningInput
+	env.OnWorkflow(DunningWorkflow, mock.Anything, mock.Anything).
+		Return(func(ctx workflow.Context, input DunningInput) error {
+			dunning = input
+			return nil
+		}).Once()
+
+	env.RegisterDelayedCallback(func() {
+		env.UpdateWorkflow(CloseBillUpdate, "close", &testsuite.TestUpdateCallback{
+			OnAccept:   func() {},
+			OnReject:   func(err error) { require.NoError(t, err) },
+			OnComplete: func(result interface{}, err error) { require.NoError(t, err) },
+		})
+	}, time.Hour)
+
+	terms := 14
+	env.ExecuteWorkflow(BillWorkflow, Bill{ID: "bill-net14", CustomerID: "customer-1", Currency: USD, Status: BillStatusOpen,
+		PaymentTermsDays: &terms}, BillProgress{})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+
+	dueAt := openedAt.Add(time.Hour).AddDate(0, 0, 14)
+	assert.Equal(t, dueAt, saved.DueAt.UTC())
+	assert.Equal(t, "bill-net14", dunning.BillID)
+	assert.Equal(t, dueAt, dunning.DueAt.UTC())
+	assert.Equal(t, DefaultDunningPolicy, dunning.Policy)
+
+	env.AssertExpectations(t)
+}
+
+func TestBillWorkflow_VoidBill(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	openedAt := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+
+	// Nothing that prices or closes the bill is registered, so the workflow
+	// fails if voiding reaches any of it.
+	newEnv := func() (*testsuite.TestWorkflowEnvironment, *VoidBillInput) {
+		env := testSuite.NewTestWorkflowEnvironment()
+		env.SetStartTime(openedAt)
+		activities := &Activities{}
+		env.RegisterActivity(activities.VoidBillActivity)
+
+		var voided VoidBillInput
+		env.OnActivity("VoidBillActivity", mock.Anything, mock.Anything).
+			Return(func(ctx context.Context, input VoidBillInput) error {
+				voided = input
+				return nil
+			}).Once()
+		return env, &voided
+	}
+	bill := Bill{ID: "bill-void", CustomerID: "customer-1", Currency: USD, Status: BillStatusOpen}
+
+	t.Run("Signal", func(t *testing.T) {
+		env, voided := newEnv()
+
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(AddLineItemSignal, LineItem{ID: "li_1", Description: "Setup", Amount: 5000})
+		}, time.Minute)
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(VoidBillSignal, BillVoid{Reason: " "})
+		}, 2*time.Minute)
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(VoidBillSignal, BillVoid{Reason: "Created by mistake"})
+		}, 3*time.Minute)
+
+		env.ExecuteWorkflow(BillWorkflow, bill, BillProgress{})
+
+		require.True(t, env.IsWorkflowCompleted())
+		require.NoError(t, env.GetWorkflowError())
+		assert.Equal(t, VoidBillInput{BillID: "bill-void", Reason: "Created by mistake", VoidedAt: openedAt.Add(3 * time.Minute)},
+			VoidBillInput{BillID: voided.BillID, Reason: voided.Reason, VoidedAt: voided.VoidedAt.UTC()})
+
+		value, err := env.QueryWorkflow(GetBillStateQuery)
+		require.NoError(t, err)
+		var state BillState
+		require.NoError(t, value.Get(&state))
+		assert.True(t, state.IsClosed)
+		assert.True(t, state.IsVoided)
+		assert.Equal(t, "Created by mistake", state.VoidReason)
+		assert.Equal(t, int64(5000), state.TotalAmount)
+		assert.Zero(t, state.TaxAmount)
+		env.AssertExpectations(t)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		env, voided := newEnv()
+
+		env.RegisterDelayedCallback(func() {
+			env.UpdateWorkflow(VoidBillUpdate, "void-empty", &testsuite.TestUpdateCallback{
+				OnAccept: func() { t.Error("void without a reason was accepted") },
+				OnReject: func(err error) {
+					assert.Equal(t, ErrInvalidVoidReason, workflowRejection(err))
+				},
+				OnComplete: func(interface{}, error) {},
+			}, BillVoid{})
+		}, time.Minute)
+
+		var result BillState
+		env.RegisterDelayedCallback(func() {
+			env.UpdateWorkflow(VoidBillUpdate, "void", &testsuite.TestUpdateCallback{
+				OnAccept: func() {},
+				OnReject: func(err error) { require.NoError(t, err) },
+				OnComplete: func(state interface{}, err error) {
+					require.NoError(t, err)
+					result = state.(BillState)
+				},
+			}, BillVoid{Reason: "Duplicate of bill-1"})
+		}, 2*time.Minute)
+
+		env.ExecuteWorkflow(BillWorkflow, bill, BillProgress{})
+
+		require.True(t, env.IsWorkflowCompleted())
+		require.NoError(t, env.GetWorkflowError())
+		assert.Equal(t, "Duplicate of bill-1", voided.Reason)
+		assert.True(t, result.IsVoided)
+		assert.Equal(t, "Duplicate of bill-1", result.VoidReason)
+		env.AssertExpectations(t)
+	})
+}
+
+func TestBillWorkflow_Reopened(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	activities := &Activities{}
+	env.RegisterActivity(activities.CalculateTotalActivity)
+	env.RegisterActivity(activities.SaveFinalBillActivity)
+	withoutDiscounts(env, activities)
+	withoutUsage(env, activities)
+	withoutTax(env, activities)
+	withoutDunning(env)
+
+	env.OnActivity("CalculateTotalActivity", mock.Anything, pricesStoredItems(nil)).Return(BillTotals{Subtotal: 1500, Total: 1500}, nil)
+	env.OnActivity("SaveFinalBillActivity", mock.Anything, mock.MatchedBy(func(bill FinalBill) bool {
+		return bill.TotalAmount == 1500
+	})).Return(nil)
+
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(AddLineItemSignal, LineItem{ID: "item-2", Description: "Late usage", Amount: 500})
+	}, time.Hour)
+
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(CloseBillSignal, nil)
+	}, 2*time.Hour)
+
+	// The period ended before the bill was closed the first time; reopening
+	// must not close it again straight away.
+	start := env.Now()
+	periodEnd := start.Add(-24 * time.Hour)
+	env.ExecuteWorkflow(BillWorkflow,
+		Bill{ID: "bill-reopened", CustomerID: "customer-1", Currency: USD, Status: BillStatusOpen, PeriodEnd: &periodEnd},
+		reopenProgress([]LineItem{{ID: "item-1", Description: "Usage", Amount: 1000}}))
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	assert.False(t, env.Now().Before(start.Add(2*time.Hour)))
+
+	env.AssertExpectations(t)
+}
+
+func TestDunningWorkflow(t *testing.T) {
+	dueAt := time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+	policy := DunningPolicy{ReminderDays: []int{1, 7}, WriteOffAfterDays: 14}
+	input := DunningInput{BillID: "bill-1", DueAt: dueAt, Policy: policy}
+
+	// setup registers the dunning activities. reminders collects every
+	// reminder sent and settledAtStage makes the bill turn out paid when that
+	// reminder is due.
+	setup := func(env *testsuite.TestWorkflowEnvironment, reminders *[]DunningStepInput, settledAtStage int) (writeOffs, stops *int) {
+		writeOffs, stops = new(int), new(int)
+		activities := &Activities{}
+		env.RegisterWorkflow(DunningWorkflow)
+		env.RegisterActivity(activities.SendDunningReminderActivity)
+		env.RegisterActivity(activities.WriteOffBillActivity)
+		env.RegisterActivity(activities.StopDunningActivity)
+
+		env.OnActivity("SendDunningReminderActivity", mock.Anything, mock.Anything).
+			Return(func(ctx context.Context, step DunningStepInput) (DunningStepResult, error) {
+				assert.False(t, env.Now().Before(dueAt.AddDate(0, 0, step.DaysOverdue)), "reminder sent early")
+				if step.Stage == settledAtStage {
+					return DunningStepResult{Settled: true}, nil
+				}
+				*reminders = append(*reminders, step)
+				return DunningStepResult{}, nil
+			}).Maybe()
+		env.OnActivity("WriteOffBillActivity", mock.Anything, mock.Anything).
+			Return(func(ctx context.Context, step DunningStepInput) (DunningStepResult, error) {
+				assert.False(t, env.Now().Before(dueAt.AddDate(0, 0, 14)), "bill written off early")
+				*writeOffs++
+				return DunningStepResult{}, nil
+			}).Maybe()
+		env.OnActivity("StopDunningActivity", mock.Anything, "bill-1").
+			Return(func(ctx context.Context, billID string) error {
+				*stops++
+				return nil
+			}).Maybe()
+		return writeOffs, stops
+	}
+
+	queryState := func(t *testing.T, env *testsuite.TestWorkflowEnvironment) DunningState {
+		value, err := env.QueryWorkflow(GetDunningStateQuery)
+		require.NoError(t, err)
+		var state DunningState
+		require.NoError(t, value.Get(&state))
+		return state
+	}
+
+	t.Run("RemindsThenWritesOff", func(t *testing.T) {
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestWorkflowEnvironment()
+		env.SetStartTime(dueAt.Add(-time.Hour))
+
+		var reminders []DunningStepInput
+		writeOffs, stops := setup(env, &reminders, 0)
+
+		env.ExecuteWorkflow(DunningWorkflow, input)
+
+		require.True(t, env.IsWorkflowCompleted())
+		require.NoError(t, env.GetWorkflowError())
+
+		require.Len(t, reminders, 2)
+		assert.Equal(t, DunningStepInput{BillID: "bill-1", DueAt: dueAt, DaysOverdue: 1, Stage: 1}, reminders[0])
+		assert.Equal(t, DunningStepInput{BillID: "bill-1", DueAt: dueAt, DaysOverdue: 7, Stage: 2, Final: true}, reminders[1])
+		assert.Equal(t, 1, *writeOffs)
+		assert.Zero(t, *stops)
+
+		state := queryState(t, env)
+		assert.Equal(t, DunningStatusWrittenOff, state.Status)
+		assert.Equal(t, 2, state.RemindersSent)
+	})
+
+	t.Run("PaymentStopsDunning", func(t *testing.T) {
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestWorkflowEnvironment()
+		env.SetStartTime(dueAt.Add(-time.Hour))
+
+		var reminders []DunningStepInput
+		writeOffs, stops := setup(env, &reminders, 0)
+
+		// A partial payment keeps the reminders coming; paying the rest stops
+		// them.
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(PaymentRecordedSignal, PaymentRecorded{PaymentID: "pay-1", PaidAmount: 4000, Balance: 6000,
+				PaymentStatus: PaymentStatusPartiallyPaid})
+		}, 48*time.Hour)
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(PaymentRecordedSignal, PaymentRecorded{PaymentID: "pay-2", PaidAmount: 10000,
+				PaymentStatus: PaymentStatusPaid})
+		}, 72*time.Hour)
+
+		env.ExecuteWorkflow(DunningWorkflow, input)
+
+		require.True(t, env.IsWorkflowCompleted())
+		require.NoError(t, env.GetWorkflowError())
+
+		assert.Len(t, reminders, 1)
+		assert.Zero(t, *writeOffs)
+		assert.Equal(t, 1, *stops)
+
+		state := queryState(t, env)
+		assert.Equal(t, DunningStatusStopped, state.Status)
+		assert.Equal(t, 1, state.RemindersSent)
+	})
+
+	t.Run("BillFoundPaid", func(t *testing.T) {
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestWorkflowEnvironment()
+		env.SetStartTime(dueAt.Add(-time.Hour))
+
+		var reminders []DunningStepInput
+		writeOffs, stops := setup(env, &reminders, 2)
+
+		env.ExecuteWorkflow(DunningWorkflow, input)
+
+		require.True(t, env.IsWorkflowCompleted())
+		require.NoError(t, env.GetWorkflowError())
+
+		assert.Len(t, reminders, 1)
+		assert.Zero(t, *writeOffs)
+		assert.Equal(t, 1, *stops)
+		assert.Equal(t, DunningStatusStopped, queryState(t, env).Status)
+	})
+}
+
+func TestSubscriptionWorkflow(t *testing.T) {
+	at := func(month time.Month, day int) time.Time { return time.Date(2026, month, day, 0, 0, 0, 0, time.UTC) }
+
+	// setup registers the renewal activity, answering with status and
+	// collecting each renewal in renewals.
+	setup := func(env *testsuite.TestWorkflowEnvironment, status SubscriptionStatus, renewals *[]RenewSubscriptionInput) {
+		activities := &Activities{}
+		env.RegisterWorkflow(SubscriptionWorkflow)
+		env.RegisterActivity(activities.RenewSubscriptionActivity)
+		env.OnActivity("RenewSubscriptionActivity", mock.Anything, mock.Anything).
+			Return(func(ctx context.Context, input RenewSubscriptionInput) (SubscriptionRenewal, error) {
+				assert.False(t, env.Now().Before(input.PeriodStart), "renewed early")
+				*renewals = append(*renewals, input)
+				return SubscriptionRenewal{BillID: "bill-1", Status: status}, nil
+			}).Maybe()
+	}
+
+	// continuedWith returns the input the workflow continued as new with.
+	continuedWith := func(t *testing.T, env *testsuite.TestWorkflowEnvironment) SubscriptionInput {
+		var continueAsNew *workflow.ContinueAsNewError
+		require.ErrorAs(t, env.GetWorkflowError(), &continueAsNew)
+		var next SubscriptionInput
+		require.NoError(t, converter.GetDefaultDataConverter().FromPayloads(continueAsNew.Input, &next))
+		return next
+	}
+
+	t.Run("FirstPeriodStartsAtOnce", func(t *testing.T) {
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestWorkflowEnvironment()
+		created := at(3, 10).Add(12 * time.Hour)
+		env.SetStartTime(created)
+
+		var renewals []RenewSubscriptionInput
+		setup(env, SubscriptionStatusActive, &renewals)
+
+		env.ExecuteWorkflow(SubscriptionWorkflow, SubscriptionInput{SubscriptionID: "sub-1", AnchorDay: 15, PeriodStart: created})
+
+		require.True(t, env.IsWorkflowCompleted())
+		assert.Equal(t, []RenewSubscriptionInput{{SubscriptionID: "sub-1", PeriodStart: created, PeriodEnd: at(3, 15)}}, renewals)
+		assert.Equal(t, SubscriptionInput{SubscriptionID: "sub-1", AnchorDay: 15, PeriodStart: at(3, 15)}, continuedWith(t, env))
+	})
+
+	t.Run("WaitsForPeriodStart", func(t *testing.T) {
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestWorkflowEnvironment()
+		env.SetStartTime(at(3, 10))
+
+		var renewals []RenewSubscriptionInput
+		setup(env, SubscriptionStatusPaused, &renewals)
+
+		env.ExecuteWorkflow(SubscriptionWorkflow, SubscriptionInput{SubscriptionID: "sub-1", AnchorDay: 15, PeriodStart: at(3, 15)})
+
+		require.True(t, env.IsWorkflowCompleted())
+		assert.Equal(t, []RenewSubscriptionInput{{SubscriptionID: "sub-1", PeriodStart: at(3, 15), PeriodEnd: at(4, 15)}}, renewals)
+		assert.Equal(t, at(4, 15), continuedWith(t, env).PeriodStart)
+	})
+
+	t.Run("SkipsMissedPeriods", func(t *testing.T) {
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestWorkflowEnvironment()
+		env.SetStartTime(at(5, 20))
+
+		var renewals []RenewSubscriptionInput
+		setup(env, SubscriptionStatusActive, &renewals)
+
+		env.ExecuteWorkflow(SubscriptionWorkflow, SubscriptionInput{SubscriptionID: "sub-1", AnchorDay: 15, PeriodStart: at(3, 15)})
+
+		require.True(t, env.IsWorkflowCompleted())
+		assert.Equal(t, []RenewSubscriptionInput{{SubscriptionID: "sub-1", PeriodStart: at(5, 15), PeriodEnd: at(6, 15)}}, renewals)
+	})
+
+	t.Run("CancelledWhileWaiting", func(t *testing.T) {
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestWorkflowEnvironment()
+		env.SetStartTime(at(3, 10))
+
+		var renewals []RenewSubscriptionInput
+		setup(env, SubscriptionStatusActive, &renewals)
+		env.RegisterDelayedCallback(func() {
+			env.SignalWorkflow(CancelSubscriptionSignal, nil)
+		}, 24*time.Hour)
+
+		env.ExecuteWorkflow(SubscriptionWorkflow, SubscriptionInput{SubscriptionID: "sub-1", AnchorDay: 15, PeriodStart: at(3, 15)})
+
+		require.True(t, env.IsWorkflowCompleted())
+		require.NoError(t, env.GetWorkflowError())
+		assert.Empty(t, renewals)
+	})
+
+	t.Run("CancelledSubscriptionEnds", func(t *testing.T) {
+		testSuite := &testsuite.WorkflowTestSuite{}
+		env := testSuite.NewTestWorkflowEnvironment()
+		env.SetStartTime(at(3, 15))
+
+		var renewals []RenewSubscriptionInput
+		setup(env, SubscriptionStatusCancelled, &renewals)
+
+		env.ExecuteWorkflow(SubscriptionWorkflow, SubscriptionInput{SubscriptionID: "sub-1", AnchorDay: 15, PeriodStart: at(3, 15)})
+
+		require.True(t, env.IsWorkflowCompleted())
+		require.NoError(t, env.GetWorkflowError())
+		assert.Len(t, renewals, 1)
+	})
+}
+
+// withoutUsage registers PriceUsageActivity and mocks it to find no metered
+// usage, for tests that aren't about usage.
+func withoutUsage(env *testsuite.TestWorkflowEnvironment, activities *Activities) {
+	env.RegisterActivity(activities.PriceUsageActivity)
+	env.OnActivity("PriceUsageActivity", mock.Anything, mock.Anything).Return(PricedUsage{}, nil).Maybe()
+}
+
+// withoutDiscounts registers ApplyDiscountsActivity and mocks it to find no
+// discounts, for tests that aren't about discounts.
+func withoutDiscounts(env *testsuite.TestWorkflowEnvironment, activities *Activities) {
+	env.RegisterActivity(activities.ApplyDiscountsActivity)
+	env.OnActivity("ApplyDiscountsActivity", mock.Anything, mock.Anything).
+		Return(func(ctx context.Context, input ApplyDiscountsInput) (BillDiscounts, error) {
+			return BillDiscounts{Amount: input.Amount, Total: input.Amount}, nil
+		}).Maybe()
+}
+
+// withoutTax registers CalculateTaxActivity and mocks it to charge no tax,
+// for tests that aren't about tax.
+func withoutTax(env *testsuite.TestWorkflowEnvironment, activities *Activities) {
+	env.RegisterActivity(activities.CalculateTaxActivity)
+	env.OnActivity("CalculateTaxActivity", mock.Anything, mock.Anything).
+		Return(func(ctx context.Context, input CalculateTaxInput) (BillTax, error) {
+			return BillTax{Subtotal: input.Amount, Total: input.Amount}, nil
+		}).Maybe()
+}
+
+// withoutDunning stubs out the DunningWorkflow a closed bill starts, for
+// tests that aren't about dunning.
+func withoutDunning(env *testsuite.TestWorkflowEnvironment) {
+	env.RegisterWorkflow(DunningWorkflow)
+	env.OnWorkflow(DunningWorkflow, mock.Anything, mock.Anything).Return(nil).Maybe()
+}
+
+// pricesStoredItems matches the CalculateTotalActivity input that prices the
+// bill's stored items along with exactly the given usage.
+func pricesStoredItems(usage []LineItem) interface{} {
+	return mock.MatchedBy(func(input CalculateTotalInput) bool {
+		return input.StoredItems && assert.ObjectsAreEqual(usage, input.LineItems)
+	})
+}
+
+// assertAcceptedItems checks the items the workflow took, which its state
+// lists ahead of those added at close.
+func assertAcceptedItems(t *testing.T, env *testsuite.TestWorkflowEnvironment, want []LineItem) {
+	t.Helper()
+	value, err := env.QueryWorkflow(GetBillStateQuery)
+	require.NoError(t, err)
+	var state BillState
+	require.NoError(t, value.Get(&state))
+	require.GreaterOrEqual(t, len(state.LineItems), len(want))
+	assert.Equal(t, want, append([]LineItem(nil), state.LineItems[:len(want)]...))
 }
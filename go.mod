@@ -4,6 +4,7 @@ go 1.24
 
 require (
 	encore.dev v1.46.1
+	go.temporal.io/api v1.49.1
 	go.temporal.io/sdk v1.35.0
 )
 
@@ -24,7 +25,6 @@ require (
 	github.com/robfig/cron v1.2.0 // indirect
 	github.com/stretchr/objx v0.5.2 // indirect
 	github.com/stretchr/testify v1.10.0 // indirect
-	go.temporal.io/api v1.49.1 // indirect
 	go.uber.org/atomic v1.10.0 // indirect
 	golang.org/x/crypto v0.37.0 // indirect
 	golang.org/x/net v0.39.0 // indirect